@@ -77,4 +77,4 @@ func main() {
 	log.Printf("接收到停止信号: %v，正在清理资源并退出...", sig)
 	time.Sleep(1 * time.Second) // 模拟清理耗时
 	log.Println("Bye Bye!")
-}
\ No newline at end of file
+}
@@ -22,6 +22,8 @@ func main() {
 	exPath := filepath.Dir(ex)
 	defaultUploadDir := filepath.Join(exPath, "uploads")
 	defaultDBPath := filepath.Join(exPath, "ops_data.db")
+	defaultLogStoreDir := filepath.Join(exPath, "log_store")
+	defaultRecordDir := filepath.Join(exPath, "recordings")
 
 	// 2. 定义命令行参数 (使用 pflag 替代 flag)
 	// -c 或 --config 用于指定配置文件路径
@@ -41,6 +43,12 @@ func main() {
 	pflag.String("store_type", "local", "Storage type: local or minio")
 	viper.BindPFlag("storage.type", pflag.Lookup("store_type"))
 
+	pflag.String("log_store_dir", defaultLogStoreDir, "Directory to store logs shipped from workers")
+	viper.BindPFlag("log_store.dir", pflag.Lookup("log_store_dir"))
+
+	pflag.String("record_dir", defaultRecordDir, "Directory to store web terminal recordings")
+	viper.BindPFlag("terminal.record_dir", pflag.Lookup("record_dir"))
+
 	// --- MinIO 配置 ---
 	pflag.String("minio_endpoint", "127.0.0.1:9000", "MinIO Endpoint")
 	viper.BindPFlag("storage.minio.endpoint", pflag.Lookup("minio_endpoint"))
@@ -13,6 +13,8 @@ import (
 	"time"
 
 	"ops-system/pkg/protocol"
+
+	"github.com/google/uuid"
 )
 
 // 配置
@@ -83,6 +85,9 @@ func runMockWorker(ip string, port int, wg *sync.WaitGroup, done chan struct{})
 		DiskTotal: 500,
 	}
 
+	// 按 IP 生成固定的节点 ID，重复运行时复用同一批节点
+	nodeID := uuid.NewSHA1(uuid.NameSpaceOID, []byte(ip)).String()
+
 	client := &http.Client{Timeout: 2 * time.Second}
 	ticker := time.NewTicker(3 * time.Second) // 3秒一次心跳
 	defer ticker.Stop()
@@ -100,6 +105,7 @@ func runMockWorker(ip string, port int, wg *sync.WaitGroup, done chan struct{})
 			}
 
 			reqData := protocol.RegisterRequest{
+				NodeID: nodeID,
 				Port:   port,
 				Info:   info,
 				Status: status,
@@ -11,6 +11,7 @@ import (
 	"ops-system/internal/worker/handler"
 	"ops-system/internal/worker/utils"
 	"ops-system/pkg/config"
+	"ops-system/pkg/labels"
 	pkgUtils "ops-system/pkg/utils"
 
 	"github.com/spf13/pflag"
@@ -18,6 +19,19 @@ import (
 )
 
 func main() {
+	// 子命令：实例控制台日志转储进程 (由 Worker 启动实例时拉起)
+	if len(os.Args) > 1 && os.Args[1] == executor.LogPipeCommand {
+		if err := executor.RunLogPipe(os.Args[2:]); err != nil {
+			log.Fatalf("logpipe: %v", err)
+		}
+		return
+	}
+	// 子命令：打印版本号 (自升级预检使用)
+	if len(os.Args) > 1 && os.Args[1] == agent.VersionCommand {
+		fmt.Println(agent.Version)
+		return
+	}
+
 	// 1. 获取当前执行文件的绝对路径 (关键修改)
 	// 这样 instances 目录永远生成在 worker.exe 旁边
 	ex, err := os.Executable()
@@ -26,6 +40,9 @@ func main() {
 	}
 	exPath := filepath.Dir(ex)
 
+	// 自升级观察期检查 (超时未确认则回滚到旧程序)
+	agent.InitUpgrade(ex)
+
 	// 2. 计算默认工作目录
 	defaultWorkDir := filepath.Join(exPath, "instances")
 
@@ -66,6 +83,31 @@ func main() {
 	// 初始化全局 HTTP Client
 	pkgUtils.InitHTTPClient(cfg.Logic.HTTPClientTimeout)
 
+	// 节点 ID: 首次启动生成并持久化，Master 以此识别节点
+	nodeIDFile := cfg.Server.NodeIDFile
+	if nodeIDFile == "" {
+		nodeIDFile = filepath.Join(exPath, "node_id")
+	}
+	nodeID, err := agent.LoadOrCreateNodeID(nodeIDFile)
+	if err != nil {
+		log.Fatalf("Load node id failed: %v", err)
+	}
+	// 节点密钥与节点 ID 放在同一目录，首次心跳后由 Master 签发
+	if err := agent.LoadNodeSecret(filepath.Join(filepath.Dir(nodeIDFile), "node_secret")); err != nil {
+		log.Fatalf("Load node secret failed: %v", err)
+	}
+	// 发往 Master 的回调 (状态、任务、日志上报等) 均携带节点凭据
+	if err := agent.InstallNodeAuth(cfg.Connect.MasterURL, nodeID); err != nil {
+		log.Fatalf("Invalid master url %q: %v", cfg.Connect.MasterURL, err)
+	}
+
+	if err := labels.Validate(cfg.Labels); err != nil {
+		log.Fatalf("Invalid node labels: %v", err)
+	}
+	if cfg.Connect.Mode != "direct" && cfg.Connect.Mode != "tunnel" {
+		log.Fatalf("Invalid connect mode %q (direct / tunnel)", cfg.Connect.Mode)
+	}
+
 	// 5. 初始化各模块
 	executor.Init(absWorkDir)
 	handler.InitHandler(cfg.Connect.MasterURL)
@@ -74,16 +116,37 @@ func main() {
 
 	log.Printf("Worker started.")
 	log.Printf(" > Executable: %s", ex)
+	log.Printf(" > Version:    %s (%s)", agent.Version, agent.Platform())
+	log.Printf(" > Node ID:    %s", nodeID)
+	if len(cfg.Labels) > 0 {
+		log.Printf(" > Labels:     %s", labels.FromMap(cfg.Labels))
+	}
 	log.Printf(" > Listen:     %s", listenAddr)
-	log.Printf(" > Master:     %s", cfg.Connect.MasterURL)
+	log.Printf(" > Master:     %s (%s)", cfg.Connect.MasterURL, cfg.Connect.Mode)
 	log.Printf(" > Work Dir:   %s", absWorkDir)
 
+	// 反向通道模式: 主动与 Master 保持长连接，指令与上报均经通道传输 (本地端口仍监听，便于排查)
+	if cfg.Connect.Mode == "tunnel" {
+		agent.StartTunnel(cfg.Connect.MasterURL, nodeID, handler.Routes())
+	}
+
 	// 6. 启动监控协程
 	executor.StartMonitor(cfg.Connect.MasterURL)
 
-	// 7. 启动 HTTP Server (接收指令)
+	// 7. 启动日志告警 (拉取规则 + tail 匹配)
+	go agent.StartLogWatcher(cfg.Connect.MasterURL)
+
+	// 同步执行策略的拒绝规则 (Master 下发前检查，Worker 执行前再次检查)
+	go agent.StartPolicySync(cfg.Connect.MasterURL, handler.SetExecPolicy)
+
+	// 8. 日志推送到 Master 集中存储 (可选)
+	if cfg.LogShip.Enabled {
+		go agent.StartLogShipper(cfg.Connect.MasterURL, absWorkDir, cfg.LogShip)
+	}
+
+	// 9. 启动 HTTP Server (接收指令)
 	go handler.StartWorkerServer(listenAddr)
 
-	// 8. 启动心跳 (上报状态)
-	agent.StartHeartbeat(cfg.Connect.MasterURL, cfg.Server.Port)
+	// 10. 启动心跳 (上报状态)
+	agent.StartHeartbeat(cfg.Connect.MasterURL, cfg.Server.Port, nodeID, cfg.Labels)
 }
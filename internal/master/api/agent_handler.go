@@ -0,0 +1,219 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"ops-system/internal/master/manager"
+	"ops-system/internal/master/ws"
+	"ops-system/pkg/code"
+	"ops-system/pkg/e"
+	"ops-system/pkg/nodeauth"
+	"ops-system/pkg/protocol"
+	"ops-system/pkg/response"
+	"ops-system/pkg/utils"
+)
+
+// UploadAgentBinary 上传 Worker 程序 (Stream 模式)
+// POST /api/agent/upload?version=v1.2.0&platform=linux/amd64  (multipart 字段 file)
+func (h *ServerHandler) UploadAgentBinary(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		response.Error(w, e.New(code.MethodNotAllowed, "Method not allowed", nil))
+		return
+	}
+	version := r.URL.Query().Get("version")
+	platform := r.URL.Query().Get("platform")
+
+	reader, err := r.MultipartReader()
+	if err != nil {
+		response.Error(w, e.New(code.PackageUploadFailed, "无法解析上传请求", err))
+		return
+	}
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			response.Error(w, e.New(code.PackageUploadFailed, "读取上传流中断", err))
+			return
+		}
+		if part.FormName() != "file" {
+			continue
+		}
+
+		bin, err := h.pkgMgr.SaveAgentBinary(version, platform, part)
+		if err != nil {
+			response.Error(w, e.New(code.PackageUploadFailed, fmt.Sprintf("保存 Worker 程序失败: %v", err), err))
+			return
+		}
+		detail := fmt.Sprintf("%s %s sha256:%s", bin.Version, bin.Platform, bin.SHA256)
+		h.logMgr.RecordLog(utils.GetClientIP(r), "upload_agent", "agent", bin.Version, detail, "success")
+		response.Success(w, bin)
+		return
+	}
+
+	response.Error(w, e.New(code.ParamError, "未找到 file 表单字段", nil))
+}
+
+// ListAgentBinaries 获取已上传的 Worker 程序
+// GET /api/agent/binaries
+func (h *ServerHandler) ListAgentBinaries(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-cache")
+	list, err := h.pkgMgr.ListAgentBinaries()
+	if err != nil {
+		response.Error(w, e.New(code.ServerError, "获取列表失败", err))
+		return
+	}
+	response.Success(w, list)
+}
+
+// DeleteAgentBinary 删除 Worker 程序
+// POST /api/agent/delete
+func (h *ServerHandler) DeleteAgentBinary(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Version  string `json:"version"`
+		Platform string `json:"platform"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, e.New(code.InvalidJSON, "JSON解析失败", err))
+		return
+	}
+	if err := h.pkgMgr.DeleteAgentBinary(req.Version, req.Platform); err != nil {
+		response.Error(w, e.New(code.PackageDeleteFailed, "删除文件失败", err))
+		return
+	}
+	h.logMgr.RecordLog(utils.GetClientIP(r), "delete_agent", "agent", req.Version, req.Platform, "success")
+	response.Success(w, nil)
+}
+
+// UpgradeAgents 下发 Worker 自升级
+// POST /api/nodes/upgrade
+// 指定 target_id/target_ip 时升级单个节点；否则按 node_ids/node_ips/group/selector 升级匹配的节点
+// 各节点按自身平台 (os/arch) 选择程序，已是目标版本的节点跳过
+func (h *ServerHandler) UpgradeAgents(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		TargetID        string `json:"target_id"` // 优先于 target_ip
+		TargetIP        string `json:"target_ip"`
+		Version         string `json:"version"`
+		RollbackTimeout int    `json:"rollback_timeout"` // 秒，默认 120
+		protocol.NodeTarget
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, e.New(code.InvalidJSON, "JSON解析失败", err))
+		return
+	}
+	if req.Version == "" {
+		response.Error(w, e.New(code.ParamError, "缺少目标版本", nil))
+		return
+	}
+	if req.RollbackTimeout <= 0 {
+		req.RollbackTimeout = 120
+	}
+
+	var nodes []protocol.NodeInfo
+	if req.TargetID != "" || req.TargetIP != "" {
+		node, ok := h.nodeMgr.GetNode(nodeRef(req.TargetID, req.TargetIP))
+		if !ok {
+			response.Error(w, e.New(code.NodeNotFound, "节点不存在", nil))
+			return
+		}
+		nodes = []protocol.NodeInfo{*node}
+	} else {
+		list, err := h.nodeMgr.SelectNodes(req.NodeTarget)
+		if err != nil {
+			response.Error(w, e.New(code.ParamError, "目标节点条件无效", err))
+			return
+		}
+		if len(list) == 0 {
+			response.Error(w, e.New(code.NodeNotFound, "没有匹配的节点", nil))
+			return
+		}
+		nodes = list
+	}
+
+	type upgradeResult struct {
+		NodeID  string `json:"node_id"`
+		NodeIP  string `json:"node_ip"`
+		From    string `json:"from,omitempty"`
+		Skipped bool   `json:"skipped,omitempty"`
+		Error   string `json:"error,omitempty"`
+	}
+	results := make([]upgradeResult, 0, len(nodes))
+	sent, failed := 0, 0
+	for i := range nodes {
+		node := &nodes[i]
+		res := upgradeResult{NodeID: node.ID, NodeIP: node.IP, From: node.AgentVersion}
+		switch {
+		case node.AgentVersion == req.Version:
+			res.Skipped = true
+		case !nodeReachable(node):
+			res.Error = "节点不在线"
+		case node.AgentPlatform == "":
+			res.Error = "Worker 版本过旧，不支持自升级"
+		default:
+			if err := h.sendAgentUpgrade(r, node, req.Version, req.RollbackTimeout); err != nil {
+				res.Error = err.Error()
+			} else {
+				sent++
+			}
+		}
+		if res.Error != "" {
+			failed++
+		}
+		results = append(results, res)
+	}
+
+	status := "success"
+	if failed > 0 {
+		status = "fail"
+	}
+	detail := fmt.Sprintf("Version: %s, Nodes: %d, Sent: %d, Failed: %d", req.Version, len(nodes), sent, failed)
+	target := nodeRef(req.TargetID, req.TargetIP)
+	if target == "" {
+		target = describeTarget(req.NodeTarget)
+	}
+	h.logMgr.RecordLog(utils.GetClientIP(r), "upgrade_agent", "node", target, detail, status)
+	ws.BroadcastNodes(h.nodeMgr.GetAllNodes())
+
+	response.Success(w, results)
+}
+
+// sendAgentUpgrade 向单个 Worker 下发自升级指令并记录升级状态
+func (h *ServerHandler) sendAgentUpgrade(r *http.Request, node *protocol.NodeInfo, version string, rollbackTimeout int) error {
+	url, sum, err := h.pkgMgr.AgentDownload(version, node.AgentPlatform, r.Host)
+	if err != nil {
+		return err
+	}
+	reqBody, _ := json.Marshal(protocol.AgentUpgradeRequest{
+		Version:         version,
+		DownloadURL:     url,
+		SHA256:          sum,
+		RollbackTimeout: rollbackTimeout,
+	})
+	// 令牌覆盖版本、下载地址与校验和，Worker 据此确认升级包由 Master 指定
+	token := h.nodeMgr.WorkerToken(node.ID, nodeauth.Scope("/api/agent/upgrade", version, url, sum))
+	if token == "" {
+		return fmt.Errorf("节点尚未签发节点密钥，无法下发升级")
+	}
+	httpReq, err := http.NewRequest(http.MethodPost, manager.WorkerURL(node, "/api/agent/upgrade"), bytes.NewReader(reqBody))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set(nodeauth.Header, token)
+	resp, err := utils.GlobalClient.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("Worker 升级请求失败: %v", err)
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Worker 升级请求失败: http status %d: %s", resp.StatusCode, bytes.TrimSpace(msg))
+	}
+	return h.nodeMgr.MarkUpgrading(node.ID, version, time.Duration(rollbackTimeout)*time.Second)
+}
@@ -2,6 +2,7 @@ package api
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"strconv"
 
@@ -9,6 +10,7 @@ import (
 	"ops-system/pkg/e"
 	"ops-system/pkg/protocol"
 	"ops-system/pkg/response"
+	"ops-system/pkg/utils"
 )
 
 // ListRules 获取所有告警规则
@@ -44,6 +46,59 @@ func (h *ServerHandler) AddRule(w http.ResponseWriter, r *http.Request) {
 	response.Success(w, nil)
 }
 
+// UpdateRule 修改告警规则
+// POST /api/alerts/rules/update
+func (h *ServerHandler) UpdateRule(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		response.Error(w, e.New(code.MethodNotAllowed, "Method not allowed", nil))
+		return
+	}
+
+	var rule protocol.AlertRule
+	if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+		response.Error(w, e.New(code.InvalidJSON, "JSON解析失败", err))
+		return
+	}
+
+	if err := h.alertMgr.UpdateRule(rule); err != nil {
+		response.Error(w, e.New(code.AlertRuleError, "修改规则失败", err))
+		return
+	}
+
+	h.logMgr.RecordLog(utils.GetClientIP(r), "update_alert_rule", "alert", rule.Name, "", "success")
+	response.Success(w, nil)
+}
+
+// EnableRule 启用/禁用告警规则
+// POST /api/alerts/rules/enable
+func (h *ServerHandler) EnableRule(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		response.Error(w, e.New(code.MethodNotAllowed, "Method not allowed", nil))
+		return
+	}
+
+	var req struct {
+		ID      int64 `json:"id"`
+		Enabled bool  `json:"enabled"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, e.New(code.InvalidJSON, "JSON解析失败", err))
+		return
+	}
+
+	if err := h.alertMgr.SetRuleEnabled(req.ID, req.Enabled); err != nil {
+		response.Error(w, e.New(code.AlertRuleError, "切换规则状态失败", err))
+		return
+	}
+
+	action := "disable_alert_rule"
+	if req.Enabled {
+		action = "enable_alert_rule"
+	}
+	h.logMgr.RecordLog(utils.GetClientIP(r), action, "alert", strconv.FormatInt(req.ID, 10), "", "success")
+	response.Success(w, nil)
+}
+
 // DeleteRule 删除告警规则
 // GET /api/alerts/rules/delete?id=...
 func (h *ServerHandler) DeleteRule(w http.ResponseWriter, r *http.Request) {
@@ -124,3 +179,177 @@ func (h *ServerHandler) ClearEvents(w http.ResponseWriter, r *http.Request) {
 
 	response.Success(w, nil)
 }
+
+// AckEvent 确认告警事件 (确认后停止重复通知)
+// POST /api/alerts/events/ack
+func (h *ServerHandler) AckEvent(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		response.Error(w, e.New(code.MethodNotAllowed, "Method not allowed", nil))
+		return
+	}
+
+	var req struct {
+		ID      int64  `json:"id"`
+		Comment string `json:"comment"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, e.New(code.InvalidJSON, "JSON解析失败", err))
+		return
+	}
+
+	operator := utils.GetClientIP(r)
+	if err := h.alertMgr.AckEvent(req.ID, operator, req.Comment); err != nil {
+		response.Error(w, e.New(code.AlertRuleError, "确认告警失败", err))
+		return
+	}
+
+	h.logMgr.RecordLog(operator, "ack_alert", "alert", strconv.FormatInt(req.ID, 10), req.Comment, "success")
+	response.Success(w, nil)
+}
+
+// ListSilences 获取静默列表
+// GET /api/alerts/silences?active=1
+func (h *ServerHandler) ListSilences(w http.ResponseWriter, r *http.Request) {
+	list, err := h.alertMgr.GetSilences(r.URL.Query().Get("active") == "1")
+	if err != nil {
+		response.Error(w, e.New(code.DatabaseError, "获取静默列表失败", err))
+		return
+	}
+	response.Success(w, list)
+}
+
+// AddSilence 添加静默
+// POST /api/alerts/silences/add
+func (h *ServerHandler) AddSilence(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		response.Error(w, e.New(code.MethodNotAllowed, "Method not allowed", nil))
+		return
+	}
+
+	var s protocol.AlertSilence
+	if err := json.NewDecoder(r.Body).Decode(&s); err != nil {
+		response.Error(w, e.New(code.InvalidJSON, "JSON解析失败", err))
+		return
+	}
+	s.Creator = utils.GetClientIP(r)
+
+	if err := h.alertMgr.AddSilence(s); err != nil {
+		response.Error(w, e.New(code.AlertRuleError, "添加静默失败", err))
+		return
+	}
+
+	detail := fmt.Sprintf("Rule: %d, Target: %s, System: %s, %s", s.RuleID, s.TargetID, s.SystemID, s.Comment)
+	h.logMgr.RecordLog(s.Creator, "add_silence", "alert", "silence", detail, "success")
+	response.Success(w, nil)
+}
+
+// DeleteSilence 删除静默
+// GET /api/alerts/silences/delete?id=...
+func (h *ServerHandler) DeleteSilence(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+	if err != nil {
+		response.Error(w, e.New(code.ParamError, "无效的静默ID", err))
+		return
+	}
+
+	if err := h.alertMgr.DeleteSilence(id); err != nil {
+		response.Error(w, e.New(code.DatabaseError, "删除静默失败", err))
+		return
+	}
+
+	h.logMgr.RecordLog(utils.GetClientIP(r), "delete_silence", "alert", strconv.FormatInt(id, 10), "", "success")
+	response.Success(w, nil)
+}
+
+// ListMaintenanceWindows 获取维护窗口列表
+// GET /api/alerts/maintenance
+func (h *ServerHandler) ListMaintenanceWindows(w http.ResponseWriter, r *http.Request) {
+	list, err := h.alertMgr.GetMaintenanceWindows()
+	if err != nil {
+		response.Error(w, e.New(code.DatabaseError, "获取维护窗口失败", err))
+		return
+	}
+	response.Success(w, list)
+}
+
+// AddMaintenanceWindow 添加周期性维护窗口
+// POST /api/alerts/maintenance/add
+func (h *ServerHandler) AddMaintenanceWindow(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		response.Error(w, e.New(code.MethodNotAllowed, "Method not allowed", nil))
+		return
+	}
+
+	var mw protocol.MaintenanceWindow
+	if err := json.NewDecoder(r.Body).Decode(&mw); err != nil {
+		response.Error(w, e.New(code.InvalidJSON, "JSON解析失败", err))
+		return
+	}
+
+	if err := h.alertMgr.AddMaintenanceWindow(mw); err != nil {
+		response.Error(w, e.New(code.ParamError, "添加维护窗口失败: "+err.Error(), err))
+		return
+	}
+
+	detail := fmt.Sprintf("%v %s-%s", mw.Weekdays, mw.StartTime, mw.EndTime)
+	h.logMgr.RecordLog(utils.GetClientIP(r), "add_maintenance_window", "alert", mw.Name, detail, "success")
+	response.Success(w, nil)
+}
+
+// DeleteMaintenanceWindow 删除维护窗口
+// GET /api/alerts/maintenance/delete?id=...
+func (h *ServerHandler) DeleteMaintenanceWindow(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+	if err != nil {
+		response.Error(w, e.New(code.ParamError, "无效的维护窗口ID", err))
+		return
+	}
+
+	if err := h.alertMgr.DeleteMaintenanceWindow(id); err != nil {
+		response.Error(w, e.New(code.DatabaseError, "删除维护窗口失败", err))
+		return
+	}
+
+	h.logMgr.RecordLog(utils.GetClientIP(r), "delete_maintenance_window", "alert", strconv.FormatInt(id, 10), "", "success")
+	response.Success(w, nil)
+}
+
+// GetLogWatchRules Worker 拉取本节点需要执行的日志匹配任务 (节点由请求携带的节点凭据确定)
+// GET /api/alerts/log_rules
+func (h *ServerHandler) GetLogWatchRules(w http.ResponseWriter, r *http.Request) {
+	nodeID, ok := h.requireNode(w, r)
+	if !ok {
+		return
+	}
+	response.Success(w, h.alertMgr.GetLogWatchRules(nodeID))
+}
+
+// ReportLogMatches Worker 上报日志匹配统计
+// POST /api/alerts/log_report
+func (h *ServerHandler) ReportLogMatches(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		response.Error(w, e.New(code.MethodNotAllowed, "Method not allowed", nil))
+		return
+	}
+
+	nodeID, ok := h.requireNode(w, r)
+	if !ok {
+		return
+	}
+
+	var req protocol.LogMatchReportRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, e.New(code.InvalidJSON, "JSON解析失败", err))
+		return
+	}
+
+	// 只接受本节点实例的统计，避免伪造其他节点的日志告警
+	items := req.Items[:0]
+	for _, item := range req.Items {
+		if h.ownsInstance(nodeID, item.InstanceID) {
+			items = append(items, item)
+		}
+	}
+	h.alertMgr.ReportLogMatches(items)
+	response.Success(w, nil)
+}
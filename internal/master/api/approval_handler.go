@@ -0,0 +1,172 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"ops-system/internal/master/manager"
+	"ops-system/pkg/code"
+	"ops-system/pkg/e"
+	"ops-system/pkg/policy"
+	"ops-system/pkg/protocol"
+	"ops-system/pkg/response"
+	"ops-system/pkg/utils"
+)
+
+// 审批单类型 (对应保存的原始请求)
+const (
+	approvalKindCmd       = "cmd"        // /api/ctrl/cmd
+	approvalKindBatchExec = "batch_exec" // /api/exec/batch
+)
+
+// execUser 下发命令的用户: 配置了用户时要求 operator 及以上角色；未配置时以请求来源作为用户名，不区分角色
+func (h *ServerHandler) execUser(w http.ResponseWriter, r *http.Request) (*manager.User, bool) {
+	if !h.userMgr.Enabled() {
+		return &manager.User{Name: utils.GetClientIP(r)}, true
+	}
+	return h.requireRole(w, r, manager.RoleOperator)
+}
+
+// checkExecPolicy 按执行策略检查命令，放行时返回 true
+// 拒绝时返回错误；命中审批规则时创建审批单并返回 approval_id (未配置用户时无人可审批，直接拒绝)；两者均记录操作日志
+func (h *ServerHandler) checkExecPolicy(w http.ResponseWriter, user *manager.User, kind, command, target string, req interface{}) bool {
+	res := h.execPolicy.Check(command, user.Role)
+	switch res.Decision {
+	case policy.Allow:
+		return true
+	case policy.Approval:
+		if !h.userMgr.Enabled() {
+			res.Reason += "，未配置用户无法审批"
+			break
+		}
+		payload, _ := json.Marshal(req)
+		approval, err := h.approvalMgr.Create(kind, command, target, string(payload), user.Name, res.Rule)
+		if err != nil {
+			response.Error(w, e.New(code.DatabaseError, "创建审批单失败", err))
+			return false
+		}
+		h.logMgr.RecordLog(user.Name, "exec_approval_request", "node", target,
+			fmt.Sprintf("%s (Rule: %s, Approval: %s)", command, res.Rule, approval.ID), "success")
+		response.Success(w, map[string]string{
+			"approval_id": approval.ID,
+			"status":      approval.Status,
+			"msg":         res.Reason + "，需其他用户审批后执行",
+		})
+		return false
+	}
+	h.logMgr.RecordLog(user.Name, "exec_denied", "node", target, fmt.Sprintf("%s (%s)", command, res.Reason), "fail")
+	response.Error(w, e.New(code.CommandDenied, res.Reason, nil))
+	return false
+}
+
+// GetExecPolicy Worker 同步执行策略 (仅拒绝规则；角色允许列表与审批在 Master 判定)
+// GET /api/exec/policy
+func (h *ServerHandler) GetExecPolicy(w http.ResponseWriter, r *http.Request) {
+	if _, ok := h.requireNode(w, r); !ok {
+		return
+	}
+	response.Success(w, protocol.ExecPolicyResp{Deny: h.execPolicy.CustomDeny()})
+}
+
+// ListExecApprovals 分页查询审批单
+// GET /api/exec/approvals?status=pending&page=1&page_size=20
+func (h *ServerHandler) ListExecApprovals(w http.ResponseWriter, r *http.Request) {
+	if _, ok := h.requireRole(w, r, manager.RoleViewer); !ok {
+		return
+	}
+	q := r.URL.Query()
+	page, _ := strconv.Atoi(q.Get("page"))
+	pageSize, _ := strconv.Atoi(q.Get("page_size"))
+	resp, err := h.approvalMgr.List(q.Get("status"), page, pageSize)
+	if err != nil {
+		response.Error(w, e.New(code.DatabaseError, "查询审批单失败", err))
+		return
+	}
+	response.Success(w, resp)
+}
+
+// DecideExecApproval 审批命令 (operator 及以上，且不能是申请人)，通过后以申请人的名义下发
+// POST /api/exec/approvals/decide
+func (h *ServerHandler) DecideExecApproval(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		response.Error(w, e.New(code.MethodNotAllowed, "Method not allowed", nil))
+		return
+	}
+	user, ok := h.requireRole(w, r, manager.RoleOperator)
+	if !ok {
+		return
+	}
+	var req struct {
+		ID      string `json:"id"`
+		Approve bool   `json:"approve"`
+		Comment string `json:"comment"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, e.New(code.InvalidJSON, "JSON解析失败", err))
+		return
+	}
+
+	approval, err := h.approvalMgr.Decide(req.ID, user.Name, req.Approve, req.Comment)
+	switch {
+	case errors.Is(err, manager.ErrApprovalNotFound):
+		response.Error(w, e.New(code.ApprovalNotFound, err.Error(), nil))
+		return
+	case errors.Is(err, manager.ErrSelfApproval):
+		response.Error(w, e.New(code.Forbidden, err.Error(), nil))
+		return
+	case err != nil:
+		response.Error(w, e.New(code.DatabaseError, "审批失败", err))
+		return
+	}
+
+	action := "reject_exec"
+	if req.Approve {
+		action = "approve_exec"
+	}
+	h.logMgr.RecordLog(user.Name, action, "node", approval.Target,
+		fmt.Sprintf("%s (Approval: %s, Requester: %s)", approval.Command, approval.ID, approval.Requester), "success")
+	if !req.Approve {
+		response.Success(w, map[string]interface{}{"approval": approval})
+		return
+	}
+
+	result, taskID, err := h.dispatchApproval(approval)
+	if err != nil {
+		h.approvalMgr.SetResult(approval.ID, err.Error())
+		response.Error(w, err)
+		return
+	}
+	h.approvalMgr.SetResult(approval.ID, taskID)
+	approval.Result = taskID
+	response.Success(w, map[string]interface{}{"approval": approval, "result": result})
+}
+
+// dispatchApproval 按审批单保存的原始请求下发，返回下发结果与任务 ID
+func (h *ServerHandler) dispatchApproval(a *protocol.ExecApproval) (interface{}, string, error) {
+	switch a.Kind {
+	case approvalKindCmd:
+		var trigger cmdTrigger
+		if err := json.Unmarshal([]byte(a.Payload), &trigger); err != nil {
+			return nil, "", e.New(code.InvalidJSON, "审批单请求无效", err)
+		}
+		result, err := h.dispatchCmd(a.Requester, trigger)
+		if err != nil {
+			return nil, "", err
+		}
+		return result, result["task_id"], nil
+	case approvalKindBatchExec:
+		var req protocol.BatchExecRequest
+		if err := json.Unmarshal([]byte(a.Payload), &req); err != nil {
+			return nil, "", e.New(code.InvalidJSON, "审批单请求无效", err)
+		}
+		result, err := h.dispatchBatchExec(a.Requester, req)
+		if err != nil {
+			return nil, "", err
+		}
+		return result, fmt.Sprint(result["task_id"]), nil
+	}
+	return nil, "", e.New(code.ParamError, "未知的审批单类型: "+a.Kind, nil)
+}
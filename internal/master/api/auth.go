@@ -0,0 +1,34 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"ops-system/internal/master/manager"
+	"ops-system/pkg/code"
+	"ops-system/pkg/e"
+	"ops-system/pkg/response"
+)
+
+// requestToken 请求携带的用户令牌: Authorization: Bearer <token>；WebSocket 无法设置请求头时使用 ?token=
+func requestToken(r *http.Request) string {
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		return strings.TrimPrefix(auth, "Bearer ")
+	}
+	return r.URL.Query().Get("token")
+}
+
+// requireRole 要求请求用户至少具有 role 角色，否则写入错误响应
+func (h *ServerHandler) requireRole(w http.ResponseWriter, r *http.Request, role string) (*manager.User, bool) {
+	user, err := h.userMgr.Authorize(requestToken(r), role)
+	switch {
+	case err == nil:
+		return user, true
+	case errors.Is(err, manager.ErrUnauthorized):
+		response.Error(w, e.New(code.Unauthorized, err.Error(), nil))
+	default:
+		response.Error(w, e.New(code.Forbidden, err.Error(), nil))
+	}
+	return nil, false
+}
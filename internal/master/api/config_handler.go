@@ -4,39 +4,63 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
-	"net/url"
+	"strconv"
 
 	"ops-system/internal/master/manager"
 	"ops-system/pkg/code"
 	"ops-system/pkg/e"
 	"ops-system/pkg/response"
+	"ops-system/pkg/textdiff"
+	"ops-system/pkg/utils"
 )
 
-// NacosSettings 获取/保存连接配置
+// configSettings 配置中心设置：当前后端 + Nacos 连接信息
+type configSettings struct {
+	Provider string `json:"provider"` // nacos / builtin
+	manager.NacosConfig
+}
+
+// configErrCode 按后端区分错误码
+func configErrCode(p manager.ConfigProvider) int {
+	if p.Name() == manager.ConfigProviderNacos {
+		return code.NacosError
+	}
+	return code.ConfigError
+}
+
+// NacosSettings 获取/保存配置中心设置
 // GET/POST /api/nacos/settings
 func (h *ServerHandler) NacosSettings(w http.ResponseWriter, r *http.Request) {
 	if r.Method == http.MethodGet {
-		cfg, err := h.configMgr.GetNacosConfig()
-		if err != nil {
-			// 没配置过返回空对象，不算错误
-			response.Success(w, map[string]string{})
-			return
+		res := configSettings{Provider: h.configMgr.ProviderName()}
+		// 没配置过 Nacos 时只返回后端类型，不算错误
+		if cfg, err := h.configMgr.GetNacosConfig(); err == nil {
+			// 处于安全考虑，不返回密码
+			cfg.Password = "******"
+			res.NacosConfig = *cfg
 		}
-		// 处于安全考虑，不返回密码
-		cfg.Password = "******"
-		response.Success(w, cfg)
+		response.Success(w, res)
 		return
 	}
 
 	if r.Method == http.MethodPost {
-		var cfg manager.NacosConfig
-		if err := json.NewDecoder(r.Body).Decode(&cfg); err != nil {
+		var req configSettings
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 			response.Error(w, e.New(code.InvalidJSON, "JSON解析失败", err))
 			return
 		}
-		if err := h.configMgr.SaveNacosConfig(cfg); err != nil {
-			response.Error(w, e.New(code.DatabaseError, "保存配置失败", err))
-			return
+		if req.Provider != "" {
+			if err := h.configMgr.SetProviderName(req.Provider); err != nil {
+				response.Error(w, e.New(code.ParamError, "未知的配置中心后端", err))
+				return
+			}
+		}
+		// 仅切换到内置存储时不覆盖已有的 Nacos 连接信息
+		if req.Provider != manager.ConfigProviderBuiltin || req.URL != "" {
+			if err := h.configMgr.SaveNacosConfig(req.NacosConfig); err != nil {
+				response.Error(w, e.New(code.DatabaseError, "保存配置失败", err))
+				return
+			}
 		}
 		response.Success(w, nil)
 		return
@@ -48,72 +72,108 @@ func (h *ServerHandler) NacosSettings(w http.ResponseWriter, r *http.Request) {
 // NacosNamespaces 获取命名空间列表
 // GET /api/nacos/namespaces
 func (h *ServerHandler) NacosNamespaces(w http.ResponseWriter, r *http.Request) {
-	// Nacos API: /nacos/v1/console/namespaces
-	resBytes, err := h.configMgr.ProxyGet("/nacos/v1/console/namespaces", url.Values{})
+	p := h.configMgr.Provider()
+	list, err := p.Namespaces()
 	if err != nil {
-		response.Error(w, e.New(code.NacosError, "获取命名空间失败", err))
+		response.Error(w, e.New(configErrCode(p), "获取命名空间失败", err))
 		return
 	}
+	// 保持 Nacos 的返回结构 { code: 200, data: [...] }
+	response.Success(w, map[string]interface{}{"code": 200, "data": list})
+}
 
-	// 将 Nacos 返回的 JSON 字节流转为对象，以便包裹在标准响应结构中
-	var nacosResp interface{}
-	if err := json.Unmarshal(resBytes, &nacosResp); err != nil {
-		// 如果转换失败（可能是纯文本报错），直接返回字符串
-		response.Success(w, string(resBytes))
+// NacosNamespaceCreate 新建命名空间 (仅内置存储)
+// POST /api/nacos/namespace/create
+func (h *ServerHandler) NacosNamespaceCreate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		response.Error(w, e.New(code.MethodNotAllowed, "Method not allowed", nil))
+		return
+	}
+	var ns manager.ConfigNamespace
+	if err := json.NewDecoder(r.Body).Decode(&ns); err != nil {
+		response.Error(w, e.New(code.InvalidJSON, "JSON解析失败", err))
+		return
+	}
+	admin, ok := h.configMgr.Provider().(manager.ConfigNamespaceAdmin)
+	if !ok {
+		response.Error(w, e.New(code.ConfigError, "当前配置中心后端不支持管理命名空间，请在 Nacos 控制台操作", nil))
 		return
 	}
+	if err := admin.CreateNamespace(ns); err != nil {
+		response.Error(w, e.New(code.ConfigError, fmt.Sprintf("创建命名空间失败: %v", err), err))
+		return
+	}
+	h.logMgr.RecordLog(utils.GetClientIP(r), "create_config_namespace", "config", ns.Namespace, ns.NamespaceShowName, "success")
+	response.Success(w, nil)
+}
 
-	response.Success(w, nacosResp)
+// NacosNamespaceDelete 删除命名空间 (仅内置存储，命名空间下不能有配置)
+// POST /api/nacos/namespace/delete
+func (h *ServerHandler) NacosNamespaceDelete(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		response.Error(w, e.New(code.MethodNotAllowed, "Method not allowed", nil))
+		return
+	}
+	var req struct {
+		Namespace string `json:"namespace"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, e.New(code.InvalidJSON, "JSON解析失败", err))
+		return
+	}
+	admin, ok := h.configMgr.Provider().(manager.ConfigNamespaceAdmin)
+	if !ok {
+		response.Error(w, e.New(code.ConfigError, "当前配置中心后端不支持管理命名空间，请在 Nacos 控制台操作", nil))
+		return
+	}
+	if err := admin.DeleteNamespace(req.Namespace); err != nil {
+		response.Error(w, e.New(code.ConfigError, fmt.Sprintf("删除命名空间失败: %v", err), err))
+		return
+	}
+	h.logMgr.RecordLog(utils.GetClientIP(r), "delete_config_namespace", "config", req.Namespace, "", "success")
+	response.Success(w, nil)
 }
 
 // NacosConfigs 获取配置列表
 // GET /api/nacos/configs
 func (h *ServerHandler) NacosConfigs(w http.ResponseWriter, r *http.Request) {
-	// Nacos API: /nacos/v1/cs/configs
 	q := r.URL.Query()
-	params := url.Values{}
-	params.Set("dataId", q.Get("dataId"))
-	params.Set("group", q.Get("group"))
-	params.Set("pageNo", q.Get("pageNo"))
-	params.Set("pageSize", q.Get("pageSize"))
-	if t := q.Get("tenant"); t != "" {
-		params.Set("tenant", t) // Namespace ID
+	query := manager.ConfigQuery{
+		Tenant: q.Get("tenant"), // Namespace ID
+		Group:  q.Get("group"),
+		DataID: q.Get("dataId"),
 	}
-
-	resBytes, err := h.configMgr.ProxyGet("/nacos/v1/cs/configs", params)
-	if err != nil {
-		response.Error(w, e.New(code.NacosError, "查询配置列表失败", err))
-		return
+	query.PageNo, _ = strconv.Atoi(q.Get("pageNo"))
+	query.PageSize, _ = strconv.Atoi(q.Get("pageSize"))
+	if query.PageNo < 1 {
+		query.PageNo = 1
+	}
+	if query.PageSize < 1 {
+		query.PageSize = 10
 	}
 
-	var nacosResp interface{}
-	if err := json.Unmarshal(resBytes, &nacosResp); err != nil {
-		response.Success(w, string(resBytes))
+	p := h.configMgr.Provider()
+	page, err := p.ListConfigs(query)
+	if err != nil {
+		response.Error(w, e.New(configErrCode(p), "查询配置列表失败", err))
 		return
 	}
-	response.Success(w, nacosResp)
+	response.Success(w, page)
 }
 
 // NacosConfigDetail 获取具体配置内容
 // GET /api/nacos/config/detail
 func (h *ServerHandler) NacosConfigDetail(w http.ResponseWriter, r *http.Request) {
 	q := r.URL.Query()
-	params := url.Values{}
-	params.Set("dataId", q.Get("dataId"))
-	params.Set("group", q.Get("group"))
-	if t := q.Get("tenant"); t != "" {
-		params.Set("tenant", t)
-	}
-
-	// Nacos 获取详情直接返回配置内容字符串
-	resBytes, err := h.configMgr.ProxyGet("/nacos/v1/cs/configs", params)
+	p := h.configMgr.Provider()
+	content, err := p.GetConfig(q.Get("tenant"), q.Get("group"), q.Get("dataId"))
 	if err != nil {
-		response.Error(w, e.New(code.NacosError, "获取配置详情失败", err))
+		response.Error(w, e.New(configErrCode(p), "获取配置详情失败", err))
 		return
 	}
 
 	// 直接返回内容字符串，response.Success 会将其放入 "data" 字段
-	response.Success(w, string(resBytes))
+	response.Success(w, content)
 }
 
 // NacosPublish 发布/修改配置
@@ -124,36 +184,36 @@ func (h *ServerHandler) NacosPublish(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 前端传 JSON，我们需要转成 Form Data 发给 Nacos
 	var req struct {
 		DataId  string `json:"dataId"`
 		Group   string `json:"group"`
 		Content string `json:"content"`
 		Type    string `json:"type"`
 		Tenant  string `json:"tenant"`
+		Author  string `json:"author"`  // 可选，默认为请求方 IP
+		Comment string `json:"comment"` // 版本备注 (内置存储)
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		response.Error(w, e.New(code.InvalidJSON, "JSON解析失败", err))
 		return
 	}
-
-	form := url.Values{}
-	form.Set("dataId", req.DataId)
-	form.Set("group", req.Group)
-	form.Set("content", req.Content)
-	form.Set("type", req.Type)
-	if req.Tenant != "" {
-		form.Set("tenant", req.Tenant)
+	author := req.Author
+	if author == "" {
+		author = utils.GetClientIP(r)
 	}
 
-	resBytes, err := h.configMgr.ProxyPost("/nacos/v1/cs/configs", form)
-	if err != nil {
-		response.Error(w, e.New(code.NacosError, fmt.Sprintf("发布配置失败: %v", err), err))
+	p := h.configMgr.Provider()
+	item := manager.ConfigItem{DataID: req.DataId, Group: req.Group, Content: req.Content, Type: req.Type, Tenant: req.Tenant}
+	if err := p.Publish(item, author, req.Comment); err != nil {
+		response.Error(w, e.New(configErrCode(p), fmt.Sprintf("发布配置失败: %v", err), err))
 		return
 	}
+	h.logMgr.RecordLog(author, "publish_config", "config", req.Group+"/"+req.DataId, req.Comment, "success")
+	// 推送到绑定了该配置的实例
+	go h.configPush.OnConfigChanged(req.Tenant, req.Group, req.DataId, author)
 
-	// Nacos 发布成功通常返回 "true" 字符串
-	response.Success(w, string(resBytes))
+	// 与 Nacos 保持一致，发布成功返回 "true"
+	response.Success(w, "true")
 }
 
 // NacosDelete 删除配置
@@ -168,16 +228,151 @@ func (h *ServerHandler) NacosDelete(w http.ResponseWriter, r *http.Request) {
 		DataId string `json:"dataId"`
 		Group  string `json:"group"`
 		Tenant string `json:"tenant"`
+		Author string `json:"author"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		response.Error(w, e.New(code.InvalidJSON, "JSON解析失败", err))
 		return
 	}
+	author := req.Author
+	if author == "" {
+		author = utils.GetClientIP(r)
+	}
 
-	if err := h.configMgr.ProxyDelete(req.DataId, req.Group, req.Tenant); err != nil {
-		response.Error(w, e.New(code.NacosError, fmt.Sprintf("删除配置失败: %v", err), err))
+	p := h.configMgr.Provider()
+	if err := p.Delete(req.Tenant, req.Group, req.DataId, author); err != nil {
+		response.Error(w, e.New(configErrCode(p), fmt.Sprintf("删除配置失败: %v", err), err))
 		return
 	}
+	h.logMgr.RecordLog(author, "delete_config", "config", req.Group+"/"+req.DataId, "", "success")
 
 	response.Success(w, nil)
 }
+
+// configVersioner 取当前后端的版本管理能力，不支持时直接写错误响应
+func (h *ServerHandler) configVersioner(w http.ResponseWriter) (manager.ConfigVersioner, bool) {
+	v, ok := h.configMgr.Provider().(manager.ConfigVersioner)
+	if !ok {
+		response.Error(w, e.New(code.ConfigError, "当前配置中心后端不支持版本管理", nil))
+	}
+	return v, ok
+}
+
+// ConfigHistory 配置的历史版本列表 (不含内容)
+// GET /api/nacos/config/history?tenant=&group=&dataId=
+func (h *ServerHandler) ConfigHistory(w http.ResponseWriter, r *http.Request) {
+	v, ok := h.configVersioner(w)
+	if !ok {
+		return
+	}
+	q := r.URL.Query()
+	list, err := v.History(q.Get("tenant"), q.Get("group"), q.Get("dataId"))
+	if err != nil {
+		response.Error(w, e.New(code.ConfigError, "查询历史版本失败", err))
+		return
+	}
+	response.Success(w, list)
+}
+
+// ConfigVersion 获取某个版本的完整内容
+// GET /api/nacos/config/version?tenant=&group=&dataId=&id=  (Nacos 后端必须给出配置坐标)
+func (h *ServerHandler) ConfigVersion(w http.ResponseWriter, r *http.Request) {
+	v, ok := h.configVersioner(w)
+	if !ok {
+		return
+	}
+	q := r.URL.Query()
+	id, err := strconv.ParseInt(q.Get("id"), 10, 64)
+	if err != nil {
+		response.Error(w, e.New(code.ParamError, "无效的版本 ID", err))
+		return
+	}
+	ver, err := v.Version(q.Get("tenant"), q.Get("group"), q.Get("dataId"), id)
+	if err != nil {
+		response.Error(w, e.New(code.ConfigError, "获取版本失败", err))
+		return
+	}
+	response.Success(w, ver)
+}
+
+// ConfigDiff 比较两个版本，to 为空时与当前内容比较
+// GET /api/nacos/config/diff?tenant=&group=&dataId=&from=&to=
+func (h *ServerHandler) ConfigDiff(w http.ResponseWriter, r *http.Request) {
+	v, ok := h.configVersioner(w)
+	if !ok {
+		return
+	}
+	q := r.URL.Query()
+	fromID, err := strconv.ParseInt(q.Get("from"), 10, 64)
+	if err != nil {
+		response.Error(w, e.New(code.ParamError, "无效的版本 ID", err))
+		return
+	}
+	from, err := v.Version(q.Get("tenant"), q.Get("group"), q.Get("dataId"), fromID)
+	if err != nil {
+		response.Error(w, e.New(code.ConfigError, "获取版本失败", err))
+		return
+	}
+
+	toName, toContent := "current", ""
+	if s := q.Get("to"); s != "" {
+		toID, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			response.Error(w, e.New(code.ParamError, "无效的版本 ID", err))
+			return
+		}
+		to, err := v.Version(q.Get("tenant"), q.Get("group"), q.Get("dataId"), toID)
+		if err != nil {
+			response.Error(w, e.New(code.ConfigError, "获取版本失败", err))
+			return
+		}
+		toName, toContent = fmt.Sprintf("#%d", to.ID), to.Content
+	} else if content, err := h.configMgr.Provider().GetConfig(from.Tenant, from.Group, from.DataID); err == nil {
+		// 配置已被删除时当前内容视为空
+		toContent = content
+	}
+
+	response.Success(w, map[string]interface{}{
+		"from": from.ID,
+		"to":   toName,
+		"diff": textdiff.Unified(fmt.Sprintf("#%d", from.ID), toName, from.Content, toContent, 3),
+	})
+}
+
+// ConfigRollback 回滚到指定版本 (生成一个新版本，历史不会被改写)
+// POST /api/nacos/config/rollback
+func (h *ServerHandler) ConfigRollback(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		response.Error(w, e.New(code.MethodNotAllowed, "Method not allowed", nil))
+		return
+	}
+	var req struct {
+		Tenant  string `json:"tenant"`
+		Group   string `json:"group"`
+		DataId  string `json:"dataId"`
+		ID      int64  `json:"id"`
+		Author  string `json:"author"`
+		Comment string `json:"comment"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, e.New(code.InvalidJSON, "JSON解析失败", err))
+		return
+	}
+	v, ok := h.configVersioner(w)
+	if !ok {
+		return
+	}
+	author := req.Author
+	if author == "" {
+		author = utils.GetClientIP(r)
+	}
+
+	ver, err := v.Rollback(req.Tenant, req.Group, req.DataId, req.ID, author, req.Comment)
+	if err != nil {
+		response.Error(w, e.New(code.ConfigError, fmt.Sprintf("回滚失败: %v", err), err))
+		return
+	}
+	h.logMgr.RecordLog(author, "rollback_config", "config", ver.Group+"/"+ver.DataID, ver.Comment, "success")
+	go h.configPush.OnConfigChanged(ver.Tenant, ver.Group, ver.DataID, author)
+	response.Success(w, ver)
+}
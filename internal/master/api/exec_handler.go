@@ -0,0 +1,287 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"ops-system/internal/master/manager"
+	"ops-system/pkg/code"
+	"ops-system/pkg/e"
+	"ops-system/pkg/nodeauth"
+	"ops-system/pkg/protocol"
+	"ops-system/pkg/response"
+	"ops-system/pkg/utils"
+)
+
+const (
+	execDefaultConcurrency = 10
+	execMaxConcurrency     = 100
+	execDefaultTimeout     = 60   // 秒
+	execMaxTimeout         = 3600 // 秒
+	execOutputLimit        = 64 << 10
+	execGrace              = 10 * time.Second // 命令超时之外等待 Worker 结束的余量
+)
+
+// BatchExec 在匹配的节点上批量执行命令，立即返回任务 ID
+// 各节点输出经 /api/exec/stream 实时推送，退出码与输出 (截断至 64KB) 记录在子任务中
+// 命令先经执行策略检查，命中审批规则时返回 approval_id，审批通过后再执行
+// POST /api/exec/batch
+func (h *ServerHandler) BatchExec(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		response.Error(w, e.New(code.MethodNotAllowed, "Method not allowed", nil))
+		return
+	}
+	user, ok := h.execUser(w, r)
+	if !ok {
+		return
+	}
+	var req protocol.BatchExecRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, e.New(code.InvalidJSON, "JSON解析失败", err))
+		return
+	}
+	if req.Command == "" {
+		response.Error(w, e.New(code.ParamError, "命令不能为空", nil))
+		return
+	}
+	if req.NodeTarget.Empty() {
+		response.Error(w, e.New(code.ParamError, "请指定目标节点", nil))
+		return
+	}
+	if req.Concurrency <= 0 {
+		req.Concurrency = execDefaultConcurrency
+	}
+	req.Concurrency = min(req.Concurrency, execMaxConcurrency)
+	if req.Timeout <= 0 {
+		req.Timeout = execDefaultTimeout
+	}
+	req.Timeout = min(req.Timeout, execMaxTimeout)
+
+	if !h.checkExecPolicy(w, user, approvalKindBatchExec, req.Command, describeTarget(req.NodeTarget), req) {
+		return
+	}
+	result, err := h.dispatchBatchExec(user.Name, req)
+	if err != nil {
+		response.Error(w, err)
+		return
+	}
+	response.Success(w, result)
+}
+
+// dispatchBatchExec 以 operator 的名义创建任务并开始执行
+func (h *ServerHandler) dispatchBatchExec(operator string, req protocol.BatchExecRequest) (map[string]interface{}, error) {
+	nodes, err := h.nodeMgr.SelectNodes(req.NodeTarget)
+	if err != nil {
+		return nil, e.New(code.ParamError, "目标节点条件无效", err)
+	}
+	if len(nodes) == 0 {
+		return nil, e.New(code.NodeNotFound, "没有匹配的节点", nil)
+	}
+
+	// 截止时间覆盖全部批次
+	waves := (len(nodes) + req.Concurrency - 1) / req.Concurrency
+	perNode := time.Duration(req.Timeout)*time.Second + execGrace
+	task, err := h.createTask(operator, "batch_exec", req.Command, nodeItems(nodes), time.Duration(waves)*perNode)
+	if err != nil {
+		return nil, err
+	}
+	h.execHub.Start(task.ID)
+
+	go h.runBatchExec(task, nodes, req, operator)
+
+	return map[string]interface{}{"task_id": task.ID, "total": len(nodes)}, nil
+}
+
+// runBatchExec 按并发上限在各节点执行，结束后推送 done 事件
+func (h *ServerHandler) runBatchExec(task *protocol.Task, nodes []protocol.NodeInfo, req protocol.BatchExecRequest, operator string) {
+	cmdReq := protocol.CommandRequest{Command: req.Command, Timeout: req.Timeout, WorkDir: req.WorkDir}
+	sem := make(chan struct{}, req.Concurrency)
+	var wg sync.WaitGroup
+	for i := range nodes {
+		node, item := &nodes[i], task.Items[i]
+		if !nodeReachable(node) {
+			h.finishExec(task.ID, node, item.ID, nil, "", "节点不在线")
+			continue
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() { <-sem; wg.Done() }()
+			h.taskMgr.StartItem(item.ID, "")
+			exitCode, output, errMsg := h.streamExec(task.ID, node, cmdReq)
+			h.finishExec(task.ID, node, item.ID, exitCode, output, errMsg)
+		}()
+	}
+	wg.Wait()
+
+	status, failed := protocol.TaskSucceeded, 0
+	if t, ok := h.taskMgr.GetTask(task.ID); ok {
+		status, failed = t.Status, t.Failed
+	}
+	h.execHub.Publish(task.ID, protocol.ExecEvent{Type: "done", Status: status})
+	h.execHub.Finish(task.ID)
+
+	logStatus := "success"
+	if status != protocol.TaskSucceeded {
+		logStatus = "fail"
+	}
+	detail := fmt.Sprintf("%s (Nodes: %d, Failed: %d, Task: %s)", req.Command, len(nodes), failed, task.ID)
+	h.logMgr.RecordLog(operator, "batch_exec", "node", describeTarget(req.NodeTarget), detail, logStatus)
+}
+
+// finishExec 记录子任务结果并推送节点的 exit 事件
+func (h *ServerHandler) finishExec(taskID string, node *protocol.NodeInfo, itemID int64, exitCode *int, output, errMsg string) {
+	h.taskMgr.FinishExecItem(itemID, exitCode, output, errMsg)
+	status := protocol.TaskSucceeded
+	if exitCode == nil || *exitCode != 0 || errMsg != "" {
+		status = protocol.TaskFailed
+	}
+	h.execHub.Publish(taskID, protocol.ExecEvent{
+		Type: "exit", NodeID: node.ID, NodeIP: node.IP, ExitCode: exitCode, Status: status, Error: errMsg,
+	})
+}
+
+// streamExec 调用 Worker 的流式执行接口，转发输出事件，返回退出码、(截断的) 输出和错误
+func (h *ServerHandler) streamExec(taskID string, node *protocol.NodeInfo, cmdReq protocol.CommandRequest) (*int, string, string) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(cmdReq.Timeout)*time.Second+execGrace)
+	defer cancel()
+
+	body, _ := json.Marshal(cmdReq)
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, manager.WorkerURL(node, "/api/exec/stream"), bytes.NewReader(body))
+	if err != nil {
+		return nil, "", err.Error()
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set(nodeauth.Header, h.nodeMgr.WorkerToken(node.ID, "/api/exec/stream"))
+	resp, err := utils.NewClient(0).Do(httpReq)
+	if err != nil {
+		return nil, "", fmt.Sprintf("连接Worker失败: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, "", fmt.Sprintf("Worker 返回 %d: %s", resp.StatusCode, bytes.TrimSpace(msg))
+	}
+
+	var output bytes.Buffer
+	truncated := false
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var ev protocol.ExecEvent
+		if err := dec.Decode(&ev); err != nil {
+			if errors.Is(err, io.EOF) {
+				err = io.ErrUnexpectedEOF
+			}
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return nil, output.String(), "执行超时"
+			}
+			log.Printf("[Exec] Stream from %s broken: %v", node.IP, err)
+			return nil, output.String(), fmt.Sprintf("输出流中断: %v", err)
+		}
+		switch ev.Type {
+		case "output":
+			ev.NodeID, ev.NodeIP = node.ID, node.IP
+			h.execHub.Publish(taskID, ev)
+			if truncated {
+				break
+			}
+			if n := execOutputLimit - output.Len(); len(ev.Data) <= n {
+				output.WriteString(ev.Data)
+			} else {
+				// 截断位置退回到字符边界，避免输出不完整的 UTF-8 字符
+				for n > 0 && !utf8.RuneStart(ev.Data[n]) {
+					n--
+				}
+				output.WriteString(ev.Data[:n])
+				output.WriteString("\n... (输出已截断)")
+				truncated = true
+			}
+		case "exit":
+			return ev.ExitCode, output.String(), ev.Error
+		}
+	}
+}
+
+// ExecStream 订阅批量命令的实时输出
+// 执行中: 先回放已产生的事件，再推送后续事件；已结束: 按任务记录回放各节点输出与退出码
+// GET /api/exec/stream?task_id=... (WebSocket)
+func (h *ServerHandler) ExecStream(w http.ResponseWriter, r *http.Request) {
+	taskID := r.URL.Query().Get("task_id")
+	task, ok := h.taskMgr.GetTask(taskID)
+	if !ok {
+		response.Error(w, e.New(code.TaskNotFound, "任务不存在", nil))
+		return
+	}
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("[Exec] Upgrade failed: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	replay, ch, cancel, live := h.execHub.Subscribe(taskID)
+	if !live {
+		// 订阅前已结束 (或来自 Master 重启前)，重新读取以包含最终结果
+		if t, ok := h.taskMgr.GetTask(taskID); ok {
+			task = t
+		}
+		for _, ev := range taskExecEvents(task) {
+			if conn.WriteJSON(ev) != nil {
+				return
+			}
+		}
+		return
+	}
+	defer cancel()
+
+	// 前端断开时取消订阅 (通道关闭后下面的循环退出)
+	go func() {
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				cancel()
+				return
+			}
+		}
+	}()
+
+	for _, ev := range replay {
+		if conn.WriteJSON(ev) != nil {
+			return
+		}
+	}
+	for ev := range ch {
+		if conn.WriteJSON(ev) != nil {
+			return
+		}
+	}
+}
+
+// taskExecEvents 由任务记录还原事件: 已结束节点的输出与 exit 事件，任务结束时追加 done
+func taskExecEvents(task *protocol.Task) []protocol.ExecEvent {
+	var events []protocol.ExecEvent
+	for _, it := range task.Items {
+		if it.Status == protocol.TaskQueued || it.Status == protocol.TaskRunning {
+			continue
+		}
+		if it.Output != "" {
+			events = append(events, protocol.ExecEvent{Type: "output", NodeID: it.NodeID, NodeIP: it.NodeIP, Stream: "stdout", Data: it.Output})
+		}
+		events = append(events, protocol.ExecEvent{
+			Type: "exit", NodeID: it.NodeID, NodeIP: it.NodeIP, ExitCode: it.ExitCode, Status: it.Status, Error: it.Error,
+		})
+	}
+	if task.EndTime > 0 {
+		events = append(events, protocol.ExecEvent{Type: "done", Status: task.Status})
+	}
+	return events
+}
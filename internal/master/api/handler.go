@@ -3,6 +3,7 @@ package api
 import (
 	"ops-system/internal/master/manager"
 	"ops-system/internal/master/monitor"
+	"ops-system/pkg/policy"
 )
 
 // ServerHandler 持有所有业务逻辑依赖
@@ -15,8 +16,18 @@ type ServerHandler struct {
 	pkgMgr       *manager.PackageManager
 	configMgr    *manager.ConfigManager
 	alertMgr     *manager.AlertManager
+	notifyMgr    *manager.NotifyManager
 	backupMgr    *manager.BackupManager
 	monitorStore *monitor.MemoryTSDB
+	logShipMgr   *manager.LogShipManager
+	configPush   *manager.ConfigPushManager
+	scheduler    *manager.Scheduler
+	taskMgr      *manager.TaskManager
+	execHub      *manager.ExecHub
+	userMgr      *manager.UserManager
+	terminalMgr  *manager.TerminalManager
+	execPolicy   *policy.Engine
+	approvalMgr  *manager.ApprovalManager
 }
 
 // NewServerHandler 构造函数
@@ -28,8 +39,18 @@ func NewServerHandler(
 	pkg *manager.PackageManager,
 	cfg *manager.ConfigManager,
 	alert *manager.AlertManager,
+	notify *manager.NotifyManager,
 	backup *manager.BackupManager,
 	monitor *monitor.MemoryTSDB,
+	logShip *manager.LogShipManager,
+	configPush *manager.ConfigPushManager,
+	scheduler *manager.Scheduler,
+	task *manager.TaskManager,
+	execHub *manager.ExecHub,
+	users *manager.UserManager,
+	terminal *manager.TerminalManager,
+	execPolicy *policy.Engine,
+	approval *manager.ApprovalManager,
 ) *ServerHandler {
 	return &ServerHandler{
 		sysMgr:       sys,
@@ -39,7 +60,17 @@ func NewServerHandler(
 		pkgMgr:       pkg,
 		configMgr:    cfg,
 		alertMgr:     alert,
+		notifyMgr:    notify,
 		backupMgr:    backup,
 		monitorStore: monitor,
+		logShipMgr:   logShip,
+		configPush:   configPush,
+		scheduler:    scheduler,
+		taskMgr:      task,
+		execHub:      execHub,
+		userMgr:      users,
+		terminalMgr:  terminal,
+		execPolicy:   execPolicy,
+		approvalMgr:  approval,
 	}
 }
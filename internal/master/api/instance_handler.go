@@ -5,8 +5,10 @@ import (
 	"fmt"
 	"net/http"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"ops-system/internal/master/manager"
 	"ops-system/pkg/code"
 	"ops-system/pkg/e"
 	"ops-system/pkg/protocol"
@@ -18,10 +20,18 @@ import (
 // 私有辅助方法
 // ==========================================
 
+// nodeRef 请求中的节点标识，node_id 优先，兼容只传 node_ip 的旧前端
+func nodeRef(nodeID, nodeIP string) string {
+	if nodeID != "" {
+		return nodeID
+	}
+	return nodeIP
+}
+
 // sendInstanceCommand 向 Worker 发送实例控制指令
 func (h *ServerHandler) sendInstanceCommand(inst *protocol.InstanceInfo, action string) error {
 	// 1. 获取节点信息 (使用注入的 nodeMgr)
-	node, exists := h.nodeMgr.GetNode(inst.NodeIP)
+	node, exists := h.nodeMgr.GetInstanceNode(inst)
 	if !exists {
 		return fmt.Errorf("node %s offline", inst.NodeIP)
 	}
@@ -34,8 +44,8 @@ func (h *ServerHandler) sendInstanceCommand(inst *protocol.InstanceInfo, action
 	reqBytes, _ := json.Marshal(workerReq)
 
 	// 3. 发送 HTTP 请求
-	targetURL := fmt.Sprintf("http://%s:%d/api/instance/action", node.IP, node.Port)
-	return utils.PostJSON(targetURL, reqBytes)
+	targetURL := manager.WorkerURL(node, "/api/instance/action")
+	return utils.PostJSONHeader(targetURL, reqBytes, h.nodeMgr.WorkerHeader(node.ID, "/api/instance/action"))
 }
 
 // ==========================================
@@ -44,12 +54,16 @@ func (h *ServerHandler) sendInstanceCommand(inst *protocol.InstanceInfo, action
 
 // DeployInstance 部署实例
 // POST /api/deploy
+// 指定 node_id/node_ip 时部署到单个节点；否则按 node_ids/node_ips/group/selector 批量部署到匹配的在线节点
+// 维护中 (已封锁) 的节点不接受新部署
 func (h *ServerHandler) DeployInstance(w http.ResponseWriter, r *http.Request) {
 	type DeployReq struct {
 		SystemID       string `json:"system_id"`
+		NodeID         string `json:"node_id"` // 优先于 node_ip
 		NodeIP         string `json:"node_ip"`
 		ServiceName    string `json:"service_name"`
 		ServiceVersion string `json:"service_version"`
+		protocol.NodeTarget
 	}
 	var req DeployReq
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -58,10 +72,30 @@ func (h *ServerHandler) DeployInstance(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// 1. 检查节点
-	node, exists := h.nodeMgr.GetNode(req.NodeIP)
-	if !exists {
-		response.Error(w, e.New(code.NodeOffline, "目标节点不在线", nil))
-		return
+	var nodes []protocol.NodeInfo
+	batch := req.NodeID == "" && req.NodeIP == ""
+	if batch {
+		list, err := h.nodeMgr.SelectNodes(req.NodeTarget)
+		if err != nil {
+			response.Error(w, e.New(code.ParamError, "目标节点条件无效", err))
+			return
+		}
+		if len(list) == 0 {
+			response.Error(w, e.New(code.NodeNotFound, "没有匹配的节点", nil))
+			return
+		}
+		nodes = list
+	} else {
+		node, exists := h.nodeMgr.GetNode(nodeRef(req.NodeID, req.NodeIP))
+		if !exists {
+			response.Error(w, e.New(code.NodeOffline, "目标节点不在线", nil))
+			return
+		}
+		if node.Cordoned {
+			response.Error(w, e.New(code.NodeCordoned, "目标节点维护中，不接受新部署", nil))
+			return
+		}
+		nodes = []protocol.NodeInfo{*node}
 	}
 
 	// 2. 获取下载链接 (使用 pkgMgr)
@@ -71,17 +105,85 @@ func (h *ServerHandler) DeployInstance(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	instanceID := fmt.Sprintf("inst-%d", time.Now().UnixNano())
+	// 3. 创建任务，每个节点一个子任务 (Worker 完成下载解压后上报结果)
+	task, err := h.newTask(r, "deploy", req.ServiceName+"@"+req.ServiceVersion, nodeItems(nodes), deployTaskTimeout)
+	if err != nil {
+		response.Error(w, err)
+		return
+	}
 
-	// 3. 预先入库 (状态为 deploying)
-	h.instMgr.RegisterInstance(&protocol.InstanceInfo{
+	if !batch {
+		id, err := h.deployToNode(r, &nodes[0], task.Items[0], req.SystemID, req.ServiceName, req.ServiceVersion, downloadURL)
+		if err != nil {
+			response.Error(w, e.New(code.DeployFailed, err.Error(), err))
+			return
+		}
+		response.Success(w, map[string]string{"task_id": task.ID, "instance_id": id})
+		return
+	}
+
+	// 批量部署: 逐个节点下发，单个节点失败不影响其他节点
+	results := make([]deployResult, 0, len(nodes))
+	for i := range nodes {
+		node := &nodes[i]
+		res := deployResult{NodeID: node.ID, NodeIP: node.IP}
+		if node.Cordoned {
+			res.Error = "节点维护中"
+		} else if node.Status != "online" {
+			res.Error = "节点不在线"
+		} else if id, err := h.deployToNode(r, node, task.Items[i], req.SystemID, req.ServiceName, req.ServiceVersion, downloadURL); err != nil {
+			res.Error = err.Error()
+		} else {
+			res.InstanceID = id
+		}
+		if res.Error != "" {
+			h.taskMgr.FinishItem(task.Items[i].ID, protocol.TaskFailed, "", res.Error)
+		}
+		results = append(results, res)
+	}
+	response.Success(w, deployBatchResult{TaskID: task.ID, Results: results})
+}
+
+// deployBatchResult 批量部署的返回: 任务 ID 与各节点的下发结果
+type deployBatchResult struct {
+	TaskID  string         `json:"task_id"`
+	Results []deployResult `json:"results"`
+}
+
+// deployResult 批量部署时单个节点的结果
+type deployResult struct {
+	NodeID     string `json:"node_id"`
+	NodeIP     string `json:"node_ip"`
+	InstanceID string `json:"instance_id,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// deployToNode 在单个节点上创建实例并下发部署请求，返回实例 ID
+// item 为对应的子任务: 下发失败时直接结束，成功后等待 Worker 上报部署结果
+func (h *ServerHandler) deployToNode(r *http.Request, node *protocol.NodeInfo, item protocol.TaskItem, systemID, serviceName, version, downloadURL string) (string, error) {
+	instanceID := fmt.Sprintf("inst-%d", time.Now().UnixNano())
+	inst := &protocol.InstanceInfo{
 		ID:             instanceID,
-		SystemID:       req.SystemID,
-		NodeIP:         req.NodeIP,
-		ServiceName:    req.ServiceName,
-		ServiceVersion: req.ServiceVersion,
+		SystemID:       systemID,
+		NodeID:         node.ID,
+		NodeIP:         node.IP,
+		ServiceName:    serviceName,
+		ServiceVersion: version,
 		Status:         "deploying",
-	})
+	}
+
+	// 渲染模块绑定的配置文件，随部署请求一起下发
+	configFiles, err := h.configPush.RenderFiles(inst)
+	if err != nil {
+		h.logMgr.RecordLog(utils.GetClientIP(r), "deploy_instance", "instance", serviceName, "Failed: "+err.Error(), "fail")
+		err = fmt.Errorf("渲染配置文件失败: %v", err)
+		h.finishTaskItem(item.ID, "", err)
+		return "", err
+	}
+
+	// 3. 预先入库 (状态为 deploying)
+	h.instMgr.RegisterInstance(inst)
+	h.taskMgr.StartItem(item.ID, instanceID)
 
 	// 触发广播
 	h.broadcastUpdate()
@@ -89,31 +191,37 @@ func (h *ServerHandler) DeployInstance(w http.ResponseWriter, r *http.Request) {
 	// 4. 构造 Worker 请求
 	workerReq := protocol.DeployRequest{
 		InstanceID:  instanceID,
-		SystemName:  req.SystemID,
-		ServiceName: req.ServiceName,
-		Version:     req.ServiceVersion,
+		SystemName:  systemID,
+		ServiceName: serviceName,
+		Version:     version,
 		DownloadURL: downloadURL,
+		ConfigFiles: configFiles,
+		TaskID:      item.TaskID,
+		TaskItemID:  item.ID,
 	}
 	reqBody, _ := json.Marshal(workerReq)
-	targetURL := fmt.Sprintf("http://%s:%d/api/deploy", node.IP, node.Port)
+	targetURL := manager.WorkerURL(node, "/api/deploy")
 
 	// 5. 发送请求 (Worker 异步处理)
-	if err := utils.PostJSON(targetURL, reqBody); err != nil {
+	if err := utils.PostJSONHeader(targetURL, reqBody, h.nodeMgr.WorkerHeader(node.ID, "/api/deploy")); err != nil {
 		// 失败回滚状态
 		h.instMgr.UpdateInstanceStatus(instanceID, "error", 0)
 
-		h.logMgr.RecordLog(utils.GetClientIP(r), "deploy_instance", "instance", req.ServiceName, "Failed: "+err.Error(), "fail")
+		h.logMgr.RecordLog(utils.GetClientIP(r), "deploy_instance", "instance", serviceName, "Failed: "+err.Error(), "fail")
 		h.broadcastUpdate()
 
-		response.Error(w, e.New(code.DeployFailed, fmt.Sprintf("Worker 部署请求失败: %v", err), err))
-		return
+		err = fmt.Errorf("Worker 部署请求失败: %v", err)
+		h.finishTaskItem(item.ID, "", err)
+		return "", err
 	}
 
 	// 记录日志
-	logDetail := fmt.Sprintf("Node: %s, Ver: %s, ID: %s", req.NodeIP, req.ServiceVersion, instanceID)
-	h.logMgr.RecordLog(utils.GetClientIP(r), "deploy_instance", "instance", req.ServiceName, logDetail, "success")
-
-	response.Success(w, nil)
+	logDetail := fmt.Sprintf("Node: %s, Ver: %s, ID: %s", node.IP, version, instanceID)
+	if len(configFiles) > 0 {
+		logDetail += fmt.Sprintf(", Configs: %d", len(configFiles))
+	}
+	h.logMgr.RecordLog(utils.GetClientIP(r), "deploy_instance", "instance", serviceName, logDetail, "success")
+	return instanceID, nil
 }
 
 // RegisterExternal 纳管外部服务
@@ -121,6 +229,7 @@ func (h *ServerHandler) DeployInstance(w http.ResponseWriter, r *http.Request) {
 func (h *ServerHandler) RegisterExternal(w http.ResponseWriter, r *http.Request) {
 	type RegExtReq struct {
 		SystemID string                  `json:"system_id"`
+		NodeID   string                  `json:"node_id"` // 优先于 node_ip
 		NodeIP   string                  `json:"node_ip"`
 		Config   protocol.ExternalConfig `json:"config"`
 	}
@@ -130,7 +239,7 @@ func (h *ServerHandler) RegisterExternal(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	node, exists := h.nodeMgr.GetNode(req.NodeIP)
+	node, exists := h.nodeMgr.GetNode(nodeRef(req.NodeID, req.NodeIP))
 	if !exists {
 		response.Error(w, e.New(code.NodeOffline, "目标节点不在线", nil))
 		return
@@ -142,7 +251,8 @@ func (h *ServerHandler) RegisterExternal(w http.ResponseWriter, r *http.Request)
 	h.instMgr.RegisterInstance(&protocol.InstanceInfo{
 		ID:             instanceID,
 		SystemID:       req.SystemID,
-		NodeIP:         req.NodeIP,
+		NodeID:         node.ID,
+		NodeIP:         node.IP,
 		ServiceName:    req.Config.Name,
 		ServiceVersion: "external",
 		Status:         "stopped",
@@ -156,9 +266,9 @@ func (h *ServerHandler) RegisterExternal(w http.ResponseWriter, r *http.Request)
 		Config:     req.Config,
 	}
 	reqBytes, _ := json.Marshal(workerReq)
-	targetURL := fmt.Sprintf("http://%s:%d/api/external/register", node.IP, node.Port)
+	targetURL := manager.WorkerURL(node, "/api/external/register")
 
-	if err := utils.PostJSON(targetURL, reqBytes); err != nil {
+	if err := utils.PostJSONHeader(targetURL, reqBytes, h.nodeMgr.WorkerHeader(node.ID, "/api/external/register")); err != nil {
 		h.instMgr.UpdateInstanceStatus(instanceID, "error", 0)
 		h.broadcastUpdate()
 		response.Error(w, e.New(code.DeployFailed, fmt.Sprintf("Worker 纳管请求失败: %v", err), err))
@@ -185,8 +295,18 @@ func (h *ServerHandler) InstanceAction(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 发送指令
-	if err := h.sendInstanceCommand(inst, req.Action); err != nil {
+	task, err := h.newTask(r, req.Action, inst.ServiceName, instanceItems([]*protocol.InstanceInfo{inst}), actionTaskTimeout)
+	if err != nil {
+		response.Error(w, err)
+		return
+	}
+	item := task.Items[0]
+
+	// 发送指令 (Worker 执行完成后才返回)
+	h.taskMgr.StartItem(item.ID, "")
+	err = h.sendInstanceCommand(inst, req.Action)
+	h.finishTaskItem(item.ID, "", err)
+	if err != nil {
 		h.logMgr.RecordLog(utils.GetClientIP(r), req.Action+"_instance", "instance", inst.ServiceName, "Failed: "+err.Error(), "fail")
 		response.Error(w, e.New(code.ActionFailed, fmt.Sprintf("发送指令失败: %v", err), err))
 		return
@@ -199,20 +319,58 @@ func (h *ServerHandler) InstanceAction(w http.ResponseWriter, r *http.Request) {
 	}
 
 	h.logMgr.RecordLog(utils.GetClientIP(r), req.Action+"_instance", "instance", inst.ServiceName, "ID: "+inst.ID, "success")
-	response.Success(w, nil)
+	response.Success(w, map[string]string{"task_id": task.ID})
+}
+
+// PushInstanceConfig 重新下发实例绑定的全部配置 (按绑定策略重载)
+// POST /api/instance/config/push
+func (h *ServerHandler) PushInstanceConfig(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		response.Error(w, e.New(code.MethodNotAllowed, "Method not allowed", nil))
+		return
+	}
+	var req struct {
+		InstanceID string `json:"instance_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, e.New(code.InvalidJSON, "JSON解析失败", err))
+		return
+	}
+	inst, ok := h.instMgr.GetInstance(req.InstanceID)
+	if !ok {
+		response.Error(w, e.New(code.InstanceNotFound, "实例不存在", nil))
+		return
+	}
+
+	res, err := h.configPush.PushInstance(inst, utils.GetClientIP(r))
+	if err != nil {
+		response.Error(w, e.New(code.ConfigError, fmt.Sprintf("下发配置失败: %v", err), err))
+		return
+	}
+	response.Success(w, res)
 }
 
 // WorkerStatusReport Worker 状态上报回调
 // POST /api/instance/status_report
 func (h *ServerHandler) WorkerStatusReport(w http.ResponseWriter, r *http.Request) {
+	nodeID, ok := h.requireNode(w, r)
+	if !ok {
+		return
+	}
 	var report protocol.InstanceStatusReport
 	if err := json.NewDecoder(r.Body).Decode(&report); err != nil {
 		response.Error(w, e.New(code.InvalidJSON, "JSON解析失败", err))
 		return
 	}
+	if !h.ownsInstance(nodeID, report.InstanceID) {
+		response.Error(w, e.New(code.InstanceNotFound, "实例不存在或不属于该节点", nil))
+		return
+	}
 
 	// 更新状态
 	h.instMgr.UpdateInstanceFullStatus(&report)
+	// 兼容不上报任务进度的旧版 Worker: 部署结束 (离开 deploying) 即结束对应的子任务
+	h.taskMgr.CompleteInstanceItems(report.InstanceID, report.Status)
 
 	// 触发广播
 	h.broadcastUpdate()
@@ -255,30 +413,38 @@ func (h *ServerHandler) SystemAction(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 并发下发
-	var wg sync.WaitGroup
-	errCount := 0
-	var mu sync.Mutex
-
-	for _, inst := range targets {
-		wg.Add(1)
-		go func(target *protocol.InstanceInfo) {
-			defer wg.Done()
-			if err := h.sendInstanceCommand(target, req.Action); err != nil {
-				// 仅记录日志，不中断其他
-				mu.Lock()
-				errCount++
-				mu.Unlock()
-			}
-		}(inst)
+	task, err := h.newTask(r, "system_"+req.Action, req.SystemID, instanceItems(targets), actionTaskTimeout)
+	if err != nil {
+		response.Error(w, err)
+		return
 	}
 
-	wg.Wait()
+	// 后台并发下发，结果记录在任务中
+	operator := utils.GetClientIP(r)
+	go func() {
+		var wg sync.WaitGroup
+		var errCount atomic.Int32
+		for i, inst := range targets {
+			wg.Add(1)
+			go func(target *protocol.InstanceInfo, item protocol.TaskItem) {
+				defer wg.Done()
+				h.taskMgr.StartItem(item.ID, "")
+				err := h.sendInstanceCommand(target, req.Action)
+				// 仅记录结果，不中断其他
+				h.finishTaskItem(item.ID, "", err)
+				if err != nil {
+					errCount.Add(1)
+				}
+			}(inst, task.Items[i])
+		}
+		wg.Wait()
 
-	logDetail := fmt.Sprintf("Action: %s, Count: %d, Failed: %d", req.Action, len(targets), errCount)
-	h.logMgr.RecordLog(utils.GetClientIP(r), "batch_"+req.Action, "system", req.SystemID, logDetail, "success")
+		logDetail := fmt.Sprintf("Action: %s, Count: %d, Failed: %d, Task: %s", req.Action, len(targets), errCount.Load(), task.ID)
+		h.logMgr.RecordLog(operator, "batch_"+req.Action, "system", req.SystemID, logDetail, "success")
+	}()
 
 	response.Success(w, map[string]string{
-		"msg": fmt.Sprintf("操作完成: %d 成功, %d 失败", len(targets)-errCount, errCount),
+		"task_id": task.ID,
+		"msg":     fmt.Sprintf("已下发 %d 个实例", len(targets)),
 	})
 }
@@ -1,17 +1,29 @@
 package api
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
+	"ops-system/internal/master/logstore"
+	"ops-system/internal/master/manager"
 	"ops-system/pkg/code"
 	"ops-system/pkg/e"
+	"ops-system/pkg/logparse"
 	"ops-system/pkg/protocol"
 	"ops-system/pkg/response"
+	"ops-system/pkg/utils"
 
 	"github.com/gorilla/websocket"
 )
@@ -61,7 +73,7 @@ func (h *ServerHandler) GetInstanceLogFiles(w http.ResponseWriter, r *http.Reque
 	}
 
 	// 2. 获取节点 (使用 nodeMgr 获取 IP 和 Port)
-	node, exists := h.nodeMgr.GetNode(inst.NodeIP)
+	node, exists := h.nodeMgr.GetInstanceNode(inst)
 	if !exists {
 		response.Error(w, e.New(code.NodeOffline, "节点离线或不存在", nil))
 		return
@@ -69,9 +81,9 @@ func (h *ServerHandler) GetInstanceLogFiles(w http.ResponseWriter, r *http.Reque
 
 	// 3. 转发请求给 Worker
 	// 拼接 URL: http://IP:Port/api/log/files?instance_id=...
-	targetURL := fmt.Sprintf("http://%s:%d/api/log/files?instance_id=%s", node.IP, node.Port, instID)
+	targetURL := manager.WorkerURL(node, "/api/log/files?instance_id="+instID)
 
-	client := &http.Client{Timeout: 3 * time.Second}
+	client := utils.NewClient(3 * time.Second)
 	resp, err := client.Get(targetURL)
 	if err != nil {
 		response.Error(w, e.New(code.NetworkError, fmt.Sprintf("连接 Worker 失败: %v", err), err))
@@ -93,11 +105,10 @@ func (h *ServerHandler) GetInstanceLogFiles(w http.ResponseWriter, r *http.Reque
 	response.Success(w, result)
 }
 
-// InstanceLogStream 代理日志 WebSocket
-// GET /api/instance/logs/stream?instance_id=...&log_key=...
+// InstanceLogStream 代理日志 WebSocket (连接后先回放最后 lines 行)
+// GET /api/instance/logs/stream?instance_id=...&log_key=...&lines=200&min_level=...&filter=...&structured=1
 func (h *ServerHandler) InstanceLogStream(w http.ResponseWriter, r *http.Request) {
 	instID := r.URL.Query().Get("instance_id")
-	logKey := r.URL.Query().Get("log_key")
 
 	// 1. 查找信息
 	inst, ok := h.instMgr.GetInstance(instID)
@@ -106,21 +117,23 @@ func (h *ServerHandler) InstanceLogStream(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	node, exists := h.nodeMgr.GetNode(inst.NodeIP)
+	node, exists := h.nodeMgr.GetInstanceNode(inst)
 	if !exists {
 		http.Error(w, "Node offline", 404)
 		return
 	}
 
 	// 2. 构造 Worker WS URL
-	// 格式: ws://IP:Port/api/log/ws...
-	workerWsURL := fmt.Sprintf("ws://%s:%d/api/log/ws?instance_id=%s&log_key=%s",
-		node.IP, node.Port, instID, url.QueryEscape(logKey))
+	// 格式: ws://IP:Port/api/log/ws... (反向通道节点为 ws://<ID>.tunnel/...)
+	workerWsURL := "ws" + strings.TrimPrefix(manager.WorkerURL(node,
+		"/api/log/ws?"+logQuery(r, "lines", "min_level", "filter", "structured")), "http")
 
 	log.Printf("[LogProxy] Connecting to Worker: %s", workerWsURL)
 
 	// 3. Dial Worker (Master 作为客户端连接 Worker)
-	workerConn, _, err := websocket.DefaultDialer.Dial(workerWsURL, nil)
+	// 复用全局连接池的拨号函数，反向通道节点经通道连接
+	dialer := websocket.Dialer{NetDialContext: utils.Transport.DialContext, HandshakeTimeout: 10 * time.Second}
+	workerConn, _, err := dialer.Dial(workerWsURL, nil)
 	if err != nil {
 		log.Printf("[LogProxy] Dial failed: %v", err)
 		http.Error(w, fmt.Sprintf("Connect worker failed: %v", err), 502)
@@ -172,3 +185,343 @@ func (h *ServerHandler) InstanceLogStream(w http.ResponseWriter, r *http.Request
 	// 阻塞直到任意一方断开
 	<-errChan
 }
+
+// GetInstanceLogPage 向前分页读取历史日志 (operator 及以上)
+// GET /api/instance/logs/page?instance_id=...&log_key=...&file=...&before=...&lines=...
+func (h *ServerHandler) GetInstanceLogPage(w http.ResponseWriter, r *http.Request) {
+	if _, ok := h.requireRole(w, r, manager.RoleOperator); !ok {
+		return
+	}
+	node, err := h.instanceNode(r.URL.Query().Get("instance_id"))
+	if err != nil {
+		response.Error(w, err)
+		return
+	}
+
+	targetURL := manager.WorkerURL(node, "/api/log/page?"+logQuery(r, "before", "lines"))
+	client := utils.NewClient(10 * time.Second)
+	resp, err := client.Get(targetURL)
+	if err != nil {
+		response.Error(w, e.New(code.NetworkError, fmt.Sprintf("连接 Worker 失败: %v", err), err))
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		msg, _ := io.ReadAll(resp.Body)
+		response.Error(w, e.New(code.ServerError, fmt.Sprintf("Worker 返回错误: %s", strings.TrimSpace(string(msg))), nil))
+		return
+	}
+
+	var page protocol.LogPage
+	if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+		response.Error(w, e.New(code.ServerError, "解析 Worker 响应失败", err))
+		return
+	}
+	response.Success(w, page)
+}
+
+// DownloadInstanceLog 下载日志文件 (经 Master 代理，gzip=1 时压缩传输)
+// 仅 operator 及以上可下载，且只允许下载实例已登记的日志及其轮转文件，下载行为记录到操作日志
+// GET /api/instance/logs/download?instance_id=...&log_key=...&file=...&gzip=1&token=...
+func (h *ServerHandler) DownloadInstanceLog(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.requireRole(w, r, manager.RoleOperator)
+	if !ok {
+		return
+	}
+	instID := r.URL.Query().Get("instance_id")
+	node, err := h.instanceNode(instID)
+	if err != nil {
+		http.Error(w, err.Error(), 404)
+		return
+	}
+
+	targetURL := manager.WorkerURL(node, "/api/log/download?"+logQuery(r, "gzip"))
+	resp, err := utils.NewClient(0).Get(targetURL) // 大文件下载不设置整体超时
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Connect worker failed: %v", err), 502)
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		msg, _ := io.ReadAll(resp.Body)
+		h.logMgr.RecordLog(user.Name, "download_log", "instance", instID, strings.TrimSpace(string(msg)), "fail")
+		http.Error(w, string(msg), resp.StatusCode)
+		return
+	}
+
+	for _, k := range []string{"Content-Type", "Content-Length", "Content-Disposition"} {
+		if v := resp.Header.Get(k); v != "" {
+			w.Header().Set(k, v)
+		}
+	}
+	h.logMgr.RecordLog(user.Name, "download_log", "instance", instID, r.URL.Query().Get("log_key")+" "+r.URL.Query().Get("file"), "success")
+	io.Copy(w, resp.Body)
+}
+
+// instanceNode 查找实例所在的在线节点
+func (h *ServerHandler) instanceNode(instID string) (*protocol.NodeInfo, error) {
+	if instID == "" {
+		return nil, e.New(code.ParamError, "缺少 instance_id", nil)
+	}
+	inst, ok := h.instMgr.GetInstance(instID)
+	if !ok {
+		return nil, e.New(code.InstanceNotFound, "实例不存在", nil)
+	}
+	node, exists := h.nodeMgr.GetInstanceNode(inst)
+	if !exists || !nodeReachable(node) {
+		return nil, e.New(code.NodeOffline, "节点离线或不存在", nil)
+	}
+	return node, nil
+}
+
+// logQuery 构造转发给 Worker 的查询参数 (实例/日志/文件 + 额外字段)
+func logQuery(r *http.Request, extra ...string) string {
+	src := r.URL.Query()
+	q := url.Values{}
+	for _, k := range append([]string{"instance_id", "log_key", "file"}, extra...) {
+		if v := src.Get(k); v != "" {
+			q.Set(k, v)
+		}
+	}
+	return q.Encode()
+}
+
+// SearchLogs 跨实例日志检索
+// 按系统/服务/实例圈定范围，按节点并发转发给 Worker，合并结果并标注实例与节点
+// POST /api/logs/search
+func (h *ServerHandler) SearchLogs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		response.Error(w, e.New(code.MethodNotAllowed, "Method not allowed", nil))
+		return
+	}
+
+	var req protocol.LogSearchReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, e.New(code.InvalidJSON, "JSON解析失败", err))
+		return
+	}
+	if req.Query == "" && req.MinLevel == "" && req.Filter == "" {
+		response.Error(w, e.New(code.ParamError, "检索条件不能为空", nil))
+		return
+	}
+	if _, err := logparse.NewFilter(req.MinLevel, req.Filter); err != nil {
+		response.Error(w, e.New(code.ParamError, "过滤条件无效", err))
+		return
+	}
+	if req.MaxResults <= 0 {
+		req.MaxResults = 500
+	}
+
+	// 1. 圈定实例并按节点分组
+	wanted := make(map[string]bool, len(req.InstanceIDs))
+	for _, id := range req.InstanceIDs {
+		wanted[id] = true
+	}
+	byNode := make(map[string][]*protocol.InstanceInfo)
+	for sysID, list := range h.instMgr.GetAllInstances() {
+		if req.SystemID != "" && sysID != req.SystemID {
+			continue
+		}
+		for _, inst := range list {
+			if req.ServiceName != "" && inst.ServiceName != req.ServiceName {
+				continue
+			}
+			if len(wanted) > 0 && !wanted[inst.ID] {
+				continue
+			}
+			ref := nodeRef(inst.NodeID, inst.NodeIP)
+			byNode[ref] = append(byNode[ref], inst)
+		}
+	}
+	if len(byNode) == 0 {
+		response.Error(w, e.New(code.InstanceNotFound, "没有匹配的实例", nil))
+		return
+	}
+
+	// 集中存储：不依赖节点在线 (Master 不知道各日志的格式，暂不支持结构化过滤)
+	if req.Source == "central" {
+		if req.MinLevel != "" || req.Filter != "" {
+			response.Error(w, e.New(code.ParamError, "集中日志检索暂不支持级别/字段过滤", nil))
+			return
+		}
+		h.searchCentralLogs(w, byNode, req)
+		return
+	}
+
+	// 2. 并发检索
+	result := protocol.LogSearchResp{Hits: []protocol.LogSearchHit{}}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for ref, insts := range byNode {
+		wg.Add(1)
+		go func(ref string, insts []*protocol.InstanceInfo) {
+			defer wg.Done()
+			resp, err := h.searchNodeLogs(ref, insts, req)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				result.Errors = append(result.Errors, fmt.Sprintf("%s: %v", insts[0].NodeIP, err))
+				return
+			}
+			result.Hits = append(result.Hits, resp.Hits...)
+			result.Errors = append(result.Errors, resp.Errors...)
+			result.Truncated = result.Truncated || resp.Truncated
+		}(ref, insts)
+	}
+	wg.Wait()
+
+	// 3. 合并：按时间排序，整体截断
+	sort.SliceStable(result.Hits, func(i, j int) bool {
+		a, b := result.Hits[i], result.Hits[j]
+		if a.Time != b.Time {
+			return a.Time < b.Time
+		}
+		if a.InstanceID != b.InstanceID {
+			return a.InstanceID < b.InstanceID
+		}
+		return a.LineNo < b.LineNo
+	})
+	if len(result.Hits) > req.MaxResults {
+		result.Hits = result.Hits[:req.MaxResults]
+		result.Truncated = true
+	}
+
+	response.Success(w, result)
+}
+
+// searchNodeLogs 向单个 Worker 发起检索，并补全命中行的实例/节点信息
+func (h *ServerHandler) searchNodeLogs(ref string, insts []*protocol.InstanceInfo, req protocol.LogSearchReq) (*protocol.LogSearchResp, error) {
+	node, exists := h.nodeMgr.GetNode(ref)
+	if !exists || !nodeReachable(node) {
+		return nil, fmt.Errorf("node offline")
+	}
+
+	services := make(map[string]string, len(insts))
+	sub := req
+	sub.SystemID, sub.ServiceName = "", ""
+	sub.InstanceIDs = make([]string, 0, len(insts))
+	for _, inst := range insts {
+		sub.InstanceIDs = append(sub.InstanceIDs, inst.ID)
+		services[inst.ID] = inst.ServiceName
+	}
+
+	body, _ := json.Marshal(sub)
+	targetURL := manager.WorkerURL(node, "/api/log/search")
+	client := utils.NewClient(30 * time.Second) // 大文件检索可能较慢
+	resp, err := client.Post(targetURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("worker returned status %d", resp.StatusCode)
+	}
+
+	var result protocol.LogSearchResp
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	for i := range result.Hits {
+		result.Hits[i].NodeIP = node.IP
+		result.Hits[i].ServiceName = services[result.Hits[i].InstanceID]
+	}
+	return &result, nil
+}
+
+// searchCentralLogs 在 Master 集中存储中检索 (结果已按实例、时间顺序排列)
+func (h *ServerHandler) searchCentralLogs(w http.ResponseWriter, byNode map[string][]*protocol.InstanceInfo, req protocol.LogSearchReq) {
+	pattern := req.Query
+	if req.IgnoreCase {
+		pattern = "(?i)" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		response.Error(w, e.New(code.ParamError, "检索表达式无效", err))
+		return
+	}
+
+	var insts []*protocol.InstanceInfo
+	for _, list := range byNode {
+		insts = append(insts, list...)
+	}
+	sort.Slice(insts, func(i, j int) bool { return insts[i].ID < insts[j].ID })
+
+	result, err := h.logShipMgr.Search(insts, req, re)
+	if err != nil {
+		response.Error(w, centralLogError("检索集中日志失败", err))
+		return
+	}
+	sort.SliceStable(result.Hits, func(i, j int) bool { return result.Hits[i].Time < result.Hits[j].Time })
+	response.Success(w, result)
+}
+
+// ShipLogs 接收 Worker 推送的日志批次 (写入集中存储，返回已确认的序号)
+// POST /api/logs/ship
+func (h *ServerHandler) ShipLogs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		response.Error(w, e.New(code.MethodNotAllowed, "Method not allowed", nil))
+		return
+	}
+	nodeID, ok := h.requireNode(w, r)
+	if !ok {
+		return
+	}
+
+	var req protocol.LogShipReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, e.New(code.InvalidJSON, "JSON解析失败", err))
+		return
+	}
+	if req.InstanceID == "" || req.LogKey == "" {
+		response.Error(w, e.New(code.ParamError, "缺少 instance_id 或 log_key", nil))
+		return
+	}
+	if !h.ownsInstance(nodeID, req.InstanceID) {
+		response.Error(w, e.New(code.InstanceNotFound, "实例不存在或不属于该节点", nil))
+		return
+	}
+	req.NodeIP = resolveWorkerIP(r, req.NodeIP)
+
+	acked, err := h.logShipMgr.Ingest(req)
+	if err != nil {
+		response.Error(w, centralLogError("写入集中日志失败", err))
+		return
+	}
+	response.Success(w, protocol.LogShipResp{Acked: acked})
+}
+
+// centralLogError 集中日志读写错误 (实例 ID 或日志 key 不能作为目录名时为参数错误)
+func centralLogError(msg string, err error) error {
+	if errors.Is(err, logstore.ErrInvalidStream) {
+		return e.New(code.ParamError, "实例 ID 或日志 key 无效", err)
+	}
+	return e.New(code.ServerError, msg, err)
+}
+
+// GetCentralLogTail 读取集中存储中实例日志的最后 N 行 (节点离线时仍可查看)
+// GET /api/logs/central/tail?instance_id=...&log_key=...&lines=200
+func (h *ServerHandler) GetCentralLogTail(w http.ResponseWriter, r *http.Request) {
+	instID := r.URL.Query().Get("instance_id")
+	if instID == "" {
+		response.Error(w, e.New(code.ParamError, "缺少 instance_id", nil))
+		return
+	}
+	lines, _ := strconv.Atoi(r.URL.Query().Get("lines"))
+	if lines <= 0 {
+		lines = 200
+	}
+	if lines > 5000 {
+		lines = 5000
+	}
+
+	result, err := h.logShipMgr.Tail(instID, r.URL.Query().Get("log_key"), lines)
+	if err != nil {
+		response.Error(w, centralLogError("读取集中日志失败", err))
+		return
+	}
+	response.Success(w, result)
+}
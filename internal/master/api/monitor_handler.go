@@ -12,15 +12,16 @@ import (
 )
 
 // QueryRange 模拟 Prometheus 查询接口
-// GET /api/monitor/query_range?query=node_cpu_usage&instance=1.2.3.4&start=...&end=...
+// GET /api/monitor/query_range?query=node_cpu_usage&instance=<节点ID>&start=...&end=...
+// 节点序列以节点 ID 标识，instance 为节点 IP 时 (旧版前端) 解析为该 IP 当前对应的节点
 func (h *ServerHandler) QueryRange(w http.ResponseWriter, r *http.Request) {
 	q := r.URL.Query()
 	metric := q.Get("query")   // e.g. node_cpu_usage
-	ip := q.Get("instance")    // e.g. 192.168.1.10
+	id := q.Get("instance")    // 节点 ID / 实例 ID
 	startStr := q.Get("start") // Unix Timestamp
 	endStr := q.Get("end")
 
-	if metric == "" || ip == "" {
+	if metric == "" || id == "" {
 		response.Error(w, e.New(code.ParamError, "缺少 query 或 instance 参数", nil))
 		return
 	}
@@ -37,12 +38,16 @@ func (h *ServerHandler) QueryRange(w http.ResponseWriter, r *http.Request) {
 		end = e
 	}
 
+	if node, ok := h.nodeMgr.GetNode(id); ok {
+		id = node.ID
+	}
+
 	// 1. 查询数据 (使用注入的 monitorStore)
-	points := h.monitorStore.QueryRange(metric, ip, start, end)
+	points := h.monitorStore.QueryRange(metric, id, start, end)
 
 	// 2. 格式化为 Prometheus 结构
 	// 返回结构: { status: "success", data: { resultType: "matrix", result: [...] } }
-	promResp := monitor.FormatPrometheusResponse(metric, ip, points)
+	promResp := monitor.FormatPrometheusResponse(metric, id, points)
 
 	// 3. 统一响应
 	// 最终前端收到的 JSON: { code: 0, msg: "success", data: { status: "success", data: ... } }
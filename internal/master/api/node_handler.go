@@ -3,14 +3,22 @@ package api
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"ops-system/internal/master/manager"
 	"ops-system/internal/master/ws"
 	"ops-system/pkg/code"
 	"ops-system/pkg/e"
+	"ops-system/pkg/labels"
+	"ops-system/pkg/nodeauth"
 	"ops-system/pkg/protocol"
 	"ops-system/pkg/response"
 	"ops-system/pkg/utils"
@@ -30,38 +38,149 @@ func (h *ServerHandler) HandleHeartbeat(w http.ResponseWriter, r *http.Request)
 		response.Error(w, e.New(code.InvalidJSON, "Invalid heartbeat payload", err))
 		return
 	}
+	// IP 形式的 ID 保留给未认领的旧记录，避免冲突
+	if req.NodeID != "" && net.ParseIP(req.NodeID) != nil {
+		response.Error(w, e.New(code.ParamError, "Invalid node id", nil))
+		return
+	}
+
+	// 1. 获取 Worker 当前通信地址 (连接层 IP + 本地开发环境修正)
+	// 节点身份以 req.NodeID 为准，IP 只是可变属性
+	remoteIP := resolveWorkerIP(r, req.Info.IP)
 
-	// 1. 获取连接层 IP
+	// 3. 校验节点密钥并更新数据库
+	secret, err := h.nodeMgr.HandleHeartbeat(req, remoteIP)
+	if err != nil {
+		// 密钥丢失 (如重装 Worker 但保留了 node_id) 时，在节点列表删除该节点后即可重新注册
+		response.Error(w, e.New(code.Unauthorized, "节点密钥无效", fmt.Errorf("node %s from %s: %w", req.NodeID, remoteIP, err)))
+		return
+	}
+
+	// 4. 触发 WebSocket 广播 (Hub 会自动节流)
+	ws.BroadcastNodes(h.nodeMgr.GetAllNodes())
+
+	// 首次注册时返回签发的节点密钥
+	response.Success(w, protocol.HeartbeatResp{Secret: secret})
+}
+
+// resolveWorkerIP 获取 Worker 的节点 IP
+// 优先使用连接层 IP；本机回环地址时改用 Worker 自报的 IP (处理本地开发环境)
+func resolveWorkerIP(r *http.Request, reportedIP string) string {
 	remoteIP := r.RemoteAddr
 	if host, _, err := net.SplitHostPort(remoteIP); err == nil {
 		remoteIP = host
 	}
-
-	// 2. 智能 IP 修正 (处理本地开发环境)
 	if (remoteIP == "127.0.0.1" || remoteIP == "::1") &&
-		req.Info.IP != "" && req.Info.IP != "127.0.0.1" {
-		remoteIP = req.Info.IP
+		reportedIP != "" && reportedIP != "127.0.0.1" {
+		remoteIP = reportedIP
 	}
+	return remoteIP
+}
 
-	// 3. 更新数据库 (无锁/低频锁)
-	h.nodeMgr.HandleHeartbeat(req, remoteIP)
-
-	// 4. 触发 WebSocket 广播 (Hub 会自动节流)
-	ws.BroadcastNodes(h.nodeMgr.GetAllNodes())
+// requireNode 校验 Worker 回调 (状态、任务、日志上报等) 携带的节点凭据，返回节点 ID
+// 节点 ID 与来源 IP 均可伪造，须以心跳注册时签发的密钥证明身份
+func (h *ServerHandler) requireNode(w http.ResponseWriter, r *http.Request) (string, bool) {
+	nodeID := r.Header.Get(protocol.NodeIDHeader)
+	if !h.nodeMgr.VerifyNodeSecret(nodeID, r.Header.Get(protocol.NodeSecretHeader)) {
+		response.Error(w, e.New(code.Unauthorized, "节点密钥无效", fmt.Errorf("%s from node %q (%s)", r.URL.Path, nodeID, r.RemoteAddr)))
+		return "", false
+	}
+	return nodeID, true
+}
 
-	// 心跳接口通常只返回简单文本或标准成功响应
-	// 这里为了统一格式返回 standard response，Worker 端通常只检查 http status 200
-	response.Success(w, "pong")
+// ownsInstance 实例是否属于 nodeID 节点 (节点只能上报自己的实例)
+func (h *ServerHandler) ownsInstance(nodeID, instanceID string) bool {
+	inst, ok := h.instMgr.GetInstance(instanceID)
+	return ok && inst.NodeID == nodeID
 }
 
 // ListNodes 获取节点列表
-// GET /api/nodes
+// GET /api/nodes?selector=env=prod,role!=db&group=web
 func (h *ServerHandler) ListNodes(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Cache-Control", "no-cache")
-	nodes := h.nodeMgr.GetAllNodes()
+	q := r.URL.Query()
+	nodes, err := h.nodeMgr.FilterNodes(q.Get("selector"), q.Get("group"))
+	if err != nil {
+		response.Error(w, e.New(code.ParamError, "节点过滤条件无效", err))
+		return
+	}
 	response.Success(w, nodes)
 }
 
+// SetNodeLabels 设置节点自定义标签 (整体替换)
+// POST /api/nodes/labels
+func (h *ServerHandler) SetNodeLabels(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ID     string            `json:"id"` // 优先于 ip
+		IP     string            `json:"ip"`
+		Labels map[string]string `json:"labels"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, e.New(code.InvalidJSON, "JSON解析失败", err))
+		return
+	}
+
+	if err := h.nodeMgr.SetNodeLabels(nodeRef(req.ID, req.IP), req.Labels); err != nil {
+		response.Error(w, e.New(code.ParamError, "设置标签失败", err))
+		return
+	}
+
+	h.logMgr.RecordLog(utils.GetClientIP(r), "set_node_labels", "node", nodeRef(req.ID, req.IP), labels.FromMap(req.Labels).String(), "success")
+	ws.BroadcastNodes(h.nodeMgr.GetAllNodes())
+
+	response.Success(w, nil)
+}
+
+// ListNodeGroups 获取节点分组 (含当前成员)
+// GET /api/nodes/groups
+func (h *ServerHandler) ListNodeGroups(w http.ResponseWriter, r *http.Request) {
+	groups, err := h.nodeMgr.ListGroups()
+	if err != nil {
+		response.Error(w, e.New(code.DatabaseError, "获取分组失败", err))
+		return
+	}
+	response.Success(w, groups)
+}
+
+// SaveNodeGroup 创建或更新节点分组
+// POST /api/nodes/groups/save
+func (h *ServerHandler) SaveNodeGroup(w http.ResponseWriter, r *http.Request) {
+	var g protocol.NodeGroup
+	if err := json.NewDecoder(r.Body).Decode(&g); err != nil {
+		response.Error(w, e.New(code.InvalidJSON, "JSON解析失败", err))
+		return
+	}
+
+	if err := h.nodeMgr.SaveGroup(g); err != nil {
+		response.Error(w, e.New(code.ParamError, "保存分组失败", err))
+		return
+	}
+
+	detail := fmt.Sprintf("Selector: %s, Nodes: %d", g.Selector, len(g.NodeIDs))
+	h.logMgr.RecordLog(utils.GetClientIP(r), "save_node_group", "node", g.Name, detail, "success")
+	response.Success(w, nil)
+}
+
+// DeleteNodeGroup 删除节点分组
+// POST /api/nodes/groups/delete
+func (h *ServerHandler) DeleteNodeGroup(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, e.New(code.InvalidJSON, "JSON解析失败", err))
+		return
+	}
+
+	if err := h.nodeMgr.DeleteGroup(req.Name); err != nil {
+		response.Error(w, e.New(code.DatabaseError, "删除分组失败", err))
+		return
+	}
+
+	h.logMgr.RecordLog(utils.GetClientIP(r), "delete_node_group", "node", req.Name, "", "success")
+	response.Success(w, nil)
+}
+
 // AddNode 添加规划节点
 // POST /api/nodes/add
 func (h *ServerHandler) AddNode(w http.ResponseWriter, r *http.Request) {
@@ -89,6 +208,7 @@ func (h *ServerHandler) AddNode(w http.ResponseWriter, r *http.Request) {
 // POST /api/nodes/delete
 func (h *ServerHandler) DeleteNode(w http.ResponseWriter, r *http.Request) {
 	var req struct {
+		ID string `json:"id"` // 优先于 ip
 		IP string `json:"ip"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -96,14 +216,20 @@ func (h *ServerHandler) DeleteNode(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.nodeMgr.DeleteNode(req.IP); err != nil {
+	node, exists := h.nodeMgr.GetNode(nodeRef(req.ID, req.IP))
+	if err := h.nodeMgr.DeleteNode(nodeRef(req.ID, req.IP)); err != nil {
 		response.Error(w, e.New(code.DatabaseError, "删除节点失败", err))
 		return
 	}
 
-	h.logMgr.RecordLog(utils.GetClientIP(r), "delete_node", "node", req.IP, "", "success")
+	h.logMgr.RecordLog(utils.GetClientIP(r), "delete_node", "node", nodeRef(req.ID, req.IP), "", "success")
 	ws.BroadcastNodes(h.nodeMgr.GetAllNodes())
 
+	// 声明了副本数的模块: 将该节点上的副本重新调度到其他节点
+	if exists {
+		h.replaceNodeReplicas(r, node)
+	}
+
 	response.Success(w, nil)
 }
 
@@ -111,6 +237,7 @@ func (h *ServerHandler) DeleteNode(w http.ResponseWriter, r *http.Request) {
 // POST /api/nodes/rename
 func (h *ServerHandler) RenameNode(w http.ResponseWriter, r *http.Request) {
 	var req struct {
+		ID   string `json:"id"` // 优先于 ip
 		IP   string `json:"ip"`
 		Name string `json:"name"`
 	}
@@ -119,12 +246,12 @@ func (h *ServerHandler) RenameNode(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.nodeMgr.RenameNode(req.IP, req.Name); err != nil {
+	if err := h.nodeMgr.RenameNode(nodeRef(req.ID, req.IP), req.Name); err != nil {
 		response.Error(w, e.New(code.DatabaseError, "重命名失败", err))
 		return
 	}
 
-	h.logMgr.RecordLog(utils.GetClientIP(r), "rename_node", "node", req.IP, req.Name, "success")
+	h.logMgr.RecordLog(utils.GetClientIP(r), "rename_node", "node", nodeRef(req.ID, req.IP), req.Name, "success")
 	ws.BroadcastNodes(h.nodeMgr.GetAllNodes())
 
 	response.Success(w, nil)
@@ -134,6 +261,7 @@ func (h *ServerHandler) RenameNode(w http.ResponseWriter, r *http.Request) {
 // POST /api/nodes/reset_name
 func (h *ServerHandler) ResetNodeName(w http.ResponseWriter, r *http.Request) {
 	var req struct {
+		ID string `json:"id"` // 优先于 ip
 		IP string `json:"ip"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -141,68 +269,217 @@ func (h *ServerHandler) ResetNodeName(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.nodeMgr.ResetNodeName(req.IP); err != nil {
+	if err := h.nodeMgr.ResetNodeName(nodeRef(req.ID, req.IP)); err != nil {
 		response.Error(w, e.New(code.DatabaseError, "重置名称失败", err))
 		return
 	}
 
-	h.logMgr.RecordLog(utils.GetClientIP(r), "reset_node_name", "node", req.IP, "", "success")
+	h.logMgr.RecordLog(utils.GetClientIP(r), "reset_node_name", "node", nodeRef(req.ID, req.IP), "", "success")
 	ws.BroadcastNodes(h.nodeMgr.GetAllNodes())
 
 	response.Success(w, nil)
 }
 
+// cmdTrigger /api/ctrl/cmd 的请求 (命中审批规则时原样保存，审批通过后按其下发)
+type cmdTrigger struct {
+	TargetID string `json:"target_id"` // 优先于 target_ip
+	TargetIP string `json:"target_ip"`
+	Command  string `json:"command"`
+	Timeout  int    `json:"timeout"`
+	WorkDir  string `json:"work_dir"`
+	protocol.NodeTarget
+}
+
+// target 目标节点的简要描述
+func (t cmdTrigger) target() string {
+	if t.TargetID == "" && t.TargetIP == "" {
+		return describeTarget(t.NodeTarget)
+	}
+	return nodeRef(t.TargetID, t.TargetIP)
+}
+
 // TriggerCmd 下发 CMD 指令
 // POST /api/ctrl/cmd
+// 指定 target_id/target_ip 时在单个节点执行；否则按 node_ids/node_ips/group/selector 在匹配的在线节点上并发执行
+// timeout 为命令执行超时 (秒，默认 10)；需要实时输出的长命令使用 /api/exec/batch
+// 命令先经执行策略检查: 命中拒绝规则直接拒绝，命中审批规则时返回 approval_id，审批通过后再下发
 func (h *ServerHandler) TriggerCmd(w http.ResponseWriter, r *http.Request) {
-	type TriggerReq struct {
-		TargetIP string `json:"target_ip"`
-		Command  string `json:"command"`
+	user, ok := h.execUser(w, r)
+	if !ok {
+		return
 	}
 
-	var trigger TriggerReq
+	var trigger cmdTrigger
 	if err := json.NewDecoder(r.Body).Decode(&trigger); err != nil {
 		response.Error(w, e.New(code.InvalidJSON, "JSON解析失败", err))
 		return
 	}
+	if trigger.Timeout <= 0 {
+		trigger.Timeout = 10
+	}
 
-	node, exists := h.nodeMgr.GetNode(trigger.TargetIP)
-	if !exists {
-		response.Error(w, e.New(code.NodeNotFound, "节点不存在或离线", nil))
+	if !h.checkExecPolicy(w, user, approvalKindCmd, trigger.Command, trigger.target(), trigger) {
+		return
+	}
+	result, err := h.dispatchCmd(user.Name, trigger)
+	if err != nil {
+		response.Error(w, err)
 		return
 	}
+	response.Success(w, result)
+}
+
+// dispatchCmd 以 operator 的名义下发指令: 单节点同步返回输出，多节点返回任务 ID
+func (h *ServerHandler) dispatchCmd(operator string, trigger cmdTrigger) (map[string]string, error) {
+	cmdReq := protocol.CommandRequest{Command: trigger.Command, Timeout: trigger.Timeout, WorkDir: trigger.WorkDir}
+
+	if trigger.TargetID == "" && trigger.TargetIP == "" {
+		return h.dispatchBatchCmd(operator, trigger.NodeTarget, cmdReq)
+	}
+
+	node, exists := h.nodeMgr.GetNode(nodeRef(trigger.TargetID, trigger.TargetIP))
+	if !exists {
+		return nil, e.New(code.NodeNotFound, "节点不存在或离线", nil)
+	}
+
+	task, err := h.createTask(operator, "exec_cmd", trigger.Command, nodeItems([]protocol.NodeInfo{*node}), cmdTaskTimeout+cmdWait(cmdReq))
+	if err != nil {
+		return nil, err
+	}
+	item := task.Items[0]
+
+	h.taskMgr.StartItem(item.ID, "")
+	result, err := h.execOnNode(node, cmdReq, cmdWait(cmdReq))
+	if err != nil {
+		h.finishTaskItem(item.ID, "", err)
+		h.logMgr.RecordLog(operator, "exec_cmd", "node", node.IP, "Network Error", "fail")
+		return nil, e.New(code.NodeExecFailed, err.Error(), err)
+	}
+	h.finishTaskItem(item.ID, result["output"], cmdError(result))
+
+	// 记录日志
+	status := "success"
+	if result["error"] != "" {
+		status = "fail"
+	}
+	h.logMgr.RecordLog(operator, "exec_cmd", "node", node.IP, trigger.Command, status)
 
+	// 返回结果
+	result["task_id"] = task.ID
+	return result, nil
+}
+
+// cmdError Worker 返回的命令执行错误
+func cmdError(result map[string]string) error {
+	if result["error"] != "" {
+		return errors.New(result["error"])
+	}
+	return nil
+}
+
+// dispatchBatchCmd 创建任务后在匹配的节点上并发执行指令，立即返回任务 ID，各节点输出记录在子任务中
+func (h *ServerHandler) dispatchBatchCmd(operator string, target protocol.NodeTarget, cmdReq protocol.CommandRequest) (map[string]string, error) {
+	command := cmdReq.Command
+	nodes, err := h.nodeMgr.SelectNodes(target)
+	if err != nil {
+		return nil, e.New(code.ParamError, "目标节点条件无效", err)
+	}
+	if len(nodes) == 0 {
+		return nil, e.New(code.NodeNotFound, "没有匹配的节点", nil)
+	}
+
+	task, err := h.createTask(operator, "exec_cmd", command, nodeItems(nodes), cmdTaskTimeout+cmdWait(cmdReq))
+	if err != nil {
+		return nil, err
+	}
+
+	go func() {
+		var wg sync.WaitGroup
+		var failed atomic.Int32
+		for i := range nodes {
+			node, item := &nodes[i], task.Items[i]
+			if !nodeReachable(node) {
+				h.finishTaskItem(item.ID, "", errors.New("节点不在线"))
+				failed.Add(1)
+				continue
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				h.taskMgr.StartItem(item.ID, "")
+				out, err := h.execOnNode(node, cmdReq, cmdWait(cmdReq))
+				if err == nil {
+					err = cmdError(out)
+				}
+				h.finishTaskItem(item.ID, out["output"], err)
+				if err != nil {
+					failed.Add(1)
+				}
+			}()
+		}
+		wg.Wait()
+
+		status := "success"
+		if failed.Load() > 0 {
+			status = "fail"
+		}
+		detail := fmt.Sprintf("%s (Nodes: %d, Failed: %d, Task: %s)", command, len(nodes), failed.Load(), task.ID)
+		h.logMgr.RecordLog(operator, "batch_exec_cmd", "node", describeTarget(target), detail, status)
+	}()
+
+	return map[string]string{"task_id": task.ID}, nil
+}
+
+// cmdWait 等待 Worker 返回结果的时长: 命令超时之外留出网络往返的余量
+func cmdWait(req protocol.CommandRequest) time.Duration {
+	return time.Duration(req.Timeout)*time.Second + 5*time.Second
+}
+
+// execOnNode 请求 Worker 执行指令，timeout 为等待执行结果的时长
+// 附带 Master 以节点密钥签发的令牌，Worker 拒绝其他来源的执行请求
+func (h *ServerHandler) execOnNode(node *protocol.NodeInfo, workerReq protocol.CommandRequest, timeout time.Duration) (map[string]string, error) {
 	// 构造请求
-	workerReq := protocol.CommandRequest{Command: trigger.Command}
 	reqBody, _ := json.Marshal(workerReq)
 
-	// 拼接 URL: http://IP:Port/api/exec
-	targetURL := fmt.Sprintf("http://%s:%d/api/exec", node.IP, node.Port)
+	// 拼接 URL: http://IP:Port/api/exec (反向通道节点为 http://<ID>.tunnel/api/exec)
+	httpReq, err := http.NewRequest(http.MethodPost, manager.WorkerURL(node, "/api/exec"), bytes.NewReader(reqBody))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set(nodeauth.Header, h.nodeMgr.WorkerToken(node.ID, "/api/exec"))
 
 	// 使用 HTTP Client 请求 Worker
-	client := &http.Client{Timeout: 10 * time.Second} // 执行命令可能稍慢
-	resp, err := client.Post(targetURL, "application/json", bytes.NewBuffer(reqBody))
+	client := utils.NewClient(timeout) // 执行命令可能稍慢
+	resp, err := client.Do(httpReq)
 	if err != nil {
-		h.logMgr.RecordLog(utils.GetClientIP(r), "exec_cmd", "node", trigger.TargetIP, "Network Error", "fail")
-		response.Error(w, e.New(code.NodeExecFailed, fmt.Sprintf("连接Worker失败: %v", err), err))
-		return
+		return nil, fmt.Errorf("连接Worker失败: %v", err)
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("Worker 返回 %d: %s", resp.StatusCode, bytes.TrimSpace(msg))
+	}
 
 	// 解析 Worker 响应
 	var result map[string]string
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		response.Error(w, e.New(code.NodeExecFailed, "解析Worker响应失败", err))
-		return
+		return nil, fmt.Errorf("解析Worker响应失败: %v", err)
 	}
+	return result, nil
+}
 
-	// 记录日志
-	status := "success"
-	if result["error"] != "" {
-		status = "fail"
+// describeTarget 目标节点条件的简要描述 (用于操作日志)
+func describeTarget(t protocol.NodeTarget) string {
+	var parts []string
+	if len(t.NodeIDs)+len(t.NodeIPs) > 0 {
+		parts = append(parts, fmt.Sprintf("nodes=%d", len(t.NodeIDs)+len(t.NodeIPs)))
 	}
-	h.logMgr.RecordLog(utils.GetClientIP(r), "exec_cmd", "node", trigger.TargetIP, trigger.Command, status)
-
-	// 返回结果
-	response.Success(w, result)
+	if t.Group != "" {
+		parts = append(parts, "group="+t.Group)
+	}
+	if t.Selector != "" {
+		parts = append(parts, "selector="+t.Selector)
+	}
+	return strings.Join(parts, " ")
 }
@@ -0,0 +1,196 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"ops-system/internal/master/ws"
+	"ops-system/pkg/code"
+	"ops-system/pkg/e"
+	"ops-system/pkg/protocol"
+	"ops-system/pkg/response"
+	"ops-system/pkg/utils"
+)
+
+// nodeReachable 节点是否可下发指令 (维护中的节点仍在线，可执行运维操作)
+func nodeReachable(node *protocol.NodeInfo) bool {
+	return node.Status == "online" || node.Status == "maintenance"
+}
+
+// nodeInstances 节点上的全部实例
+func (h *ServerHandler) nodeInstances(node *protocol.NodeInfo) []protocol.InstanceInfo {
+	var list []protocol.InstanceInfo
+	for _, inst := range h.instMgr.GetAllInstancesMetrics() {
+		if inst.NodeID == node.ID || (inst.NodeID == "" && inst.NodeIP == node.IP) {
+			list = append(list, inst)
+		}
+	}
+	return list
+}
+
+// maintenanceResult 排空/恢复时单个实例的处理结果
+type maintenanceResult struct {
+	InstanceID  string `json:"instance_id"`
+	ServiceName string `json:"service_name,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+// CordonNode 封锁节点 (不再接受新部署，告警跳过该节点)
+// POST /api/nodes/cordon
+func (h *ServerHandler) CordonNode(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ID string `json:"id"` // 优先于 ip
+		IP string `json:"ip"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, e.New(code.InvalidJSON, "JSON解析失败", err))
+		return
+	}
+
+	ref := nodeRef(req.ID, req.IP)
+	if _, err := h.nodeMgr.Cordon(ref); err != nil {
+		response.Error(w, e.New(code.NodeNotFound, "封锁节点失败", err))
+		return
+	}
+
+	h.logMgr.RecordLog(utils.GetClientIP(r), "cordon_node", "node", ref, "", "success")
+	ws.BroadcastNodes(h.nodeMgr.GetAllNodes())
+	response.Success(w, nil)
+}
+
+// DrainNode 排空节点: 封锁后执行可选的停止前钩子，再停止节点上所有运行中的实例
+// 被停止的实例会被记录，解除封锁时自动恢复
+// POST /api/nodes/drain
+func (h *ServerHandler) DrainNode(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ID          string `json:"id"` // 优先于 ip
+		IP          string `json:"ip"`
+		PreStop     string `json:"pre_stop"`     // 停止实例前在节点上执行的指令
+		HookTimeout int    `json:"hook_timeout"` // 钩子超时 (秒)，默认 60
+		Force       bool   `json:"force"`        // 钩子失败时仍继续排空
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, e.New(code.InvalidJSON, "JSON解析失败", err))
+		return
+	}
+	if req.HookTimeout <= 0 {
+		req.HookTimeout = 60
+	}
+
+	ref := nodeRef(req.ID, req.IP)
+	id, err := h.nodeMgr.Cordon(ref)
+	if err != nil {
+		response.Error(w, e.New(code.NodeNotFound, "封锁节点失败", err))
+		return
+	}
+	node, ok := h.nodeMgr.GetNode(id)
+	if !ok {
+		response.Error(w, e.New(code.NodeNotFound, "节点不存在", nil))
+		return
+	}
+	ws.BroadcastNodes(h.nodeMgr.GetAllNodes())
+	clientIP := utils.GetClientIP(r)
+
+	// 1. 停止前钩子 (节点已封锁，钩子失败时保持封锁状态，便于人工处理)
+	if strings.TrimSpace(req.PreStop) != "" {
+		hookErr := func() error {
+			if !nodeReachable(node) {
+				return fmt.Errorf("节点不在线")
+			}
+			out, err := h.execOnNode(node, protocol.CommandRequest{Command: req.PreStop, Timeout: req.HookTimeout},
+				time.Duration(req.HookTimeout)*time.Second)
+			if err != nil {
+				return err
+			}
+			if out["error"] != "" {
+				return fmt.Errorf("%s", out["error"])
+			}
+			return nil
+		}()
+		if hookErr != nil && !req.Force {
+			h.logMgr.RecordLog(clientIP, "drain_node", "node", node.IP, "PreStop Failed: "+hookErr.Error(), "fail")
+			response.Error(w, e.New(code.NodeExecFailed, fmt.Sprintf("停止前钩子执行失败: %v", hookErr), hookErr))
+			return
+		}
+	}
+
+	// 2. 停止运行中的实例，记录成功停止的实例
+	results := []maintenanceResult{}
+	var stopped []string
+	for _, inst := range h.nodeInstances(node) {
+		if inst.Status != "running" {
+			continue
+		}
+		res := maintenanceResult{InstanceID: inst.ID, ServiceName: inst.ServiceName}
+		if err := h.sendInstanceCommand(&inst, "stop"); err != nil {
+			res.Error = err.Error()
+		} else {
+			stopped = append(stopped, inst.ID)
+		}
+		results = append(results, res)
+	}
+	if err := h.nodeMgr.RecordDrained(id, stopped); err != nil {
+		response.Error(w, e.New(code.DatabaseError, "记录排空实例失败", err))
+		return
+	}
+
+	status := "success"
+	if len(stopped) < len(results) {
+		status = "fail"
+	}
+	detail := fmt.Sprintf("Stopped: %d, Failed: %d", len(stopped), len(results)-len(stopped))
+	h.logMgr.RecordLog(clientIP, "drain_node", "node", node.IP, detail, status)
+	ws.BroadcastNodes(h.nodeMgr.GetAllNodes())
+	h.broadcastUpdate()
+
+	response.Success(w, results)
+}
+
+// UncordonNode 解除封锁，并启动排空时停止的实例 (已删除或已在运行的实例跳过)
+// POST /api/nodes/uncordon
+func (h *ServerHandler) UncordonNode(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ID string `json:"id"` // 优先于 ip
+		IP string `json:"ip"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, e.New(code.InvalidJSON, "JSON解析失败", err))
+		return
+	}
+
+	ref := nodeRef(req.ID, req.IP)
+	_, drained, err := h.nodeMgr.Uncordon(ref)
+	if err != nil {
+		response.Error(w, e.New(code.NodeNotFound, "解除封锁失败", err))
+		return
+	}
+
+	results := []maintenanceResult{}
+	failed := 0
+	for _, instID := range drained {
+		inst, ok := h.instMgr.GetInstance(instID)
+		if !ok || inst.Status == "running" {
+			continue
+		}
+		res := maintenanceResult{InstanceID: inst.ID, ServiceName: inst.ServiceName}
+		if err := h.sendInstanceCommand(inst, "start"); err != nil {
+			res.Error = err.Error()
+			failed++
+		}
+		results = append(results, res)
+	}
+
+	status := "success"
+	if failed > 0 {
+		status = "fail"
+	}
+	detail := fmt.Sprintf("Restored: %d, Failed: %d", len(results)-failed, failed)
+	h.logMgr.RecordLog(utils.GetClientIP(r), "uncordon_node", "node", ref, detail, status)
+	ws.BroadcastNodes(h.nodeMgr.GetAllNodes())
+	h.broadcastUpdate()
+
+	response.Success(w, results)
+}
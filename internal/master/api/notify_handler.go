@@ -0,0 +1,204 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"ops-system/internal/master/notify"
+	"ops-system/pkg/code"
+	"ops-system/pkg/e"
+	"ops-system/pkg/protocol"
+	"ops-system/pkg/response"
+	"ops-system/pkg/utils"
+)
+
+// ListChannels 获取所有通知渠道
+// GET /api/alerts/channels
+func (h *ServerHandler) ListChannels(w http.ResponseWriter, r *http.Request) {
+	list, err := h.notifyMgr.GetChannels()
+	if err != nil {
+		response.Error(w, e.New(code.DatabaseError, "获取通知渠道失败", err))
+		return
+	}
+	// 处于安全考虑，不返回密码、加签密钥与请求头 (通常含 Bearer Token)
+	for _, ch := range list {
+		maskChannelSecrets(&ch.Config)
+	}
+	response.Success(w, list)
+}
+
+// AddChannel 添加通知渠道
+// POST /api/alerts/channels/add
+func (h *ServerHandler) AddChannel(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		response.Error(w, e.New(code.MethodNotAllowed, "Method not allowed", nil))
+		return
+	}
+
+	var ch protocol.NotifyChannel
+	if err := json.NewDecoder(r.Body).Decode(&ch); err != nil {
+		response.Error(w, e.New(code.InvalidJSON, "JSON解析失败", err))
+		return
+	}
+	if !validChannelType(ch.Type) {
+		response.Error(w, e.New(code.ParamError, "不支持的渠道类型: "+ch.Type, nil))
+		return
+	}
+
+	if err := h.notifyMgr.AddChannel(ch); err != nil {
+		response.Error(w, e.New(code.DatabaseError, "添加通知渠道失败", err))
+		return
+	}
+
+	h.logMgr.RecordLog(utils.GetClientIP(r), "add_notify_channel", "alert", ch.Name, ch.Type, "success")
+	response.Success(w, nil)
+}
+
+// UpdateChannel 修改通知渠道
+// POST /api/alerts/channels/update
+func (h *ServerHandler) UpdateChannel(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		response.Error(w, e.New(code.MethodNotAllowed, "Method not allowed", nil))
+		return
+	}
+
+	var ch protocol.NotifyChannel
+	if err := json.NewDecoder(r.Body).Decode(&ch); err != nil {
+		response.Error(w, e.New(code.InvalidJSON, "JSON解析失败", err))
+		return
+	}
+	if !validChannelType(ch.Type) {
+		response.Error(w, e.New(code.ParamError, "不支持的渠道类型: "+ch.Type, nil))
+		return
+	}
+
+	// 前端回传的是掩码，保留原值
+	if old, err := h.notifyMgr.GetChannel(ch.ID); err == nil {
+		keepMaskedSecrets(&ch.Config, &old.Config)
+	}
+
+	if err := h.notifyMgr.UpdateChannel(ch); err != nil {
+		response.Error(w, e.New(code.DatabaseError, "修改通知渠道失败", err))
+		return
+	}
+
+	h.logMgr.RecordLog(utils.GetClientIP(r), "update_notify_channel", "alert", ch.Name, ch.Type, "success")
+	response.Success(w, nil)
+}
+
+// DeleteChannel 删除通知渠道
+// GET /api/alerts/channels/delete?id=...
+func (h *ServerHandler) DeleteChannel(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+	if err != nil {
+		response.Error(w, e.New(code.ParamError, "无效的渠道ID", err))
+		return
+	}
+
+	if err := h.notifyMgr.DeleteChannel(id); err != nil {
+		response.Error(w, e.New(code.DatabaseError, "删除通知渠道失败", err))
+		return
+	}
+
+	h.logMgr.RecordLog(utils.GetClientIP(r), "delete_notify_channel", "alert", strconv.FormatInt(id, 10), "", "success")
+	response.Success(w, nil)
+}
+
+// TestChannel 发送测试消息
+// POST /api/alerts/channels/test?id=...
+func (h *ServerHandler) TestChannel(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+	if err != nil {
+		response.Error(w, e.New(code.ParamError, "无效的渠道ID", err))
+		return
+	}
+
+	if err := h.notifyMgr.TestChannel(id); err != nil {
+		response.Error(w, e.New(code.NotifyError, "测试消息发送失败: "+err.Error(), err))
+		return
+	}
+	response.Success(w, nil)
+}
+
+// GetNotifyLogs 获取通知投递记录
+// GET /api/alerts/notify_logs?limit=100
+func (h *ServerHandler) GetNotifyLogs(w http.ResponseWriter, r *http.Request) {
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit <= 0 {
+		limit = 100
+	}
+
+	logs, err := h.notifyMgr.GetLogs(limit)
+	if err != nil {
+		response.Error(w, e.New(code.DatabaseError, "获取投递记录失败", err))
+		return
+	}
+	response.Success(w, logs)
+}
+
+// SetRuleChannels 设置规则的通知渠道
+// POST /api/alerts/rules/channels
+func (h *ServerHandler) SetRuleChannels(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		response.Error(w, e.New(code.MethodNotAllowed, "Method not allowed", nil))
+		return
+	}
+
+	var req struct {
+		ID         int64   `json:"id"`
+		ChannelIDs []int64 `json:"channel_ids"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, e.New(code.InvalidJSON, "JSON解析失败", err))
+		return
+	}
+
+	if err := h.alertMgr.SetRuleChannels(req.ID, req.ChannelIDs); err != nil {
+		response.Error(w, e.New(code.AlertRuleError, "设置通知渠道失败", err))
+		return
+	}
+	response.Success(w, nil)
+}
+
+const maskedPassword = "******"
+
+// maskChannelSecrets 将渠道配置中的敏感字段替换为掩码
+func maskChannelSecrets(cfg *protocol.NotifyChannelConfig) {
+	if cfg.Password != "" {
+		cfg.Password = maskedPassword
+	}
+	if cfg.Secret != "" {
+		cfg.Secret = maskedPassword
+	}
+	if len(cfg.Headers) > 0 {
+		masked := make(map[string]string, len(cfg.Headers))
+		for k := range cfg.Headers {
+			masked[k] = maskedPassword
+		}
+		cfg.Headers = masked
+	}
+}
+
+// keepMaskedSecrets 修改渠道时，回传掩码的字段沿用原值
+func keepMaskedSecrets(cfg, old *protocol.NotifyChannelConfig) {
+	if cfg.Password == maskedPassword {
+		cfg.Password = old.Password
+	}
+	if cfg.Secret == maskedPassword {
+		cfg.Secret = old.Secret
+	}
+	for k, v := range cfg.Headers {
+		if v == maskedPassword {
+			cfg.Headers[k] = old.Headers[k]
+		}
+	}
+}
+
+func validChannelType(t string) bool {
+	switch t {
+	case notify.TypeWebhook, notify.TypeEmail, notify.TypeDingTalk, notify.TypeWeCom, notify.TypeFeishu, notify.TypeSlack:
+		return true
+	}
+	return false
+}
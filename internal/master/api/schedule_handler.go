@@ -0,0 +1,141 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	"ops-system/pkg/code"
+	"ops-system/pkg/e"
+	"ops-system/pkg/protocol"
+	"ops-system/pkg/response"
+	"ops-system/pkg/utils"
+)
+
+// UpdateModulePlacement 更新模块的副本数与调度约束
+// POST /api/systems/module/placement
+func (h *ServerHandler) UpdateModulePlacement(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ID        string                   `json:"id"`
+		Replicas  int                      `json:"replicas"`
+		Placement protocol.PlacementPolicy `json:"placement"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, e.New(code.InvalidJSON, "JSON解析失败", err))
+		return
+	}
+	if err := h.sysMgr.SetModulePlacement(req.ID, req.Replicas, req.Placement); err != nil {
+		response.Error(w, e.New(code.ParamError, "更新调度约束失败", err))
+		return
+	}
+
+	detail := fmt.Sprintf("Replicas: %d", req.Replicas)
+	h.logMgr.RecordLog(utils.GetClientIP(r), "update_module_placement", "module", req.ID, detail, "success")
+	h.broadcastUpdate()
+
+	response.Success(w, nil)
+}
+
+// ScheduleModule 按模块的副本数与调度约束选择节点并创建缺少的副本
+// dry_run 时只返回调度计划
+// POST /api/systems/module/schedule
+func (h *ServerHandler) ScheduleModule(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ModuleID string `json:"module_id"`
+		DryRun   bool   `json:"dry_run"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, e.New(code.InvalidJSON, "JSON解析失败", err))
+		return
+	}
+
+	plan, err := h.scheduler.Plan(req.ModuleID)
+	if err != nil {
+		response.Error(w, e.New(code.ParamError, "生成调度计划失败", err))
+		return
+	}
+	if req.DryRun {
+		response.Success(w, map[string]interface{}{"plan": plan})
+		return
+	}
+
+	batch, err := h.applyPlan(r, plan)
+	if err != nil {
+		if _, ok := err.(*e.CodeError); !ok {
+			err = e.New(code.PackageNotFound, "生成下载链接失败", err)
+		}
+		response.Error(w, err)
+		return
+	}
+	response.Success(w, map[string]interface{}{"plan": plan, "task_id": batch.TaskID, "results": batch.Results})
+}
+
+// applyPlan 按调度计划在各节点创建实例
+func (h *ServerHandler) applyPlan(r *http.Request, plan *protocol.PlacementPlan) (*deployBatchResult, error) {
+	batch := &deployBatchResult{Results: []deployResult{}}
+	if len(plan.Nodes) == 0 {
+		return batch, nil
+	}
+	downloadURL, err := h.pkgMgr.GetDownloadURL(plan.ServiceName, plan.Version, r.Host)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]protocol.TaskItem, len(plan.Nodes))
+	for i, pn := range plan.Nodes {
+		items[i] = protocol.TaskItem{NodeID: pn.NodeID, NodeIP: pn.NodeIP}
+	}
+	task, err := h.newTask(r, "deploy", plan.ServiceName+"@"+plan.Version, items, deployTaskTimeout)
+	if err != nil {
+		return nil, err
+	}
+	batch.TaskID = task.ID
+
+	failed := 0
+	for i, pn := range plan.Nodes {
+		res := deployResult{NodeID: pn.NodeID, NodeIP: pn.NodeIP}
+		if node, ok := h.nodeMgr.GetNode(pn.NodeID); !ok {
+			res.Error = "节点不存在"
+			h.taskMgr.FinishItem(task.Items[i].ID, protocol.TaskFailed, "", res.Error)
+		} else if id, err := h.deployToNode(r, node, task.Items[i], plan.SystemID, plan.ServiceName, plan.Version, downloadURL); err != nil {
+			res.Error = err.Error()
+		} else {
+			res.InstanceID = id
+		}
+		if res.Error != "" {
+			failed++
+		}
+		batch.Results = append(batch.Results, res)
+	}
+
+	status := "success"
+	if failed > 0 || plan.Unplaced > 0 {
+		status = "fail"
+	}
+	detail := fmt.Sprintf("Replicas: %d/%d, Placed: %d, Failed: %d, Unplaced: %d",
+		plan.Current, plan.Replicas, len(batch.Results)-failed, failed, plan.Unplaced)
+	h.logMgr.RecordLog(utils.GetClientIP(r), "schedule_module", "module", plan.ModuleID, detail, status)
+	return batch, nil
+}
+
+// replaceNodeReplicas 节点删除后，将其上调度模块的副本重新放置到其他节点
+func (h *ServerHandler) replaceNodeReplicas(r *http.Request, node *protocol.NodeInfo) {
+	modIDs, err := h.scheduler.EvictNode(node)
+	if err != nil {
+		log.Printf("[Scheduler] evict node %s failed: %v", node.ID, err)
+		return
+	}
+	for _, modID := range modIDs {
+		plan, err := h.scheduler.Plan(modID)
+		if err == nil {
+			_, err = h.applyPlan(r, plan)
+		}
+		if err != nil {
+			log.Printf("[Scheduler] re-place module %s failed: %v", modID, err)
+		}
+	}
+	if len(modIDs) > 0 {
+		h.broadcastUpdate()
+	}
+}
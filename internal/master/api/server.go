@@ -5,13 +5,18 @@ import (
 	"io/fs"
 	"log"
 	"net/http"
+	"path/filepath"
+	"time"
 
 	"ops-system/internal/master/db"
+	"ops-system/internal/master/logstore"
 	"ops-system/internal/master/manager"
 	"ops-system/internal/master/monitor"
 	"ops-system/internal/master/ws"
 	"ops-system/pkg/config"
+	"ops-system/pkg/policy"
 	"ops-system/pkg/storage"
+	"ops-system/pkg/utils"
 )
 
 // 定义配置结构体
@@ -63,14 +68,48 @@ func StartMasterServer(cfg *config.MasterConfig, assets fs.FS) error {
 	// 注意顺序：底层依赖先初始化
 	logMgr := manager.NewLogManager(database)
 	sysMgr := manager.NewSystemManager(database)
-	instMgr := manager.NewInstanceManager(database)
+	instMgr := manager.NewInstanceManager(database, monitorStore)
 	nodeMgr := manager.NewNodeManager(database, monitorStore, cfg.Logic.NodeOfflineThreshold)
+	// 访问 Worker 的请求统一走 utils.Transport，<节点ID>.tunnel 地址转入反向通道
+	utils.Transport.DialContext = nodeMgr.Tunnels().DialContext(utils.Transport.DialContext)
 	pkgMgr := manager.NewPackageManager(storeProvider)
 	configMgr := manager.NewConfigManager(database)
 	backupMgr := manager.NewBackupManager(database, cfg.Storage.UploadDir)
 
-	// AlertManager 依赖 DB, NodeManager, InstanceManager
-	alertMgr := manager.NewAlertManager(database, nodeMgr, instMgr)
+	// AlertManager 依赖 DB, NodeManager, InstanceManager, NotifyManager
+	notifyMgr := manager.NewNotifyManager(database)
+	alertMgr := manager.NewAlertManager(database, nodeMgr, instMgr, notifyMgr, monitorStore)
+
+	// 集中日志存储 (Worker 开启 log_ship 后推送)
+	logStore, err := logstore.New(cfg.LogStore.Dir, time.Duration(cfg.LogStore.RetentionDays)*24*time.Hour)
+	if err != nil {
+		return fmt.Errorf("init log store failed: %v", err)
+	}
+	go logStore.StartPruner(time.Hour)
+	logShipMgr := manager.NewLogShipManager(database, logStore)
+
+	// 配置下发依赖 系统/实例/节点/配置中心
+	configPushMgr := manager.NewConfigPushManager(sysMgr, instMgr, nodeMgr, configMgr, logMgr)
+
+	// 副本调度依赖 系统/节点/实例
+	scheduler := manager.NewScheduler(sysMgr, nodeMgr, instMgr)
+
+	// 异步任务记录 (超时的部署会将实例置为 error)
+	taskMgr := manager.NewTaskManager(database, instMgr)
+
+	// 用户与角色 (Web 终端仅 admin 可用，会话全程录像)
+	userMgr := manager.NewUserManager(cfg.Security.Users)
+	recordDir := cfg.Terminal.RecordDir
+	if recordDir == "" {
+		recordDir = filepath.Join(filepath.Dir(cfg.Server.DBPath), "recordings")
+	}
+	terminalMgr := manager.NewTerminalManager(database, recordDir, cfg.Terminal.IdleTimeout)
+
+	// 远程命令执行策略 (规则无效时拒绝启动，避免策略静默失效)
+	execPolicy, err := policy.New(cfg.Security.ExecPolicy)
+	if err != nil {
+		return fmt.Errorf("init exec policy failed: %v", err)
+	}
 
 	// 5. 初始化全局 Handler 容器
 	// 将所有 Manager 注入到 Handler 中，彻底消除全局变量
@@ -82,9 +121,20 @@ func StartMasterServer(cfg *config.MasterConfig, assets fs.FS) error {
 		pkgMgr,
 		configMgr,
 		alertMgr,
+		notifyMgr,
 		backupMgr,
 		monitorStore,
+		logShipMgr,
+		configPushMgr,
+		scheduler,
+		taskMgr,
+		manager.NewExecHub(), // 批量命令的实时输出
+		userMgr,
+		terminalMgr,
+		execPolicy,
+		manager.NewApprovalManager(database), // 命中审批规则的命令
 	)
+	go taskMgr.StartSweeper(30*time.Second, serverHandler.broadcastUpdate)
 
 	// 6. 启动 WebSocket Hub
 	go ws.GlobalHub.Run()
@@ -110,11 +160,20 @@ func StartMasterServer(cfg *config.MasterConfig, assets fs.FS) error {
 func registerRoutes(mux *http.ServeMux, h *ServerHandler, uploadPath string, assets fs.FS) {
 	// --- Node 相关 (node_handler.go) ---
 	mux.HandleFunc("/api/worker/heartbeat", h.HandleHeartbeat)
+	mux.HandleFunc("/api/worker/tunnel", h.WorkerTunnel(mux))
 	mux.HandleFunc("/api/nodes", h.ListNodes)
 	mux.HandleFunc("/api/nodes/add", h.AddNode)
 	mux.HandleFunc("/api/nodes/delete", h.DeleteNode)
 	mux.HandleFunc("/api/nodes/rename", h.RenameNode)
 	mux.HandleFunc("/api/nodes/reset_name", h.ResetNodeName)
+	mux.HandleFunc("/api/nodes/labels", h.SetNodeLabels)
+	mux.HandleFunc("/api/nodes/groups", h.ListNodeGroups)
+	mux.HandleFunc("/api/nodes/groups/save", h.SaveNodeGroup)
+	mux.HandleFunc("/api/nodes/groups/delete", h.DeleteNodeGroup)
+	mux.HandleFunc("/api/nodes/cordon", h.CordonNode)
+	mux.HandleFunc("/api/nodes/drain", h.DrainNode)
+	mux.HandleFunc("/api/nodes/uncordon", h.UncordonNode)
+	mux.HandleFunc("/api/nodes/upgrade", h.UpgradeAgents)
 	mux.HandleFunc("/api/ctrl/cmd", h.TriggerCmd)
 
 	// --- System 配置相关 (system_handler.go) ---
@@ -123,13 +182,36 @@ func registerRoutes(mux *http.ServeMux, h *ServerHandler, uploadPath string, ass
 	mux.HandleFunc("/api/systems/delete", h.DeleteSystem)
 	mux.HandleFunc("/api/systems/module/add", h.CreateSystemModule)
 	mux.HandleFunc("/api/systems/module/delete", h.DeleteSystemModule)
+	mux.HandleFunc("/api/systems/module/placement", h.UpdateModulePlacement)
+	mux.HandleFunc("/api/systems/module/schedule", h.ScheduleModule)
+	mux.HandleFunc("/api/systems/module/bindings", h.UpdateModuleBindings)
 
 	// --- Instance 运行相关 (instance_handler.go) ---
 	mux.HandleFunc("/api/deploy", h.DeployInstance)
 	mux.HandleFunc("/api/deploy/external", h.RegisterExternal) // 纳管
 	mux.HandleFunc("/api/instance/action", h.InstanceAction)
 	mux.HandleFunc("/api/instance/status_report", h.WorkerStatusReport)
-	mux.HandleFunc("/api/systems/action", h.SystemAction) // 批量操作
+	mux.HandleFunc("/api/instance/config/push", h.PushInstanceConfig) // 重新下发绑定配置
+	mux.HandleFunc("/api/systems/action", h.SystemAction)             // 批量操作
+
+	// --- 异步任务 (task_handler.go) ---
+	mux.HandleFunc("/api/tasks", h.ListTasks)
+	mux.HandleFunc("/api/tasks/detail", h.GetTask)
+	mux.HandleFunc("/api/tasks/report", h.TaskReport) // Worker 上报进度
+
+	// --- 批量命令 (exec_handler.go) ---
+	mux.HandleFunc("/api/exec/batch", h.BatchExec)
+	mux.HandleFunc("/api/exec/stream", h.ExecStream) // WebSocket 实时输出
+
+	// --- 执行策略与审批 (approval_handler.go) ---
+	mux.HandleFunc("/api/exec/policy", h.GetExecPolicy) // Worker 同步拒绝规则
+	mux.HandleFunc("/api/exec/approvals", h.ListExecApprovals)
+	mux.HandleFunc("/api/exec/approvals/decide", h.DecideExecApproval)
+
+	// --- Web 终端 (terminal_handler.go，仅 admin) ---
+	mux.HandleFunc("/api/terminal", h.OpenTerminal) // WebSocket
+	mux.HandleFunc("/api/terminal/sessions", h.ListTerminalSessions)
+	mux.HandleFunc("/api/terminal/recording", h.GetTerminalRecording)
 
 	// --- Package 相关 (package_handler.go) ---
 	mux.HandleFunc("/api/upload", h.UploadPackage)
@@ -137,18 +219,34 @@ func registerRoutes(mux *http.ServeMux, h *ServerHandler, uploadPath string, ass
 	mux.HandleFunc("/api/packages/delete", h.DeletePackage)
 	mux.HandleFunc("/api/packages/manifest", h.GetPackageManifest)
 
+	// --- Worker 程序与自升级 (agent_handler.go) ---
+	mux.HandleFunc("/api/agent/upload", h.UploadAgentBinary)
+	mux.HandleFunc("/api/agent/binaries", h.ListAgentBinaries)
+	mux.HandleFunc("/api/agent/delete", h.DeleteAgentBinary)
+
 	// --- Log 相关 (log_handler.go) ---
 	mux.HandleFunc("/api/logs", h.GetOpLogs)
+	mux.HandleFunc("/api/logs/search", h.SearchLogs)
+	mux.HandleFunc("/api/logs/ship", h.ShipLogs) // Worker 推送集中日志
+	mux.HandleFunc("/api/logs/central/tail", h.GetCentralLogTail)
 	mux.HandleFunc("/api/instance/logs/files", h.GetInstanceLogFiles)
 	mux.HandleFunc("/api/instance/logs/stream", h.InstanceLogStream)
+	mux.HandleFunc("/api/instance/logs/page", h.GetInstanceLogPage)
+	mux.HandleFunc("/api/instance/logs/download", h.DownloadInstanceLog)
 
-	// --- Config Center (Nacos) 相关 (config_handler.go) ---
+	// --- Config Center (Nacos / 内置存储) 相关 (config_handler.go) ---
 	mux.HandleFunc("/api/nacos/settings", h.NacosSettings)
 	mux.HandleFunc("/api/nacos/namespaces", h.NacosNamespaces)
 	mux.HandleFunc("/api/nacos/configs", h.NacosConfigs)
 	mux.HandleFunc("/api/nacos/config/detail", h.NacosConfigDetail)
 	mux.HandleFunc("/api/nacos/config/publish", h.NacosPublish)
 	mux.HandleFunc("/api/nacos/config/delete", h.NacosDelete)
+	mux.HandleFunc("/api/nacos/namespace/create", h.NacosNamespaceCreate)
+	mux.HandleFunc("/api/nacos/namespace/delete", h.NacosNamespaceDelete)
+	mux.HandleFunc("/api/nacos/config/history", h.ConfigHistory)
+	mux.HandleFunc("/api/nacos/config/version", h.ConfigVersion)
+	mux.HandleFunc("/api/nacos/config/diff", h.ConfigDiff)
+	mux.HandleFunc("/api/nacos/config/rollback", h.ConfigRollback)
 
 	// --- Backup 相关 (backup_handler.go) ---
 	mux.HandleFunc("/api/backups", h.ListBackups)
@@ -162,10 +260,28 @@ func registerRoutes(mux *http.ServeMux, h *ServerHandler, uploadPath string, ass
 	// --- Alert 相关 (alert_handler.go) ---
 	mux.HandleFunc("/api/alerts/rules", h.ListRules)
 	mux.HandleFunc("/api/alerts/rules/add", h.AddRule)
+	mux.HandleFunc("/api/alerts/rules/update", h.UpdateRule)
+	mux.HandleFunc("/api/alerts/rules/enable", h.EnableRule)
 	mux.HandleFunc("/api/alerts/rules/delete", h.DeleteRule)
 	mux.HandleFunc("/api/alerts/events", h.GetAlerts)
 	mux.HandleFunc("/api/alerts/events/delete", h.DeleteEvent)
 	mux.HandleFunc("/api/alerts/events/clear", h.ClearEvents)
+	mux.HandleFunc("/api/alerts/rules/channels", h.SetRuleChannels)
+	mux.HandleFunc("/api/alerts/channels", h.ListChannels)
+	mux.HandleFunc("/api/alerts/channels/add", h.AddChannel)
+	mux.HandleFunc("/api/alerts/channels/update", h.UpdateChannel)
+	mux.HandleFunc("/api/alerts/channels/delete", h.DeleteChannel)
+	mux.HandleFunc("/api/alerts/channels/test", h.TestChannel)
+	mux.HandleFunc("/api/alerts/notify_logs", h.GetNotifyLogs)
+	mux.HandleFunc("/api/alerts/events/ack", h.AckEvent)
+	mux.HandleFunc("/api/alerts/silences", h.ListSilences)
+	mux.HandleFunc("/api/alerts/silences/add", h.AddSilence)
+	mux.HandleFunc("/api/alerts/silences/delete", h.DeleteSilence)
+	mux.HandleFunc("/api/alerts/maintenance", h.ListMaintenanceWindows)
+	mux.HandleFunc("/api/alerts/maintenance/add", h.AddMaintenanceWindow)
+	mux.HandleFunc("/api/alerts/maintenance/delete", h.DeleteMaintenanceWindow)
+	mux.HandleFunc("/api/alerts/log_rules", h.GetLogWatchRules)
+	mux.HandleFunc("/api/alerts/log_report", h.ReportLogMatches)
 
 	// --- WebSocket ---
 	mux.HandleFunc("/api/ws", ws.HandleWebsocket)
@@ -6,6 +6,7 @@ import (
 	"net/http"
 
 	"ops-system/internal/master/ws"
+	"ops-system/pkg/protocol"
 
 	"ops-system/pkg/response"
 	"ops-system/pkg/utils"
@@ -91,6 +92,29 @@ func (h *ServerHandler) CreateSystemModule(w http.ResponseWriter, r *http.Reques
 	response.Success(w, nil)
 }
 
+// UpdateModuleBindings 更新模块的配置绑定 (整体替换)
+// POST /api/systems/module/bindings
+func (h *ServerHandler) UpdateModuleBindings(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ID             string                   `json:"id"`
+		ConfigBindings []protocol.ConfigBinding `json:"config_bindings"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.Error(w, err)
+		return
+	}
+	if err := h.sysMgr.SetModuleBindings(req.ID, req.ConfigBindings); err != nil {
+		response.Error(w, err)
+		return
+	}
+
+	detail := fmt.Sprintf("Bindings: %d", len(req.ConfigBindings))
+	h.logMgr.RecordLog(utils.GetClientIP(r), "update_module_bindings", "module", req.ID, detail, "success")
+	h.broadcastUpdate()
+
+	response.Success(w, nil)
+}
+
 // handleDeleteSystemModule 删除服务定义
 func (h *ServerHandler) DeleteSystemModule(w http.ResponseWriter, r *http.Request) {
 	var req struct {
@@ -28,8 +28,8 @@ func setupHandler(t *testing.T) (*api.ServerHandler, *sql.DB) {
 	// 建表
 	sqls := []string{
 		`CREATE TABLE IF NOT EXISTS system_infos (id TEXT PRIMARY KEY, name TEXT, description TEXT, create_time INTEGER);`,
-		`CREATE TABLE IF NOT EXISTS system_modules (id TEXT PRIMARY KEY, system_id TEXT, module_name TEXT, package_name TEXT, package_version TEXT, description TEXT);`,
-		`CREATE TABLE IF NOT EXISTS instance_infos (id TEXT PRIMARY KEY, system_id TEXT, node_ip TEXT, service_name TEXT, service_version TEXT, status TEXT, pid INTEGER, uptime INTEGER);`,
+		`CREATE TABLE IF NOT EXISTS system_modules (id TEXT PRIMARY KEY, system_id TEXT, module_name TEXT, package_name TEXT, package_version TEXT, description TEXT, config_bindings TEXT DEFAULT '[]', replicas INTEGER DEFAULT 0, placement TEXT DEFAULT '{}');`,
+		`CREATE TABLE IF NOT EXISTS instance_infos (id TEXT PRIMARY KEY, system_id TEXT, node_id TEXT DEFAULT '', node_ip TEXT, service_name TEXT, service_version TEXT, status TEXT, pid INTEGER, uptime INTEGER);`,
 		`CREATE TABLE IF NOT EXISTS sys_op_logs (id INTEGER PRIMARY KEY AUTOINCREMENT, operator TEXT, action TEXT, target_type TEXT, target_name TEXT, detail TEXT, status TEXT, create_time INTEGER);`,
 	}
 	for _, s := range sqls {
@@ -39,7 +39,7 @@ func setupHandler(t *testing.T) (*api.ServerHandler, *sql.DB) {
 	// 2. 初始化 Managers
 	// 注意：这里需要传入 nil 的 monitorStore 等，因为我们只测 System Handler
 	sysMgr := manager.NewSystemManager(db)
-	instMgr := manager.NewInstanceManager(db)
+	instMgr := manager.NewInstanceManager(db, nil)
 	logMgr := manager.NewLogManager(db)
 	// 其他 manager 可以是 nil，只要 SystemHandler 不用到它们
 
@@ -47,7 +47,7 @@ func setupHandler(t *testing.T) (*api.ServerHandler, *sql.DB) {
 	go ws.GlobalHub.Run()
 
 	// 4. 构造 Handler
-	h := api.NewServerHandler(sysMgr, instMgr, nil, logMgr, nil, nil, nil, nil, nil)
+	h := api.NewServerHandler(sysMgr, instMgr, nil, logMgr, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil)
 	return h, db
 }
 
@@ -0,0 +1,110 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"ops-system/pkg/code"
+	"ops-system/pkg/e"
+	"ops-system/pkg/protocol"
+	"ops-system/pkg/response"
+	"ops-system/pkg/utils"
+)
+
+// 各类任务的截止时长 (超过后未结束的子任务记为 timeout)
+const (
+	deployTaskTimeout = 10 * time.Minute // 含下载安装包
+	actionTaskTimeout = 2 * time.Minute
+	cmdTaskTimeout    = time.Minute
+)
+
+// nodeItems 每个节点一个子任务
+func nodeItems(nodes []protocol.NodeInfo) []protocol.TaskItem {
+	items := make([]protocol.TaskItem, len(nodes))
+	for i, n := range nodes {
+		items[i] = protocol.TaskItem{NodeID: n.ID, NodeIP: n.IP}
+	}
+	return items
+}
+
+// instanceItems 每个实例一个子任务
+func instanceItems(insts []*protocol.InstanceInfo) []protocol.TaskItem {
+	items := make([]protocol.TaskItem, len(insts))
+	for i, inst := range insts {
+		items[i] = protocol.TaskItem{NodeID: inst.NodeID, NodeIP: inst.NodeIP, InstanceID: inst.ID}
+	}
+	return items
+}
+
+// newTask 以请求来源作为操作人创建任务
+func (h *ServerHandler) newTask(r *http.Request, typ, target string, items []protocol.TaskItem, timeout time.Duration) (*protocol.Task, error) {
+	return h.createTask(utils.GetClientIP(r), typ, target, items, timeout)
+}
+
+// createTask 以 operator 作为操作人创建任务
+func (h *ServerHandler) createTask(operator, typ, target string, items []protocol.TaskItem, timeout time.Duration) (*protocol.Task, error) {
+	task, err := h.taskMgr.CreateTask(typ, target, operator, items, timeout)
+	if err != nil {
+		return nil, e.New(code.DatabaseError, "创建任务失败", err)
+	}
+	return task, nil
+}
+
+// finishTaskItem 按同步调用的结果结束子任务
+func (h *ServerHandler) finishTaskItem(itemID int64, output string, err error) {
+	if err != nil {
+		h.taskMgr.FinishItem(itemID, protocol.TaskFailed, output, err.Error())
+		return
+	}
+	h.taskMgr.FinishItem(itemID, protocol.TaskSucceeded, output, "")
+}
+
+// ListTasks 分页查询任务
+// GET /api/tasks?page=1&page_size=20&status=running&type=deploy
+func (h *ServerHandler) ListTasks(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	page, _ := strconv.Atoi(q.Get("page"))
+	pageSize, _ := strconv.Atoi(q.Get("page_size"))
+	resp, err := h.taskMgr.ListTasks(page, pageSize, q.Get("status"), q.Get("type"))
+	if err != nil {
+		response.Error(w, e.New(code.DatabaseError, "查询任务失败", err))
+		return
+	}
+	response.Success(w, resp)
+}
+
+// GetTask 获取任务详情 (含各节点/实例的子任务结果)
+// GET /api/tasks/detail?id=...
+func (h *ServerHandler) GetTask(w http.ResponseWriter, r *http.Request) {
+	task, ok := h.taskMgr.GetTask(r.URL.Query().Get("id"))
+	if !ok {
+		response.Error(w, e.New(code.TaskNotFound, "任务不存在", nil))
+		return
+	}
+	response.Success(w, task)
+}
+
+// TaskReport Worker 上报子任务进度 (须携带节点凭据，且只能上报以本节点为目标的子任务)
+// POST /api/tasks/report
+func (h *ServerHandler) TaskReport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		response.Error(w, e.New(code.MethodNotAllowed, "Method not allowed", nil))
+		return
+	}
+	nodeID, ok := h.requireNode(w, r)
+	if !ok {
+		return
+	}
+	var report protocol.TaskReport
+	if err := json.NewDecoder(r.Body).Decode(&report); err != nil {
+		response.Error(w, e.New(code.InvalidJSON, "JSON解析失败", err))
+		return
+	}
+	if err := h.taskMgr.Report(nodeID, report); err != nil {
+		response.Error(w, e.New(code.TaskNotFound, err.Error(), err))
+		return
+	}
+	response.Success(w, nil)
+}
@@ -0,0 +1,207 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"ops-system/internal/master/manager"
+	"ops-system/pkg/code"
+	"ops-system/pkg/e"
+	"ops-system/pkg/nodeauth"
+	"ops-system/pkg/protocol"
+	"ops-system/pkg/response"
+	"ops-system/pkg/utils"
+
+	"github.com/gorilla/websocket"
+)
+
+// OpenTerminal Web 终端: 代理到 Worker 的 PTY，输入、输出与窗口变化全程录像 (仅 admin)
+// 指定 instance_id 时在实例所在节点打开，工作目录为实例目录
+// 消息格式同 Worker: 二进制帧为终端输出，文本帧为 protocol.TerminalMessage
+// GET /api/terminal?node_id=...&node_ip=...&instance_id=...&cols=120&rows=40&token=... (WebSocket)
+func (h *ServerHandler) OpenTerminal(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.requireRole(w, r, manager.RoleAdmin)
+	if !ok {
+		return
+	}
+
+	// 1. 查找目标节点
+	q := r.URL.Query()
+	instID := q.Get("instance_id")
+	var node *protocol.NodeInfo
+	targetType, target := "node", ""
+	if instID != "" {
+		inst, ok := h.instMgr.GetInstance(instID)
+		if !ok {
+			response.Error(w, e.New(code.InstanceNotFound, "实例不存在", nil))
+			return
+		}
+		if node, ok = h.nodeMgr.GetInstanceNode(inst); !ok {
+			response.Error(w, e.New(code.NodeNotFound, "节点不存在", nil))
+			return
+		}
+		targetType, target = "instance", inst.ServiceName+"/"+instID
+	} else {
+		if node, ok = h.nodeMgr.GetNode(nodeRef(q.Get("node_id"), q.Get("node_ip"))); !ok {
+			response.Error(w, e.New(code.NodeNotFound, "节点不存在", nil))
+			return
+		}
+		target = node.IP
+	}
+	if !nodeReachable(node) {
+		response.Error(w, e.New(code.NodeOffline, "节点不在线", nil))
+		return
+	}
+	cols, _ := strconv.Atoi(q.Get("cols"))
+	rows, _ := strconv.Atoi(q.Get("rows"))
+
+	// 2. 连接 Worker
+	workerQuery := url.Values{}
+	workerQuery.Set("instance_id", instID)
+	workerQuery.Set("cols", strconv.Itoa(cols))
+	workerQuery.Set("rows", strconv.Itoa(rows))
+	if idle := h.terminalMgr.IdleTimeout(); idle > 0 {
+		workerQuery.Set("idle", strconv.Itoa(int(idle.Seconds())))
+	}
+	workerWsURL := "ws" + strings.TrimPrefix(manager.WorkerURL(node, "/api/terminal/ws?"+workerQuery.Encode()), "http")
+	dialer := websocket.Dialer{NetDialContext: utils.Transport.DialContext, HandshakeTimeout: 10 * time.Second}
+	// Worker 只接受 Master 以节点密钥签发的令牌，拒绝直连
+	header := http.Header{nodeauth.Header: {h.nodeMgr.WorkerToken(node.ID, "/api/terminal/ws")}}
+	workerConn, _, err := dialer.Dial(workerWsURL, header)
+	if err != nil {
+		response.Error(w, e.New(code.NetworkError, fmt.Sprintf("连接 Worker 失败: %v", err), err))
+		return
+	}
+	defer workerConn.Close()
+
+	// 3. 登记会话 (无法录像时拒绝打开)
+	clientIP := utils.GetClientIP(r)
+	session := &protocol.TerminalSession{
+		Operator: user.Name, ClientIP: clientIP, NodeID: node.ID, NodeIP: node.IP, InstanceID: instID,
+	}
+	rec, err := h.terminalMgr.StartSession(session, cols, rows)
+	if err != nil {
+		response.Error(w, e.New(code.DatabaseError, "创建终端录像失败", err))
+		return
+	}
+
+	clientConn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("[Terminal] Upgrade failed: %v", err)
+		rec.Close("升级 WebSocket 失败")
+		return
+	}
+	defer clientConn.Close()
+
+	h.logMgr.RecordLog(user.Name, "open_terminal", targetType, target,
+		fmt.Sprintf("session=%s node=%s client=%s", session.ID, node.IP, clientIP), "success")
+
+	// 4. 双向转发并录像，任意一方断开即结束
+	var reasonMu sync.Mutex
+	reason := ""
+	setReason := func(s string) {
+		reasonMu.Lock()
+		defer reasonMu.Unlock()
+		if reason == "" {
+			reason = s
+		}
+	}
+	errChan := make(chan error, 2)
+
+	// Worker -> 前端
+	go func() {
+		for {
+			mt, data, err := workerConn.ReadMessage()
+			if err != nil {
+				setReason("Worker 断开")
+				errChan <- err
+				return
+			}
+			if mt == websocket.BinaryMessage {
+				rec.Output(data)
+			} else {
+				var msg protocol.TerminalMessage
+				if json.Unmarshal(data, &msg) == nil && msg.Type == "exit" {
+					setReason(msg.Data)
+				}
+			}
+			if err := clientConn.WriteMessage(mt, data); err != nil {
+				errChan <- err
+				return
+			}
+		}
+	}()
+
+	// 前端 -> Worker
+	go func() {
+		for {
+			mt, data, err := clientConn.ReadMessage()
+			if err != nil {
+				setReason("前端断开")
+				errChan <- err
+				return
+			}
+			if mt == websocket.BinaryMessage {
+				rec.Input(string(data))
+			} else {
+				var msg protocol.TerminalMessage
+				if json.Unmarshal(data, &msg) == nil {
+					switch msg.Type {
+					case "input":
+						rec.Input(msg.Data)
+					case "resize":
+						rec.Resize(msg.Cols, msg.Rows)
+					}
+				}
+			}
+			if err := workerConn.WriteMessage(mt, data); err != nil {
+				errChan <- err
+				return
+			}
+		}
+	}()
+
+	<-errChan
+	setReason("连接断开")
+	rec.Close(reason)
+	log.Printf("[Terminal] Session %s (%s@%s) closed: %s", session.ID, user.Name, node.IP, reason)
+}
+
+// ListTerminalSessions 分页查询终端会话 (仅 admin)
+// GET /api/terminal/sessions?page=1&page_size=20
+func (h *ServerHandler) ListTerminalSessions(w http.ResponseWriter, r *http.Request) {
+	if _, ok := h.requireRole(w, r, manager.RoleAdmin); !ok {
+		return
+	}
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	pageSize, _ := strconv.Atoi(r.URL.Query().Get("page_size"))
+	resp, err := h.terminalMgr.ListSessions(page, pageSize)
+	if err != nil {
+		response.Error(w, e.New(code.DatabaseError, "查询终端会话失败", err))
+		return
+	}
+	response.Success(w, resp)
+}
+
+// GetTerminalRecording 下载会话录像 (asciicast v2，仅 admin)
+// GET /api/terminal/recording?id=...
+func (h *ServerHandler) GetTerminalRecording(w http.ResponseWriter, r *http.Request) {
+	if _, ok := h.requireRole(w, r, manager.RoleAdmin); !ok {
+		return
+	}
+	session, ok := h.terminalMgr.GetSession(r.URL.Query().Get("id"))
+	if !ok {
+		response.Error(w, e.New(code.TerminalNotFound, "终端会话不存在", nil))
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-asciicast")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.cast"`, session.ID))
+	http.ServeFile(w, r, h.terminalMgr.RecordingPath(session.ID))
+}
@@ -0,0 +1,53 @@
+package api
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+
+	"ops-system/internal/master/ws"
+	"ops-system/pkg/code"
+	"ops-system/pkg/e"
+	"ops-system/pkg/protocol"
+	"ops-system/pkg/response"
+	"ops-system/pkg/tunnel"
+)
+
+// WorkerTunnel 反向通道: Worker 主动建立的长连接
+// 连接上的流双向复用 HTTP —— Worker 打开的流 (心跳、状态上报、日志推送) 交给 mux 处理，
+// Master 打开的流 (命令、部署、日志查看、exec) 由 Worker 本地的接口处理
+// GET /api/worker/tunnel?node_id=... (WebSocket，请求头 X-Node-Secret 携带节点密钥)
+func (h *ServerHandler) WorkerTunnel(mux http.Handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		nodeID := r.URL.Query().Get("node_id")
+		// 通道地址以节点 ID 为主机名，要求 Worker 上报 UUID (IP 形式保留给旧记录)
+		if nodeID == "" || net.ParseIP(nodeID) != nil {
+			response.Error(w, e.New(code.ParamError, "Invalid node id", nil))
+			return
+		}
+		// 节点 ID 是公开的，须以心跳注册时签发的密钥证明身份，否则任何人都能冒名接管该节点的指令
+		if !h.nodeMgr.VerifyNodeSecret(nodeID, r.Header.Get(protocol.NodeSecretHeader)) {
+			response.Error(w, e.New(code.Unauthorized, "节点密钥无效", fmt.Errorf("tunnel for node %s from %s", nodeID, r.RemoteAddr)))
+			return
+		}
+
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			log.Printf("[Tunnel] Upgrade failed: %v", err)
+			return
+		}
+		sess := tunnel.Server(conn)
+		tunnels := h.nodeMgr.Tunnels()
+		tunnels.Register(nodeID, sess)
+		log.Printf("[Tunnel] Node %s connected from %s", nodeID, conn.RemoteAddr())
+		ws.BroadcastNodes(h.nodeMgr.GetAllNodes())
+
+		// 阻塞直到会话断开
+		(&http.Server{Handler: mux}).Serve(sess)
+
+		tunnels.Unregister(nodeID, sess)
+		log.Printf("[Tunnel] Node %s disconnected", nodeID)
+		ws.BroadcastNodes(h.nodeMgr.GetAllNodes())
+	}
+}
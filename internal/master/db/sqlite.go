@@ -3,6 +3,7 @@ package db
 import (
 	"database/sql"
 	"log"
+	"strings"
 
 	_ "modernc.org/sqlite"
 )
@@ -23,33 +24,63 @@ func InitDB(dbPath string) *sql.DB {
 	return db
 }
 
+// nodeInfosDDL 节点表 (id 为 Worker 生成的 UUID；ip 为当前通信地址，ips 为 JSON 数组)
+// labels 为接口设置的标签，worker_labels 为 Worker 配置上报的标签 (均为 JSON 对象)
+// cordoned 为维护封锁标记，drained_instances 为排空时停止的实例 ID (JSON 数组)
+// upgrade_target/upgrade_deadline 为下发中的 Worker 自升级 (以目标版本心跳后清空)
+// registered = 0 表示尚未被 Worker 以 UUID 认领 (旧数据或规划节点，id 暂为 IP)
+// secret 为首次注册时签发给 Worker 的节点密钥，之后的心跳与反向通道须携带
+const nodeInfosDDL = `CREATE TABLE IF NOT EXISTS node_infos (
+	id TEXT PRIMARY KEY,
+	ip TEXT,
+	ips TEXT DEFAULT '[]',
+	registered INTEGER DEFAULT 0,
+	secret TEXT DEFAULT '',
+	labels TEXT DEFAULT '{}',
+	worker_labels TEXT DEFAULT '{}',
+	cordoned INTEGER DEFAULT 0,
+	cordon_time INTEGER DEFAULT 0,
+	drained_instances TEXT DEFAULT '[]',
+	agent_version TEXT DEFAULT '',
+	agent_platform TEXT DEFAULT '',
+	upgrade_target TEXT DEFAULT '',
+	upgrade_deadline INTEGER DEFAULT 0,
+	port INTEGER,
+	hostname TEXT,
+	name TEXT,
+	mac_addr TEXT,
+	os TEXT,
+	arch TEXT,
+	cpu_cores INTEGER,
+	mem_total INTEGER,
+	disk_total INTEGER,
+	status TEXT,
+	last_heartbeat INTEGER,
+	cpu_usage REAL,
+	mem_usage REAL
+);`
+
 func initTables(db *sql.DB) {
 	sqls := []string{
 		// 系统表
 		`CREATE TABLE IF NOT EXISTS system_infos (id TEXT PRIMARY KEY, name TEXT, description TEXT, create_time INTEGER);`,
 		// 模块表
-		`CREATE TABLE IF NOT EXISTS system_modules (id TEXT PRIMARY KEY, system_id TEXT, module_name TEXT, package_name TEXT, package_version TEXT, description TEXT);`,
+		`CREATE TABLE IF NOT EXISTS system_modules (id TEXT PRIMARY KEY, system_id TEXT, module_name TEXT, package_name TEXT, package_version TEXT, description TEXT, config_bindings TEXT DEFAULT '[]', replicas INTEGER DEFAULT 0, placement TEXT DEFAULT '{}');`,
 		// 实例表
-		`CREATE TABLE IF NOT EXISTS instance_infos (id TEXT PRIMARY KEY, system_id TEXT, node_ip TEXT, service_name TEXT, service_version TEXT, status TEXT, pid INTEGER, uptime INTEGER);`,
+		`CREATE TABLE IF NOT EXISTS instance_infos (id TEXT PRIMARY KEY, system_id TEXT, node_id TEXT DEFAULT '', node_ip TEXT, service_name TEXT, service_version TEXT, status TEXT, pid INTEGER, uptime INTEGER);`,
 		// 日志表
 		`CREATE TABLE IF NOT EXISTS sys_op_logs (id INTEGER PRIMARY KEY AUTOINCREMENT, operator TEXT, action TEXT, target_type TEXT, target_name TEXT, detail TEXT, status TEXT, create_time INTEGER);`,
 
-		// 节点表 (包含 port 和 mac_addr)
-		`CREATE TABLE IF NOT EXISTS node_infos (
-			ip TEXT PRIMARY KEY,
-			port INTEGER,
-			hostname TEXT,
-			name TEXT,
-			mac_addr TEXT,
-			os TEXT,
-			arch TEXT,
-			cpu_cores INTEGER,
-			mem_total INTEGER,
-			disk_total INTEGER,
-			status TEXT,
-			last_heartbeat INTEGER,
-			cpu_usage REAL,
-			mem_usage REAL
+		// 节点表
+		nodeInfosDDL,
+
+		// 节点分组表 (node_ids 为 JSON 数组)
+		`CREATE TABLE IF NOT EXISTS node_groups (
+			name TEXT PRIMARY KEY,
+			description TEXT,
+			selector TEXT DEFAULT '',
+			node_ids TEXT DEFAULT '[]',
+			create_time INTEGER
 		);`,
 
 		// 通用配置表
@@ -68,7 +99,20 @@ func initTables(db *sql.DB) {
 			condition TEXT,
 			threshold REAL,
 			duration INTEGER,
-			enabled BOOLEAN
+			enabled BOOLEAN,
+			channel_ids TEXT DEFAULT '[]',
+			repeat_interval INTEGER DEFAULT 0,
+			severity TEXT DEFAULT 'warning',
+			system_id TEXT DEFAULT '',
+			service_name TEXT DEFAULT '',
+			node_ips TEXT DEFAULT '[]',
+			node_group TEXT DEFAULT '',
+			label_selector TEXT DEFAULT '',
+			expr TEXT DEFAULT '',
+			message_template TEXT DEFAULT '',
+			log_key TEXT DEFAULT '',
+			pattern TEXT DEFAULT '',
+			log_window INTEGER DEFAULT 0
 		);`,
 
 		// 告警事件表 (记录历史)
@@ -83,8 +127,172 @@ func initTables(db *sql.DB) {
 			message TEXT,
 			status TEXT,
 			start_time INTEGER,
-			end_time INTEGER
+			end_time INTEGER,
+			silenced BOOLEAN DEFAULT 0,
+			acked BOOLEAN DEFAULT 0,
+			ack_by TEXT DEFAULT '',
+			ack_comment TEXT DEFAULT '',
+			ack_time INTEGER DEFAULT 0,
+			severity TEXT DEFAULT 'warning',
+			samples TEXT DEFAULT '[]'
+		);`,
+
+		// 告警静默表
+		`CREATE TABLE IF NOT EXISTS sys_alert_silences (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			rule_id INTEGER,
+			target_id TEXT,
+			system_id TEXT,
+			start_time INTEGER,
+			end_time INTEGER,
+			comment TEXT,
+			creator TEXT,
+			create_time INTEGER
+		);`,
+
+		// 维护窗口表 (weekdays 为 JSON 数组)
+		`CREATE TABLE IF NOT EXISTS sys_maintenance_windows (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT,
+			rule_id INTEGER,
+			target_id TEXT,
+			system_id TEXT,
+			weekdays TEXT,
+			start_time TEXT,
+			end_time TEXT,
+			enabled BOOLEAN,
+			comment TEXT
+		);`,
+
+		// 告警通知渠道表 (config 为 JSON)
+		`CREATE TABLE IF NOT EXISTS sys_notify_channels (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT,
+			type TEXT,
+			config TEXT,
+			enabled BOOLEAN,
+			create_time INTEGER
+		);`,
+
+		// 通知投递记录表
+		`CREATE TABLE IF NOT EXISTS sys_notify_logs (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			channel_id INTEGER,
+			channel_name TEXT,
+			event_id INTEGER,
+			kind TEXT,
+			status TEXT,
+			attempts INTEGER,
+			error TEXT,
+			create_time INTEGER
+		);`,
+
+		// 集中日志推送进度表 (按 实例+日志 记录已持久化的流序号，用于去重)
+		`CREATE TABLE IF NOT EXISTS log_ship_offsets (
+			instance_id TEXT,
+			log_key TEXT,
+			node_ip TEXT,
+			seq INTEGER,
+			update_time INTEGER,
+			PRIMARY KEY (instance_id, log_key)
 		);`,
+
+		// 内置配置中心: 命名空间 (默认命名空间 public 的 ID 为空串，不入库)
+		`CREATE TABLE IF NOT EXISTS config_namespaces (
+			id TEXT PRIMARY KEY,
+			name TEXT,
+			description TEXT,
+			create_time INTEGER
+		);`,
+		// 内置配置中心: 当前生效的配置
+		`CREATE TABLE IF NOT EXISTS config_items (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			namespace TEXT,
+			group_name TEXT,
+			data_id TEXT,
+			type TEXT,
+			content TEXT,
+			md5 TEXT,
+			version_id INTEGER,
+			update_time INTEGER,
+			UNIQUE (namespace, group_name, data_id)
+		);`,
+		// 内置配置中心: 历史版本 (只增不改，每次发布/回滚/删除各生成一条)
+		`CREATE TABLE IF NOT EXISTS config_versions (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			namespace TEXT,
+			group_name TEXT,
+			data_id TEXT,
+			type TEXT,
+			content TEXT,
+			md5 TEXT,
+			op TEXT,
+			author TEXT,
+			comment TEXT,
+			create_time INTEGER
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_config_versions_item ON config_versions (namespace, group_name, data_id, id);`,
+
+		// 异步任务: Master -> Worker 的每次操作一条，按节点/实例拆分为子任务
+		`CREATE TABLE IF NOT EXISTS tasks (
+			id TEXT PRIMARY KEY,
+			type TEXT,
+			target TEXT,
+			operator TEXT,
+			status TEXT,
+			error TEXT DEFAULT '',
+			create_time INTEGER,
+			start_time INTEGER DEFAULT 0,
+			end_time INTEGER DEFAULT 0,
+			deadline INTEGER DEFAULT 0
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_tasks_create ON tasks (create_time);`,
+		`CREATE TABLE IF NOT EXISTS task_items (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			task_id TEXT,
+			node_id TEXT DEFAULT '',
+			node_ip TEXT DEFAULT '',
+			instance_id TEXT DEFAULT '',
+			status TEXT,
+			progress INTEGER DEFAULT 0,
+			message TEXT DEFAULT '',
+			output TEXT DEFAULT '',
+			exit_code INTEGER,
+			error TEXT DEFAULT '',
+			start_time INTEGER DEFAULT 0,
+			end_time INTEGER DEFAULT 0
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_task_items_task ON task_items (task_id);`,
+		`CREATE INDEX IF NOT EXISTS idx_task_items_instance ON task_items (instance_id, status);`,
+		`CREATE TABLE IF NOT EXISTS terminal_sessions (
+			id TEXT PRIMARY KEY,
+			operator TEXT,
+			client_ip TEXT DEFAULT '',
+			node_id TEXT DEFAULT '',
+			node_ip TEXT DEFAULT '',
+			instance_id TEXT DEFAULT '',
+			start_time INTEGER,
+			end_time INTEGER DEFAULT 0,
+			size INTEGER DEFAULT 0,
+			reason TEXT DEFAULT ''
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_terminal_sessions_start ON terminal_sessions (start_time);`,
+		`CREATE TABLE IF NOT EXISTS exec_approvals (
+			id TEXT PRIMARY KEY,
+			kind TEXT,
+			command TEXT,
+			target TEXT DEFAULT '',
+			payload TEXT DEFAULT '',
+			requester TEXT,
+			rule TEXT DEFAULT '',
+			status TEXT DEFAULT 'pending',
+			approver TEXT DEFAULT '',
+			comment TEXT DEFAULT '',
+			result TEXT DEFAULT '',
+			create_time INTEGER,
+			decide_time INTEGER DEFAULT 0
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_exec_approvals_status ON exec_approvals (status, create_time);`,
 	}
 
 	for _, sqlStmt := range sqls {
@@ -92,6 +300,117 @@ func initTables(db *sql.DB) {
 			log.Fatalf("Failed to init table: %v\nSQL: %s", err, sqlStmt)
 		}
 	}
+
+	migrateColumns(db)
+	migrateNodeIdentity(db)
+}
+
+// migrateColumns 为旧版本创建的表补充新增列
+// SQLite 不支持 ADD COLUMN IF NOT EXISTS，列已存在时忽略 duplicate column 错误
+func migrateColumns(db *sql.DB) {
+	alters := []string{
+		`ALTER TABLE sys_alert_rules ADD COLUMN channel_ids TEXT DEFAULT '[]'`,
+		`ALTER TABLE sys_alert_rules ADD COLUMN repeat_interval INTEGER DEFAULT 0`,
+		`ALTER TABLE sys_alert_events ADD COLUMN silenced BOOLEAN DEFAULT 0`,
+		`ALTER TABLE sys_alert_events ADD COLUMN acked BOOLEAN DEFAULT 0`,
+		`ALTER TABLE sys_alert_events ADD COLUMN ack_by TEXT DEFAULT ''`,
+		`ALTER TABLE sys_alert_events ADD COLUMN ack_comment TEXT DEFAULT ''`,
+		`ALTER TABLE sys_alert_events ADD COLUMN ack_time INTEGER DEFAULT 0`,
+		`ALTER TABLE sys_alert_rules ADD COLUMN severity TEXT DEFAULT 'warning'`,
+		`ALTER TABLE sys_alert_rules ADD COLUMN system_id TEXT DEFAULT ''`,
+		`ALTER TABLE sys_alert_rules ADD COLUMN service_name TEXT DEFAULT ''`,
+		`ALTER TABLE sys_alert_rules ADD COLUMN node_ips TEXT DEFAULT '[]'`,
+		`ALTER TABLE sys_alert_rules ADD COLUMN label_selector TEXT DEFAULT ''`,
+		`ALTER TABLE sys_alert_events ADD COLUMN severity TEXT DEFAULT 'warning'`,
+		`ALTER TABLE sys_alert_rules ADD COLUMN expr TEXT DEFAULT ''`,
+		`ALTER TABLE sys_alert_rules ADD COLUMN message_template TEXT DEFAULT ''`,
+		`ALTER TABLE sys_alert_rules ADD COLUMN log_key TEXT DEFAULT ''`,
+		`ALTER TABLE sys_alert_rules ADD COLUMN pattern TEXT DEFAULT ''`,
+		`ALTER TABLE sys_alert_rules ADD COLUMN log_window INTEGER DEFAULT 0`,
+		`ALTER TABLE sys_alert_events ADD COLUMN samples TEXT DEFAULT '[]'`,
+		`ALTER TABLE system_modules ADD COLUMN config_bindings TEXT DEFAULT '[]'`,
+		`ALTER TABLE system_modules ADD COLUMN replicas INTEGER DEFAULT 0`,
+		`ALTER TABLE system_modules ADD COLUMN placement TEXT DEFAULT '{}'`,
+		`ALTER TABLE instance_infos ADD COLUMN node_id TEXT DEFAULT ''`,
+		`ALTER TABLE node_infos ADD COLUMN secret TEXT DEFAULT ''`,
+		`ALTER TABLE node_infos ADD COLUMN labels TEXT DEFAULT '{}'`,
+		`ALTER TABLE node_infos ADD COLUMN worker_labels TEXT DEFAULT '{}'`,
+		`ALTER TABLE sys_alert_rules ADD COLUMN node_group TEXT DEFAULT ''`,
+		`ALTER TABLE node_infos ADD COLUMN cordoned INTEGER DEFAULT 0`,
+		`ALTER TABLE node_infos ADD COLUMN cordon_time INTEGER DEFAULT 0`,
+		`ALTER TABLE node_infos ADD COLUMN drained_instances TEXT DEFAULT '[]'`,
+		`ALTER TABLE node_infos ADD COLUMN agent_version TEXT DEFAULT ''`,
+		`ALTER TABLE node_infos ADD COLUMN agent_platform TEXT DEFAULT ''`,
+		`ALTER TABLE node_infos ADD COLUMN upgrade_target TEXT DEFAULT ''`,
+		`ALTER TABLE node_infos ADD COLUMN upgrade_deadline INTEGER DEFAULT 0`,
+		`ALTER TABLE task_items ADD COLUMN exit_code INTEGER`,
+	}
+
+	for _, sqlStmt := range alters {
+		if _, err := db.Exec(sqlStmt); err != nil && !strings.Contains(err.Error(), "duplicate column") {
+			log.Fatalf("Failed to migrate table: %v\nSQL: %s", err, sqlStmt)
+		}
+	}
+}
+
+// migrateNodeIdentity 将以 ip 为主键的旧版节点表迁移为以 id 为主键
+// 旧记录以 IP 作为临时 ID (registered = 0)，对应 Worker 升级后首次心跳时认领为 UUID
+// 实例按 node_ip 补齐 node_id，与节点的临时 ID 对应
+func migrateNodeIdentity(db *sql.DB) {
+	if !hasColumn(db, "node_infos", "id") {
+		log.Println(">>> Migrating node_infos: primary key ip -> id")
+		stmts := []string{
+			`ALTER TABLE node_infos RENAME TO node_infos_legacy`,
+			nodeInfosDDL,
+			`INSERT INTO node_infos (id, ip, ips, registered, port, hostname, name, mac_addr, os, arch, cpu_cores, mem_total, disk_total, status, last_heartbeat, cpu_usage, mem_usage)
+				SELECT ip, ip, '[]', 0, port, hostname, name, mac_addr, os, arch, cpu_cores, mem_total, disk_total, status, last_heartbeat, cpu_usage, mem_usage
+				FROM node_infos_legacy`,
+			`DROP TABLE node_infos_legacy`,
+		}
+		tx, err := db.Begin()
+		if err != nil {
+			log.Fatalf("Failed to migrate node_infos: %v", err)
+		}
+		for _, sqlStmt := range stmts {
+			if _, err := tx.Exec(sqlStmt); err != nil {
+				tx.Rollback()
+				log.Fatalf("Failed to migrate node_infos: %v\nSQL: %s", err, sqlStmt)
+			}
+		}
+		if err := tx.Commit(); err != nil {
+			log.Fatalf("Failed to migrate node_infos: %v", err)
+		}
+	}
+
+	stmts := []string{
+		`CREATE INDEX IF NOT EXISTS idx_node_infos_ip ON node_infos (ip)`,
+		`UPDATE instance_infos SET node_id = node_ip WHERE node_id IS NULL OR node_id = ''`,
+	}
+	for _, sqlStmt := range stmts {
+		if _, err := db.Exec(sqlStmt); err != nil {
+			log.Fatalf("Failed to migrate table: %v\nSQL: %s", err, sqlStmt)
+		}
+	}
+}
+
+// hasColumn 判断表是否包含指定列
+func hasColumn(db *sql.DB, table, column string) bool {
+	rows, err := db.Query("PRAGMA table_info(" + table + ")")
+	if err != nil {
+		log.Fatalf("Failed to inspect table %s: %v", table, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			cid, notNull, pk int
+			name, typ        string
+			dflt             sql.NullString
+		)
+		if err := rows.Scan(&cid, &name, &typ, &notNull, &dflt, &pk); err == nil && name == column {
+			return true
+		}
+	}
+	return false
 }
 
 // CloseDB 关闭数据库连接 (用于恢复备份前释放锁)
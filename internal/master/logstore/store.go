@@ -0,0 +1,291 @@
+package logstore
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	partitionLayout = "2006010215" // 按小时分区
+	partitionExt    = ".log.gz"
+)
+
+// ErrInvalidStream 实例 ID 或日志 key 不能作为目录名
+var ErrInvalidStream = errors.New("invalid instance id or log key")
+
+// Record 一行日志 (Time 为行时间，无法解析时由调用方补齐)
+type Record struct {
+	Time int64
+	Line string
+}
+
+// Hit 检索命中的一行
+type Hit struct {
+	InstanceID string
+	Partition  string // 分区文件名
+	LineNo     int
+	Record
+}
+
+// Store Master 端集中日志存储
+// 目录结构: <dir>/<instance_id>/<log_key>/<YYYYMMDDHH>.log.gz
+// 每次追加写入一个独立的 gzip member (多个 member 串联仍是合法的 gzip 文件)，行格式为 "<unix>\t<line>"
+type Store struct {
+	dir       string
+	retention time.Duration
+
+	mu sync.RWMutex // 追加时独占，读取时共享 (避免读到写了一半的 gzip member)
+}
+
+// New 创建存储，retention <= 0 表示不清理
+func New(dir string, retention time.Duration) (*Store, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &Store{dir: dir, retention: retention}, nil
+}
+
+// Append 追加一批日志，按行时间落到对应的小时分区
+func (s *Store) Append(instID, logKey string, recs []Record) error {
+	if len(recs) == 0 {
+		return nil
+	}
+	dir, err := s.streamDir(instID, logKey)
+	if err != nil {
+		return err
+	}
+
+	// 按分区分组并保持原始顺序
+	var order []string
+	groups := make(map[string][]Record)
+	for _, r := range recs {
+		p := time.Unix(r.Time, 0).Format(partitionLayout)
+		if _, ok := groups[p]; !ok {
+			order = append(order, p)
+		}
+		groups[p] = append(groups[p], r)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for _, p := range order {
+		if err := appendMember(filepath.Join(dir, p+partitionExt), groups[p]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func appendMember(path string, recs []Record) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(f)
+	bw := bufio.NewWriter(gz)
+	for _, r := range recs {
+		bw.WriteString(strconv.FormatInt(r.Time, 10))
+		bw.WriteByte('\t')
+		bw.WriteString(r.Line)
+		bw.WriteByte('\n')
+	}
+	err = bw.Flush()
+	if cerr := gz.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = f.Sync() // 落盘后才向 Worker 确认
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// Search 在指定实例的集中日志中按正则检索 (start/end 为 0 表示不限制)
+func (s *Store) Search(instIDs []string, logKey string, re *regexp.Regexp, start, end int64, limit int) ([]Hit, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var hits []Hit
+	for _, instID := range instIDs {
+		dir, parts, err := s.partitions(instID, logKey)
+		if err != nil {
+			return nil, false, err
+		}
+		for _, p := range parts {
+			pt, _ := time.ParseInLocation(partitionLayout, strings.TrimSuffix(p, partitionExt), time.Local)
+			if (start > 0 && pt.Unix()+3600 <= start) || (end > 0 && pt.Unix() > end) {
+				continue
+			}
+			lineNo := 0
+			err := readPartition(filepath.Join(dir, p), func(r Record) bool {
+				lineNo++
+				if (start > 0 && r.Time < start) || (end > 0 && r.Time > end) || !re.MatchString(r.Line) {
+					return true
+				}
+				hits = append(hits, Hit{InstanceID: instID, Partition: p, LineNo: lineNo, Record: r})
+				return len(hits) < limit
+			})
+			if err != nil {
+				return hits, false, err
+			}
+			if len(hits) >= limit {
+				return hits, true, nil
+			}
+		}
+	}
+	return hits, false, nil
+}
+
+// Tail 读取最后 n 行
+func (s *Store) Tail(instID, logKey string, n int) ([]Record, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	dir, parts, err := s.partitions(instID, logKey)
+	if err != nil {
+		return nil, err
+	}
+	var res []Record
+	// 从最新的分区向前读取，直到凑够 n 行
+	for i := len(parts) - 1; i >= 0 && len(res) < n; i-- {
+		var recs []Record
+		err := readPartition(filepath.Join(dir, parts[i]), func(r Record) bool {
+			recs = append(recs, r)
+			return true
+		})
+		if err != nil {
+			return nil, err
+		}
+		res = append(recs, res...)
+	}
+	if len(res) > n {
+		res = res[len(res)-n:]
+	}
+	return res, nil
+}
+
+// Prune 删除超过保留期的分区，返回删除的文件数
+func (s *Store) Prune(now time.Time) int {
+	if s.retention <= 0 {
+		return 0
+	}
+	cutoff := now.Add(-s.retention)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	removed := 0
+	filepath.WalkDir(s.dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.HasSuffix(d.Name(), partitionExt) {
+			return nil
+		}
+		pt, perr := time.ParseInLocation(partitionLayout, strings.TrimSuffix(d.Name(), partitionExt), time.Local)
+		// 分区结束时间早于截止时间才删除
+		if perr == nil && pt.Add(time.Hour).Before(cutoff) {
+			if os.Remove(path) == nil {
+				removed++
+			}
+		}
+		return nil
+	})
+	return removed
+}
+
+// StartPruner 定期清理过期分区
+func (s *Store) StartPruner(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		s.Prune(now)
+	}
+}
+
+// partitions 返回实例日志的目录与分区文件名 (按时间升序)
+func (s *Store) partitions(instID, logKey string) (string, []string, error) {
+	dir, err := s.streamDir(instID, logKey)
+	if err != nil {
+		return "", nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return dir, nil, nil
+	}
+	var parts []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasSuffix(e.Name(), partitionExt) {
+			parts = append(parts, e.Name())
+		}
+	}
+	sort.Strings(parts)
+	return dir, parts, nil
+}
+
+// streamDir 实例日志目录: 拒绝 . / .. 与路径分隔符，并确认结果位于存储目录之下
+func (s *Store) streamDir(instID, logKey string) (string, error) {
+	for _, name := range []string{instID, logKey} {
+		if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+			return "", ErrInvalidStream
+		}
+	}
+	dir := filepath.Join(s.dir, url.PathEscape(instID), url.PathEscape(logKey))
+	if rel, err := filepath.Rel(s.dir, dir); err != nil || !filepath.IsLocal(rel) {
+		return "", ErrInvalidStream
+	}
+	return dir, nil
+}
+
+// readPartition 逐行读取分区，fn 返回 false 时停止
+func readPartition(path string, fn func(Record) bool) error {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		if err == io.EOF {
+			return nil
+		}
+		return err
+	}
+	scanner := bufio.NewScanner(gz)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		var r Record
+		if ts, text, ok := strings.Cut(line, "\t"); ok {
+			r.Time, _ = strconv.ParseInt(ts, 10, 64)
+			r.Line = text
+		} else {
+			r.Line = line
+		}
+		if !fn(r) {
+			return nil
+		}
+	}
+	// 进程异常退出可能留下不完整的 member，读到此处为止
+	if err := scanner.Err(); err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package logstore_test
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"ops-system/internal/master/logstore"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStoreAppendSearchTail(t *testing.T) {
+	s, err := logstore.New(t.TempDir(), 24*time.Hour)
+	require.NoError(t, err)
+
+	now := time.Now().Truncate(time.Hour)
+	old := now.Add(-48 * time.Hour).Unix()
+	// 同一批跨两个小时分区，分两次追加 (每次一个 gzip member)
+	require.NoError(t, s.Append("i1", "Console Log", []logstore.Record{
+		{Time: old, Line: "boot ok"},
+		{Time: now.Unix(), Line: "ERROR db timeout"},
+	}))
+	require.NoError(t, s.Append("i1", "Console Log", []logstore.Record{
+		{Time: now.Unix() + 1, Line: "request done"},
+		{Time: now.Unix() + 2, Line: "ERROR disk full"},
+	}))
+
+	hits, truncated, err := s.Search([]string{"i1"}, "Console Log", regexp.MustCompile("ERROR"), 0, 0, 10)
+	require.NoError(t, err)
+	assert.False(t, truncated)
+	require.Len(t, hits, 2)
+	assert.Equal(t, "ERROR db timeout", hits[0].Line)
+	assert.Equal(t, 3, hits[1].LineNo)
+
+	// 时间范围过滤
+	hits, _, err = s.Search([]string{"i1"}, "Console Log", regexp.MustCompile("."), old, old, 10)
+	require.NoError(t, err)
+	require.Len(t, hits, 1)
+	assert.Equal(t, "boot ok", hits[0].Line)
+
+	recs, err := s.Tail("i1", "Console Log", 2)
+	require.NoError(t, err)
+	require.Len(t, recs, 2)
+	assert.Equal(t, "request done", recs[0].Line)
+	assert.Equal(t, "ERROR disk full", recs[1].Line)
+
+	// 超过保留期的分区被清理
+	assert.Equal(t, 1, s.Prune(time.Now()))
+	recs, err = s.Tail("i1", "Console Log", 10)
+	require.NoError(t, err)
+	assert.Len(t, recs, 3)
+
+	// 实例 ID 与日志 key 不能跳出存储目录
+	for _, name := range []string{"..", ".", "a/b", `a\b`, ""} {
+		assert.ErrorIs(t, s.Append(name, "Console Log", []logstore.Record{{Time: now.Unix(), Line: "x"}}), logstore.ErrInvalidStream, name)
+		assert.ErrorIs(t, s.Append("i1", name, []logstore.Record{{Time: now.Unix(), Line: "x"}}), logstore.ErrInvalidStream, name)
+		_, err = s.Tail(name, "Console Log", 1)
+		assert.ErrorIs(t, err, logstore.ErrInvalidStream, name)
+	}
+}
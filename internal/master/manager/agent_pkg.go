@@ -0,0 +1,154 @@
+package manager
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"ops-system/pkg/protocol"
+)
+
+// Worker 程序在存储中的布局: _agent/<version>/<os>-<arch>/worker[.exe]，旁边存放 .sha256
+// 三级路径不会被 ListPackages (serviceName/version.zip) 误识别为服务包
+const agentPrefix = "_agent"
+
+var (
+	agentVersionRe  = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._+-]{0,63}$`)
+	agentPlatformRe = regexp.MustCompile(`^[a-z0-9]+/[a-z0-9]+$`)
+)
+
+// agentKey Worker 程序的存储路径 (统一使用 / 分隔，与对象存储一致)
+func agentKey(version, platform string) string {
+	name := "worker"
+	if strings.HasPrefix(platform, "windows/") {
+		name += ".exe"
+	}
+	return path.Join(agentPrefix, version, strings.Replace(platform, "/", "-", 1), name)
+}
+
+// ValidateAgentTarget 校验版本号与平台 (如 linux/amd64)
+func ValidateAgentTarget(version, platform string) error {
+	if !agentVersionRe.MatchString(version) {
+		return fmt.Errorf("invalid version %q", version)
+	}
+	if !agentPlatformRe.MatchString(platform) {
+		return fmt.Errorf("invalid platform %q, expected os/arch", platform)
+	}
+	return nil
+}
+
+// SaveAgentBinary 保存 Worker 程序并记录 sha256 (同版本同平台覆盖)
+func (pm *PackageManager) SaveAgentBinary(version, platform string, reader io.Reader) (*protocol.AgentBinary, error) {
+	if err := ValidateAgentTarget(version, platform); err != nil {
+		return nil, err
+	}
+
+	// 先落地临时文件计算摘要，避免存储中出现摘要不匹配的程序
+	tempFile, err := os.CreateTemp("", "agent-*")
+	if err != nil {
+		return nil, err
+	}
+	tempPath := tempFile.Name()
+	defer func() {
+		tempFile.Close()
+		os.Remove(tempPath)
+	}()
+
+	h := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tempFile, h), reader)
+	if err != nil {
+		return nil, err
+	}
+	if size == 0 {
+		return nil, fmt.Errorf("empty binary")
+	}
+	sum := hex.EncodeToString(h.Sum(nil))
+
+	if _, err := tempFile.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	key := agentKey(version, platform)
+	if err := pm.store.Save(key, tempFile); err != nil {
+		return nil, fmt.Errorf("storage save failed: %v", err)
+	}
+	if err := pm.store.Save(key+".sha256", strings.NewReader(sum)); err != nil {
+		return nil, fmt.Errorf("storage save failed: %v", err)
+	}
+	return &protocol.AgentBinary{Version: version, Platform: platform, SHA256: sum, Size: size}, nil
+}
+
+// ListAgentBinaries 列出已上传的 Worker 程序 (按版本、平台排序)
+func (pm *PackageManager) ListAgentBinaries() ([]protocol.AgentBinary, error) {
+	files, err := pm.store.ListFiles()
+	if err != nil {
+		return nil, err
+	}
+	list := []protocol.AgentBinary{}
+	for _, f := range files {
+		parts := strings.Split(strings.ReplaceAll(f.Name, "\\", "/"), "/")
+		if len(parts) != 4 || parts[0] != agentPrefix || strings.HasSuffix(parts[3], ".sha256") {
+			continue
+		}
+		platform := strings.Replace(parts[2], "-", "/", 1)
+		b := protocol.AgentBinary{Version: parts[1], Platform: platform, Size: f.Size, UploadTime: f.ModTime}
+		b.SHA256, _ = pm.agentChecksum(b.Version, b.Platform)
+		list = append(list, b)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Version != list[j].Version {
+			return list[i].Version < list[j].Version
+		}
+		return list[i].Platform < list[j].Platform
+	})
+	return list, nil
+}
+
+// agentChecksum 读取 Worker 程序的 sha256 (不存在时表示该版本/平台未上传)
+func (pm *PackageManager) agentChecksum(version, platform string) (string, error) {
+	rc, err := pm.store.Get(agentKey(version, platform) + ".sha256")
+	if err != nil {
+		return "", fmt.Errorf("worker %s for %s not found", version, platform)
+	}
+	defer rc.Close()
+	// MinIO 的 GetObject 在读取时才返回对象不存在
+	data, err := io.ReadAll(rc)
+	if err != nil || len(data) == 0 {
+		return "", fmt.Errorf("worker %s for %s not found", version, platform)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// AgentDownload 获取 Worker 程序的下载链接与 sha256
+func (pm *PackageManager) AgentDownload(version, platform, masterAddr string) (string, string, error) {
+	if err := ValidateAgentTarget(version, platform); err != nil {
+		return "", "", err
+	}
+	sum, err := pm.agentChecksum(version, platform)
+	if err != nil {
+		return "", "", err
+	}
+	url, err := pm.store.GetDownloadURL(agentKey(version, platform), masterAddr)
+	if err != nil {
+		return "", "", err
+	}
+	return url, sum, nil
+}
+
+// DeleteAgentBinary 删除 Worker 程序及其摘要
+func (pm *PackageManager) DeleteAgentBinary(version, platform string) error {
+	if err := ValidateAgentTarget(version, platform); err != nil {
+		return err
+	}
+	key := agentKey(version, platform)
+	if err := pm.store.Delete(key); err != nil {
+		return err
+	}
+	pm.store.Delete(key + ".sha256")
+	return nil
+}
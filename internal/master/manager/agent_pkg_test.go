@@ -0,0 +1,104 @@
+package manager_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"testing"
+	"time"
+
+	"ops-system/internal/master/manager"
+	"ops-system/pkg/protocol"
+	"ops-system/pkg/storage"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAgentBinaries(t *testing.T) {
+	pm := manager.NewPackageManager(storage.NewLocalProvider(t.TempDir()))
+
+	content := "fake worker binary"
+	sum := sha256.Sum256([]byte(content))
+
+	// 1. 上传时计算摘要，非法版本/平台拒绝
+	_, err := pm.SaveAgentBinary("../v1", "linux/amd64", strings.NewReader(content))
+	assert.Error(t, err)
+	_, err = pm.SaveAgentBinary("v1.2.0", "linux", strings.NewReader(content))
+	assert.Error(t, err)
+	bin, err := pm.SaveAgentBinary("v1.2.0", "linux/amd64", strings.NewReader(content))
+	assert.NoError(t, err)
+	assert.Equal(t, hex.EncodeToString(sum[:]), bin.SHA256)
+
+	// 2. 列表只包含 Worker 程序，不影响服务包列表
+	list, err := pm.ListAgentBinaries()
+	assert.NoError(t, err)
+	if assert.Len(t, list, 1) {
+		assert.Equal(t, "linux/amd64", list[0].Platform)
+		assert.Equal(t, bin.SHA256, list[0].SHA256)
+	}
+	pkgs, err := pm.ListPackages()
+	assert.NoError(t, err)
+	assert.Empty(t, pkgs)
+
+	// 3. 按节点平台获取下载信息
+	url, got, err := pm.AgentDownload("v1.2.0", "linux/amd64", "master:8080")
+	assert.NoError(t, err)
+	assert.Equal(t, bin.SHA256, got)
+	assert.Equal(t, "http://master:8080/download/_agent/v1.2.0/linux-amd64/worker", url)
+	_, _, err = pm.AgentDownload("v1.2.0", "linux/arm64", "master:8080")
+	assert.Error(t, err)
+
+	assert.NoError(t, pm.DeleteAgentBinary("v1.2.0", "linux/amd64"))
+	_, _, err = pm.AgentDownload("v1.2.0", "linux/amd64", "master:8080")
+	assert.Error(t, err)
+}
+
+func TestNodeUpgradeState(t *testing.T) {
+	db := setupNodeDB(t)
+	defer db.Close()
+
+	nm := manager.NewNodeManager(db, nil, time.Minute)
+	const nodeID = "d4e5f6a7-0000-4000-8000-000000000001"
+	var secret string
+	beat := func(version string) {
+		issued, err := nm.HandleHeartbeat(protocol.RegisterRequest{
+			NodeID: nodeID, Secret: secret, Version: version, Platform: "linux/amd64",
+			Info: protocol.NodeInfo{Hostname: "host-a"},
+		}, "10.0.0.1")
+		assert.NoError(t, err)
+		if issued != "" {
+			secret = issued
+		}
+	}
+	beat("v1.1.0")
+
+	node, ok := nm.GetNode(nodeID)
+	assert.True(t, ok)
+	assert.Equal(t, "v1.1.0", node.AgentVersion)
+	assert.Equal(t, "linux/amd64", node.AgentPlatform)
+
+	// 1. 下发后处于 upgrading，仍以旧版本心跳不影响
+	assert.NoError(t, nm.MarkUpgrading(nodeID, "v1.2.0", time.Minute))
+	beat("v1.1.0")
+	nodes := nm.GetAllNodes()
+	if assert.Len(t, nodes, 1) {
+		assert.Equal(t, "v1.2.0", nodes[0].UpgradeTarget)
+		assert.Equal(t, "upgrading", nodes[0].Upgrade)
+	}
+
+	// 2. 以目标版本心跳后完成
+	beat("v1.2.0")
+	nodes = nm.GetAllNodes()
+	if assert.Len(t, nodes, 1) {
+		assert.Equal(t, "v1.2.0", nodes[0].AgentVersion)
+		assert.Empty(t, nodes[0].UpgradeTarget)
+		assert.Empty(t, nodes[0].Upgrade)
+	}
+
+	// 3. 超过截止时间仍未完成视为失败
+	assert.NoError(t, nm.MarkUpgrading(nodeID, "v1.3.0", -time.Hour))
+	nodes = nm.GetAllNodes()
+	if assert.Len(t, nodes, 1) {
+		assert.Equal(t, "failed", nodes[0].Upgrade)
+	}
+}
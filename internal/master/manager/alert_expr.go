@@ -0,0 +1,145 @@
+package manager
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"text/template"
+
+	"ops-system/internal/master/monitor"
+	"ops-system/pkg/protocol"
+)
+
+// 由状态快照合成的指标 (离线节点不再上报，TSDB 中无法体现 0 值)
+const (
+	metricNodeUp     = "node_up"
+	metricInstanceUp = "instance_up"
+)
+
+// legacyExpr 将旧版 Metric/Condition/Threshold 规则翻译为表达式
+func legacyExpr(rule *protocol.AlertRule) (string, error) {
+	prefix := "node_"
+	if rule.TargetType == "instance" {
+		prefix = "instance_"
+	}
+
+	if rule.Metric == "status" {
+		// 约定：status 规则在 offline/stopped/error 等异常状态时触发
+		return prefix + "up == 0", nil
+	}
+
+	var series string
+	switch rule.Metric {
+	case "cpu":
+		series = prefix + "cpu_usage"
+	case "mem":
+		series = prefix + "mem_usage"
+	default:
+		return "", fmt.Errorf("unsupported metric: %s", rule.Metric)
+	}
+
+	op := rule.Condition
+	switch op {
+	case "=":
+		op = "=="
+	case ">", "<", ">=", "<=", "==", "!=":
+	default:
+		return "", fmt.Errorf("unsupported condition: %s", rule.Condition)
+	}
+	return fmt.Sprintf("%s %s %g", series, op, rule.Threshold), nil
+}
+
+// compileRule 解析规则的表达式与消息模板 (日志规则没有表达式，expr 返回 nil)
+func compileRule(rule *protocol.AlertRule) (*monitor.Expr, *template.Template, error) {
+	var expr *monitor.Expr
+	var err error
+	if rule.TargetType == "log" {
+		if _, err = regexp.Compile(rule.Pattern); err != nil {
+			return nil, nil, err
+		}
+	} else {
+		src := rule.Expr
+		if src == "" {
+			if src, err = legacyExpr(rule); err != nil {
+				return nil, nil, err
+			}
+		}
+		if expr, err = monitor.ParseExpr(src); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var tpl *template.Template
+	if rule.MessageTemplate != "" {
+		if tpl, err = template.New("message").Parse(rule.MessageTemplate); err != nil {
+			return nil, nil, err
+		}
+	}
+	return expr, tpl, nil
+}
+
+// messageData 消息模板的渲染上下文
+type messageData struct {
+	Rule     string
+	Severity string
+	Target   string
+	TargetID string
+	Value    float64
+	Expr     string
+	Labels   map[string]string
+	Samples  []string // 日志规则的样例行
+}
+
+// renderMessage 生成告警消息，未配置模板或渲染失败时使用默认格式
+func renderMessage(rule *protocol.AlertRule, expr *monitor.Expr, tpl *template.Template, target alertTarget, res evalResult) string {
+	val := res.Value
+	exprStr := rule.Pattern
+	if expr != nil {
+		exprStr = expr.String()
+	}
+	if tpl != nil {
+		var buf bytes.Buffer
+		err := tpl.Execute(&buf, messageData{
+			Rule: rule.Name, Severity: rule.Severity, Target: target.Name, TargetID: target.ID,
+			Value: val, Expr: exprStr, Labels: target.Labels, Samples: res.Samples,
+		})
+		if err == nil {
+			return buf.String()
+		}
+	}
+	if rule.TargetType == "log" {
+		return fmt.Sprintf("[%s] %s: /%s/ matched %d times in %ds", rule.Name, target.Name, rule.Pattern, int(val), rule.Window)
+	}
+	if rule.Expr == "" {
+		return fmt.Sprintf("[%s] %s %s %.1f (Threshold: %.1f)", rule.Name, target.Name, rule.Metric, val, rule.Threshold)
+	}
+	return fmt.Sprintf("[%s] %s: %s (current: %.2f)", rule.Name, target.Name, expr.String(), val)
+}
+
+// snapshotSource 在 TSDB 之上叠加本轮评估的状态快照 (*_up 指标)
+type snapshotSource struct {
+	base monitor.Source
+	up   map[string]float64 // 目标 ID -> 1/0
+	now  int64
+}
+
+func (s *snapshotSource) QueryRange(metric, id string, start, end int64) []monitor.Point {
+	if metric == metricNodeUp || metric == metricInstanceUp {
+		if v, ok := s.up[metric+"|"+id]; ok && s.now >= start && s.now <= end {
+			return []monitor.Point{{Time: s.now, Value: v}}
+		}
+		return nil
+	}
+	if s.base == nil {
+		return nil
+	}
+	return s.base.QueryRange(metric, id, start, end)
+}
+
+func (s *snapshotSource) setUp(metric, id string, up bool) {
+	v := 0.0
+	if up {
+		v = 1
+	}
+	s.up[metric+"|"+id] = v
+}
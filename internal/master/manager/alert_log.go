@@ -0,0 +1,85 @@
+package manager
+
+import (
+	"time"
+
+	"ops-system/pkg/protocol"
+)
+
+const (
+	defaultLogWindow = 60 // 日志规则默认统计窗口(秒)
+	// logReportTTL 上报超过该时长未更新视为无数据 (Worker 离线或任务已撤销)
+	logReportTTL = 60
+)
+
+// logMatch 某条日志规则在某个实例上的最新统计
+type logMatch struct {
+	Count      int
+	Samples    []string
+	ReportTime int64
+}
+
+// GetLogWatchRules 获取需要在指定节点上执行的日志匹配任务
+func (am *AlertManager) GetLogWatchRules(nodeID string) []protocol.LogWatchRule {
+	rules, _ := am.GetRules()
+	instances := am.instMgr.GetAllInstancesMetrics()
+	groups := am.nodeMgr.GroupMembers()
+
+	list := []protocol.LogWatchRule{}
+	for _, rule := range rules {
+		if !rule.Enabled || rule.TargetType != "log" {
+			continue
+		}
+		scope, err := newRuleScope(rule, groups)
+		if err != nil {
+			continue
+		}
+		for _, inst := range instances {
+			if inst.NodeID != nodeID || !scope.MatchInstance(&inst) {
+				continue
+			}
+			list = append(list, protocol.LogWatchRule{
+				RuleID:     rule.ID,
+				InstanceID: inst.ID,
+				LogKey:     rule.LogKey,
+				Pattern:    rule.Pattern,
+				Window:     rule.Window,
+			})
+		}
+	}
+	return list
+}
+
+// ReportLogMatches 接收 Worker 上报的匹配统计，由评估循环统一处理
+func (am *AlertManager) ReportLogMatches(items []protocol.LogMatchReport) {
+	now := time.Now().Unix()
+	am.logMu.Lock()
+	defer am.logMu.Unlock()
+	for _, item := range items {
+		am.logMatches[stateKey{RuleID: item.RuleID, TargetID: item.InstanceID}] = &logMatch{
+			Count:      item.Count,
+			Samples:    item.Samples,
+			ReportTime: now,
+		}
+	}
+}
+
+// evalLogRule 根据最近一次上报判断日志规则是否触发
+func (am *AlertManager) evalLogRule(rule *protocol.AlertRule, target alertTarget, now int64) evalResult {
+	am.logMu.Lock()
+	m, ok := am.logMatches[stateKey{RuleID: rule.ID, TargetID: target.ID}]
+	if ok && now-m.ReportTime > logReportTTL {
+		delete(am.logMatches, stateKey{RuleID: rule.ID, TargetID: target.ID})
+		ok = false
+	}
+	am.logMu.Unlock()
+
+	if !ok {
+		return evalResult{}
+	}
+	return evalResult{
+		Value:     float64(m.Count),
+		Triggered: float64(m.Count) >= rule.Threshold,
+		Samples:   m.Samples,
+	}
+}
@@ -2,11 +2,14 @@ package manager
 
 import (
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"log"
 	"sync"
 	"time"
 
+	"ops-system/internal/master/monitor"
+	"ops-system/internal/master/notify"
 	"ops-system/internal/master/ws" // 用于推送
 	"ops-system/pkg/protocol"
 )
@@ -21,24 +24,54 @@ type alertState struct {
 	FirstTriggerTime int64 // 第一次满足条件的时间 (用于防抖)
 	IsFiring         bool  // 是否已经触发告警
 	EventID          int64 // 数据库中的 Event ID (用于更新 EndTime)
+	Silenced         bool  // 当前是否处于静默 (静默期间不发通知)
+	Notified         bool  // 触发通知是否已发出 (决定恢复时是否通知，与之后是否进入静默无关)
+	Acked            bool  // 是否已被确认 (确认后不再重复通知)
+	LastNotifyTime   int64 // 上次发送通知的时间 (用于重复通知)
+}
+
+// alertTarget 被评估的告警对象
+type alertTarget struct {
+	ID       string            // 节点 ID 或实例 ID
+	Name     string            // 用于展示
+	SystemID string            // 实例所属系统 (节点为空)
+	Labels   map[string]string // 内置标签 (用于消息模板)
+}
+
+// evalResult 规则在单个目标上的一次评估结果
+type evalResult struct {
+	Value     float64
+	Triggered bool
+	Message   string   // 仅触发时生成
+	Samples   []string // 日志规则的样例行
 }
 
 type AlertManager struct {
-	db      *sql.DB
-	nodeMgr *NodeManager
-	instMgr *InstanceManager
+	db        *sql.DB
+	nodeMgr   *NodeManager
+	instMgr   *InstanceManager
+	notifyMgr *NotifyManager
+	tsdb      *monitor.MemoryTSDB
 
 	mu     sync.RWMutex
 	states map[stateKey]*alertState // 内存状态机
+
+	logMu      sync.Mutex
+	logMatches map[stateKey]*logMatch // Worker 上报的日志匹配统计
 }
 
-func NewAlertManager(db *sql.DB, nm *NodeManager, im *InstanceManager) *AlertManager {
+func NewAlertManager(db *sql.DB, nm *NodeManager, im *InstanceManager, notifyMgr *NotifyManager, tsdb *monitor.MemoryTSDB) *AlertManager {
 	am := &AlertManager{
-		db:      db,
-		nodeMgr: nm,
-		instMgr: im,
-		states:  make(map[stateKey]*alertState),
+		db:        db,
+		nodeMgr:   nm,
+		instMgr:   im,
+		notifyMgr: notifyMgr,
+		tsdb:      tsdb,
+		states:    make(map[stateKey]*alertState),
+
+		logMatches: make(map[stateKey]*logMatch),
 	}
+	am.restoreStates()
 	go am.runEvaluationLoop()
 	return am
 }
@@ -46,10 +79,62 @@ func NewAlertManager(db *sql.DB, nm *NodeManager, im *InstanceManager) *AlertMan
 // --- 规则管理 ---
 
 func (am *AlertManager) AddRule(r protocol.AlertRule) error {
+	if err := validateRule(&r); err != nil {
+		return err
+	}
 	am.mu.Lock()
 	defer am.mu.Unlock()
-	_, err := am.db.Exec(`INSERT INTO sys_alert_rules (name, target_type, metric, condition, threshold, duration, enabled) VALUES (?, ?, ?, ?, ?, ?, ?)`,
-		r.Name, r.TargetType, r.Metric, r.Condition, r.Threshold, r.Duration, true)
+	_, err := am.db.Exec(`INSERT INTO sys_alert_rules (name, target_type, metric, condition, threshold, duration, enabled, channel_ids, repeat_interval, severity, system_id, service_name, node_ips, node_group, label_selector, expr, message_template, log_key, pattern, log_window) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		r.Name, r.TargetType, r.Metric, r.Condition, r.Threshold, r.Duration, true, encodeIDs(r.ChannelIDs), r.RepeatInterval,
+		r.Severity, r.SystemID, r.ServiceName, encodeStrings(r.NodeIPs), r.NodeGroup, r.LabelSelector, r.Expr, r.MessageTemplate, r.LogKey, r.Pattern, r.Window)
+	return err
+}
+
+// UpdateRule 修改规则 (启用状态通过 SetRuleEnabled 单独切换)
+// 规则修改后条件/范围可能变化，清空该规则的内存状态，下一周期重新评估
+func (am *AlertManager) UpdateRule(r protocol.AlertRule) error {
+	if err := validateRule(&r); err != nil {
+		return err
+	}
+	am.mu.Lock()
+	defer am.mu.Unlock()
+	res, err := am.db.Exec(`UPDATE sys_alert_rules SET name = ?, target_type = ?, metric = ?, condition = ?, threshold = ?, duration = ?, channel_ids = ?, repeat_interval = ?,
+		severity = ?, system_id = ?, service_name = ?, node_ips = ?, node_group = ?, label_selector = ?, expr = ?, message_template = ?,
+		log_key = ?, pattern = ?, log_window = ? WHERE id = ?`,
+		r.Name, r.TargetType, r.Metric, r.Condition, r.Threshold, r.Duration, encodeIDs(r.ChannelIDs), r.RepeatInterval,
+		r.Severity, r.SystemID, r.ServiceName, encodeStrings(r.NodeIPs), r.NodeGroup, r.LabelSelector, r.Expr, r.MessageTemplate, r.LogKey, r.Pattern, r.Window, r.ID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("rule %d not found", r.ID)
+	}
+	am.resetRuleStates(r.ID)
+	return nil
+}
+
+// SetRuleEnabled 启用/禁用规则，禁用时结束该规则下所有活跃告警
+func (am *AlertManager) SetRuleEnabled(id int64, enabled bool) error {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+	res, err := am.db.Exec("UPDATE sys_alert_rules SET enabled = ? WHERE id = ?", enabled, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("rule %d not found", id)
+	}
+	if !enabled {
+		am.resetRuleStates(id)
+	}
+	return nil
+}
+
+// SetRuleChannels 设置规则绑定的通知渠道
+func (am *AlertManager) SetRuleChannels(id int64, channelIDs []int64) error {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+	_, err := am.db.Exec("UPDATE sys_alert_rules SET channel_ids = ? WHERE id = ?", encodeIDs(channelIDs), id)
 	return err
 }
 
@@ -57,11 +142,30 @@ func (am *AlertManager) DeleteRule(id int64) error {
 	am.mu.Lock()
 	defer am.mu.Unlock()
 	_, err := am.db.Exec("DELETE FROM sys_alert_rules WHERE id = ?", id)
+	if err == nil {
+		am.resetRuleStates(id)
+	}
 	return err
 }
 
+// resetRuleStates 清除规则的内存状态，并直接结束其活跃事件 (不发通知)
+// 调用方需持有 am.mu
+func (am *AlertManager) resetRuleStates(ruleID int64) {
+	for key, state := range am.states {
+		if key.RuleID != ruleID {
+			continue
+		}
+		if state.IsFiring {
+			am.resolveAlert(nil, state.EventID, false)
+		}
+		delete(am.states, key)
+	}
+}
+
 func (am *AlertManager) GetRules() ([]*protocol.AlertRule, error) {
-	rows, err := am.db.Query("SELECT id, name, target_type, metric, condition, threshold, duration, enabled FROM sys_alert_rules")
+	rows, err := am.db.Query(`SELECT id, name, target_type, metric, condition, threshold, duration, enabled, COALESCE(channel_ids, '[]'), COALESCE(repeat_interval, 0),
+		COALESCE(severity, 'warning'), COALESCE(system_id, ''), COALESCE(service_name, ''), COALESCE(node_ips, '[]'), COALESCE(node_group, ''), COALESCE(label_selector, ''),
+		COALESCE(expr, ''), COALESCE(message_template, ''), COALESCE(log_key, ''), COALESCE(pattern, ''), COALESCE(log_window, 0) FROM sys_alert_rules`)
 	if err != nil {
 		return nil, err
 	}
@@ -69,15 +173,49 @@ func (am *AlertManager) GetRules() ([]*protocol.AlertRule, error) {
 	var list []*protocol.AlertRule
 	for rows.Next() {
 		var r protocol.AlertRule
-		rows.Scan(&r.ID, &r.Name, &r.TargetType, &r.Metric, &r.Condition, &r.Threshold, &r.Duration, &r.Enabled)
+		var channels, nodeIPs string
+		rows.Scan(&r.ID, &r.Name, &r.TargetType, &r.Metric, &r.Condition, &r.Threshold, &r.Duration, &r.Enabled, &channels, &r.RepeatInterval,
+			&r.Severity, &r.SystemID, &r.ServiceName, &nodeIPs, &r.NodeGroup, &r.LabelSelector, &r.Expr, &r.MessageTemplate, &r.LogKey, &r.Pattern, &r.Window)
+		r.ChannelIDs = decodeIDs(channels)
+		r.NodeIPs = decodeStrings(nodeIPs)
 		list = append(list, &r)
 	}
 	return list, nil
 }
 
+// encodeIDs / decodeIDs ID 列表与 JSON 文本列互转
+func encodeIDs(ids []int64) string {
+	if ids == nil {
+		ids = []int64{}
+	}
+	b, _ := json.Marshal(ids)
+	return string(b)
+}
+
+func decodeIDs(s string) []int64 {
+	ids := []int64{}
+	json.Unmarshal([]byte(s), &ids)
+	return ids
+}
+
+func encodeStrings(list []string) string {
+	if list == nil {
+		list = []string{}
+	}
+	b, _ := json.Marshal(list)
+	return string(b)
+}
+
+func decodeStrings(s string) []string {
+	list := []string{}
+	json.Unmarshal([]byte(s), &list)
+	return list
+}
+
 func (am *AlertManager) GetActiveEvents() ([]*protocol.AlertEvent, error) {
 	// 查询未结束的告警 (status = 'firing')
-	rows, err := am.db.Query("SELECT id, rule_name, target_type, target_id, target_name, metric_val, message, start_time FROM sys_alert_events WHERE status = 'firing' ORDER BY start_time DESC")
+	rows, err := am.db.Query(`SELECT id, rule_name, target_type, target_id, target_name, metric_val, message, start_time, silenced, acked, ack_by, ack_comment, ack_time, COALESCE(severity, 'warning'), COALESCE(samples, '[]')
+		FROM sys_alert_events WHERE status = 'firing' ORDER BY start_time DESC`)
 	if err != nil {
 		return nil, err
 	}
@@ -85,7 +223,10 @@ func (am *AlertManager) GetActiveEvents() ([]*protocol.AlertEvent, error) {
 	var list []*protocol.AlertEvent
 	for rows.Next() {
 		var e protocol.AlertEvent
-		rows.Scan(&e.ID, &e.RuleName, &e.TargetType, &e.TargetID, &e.TargetName, &e.MetricVal, &e.Message, &e.StartTime)
+		var samples string
+		rows.Scan(&e.ID, &e.RuleName, &e.TargetType, &e.TargetID, &e.TargetName, &e.MetricVal, &e.Message, &e.StartTime,
+			&e.Silenced, &e.Acked, &e.AckBy, &e.AckComment, &e.AckTime, &e.Severity, &samples)
+		e.Samples = decodeStrings(samples)
 		e.Status = "firing"
 		list = append(list, &e)
 	}
@@ -93,7 +234,8 @@ func (am *AlertManager) GetActiveEvents() ([]*protocol.AlertEvent, error) {
 }
 
 func (am *AlertManager) GetHistoryEvents(limit int) ([]*protocol.AlertEvent, error) {
-	rows, err := am.db.Query("SELECT id, rule_name, target_type, target_name, message, status, start_time, end_time FROM sys_alert_events ORDER BY start_time DESC LIMIT ?", limit)
+	rows, err := am.db.Query(`SELECT id, rule_name, target_type, target_name, message, status, start_time, end_time, silenced, acked, ack_by, ack_comment, ack_time, COALESCE(severity, 'warning'), COALESCE(samples, '[]')
+		FROM sys_alert_events ORDER BY start_time DESC LIMIT ?`, limit)
 	if err != nil {
 		return nil, err
 	}
@@ -101,12 +243,90 @@ func (am *AlertManager) GetHistoryEvents(limit int) ([]*protocol.AlertEvent, err
 	var list []*protocol.AlertEvent
 	for rows.Next() {
 		var e protocol.AlertEvent
-		rows.Scan(&e.ID, &e.RuleName, &e.TargetType, &e.TargetName, &e.Message, &e.Status, &e.StartTime, &e.EndTime)
+		var samples string
+		rows.Scan(&e.ID, &e.RuleName, &e.TargetType, &e.TargetName, &e.Message, &e.Status, &e.StartTime, &e.EndTime,
+			&e.Silenced, &e.Acked, &e.AckBy, &e.AckComment, &e.AckTime, &e.Severity, &samples)
+		e.Samples = decodeStrings(samples)
 		list = append(list, &e)
 	}
 	return list, nil
 }
 
+// --- 状态恢复 ---
+
+// restoreStates 启动时根据数据库中未结束的事件重建内存状态机
+// 否则重启前的 firing 事件永远不会被恢复，且同一目标会重复产生新事件
+func (am *AlertManager) restoreStates() {
+	rules, _ := am.GetRules()
+	ruleMap := make(map[int64]*protocol.AlertRule, len(rules))
+	for _, r := range rules {
+		ruleMap[r.ID] = r
+	}
+
+	rows, err := am.db.Query(`SELECT id, rule_id, target_id, start_time, COALESCE(silenced, 0), COALESCE(acked, 0)
+		FROM sys_alert_events WHERE status = 'firing' ORDER BY id`)
+	if err != nil {
+		log.Printf("[Alert] restore states failed: %v", err)
+		return
+	}
+	type openEvent struct {
+		id, ruleID, startTime int64
+		targetID              string
+		silenced, acked       bool
+	}
+	var events []openEvent
+	for rows.Next() {
+		var ev openEvent
+		rows.Scan(&ev.id, &ev.ruleID, &ev.targetID, &ev.startTime, &ev.silenced, &ev.acked)
+		events = append(events, ev)
+	}
+	rows.Close()
+
+	am.mu.Lock()
+	defer am.mu.Unlock()
+
+	now := time.Now().Unix()
+	restored, closed := 0, 0
+	for _, ev := range events {
+		// 规则已删除或已禁用：直接结束
+		if rule, ok := ruleMap[ev.ruleID]; !ok || !rule.Enabled {
+			am.resolveAlert(nil, ev.id, false)
+			closed++
+			continue
+		}
+
+		key := stateKey{RuleID: ev.ruleID, TargetID: ev.targetID}
+		if _, ok := am.states[key]; ok {
+			// 历史遗留的重复事件：保留最早的一条，其余直接结束
+			am.resolveAlert(nil, ev.id, false)
+			closed++
+			continue
+		}
+		am.states[key] = &alertState{
+			FirstTriggerTime: ev.startTime,
+			IsFiring:         true,
+			EventID:          ev.id,
+			Silenced:         ev.silenced,
+			Notified:         !ev.silenced, // 事件的静默标记在补发触发通知后清除
+			Acked:            ev.acked,
+			LastNotifyTime:   now, // 避免重启后立即补发一轮重复通知
+		}
+		restored++
+	}
+
+	if restored > 0 || closed > 0 {
+		log.Printf("[Alert] restored %d firing alerts, closed %d stale/duplicate events", restored, closed)
+	}
+}
+
+// findFiringEvent 查找规则在目标上未结束的事件 (防止重复创建)
+func (am *AlertManager) findFiringEvent(ruleID int64, targetID string) (int64, bool) {
+	var id int64
+	err := am.db.QueryRow(`SELECT id FROM sys_alert_events WHERE rule_id = ? AND target_id = ? AND status = 'firing' ORDER BY id LIMIT 1`,
+		ruleID, targetID).Scan(&id)
+	return id, err == nil
+}
+
 // --- 评估引擎 (核心) ---
 
 func (am *AlertManager) runEvaluationLoop() {
@@ -125,74 +345,119 @@ func (am *AlertManager) evaluate() {
 	// 获取快照
 	nodes := am.nodeMgr.GetAllNodesMetrics()
 	instances := am.instMgr.GetAllInstancesMetrics()
+	groups := am.nodeMgr.GroupMembers()
+	silences := am.loadSilenceMatcher()
 
 	am.mu.Lock()
 	defer am.mu.Unlock()
 
-	now := time.Now().Unix()
+	now := time.Now()
+
+	// 表达式数据源：TSDB + 本轮状态快照
+	src := &snapshotSource{up: make(map[string]float64), now: now.Unix()}
+	if am.tsdb != nil {
+		src.base = am.tsdb
+	}
+	// 维护中的节点及其实例不参与告警 (状态未被评估，已触发的告警随之恢复)
+	maintenance := make(map[string]bool)
+	for _, node := range nodes {
+		src.setUp(metricNodeUp, node.ID, node.Status == "online" || node.Status == "maintenance")
+		if node.Cordoned {
+			maintenance[node.ID] = true
+		}
+	}
+
+	// 按节点归集实例 (节点类规则按系统/服务圈定范围时使用)
+	hosted := make(map[string][]protocol.InstanceInfo)
+	for _, inst := range instances {
+		hosted[inst.NodeID] = append(hosted[inst.NodeID], inst)
+		src.setUp(metricInstanceUp, inst.ID, inst.Status == "running")
+	}
+
+	// 本轮评估过的 (规则, 目标)，未出现的状态视为目标已删除或移出规则范围
+	seen := make(map[stateKey]bool)
 
 	for _, rule := range rules {
 		if !rule.Enabled {
 			continue
 		}
+		scope, err := newRuleScope(rule, groups)
+		if err != nil {
+			log.Printf("[Alert] rule %d has invalid label selector: %v", rule.ID, err)
+			continue
+		}
+		expr, tpl, err := compileRule(rule)
+		if err != nil {
+			log.Printf("[Alert] rule %d has invalid expression: %v", rule.ID, err)
+			continue
+		}
 
-		// 根据规则类型遍历目标
+		// 根据规则类型收集目标
+		var targets []alertTarget
 		if rule.TargetType == "node" {
 			for _, node := range nodes {
-				val, triggered := checkCondition(rule, node.Status, node.CPUUsage, node.MemUsage)
-				am.handleState(rule, node.IP, node.Hostname, val, triggered, now)
+				if node.Cordoned || !scope.MatchNode(&node, hosted[node.ID]) {
+					continue
+				}
+				targets = append(targets, alertTarget{ID: node.ID, Name: node.Hostname, Labels: nodeLabels(&node)})
 			}
-		} else if rule.TargetType == "instance" {
+		} else if rule.TargetType == "instance" || rule.TargetType == "log" {
 			for _, inst := range instances {
-				val, triggered := checkCondition(rule, inst.Status, inst.CpuUsage, float64(inst.MemUsage))
-				targetName := fmt.Sprintf("%s (%s)", inst.ServiceName, inst.NodeIP)
-				am.handleState(rule, inst.ID, targetName, val, triggered, now)
+				if maintenance[inst.NodeID] || !scope.MatchInstance(&inst) {
+					continue
+				}
+				targets = append(targets, alertTarget{
+					ID: inst.ID, Name: fmt.Sprintf("%s (%s)", inst.ServiceName, inst.NodeIP), SystemID: inst.SystemID, Labels: instanceLabels(&inst),
+				})
 			}
 		}
-	}
-}
-
-// 辅助：检查数值是否满足条件
-func checkCondition(rule *protocol.AlertRule, status string, cpu, mem float64) (float64, bool) {
-	var currentVal float64
 
-	// 特殊处理状态检查
-	if rule.Metric == "status" {
-		// 约定：如果 rule.Condition 是 "="，且 rule.Threshold 是 0(offline/stopped) 或 1(online/running)
-		// 这里简化：如果 metric 是 status，我们认为 offline/stopped/error 是异常(1), online/running 是正常(0)
-		isAbnormal := false
-		if status != "online" && status != "running" {
-			isAbnormal = true
-		}
-		// 如果规则是 "> 0"，则异常时触发
-		if isAbnormal {
-			return 1, true
+		for _, target := range targets {
+			seen[stateKey{RuleID: rule.ID, TargetID: target.ID}] = true
+			var res evalResult
+			if rule.TargetType == "log" {
+				res = am.evalLogRule(rule, target, now.Unix())
+			} else {
+				r := expr.Eval(src, target.ID, now.Unix())
+				res = evalResult{Value: r.Value, Triggered: r.Triggered}
+			}
+			if res.Triggered {
+				res.Message = renderMessage(rule, expr, tpl, target, res)
+			}
+			am.handleState(rule, target, res, silences.Match(rule.ID, target.ID, target.SystemID, now), now.Unix())
 		}
-		return 0, false
 	}
 
-	if rule.Metric == "cpu" {
-		currentVal = cpu
-	}
-	if rule.Metric == "mem" {
-		currentVal = mem
-	}
+	am.cleanupStale(rules, seen)
+}
 
-	if rule.Condition == ">" && currentVal > rule.Threshold {
-		return currentVal, true
+// cleanupStale 清理本轮未评估到的状态 (节点/实例被删除，或不再属于规则范围)
+// 已触发的告警按正常流程恢复，以免永远停留在 firing
+func (am *AlertManager) cleanupStale(rules []*protocol.AlertRule, seen map[stateKey]bool) {
+	ruleMap := make(map[int64]*protocol.AlertRule, len(rules))
+	for _, r := range rules {
+		ruleMap[r.ID] = r
 	}
-	if rule.Condition == "<" && currentVal < rule.Threshold {
-		return currentVal, true
+
+	for key, state := range am.states {
+		if seen[key] {
+			continue
+		}
+		if state.IsFiring {
+			log.Printf("[Alert] target %s of rule %d is gone, resolving event %d", key.TargetID, key.RuleID, state.EventID)
+			rule, ok := ruleMap[key.RuleID]
+			am.resolveAlert(rule, state.EventID, ok && state.Notified)
+		}
+		delete(am.states, key)
 	}
-	return currentVal, false
 }
 
 // 辅助：状态流转 (Pending -> Firing -> Resolved)
-func (am *AlertManager) handleState(rule *protocol.AlertRule, targetID, targetName string, val float64, triggered bool, now int64) {
-	key := stateKey{RuleID: rule.ID, TargetID: targetID}
+func (am *AlertManager) handleState(rule *protocol.AlertRule, target alertTarget, res evalResult, silenced bool, now int64) {
+	key := stateKey{RuleID: rule.ID, TargetID: target.ID}
 	state, exists := am.states[key]
 
-	if triggered {
+	if res.Triggered {
 		if !exists {
 			// 1. 首次触发 -> 进入 Pending
 			am.states[key] = &alertState{FirstTriggerTime: now, IsFiring: false}
@@ -202,19 +467,24 @@ func (am *AlertManager) handleState(rule *protocol.AlertRule, targetID, targetNa
 				// 检查是否达到 Duration
 				if now-state.FirstTriggerTime >= int64(rule.Duration) {
 					// -> Firing (记录数据库 + 广播)
-					eventID := am.fireAlert(rule, targetID, targetName, val)
+					eventID := am.fireAlert(rule, target, res, silenced)
 					state.IsFiring = true
 					state.EventID = eventID
+					state.Silenced = silenced
+					state.Notified = !silenced
+					state.LastNotifyTime = now
 				}
+			} else {
+				// 已经是 Firing：处理静默结束与重复通知
+				am.renotify(rule, state, silenced, now)
 			}
-			// 如果已经是 Firing，保持现状 (暂不重复发通知)
 		}
 	} else {
 		if exists {
 			// 3. 恢复正常
 			if state.IsFiring {
-				// -> Resolved
-				am.resolveAlert(state.EventID)
+				// -> Resolved (发过触发通知的才通知恢复；触发后才进入静默的仍需通知，否则接收方只收到 firing)
+				am.resolveAlert(rule, state.EventID, state.Notified)
 			}
 			// 清除状态
 			delete(am.states, key)
@@ -222,32 +492,120 @@ func (am *AlertManager) handleState(rule *protocol.AlertRule, targetID, targetNa
 	}
 }
 
-func (am *AlertManager) fireAlert(rule *protocol.AlertRule, targetID, targetName string, val float64) int64 {
-	msg := fmt.Sprintf("[%s] %s %s %.1f (Threshold: %.1f)", rule.Name, targetName, rule.Metric, val, rule.Threshold)
-	log.Printf("🔥 ALERT FIRING: %s", msg)
+// renotify 持续告警期间的通知处理
+// 1. 静默结束后补发一次触发通知 (静默期间触发、尚未通知过的)
+// 2. 配置了 RepeatInterval 且未被确认时，按间隔重复通知
+func (am *AlertManager) renotify(rule *protocol.AlertRule, state *alertState, silenced bool, now int64) {
+	if silenced {
+		state.Silenced = true
+		return
+	}
+
+	due := false
+	if state.Silenced {
+		state.Silenced = false
+		am.db.Exec("UPDATE sys_alert_events SET silenced = 0 WHERE id = ?", state.EventID)
+		due = !state.Notified
+	}
+	if !due && rule.RepeatInterval > 0 && !state.Acked && now-state.LastNotifyTime >= int64(rule.RepeatInterval) {
+		due = true
+	}
+	if !due {
+		return
+	}
+
+	if ev, err := am.getEvent(state.EventID); err == nil {
+		state.LastNotifyTime = now
+		state.Notified = true
+		am.notify(rule, ev)
+	}
+}
+
+func (am *AlertManager) fireAlert(rule *protocol.AlertRule, target alertTarget, res evalResult, silenced bool) int64 {
+	// 已存在未结束的事件 (例如状态丢失后重新进入 firing)，沿用原事件，不重复记录与通知
+	if id, ok := am.findFiringEvent(rule.ID, target.ID); ok {
+		return id
+	}
+
+	msg, val := res.Message, res.Value
+	if silenced {
+		log.Printf("🔕 ALERT FIRING (silenced): %s", msg)
+	} else {
+		log.Printf("🔥 ALERT FIRING: %s", msg)
+	}
 
 	// 写入 DB
-	res, _ := am.db.Exec(`INSERT INTO sys_alert_events (rule_id, rule_name, target_type, target_id, target_name, metric_val, message, status, start_time) VALUES (?, ?, ?, ?, ?, ?, ?, 'firing', ?)`,
-		rule.ID, rule.Name, rule.TargetType, targetID, targetName, val, msg, time.Now().Unix())
+	dbRes, _ := am.db.Exec(`INSERT INTO sys_alert_events (rule_id, rule_name, target_type, target_id, target_name, metric_val, message, status, start_time, silenced, severity, samples) VALUES (?, ?, ?, ?, ?, ?, ?, 'firing', ?, ?, ?, ?)`,
+		rule.ID, rule.Name, rule.TargetType, target.ID, target.Name, val, msg, time.Now().Unix(), silenced, rule.Severity, encodeStrings(res.Samples))
 
-	id, _ := res.LastInsertId()
+	id, _ := dbRes.LastInsertId()
 
-	// WebSocket 广播
+	// WebSocket 广播 (静默的告警仍然推送给前端展示)
 	ws.BroadcastAlerts(map[string]interface{}{
-		"type": "fire", "message": msg, "target": targetName,
+		"type": "fire", "message": msg, "target": target.Name, "silenced": silenced, "severity": rule.Severity,
 	})
 
+	// 外部通知渠道
+	if !silenced {
+		am.notify(rule, &protocol.AlertEvent{
+			ID: id, RuleID: rule.ID, RuleName: rule.Name, TargetType: rule.TargetType, TargetID: target.ID,
+			TargetName: target.Name, MetricVal: val, Message: msg, Status: "firing", StartTime: time.Now().Unix(), Severity: rule.Severity, Samples: res.Samples,
+		})
+	}
+
 	return id
 }
 
-func (am *AlertManager) resolveAlert(eventID int64) {
+func (am *AlertManager) getEvent(eventID int64) (*protocol.AlertEvent, error) {
+	var ev protocol.AlertEvent
+	var samples string
+	err := am.db.QueryRow(`SELECT id, rule_id, rule_name, target_type, target_id, target_name, metric_val, message, status, start_time, COALESCE(end_time, 0), COALESCE(severity, 'warning'), COALESCE(samples, '[]') FROM sys_alert_events WHERE id = ?`, eventID).
+		Scan(&ev.ID, &ev.RuleID, &ev.RuleName, &ev.TargetType, &ev.TargetID, &ev.TargetName, &ev.MetricVal, &ev.Message, &ev.Status, &ev.StartTime, &ev.EndTime, &ev.Severity, &samples)
+	if err != nil {
+		return nil, err
+	}
+	ev.Samples = decodeStrings(samples)
+	return &ev, nil
+}
+
+func (am *AlertManager) resolveAlert(rule *protocol.AlertRule, eventID int64, sendNotify bool) {
 	log.Printf("✅ ALERT RESOLVED: Event %d", eventID)
-	am.db.Exec(`UPDATE sys_alert_events SET status = 'resolved', end_time = ? WHERE id = ?`, time.Now().Unix(), eventID)
+	now := time.Now().Unix()
+	am.db.Exec(`UPDATE sys_alert_events SET status = 'resolved', end_time = ? WHERE id = ?`, now, eventID)
 
 	// 广播
 	ws.BroadcastAlerts(map[string]interface{}{
 		"type": "resolve", "id": eventID,
 	})
+
+	if !sendNotify {
+		return
+	}
+	if ev, err := am.getEvent(eventID); err == nil {
+		am.notify(rule, ev)
+	}
+}
+
+// notify 将告警事件推送到规则绑定的通知渠道
+func (am *AlertManager) notify(rule *protocol.AlertRule, ev *protocol.AlertEvent) {
+	if am.notifyMgr == nil || len(rule.ChannelIDs) == 0 {
+		return
+	}
+	am.notifyMgr.Dispatch(rule.ChannelIDs, notify.Message{
+		Kind:       ev.Status,
+		EventID:    ev.ID,
+		RuleID:     rule.ID,
+		RuleName:   rule.Name,
+		Severity:   ev.Severity,
+		TargetType: ev.TargetType,
+		TargetID:   ev.TargetID,
+		TargetName: ev.TargetName,
+		Value:      ev.MetricVal,
+		Message:    ev.Message,
+		Samples:    ev.Samples,
+		StartTime:  ev.StartTime,
+		EndTime:    ev.EndTime,
+	})
 }
 
 // DeleteEvent 删除单个告警记录
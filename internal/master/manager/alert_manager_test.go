@@ -0,0 +1,92 @@
+package manager_test
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"ops-system/internal/master/manager"
+	"ops-system/internal/master/ws"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func init() {
+	// 告警触发/恢复会向 Hub 推送消息
+	go ws.GlobalHub.Run()
+}
+
+// setupAlertDB 在 setupTestDB 基础上补充告警相关表
+func setupAlertDB(t *testing.T) *sql.DB {
+	db := setupTestDB(t)
+	// 内存库每个连接独立，评估协程与测试需共用同一连接
+	db.SetMaxOpenConns(1)
+
+	sqls := []string{
+		`CREATE TABLE node_infos (id TEXT PRIMARY KEY, ip TEXT, ips TEXT DEFAULT '[]', registered INTEGER DEFAULT 0, secret TEXT DEFAULT '', labels TEXT DEFAULT '{}', worker_labels TEXT DEFAULT '{}', cordoned INTEGER DEFAULT 0, cordon_time INTEGER DEFAULT 0, drained_instances TEXT DEFAULT '[]', agent_version TEXT DEFAULT '', agent_platform TEXT DEFAULT '', upgrade_target TEXT DEFAULT '', upgrade_deadline INTEGER DEFAULT 0, port INTEGER, hostname TEXT, name TEXT, mac_addr TEXT, os TEXT, arch TEXT, cpu_cores INTEGER, mem_total INTEGER, disk_total INTEGER, status TEXT, last_heartbeat INTEGER);`,
+		`CREATE TABLE sys_alert_rules (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT, target_type TEXT, metric TEXT, condition TEXT, threshold REAL, duration INTEGER, enabled BOOLEAN, channel_ids TEXT DEFAULT '[]', repeat_interval INTEGER DEFAULT 0, severity TEXT DEFAULT 'warning', system_id TEXT DEFAULT '', service_name TEXT DEFAULT '', node_ips TEXT DEFAULT '[]', node_group TEXT DEFAULT '', label_selector TEXT DEFAULT '', expr TEXT DEFAULT '', message_template TEXT DEFAULT '', log_key TEXT DEFAULT '', pattern TEXT DEFAULT '', log_window INTEGER DEFAULT 0);`,
+		`CREATE TABLE sys_alert_events (id INTEGER PRIMARY KEY AUTOINCREMENT, rule_id INTEGER, rule_name TEXT, target_type TEXT, target_id TEXT, target_name TEXT, metric_val REAL, message TEXT, status TEXT, start_time INTEGER, end_time INTEGER, silenced BOOLEAN DEFAULT 0, acked BOOLEAN DEFAULT 0, ack_by TEXT DEFAULT '', ack_comment TEXT DEFAULT '', ack_time INTEGER DEFAULT 0, severity TEXT DEFAULT 'warning', samples TEXT DEFAULT '[]');`,
+		`CREATE TABLE sys_alert_silences (id INTEGER PRIMARY KEY AUTOINCREMENT, rule_id INTEGER, target_id TEXT, system_id TEXT, start_time INTEGER, end_time INTEGER, comment TEXT, creator TEXT, create_time INTEGER);`,
+		`CREATE TABLE sys_maintenance_windows (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT, rule_id INTEGER, target_id TEXT, system_id TEXT, weekdays TEXT, start_time TEXT, end_time TEXT, enabled BOOLEAN, comment TEXT);`,
+	}
+	for _, s := range sqls {
+		_, err := db.Exec(s)
+		assert.NoError(t, err)
+	}
+	return db
+}
+
+func insertFiring(t *testing.T, db *sql.DB, ruleID int64, targetID string) int64 {
+	res, err := db.Exec(`INSERT INTO sys_alert_events (rule_id, rule_name, target_type, target_id, target_name, metric_val, message, status, start_time) VALUES (?, 'r', 'instance', ?, ?, 1, 'm', 'firing', ?)`,
+		ruleID, targetID, targetID, time.Now().Unix()-60)
+	assert.NoError(t, err)
+	id, _ := res.LastInsertId()
+	return id
+}
+
+func eventStatus(t *testing.T, db *sql.DB, id int64) string {
+	var status string
+	assert.NoError(t, db.QueryRow("SELECT status FROM sys_alert_events WHERE id = ?", id).Scan(&status))
+	return status
+}
+
+func TestAlertStateRestore(t *testing.T) {
+	db := setupAlertDB(t)
+	defer db.Close()
+
+	// 一条实例状态规则，实例 inst-1 处于停止状态
+	_, err := db.Exec(`INSERT INTO sys_alert_rules (id, name, target_type, metric, condition, threshold, duration, enabled) VALUES (1, 'down', 'instance', 'status', '>', 0, 0, 1)`)
+	assert.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO instance_infos (id, system_id, node_ip, service_name, service_version, status, pid, uptime) VALUES ('inst-1', 'sys', '10.0.0.1', 'api', 'v1', 'stopped', 0, 0)`)
+	assert.NoError(t, err)
+
+	// 模拟重启前遗留的事件
+	kept := insertFiring(t, db, 1, "inst-1")
+	dup := insertFiring(t, db, 1, "inst-1")     // 重复事件
+	orphan := insertFiring(t, db, 99, "inst-1") // 规则已删除
+	gone := insertFiring(t, db, 1, "inst-gone") // 实例已删除
+
+	nodeMgr := manager.NewNodeManager(db, nil, time.Minute)
+	instMgr := manager.NewInstanceManager(db, nil)
+	am := manager.NewAlertManager(db, nodeMgr, instMgr, nil, nil)
+
+	// 启动恢复：重复事件与孤儿事件被结束
+	assert.Equal(t, "firing", eventStatus(t, db, kept))
+	assert.Equal(t, "resolved", eventStatus(t, db, dup))
+	assert.Equal(t, "resolved", eventStatus(t, db, orphan))
+
+	// 评估一轮：沿用已有事件，不产生新事件；已删除实例的告警被恢复
+	am.EvaluateOnce()
+
+	var firing int
+	assert.NoError(t, db.QueryRow("SELECT count(*) FROM sys_alert_events WHERE status = 'firing'").Scan(&firing))
+	assert.Equal(t, 1, firing)
+	assert.Equal(t, "firing", eventStatus(t, db, kept))
+	assert.Equal(t, "resolved", eventStatus(t, db, gone))
+
+	// 实例恢复运行后，原事件正常结束
+	_, err = db.Exec(`UPDATE instance_infos SET status = 'running' WHERE id = 'inst-1'`)
+	assert.NoError(t, err)
+	am.EvaluateOnce()
+	assert.Equal(t, "resolved", eventStatus(t, db, kept))
+}
@@ -0,0 +1,124 @@
+package manager
+
+import (
+	"fmt"
+
+	"ops-system/pkg/labels"
+	"ops-system/pkg/protocol"
+)
+
+// validateRule 校验并补全规则 (添加/修改时调用)
+func validateRule(r *protocol.AlertRule) error {
+	switch r.TargetType {
+	case "node", "instance":
+	case "log":
+		if r.Pattern == "" {
+			return fmt.Errorf("pattern is required for log rules")
+		}
+		if r.Window <= 0 {
+			r.Window = defaultLogWindow
+		}
+		if r.Threshold <= 0 {
+			r.Threshold = 1
+		}
+	default:
+		return fmt.Errorf("invalid target_type: %s", r.TargetType)
+	}
+	switch r.Severity {
+	case "":
+		r.Severity = protocol.SeverityWarning
+	case protocol.SeverityInfo, protocol.SeverityWarning, protocol.SeverityCritical:
+	default:
+		return fmt.Errorf("invalid severity: %s", r.Severity)
+	}
+	if _, err := labels.Parse(r.LabelSelector); err != nil {
+		return err
+	}
+	_, _, err := compileRule(r)
+	return err
+}
+
+// ruleScope 规则作用范围 (一次评估周期内预解析，避免重复解析选择器)
+type ruleScope struct {
+	rule       *protocol.AlertRule
+	selector   labels.Selector
+	nodeIPs    map[string]bool
+	groupNodes map[string]bool // 规则指定分组时的成员节点 ID
+}
+
+// groups 为分组成员索引 (见 NodeManager.GroupMembers)，分组不存在时规则不匹配任何目标
+func newRuleScope(rule *protocol.AlertRule, groups map[string]map[string]bool) (*ruleScope, error) {
+	sel, err := labels.Parse(rule.LabelSelector)
+	if err != nil {
+		return nil, err
+	}
+	rs := &ruleScope{rule: rule, selector: sel}
+	if rule.NodeGroup != "" {
+		rs.groupNodes = groups[rule.NodeGroup]
+		if rs.groupNodes == nil {
+			rs.groupNodes = map[string]bool{}
+		}
+	}
+	if len(rule.NodeIPs) > 0 {
+		rs.nodeIPs = make(map[string]bool, len(rule.NodeIPs))
+		for _, ip := range rule.NodeIPs {
+			rs.nodeIPs[ip] = true
+		}
+	}
+	return rs, nil
+}
+
+func (rs *ruleScope) matchNodeIP(ip string) bool {
+	return rs.nodeIPs == nil || rs.nodeIPs[ip]
+}
+
+func (rs *ruleScope) matchGroup(nodeID string) bool {
+	return rs.groupNodes == nil || rs.groupNodes[nodeID]
+}
+
+// MatchInstance 判断实例是否在规则范围内
+func (rs *ruleScope) MatchInstance(inst *protocol.InstanceInfo) bool {
+	if rs.rule.SystemID != "" && rs.rule.SystemID != inst.SystemID {
+		return false
+	}
+	if rs.rule.ServiceName != "" && rs.rule.ServiceName != inst.ServiceName {
+		return false
+	}
+	if !rs.matchNodeIP(inst.NodeIP) || !rs.matchGroup(inst.NodeID) {
+		return false
+	}
+	return rs.selector.Matches(instanceLabels(inst))
+}
+
+// MatchNode 判断节点是否在规则范围内
+// hosted 为该节点上运行的实例 (用于按系统/服务圈定节点)
+func (rs *ruleScope) MatchNode(node *protocol.NodeInfo, hosted []protocol.InstanceInfo) bool {
+	if !rs.matchNodeIP(node.IP) || !rs.matchGroup(node.ID) {
+		return false
+	}
+	if rs.rule.SystemID != "" || rs.rule.ServiceName != "" {
+		found := false
+		for i := range hosted {
+			inst := &hosted[i]
+			if (rs.rule.SystemID == "" || rs.rule.SystemID == inst.SystemID) &&
+				(rs.rule.ServiceName == "" || rs.rule.ServiceName == inst.ServiceName) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return rs.selector.Matches(nodeLabels(node))
+}
+
+// instanceLabels 实例内置标签
+func instanceLabels(inst *protocol.InstanceInfo) map[string]string {
+	return map[string]string{
+		"system":  inst.SystemID,
+		"service": inst.ServiceName,
+		"version": inst.ServiceVersion,
+		"node":    inst.NodeIP,
+	}
+}
@@ -0,0 +1,204 @@
+package manager
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"ops-system/pkg/protocol"
+)
+
+// --- 静默管理 ---
+
+func (am *AlertManager) AddSilence(s protocol.AlertSilence) error {
+	if s.EndTime <= s.StartTime {
+		return fmt.Errorf("end_time must be after start_time")
+	}
+	_, err := am.db.Exec(`INSERT INTO sys_alert_silences (rule_id, target_id, system_id, start_time, end_time, comment, creator, create_time) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		s.RuleID, s.TargetID, s.SystemID, s.StartTime, s.EndTime, s.Comment, s.Creator, time.Now().Unix())
+	return err
+}
+
+func (am *AlertManager) DeleteSilence(id int64) error {
+	_, err := am.db.Exec("DELETE FROM sys_alert_silences WHERE id = ?", id)
+	return err
+}
+
+// GetSilences 获取静默列表 (activeOnly=true 时只返回尚未结束的)
+func (am *AlertManager) GetSilences(activeOnly bool) ([]*protocol.AlertSilence, error) {
+	query := "SELECT id, rule_id, target_id, system_id, start_time, end_time, comment, creator, create_time FROM sys_alert_silences"
+	var args []interface{}
+	if activeOnly {
+		query += " WHERE end_time >= ?"
+		args = append(args, time.Now().Unix())
+	}
+	query += " ORDER BY start_time DESC"
+
+	rows, err := am.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := []*protocol.AlertSilence{}
+	for rows.Next() {
+		var s protocol.AlertSilence
+		rows.Scan(&s.ID, &s.RuleID, &s.TargetID, &s.SystemID, &s.StartTime, &s.EndTime, &s.Comment, &s.Creator, &s.CreateTime)
+		list = append(list, &s)
+	}
+	return list, nil
+}
+
+// --- 维护窗口管理 ---
+
+func (am *AlertManager) AddMaintenanceWindow(mw protocol.MaintenanceWindow) error {
+	if _, err := parseClock(mw.StartTime); err != nil {
+		return err
+	}
+	if _, err := parseClock(mw.EndTime); err != nil {
+		return err
+	}
+	days, _ := json.Marshal(mw.Weekdays)
+	_, err := am.db.Exec(`INSERT INTO sys_maintenance_windows (name, rule_id, target_id, system_id, weekdays, start_time, end_time, enabled, comment) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		mw.Name, mw.RuleID, mw.TargetID, mw.SystemID, string(days), mw.StartTime, mw.EndTime, mw.Enabled, mw.Comment)
+	return err
+}
+
+func (am *AlertManager) DeleteMaintenanceWindow(id int64) error {
+	_, err := am.db.Exec("DELETE FROM sys_maintenance_windows WHERE id = ?", id)
+	return err
+}
+
+func (am *AlertManager) GetMaintenanceWindows() ([]*protocol.MaintenanceWindow, error) {
+	rows, err := am.db.Query("SELECT id, name, rule_id, target_id, system_id, weekdays, start_time, end_time, enabled, comment FROM sys_maintenance_windows")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := []*protocol.MaintenanceWindow{}
+	for rows.Next() {
+		var mw protocol.MaintenanceWindow
+		var days string
+		rows.Scan(&mw.ID, &mw.Name, &mw.RuleID, &mw.TargetID, &mw.SystemID, &days, &mw.StartTime, &mw.EndTime, &mw.Enabled, &mw.Comment)
+		json.Unmarshal([]byte(days), &mw.Weekdays)
+		list = append(list, &mw)
+	}
+	return list, nil
+}
+
+// --- 确认 ---
+
+// AckEvent 确认告警事件，确认后不再重复通知
+func (am *AlertManager) AckEvent(id int64, operator, comment string) error {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+
+	res, err := am.db.Exec(`UPDATE sys_alert_events SET acked = 1, ack_by = ?, ack_comment = ?, ack_time = ? WHERE id = ? AND status = 'firing'`,
+		operator, comment, time.Now().Unix(), id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("event %d not found or already resolved", id)
+	}
+
+	for _, st := range am.states {
+		if st.EventID == id {
+			st.Acked = true
+		}
+	}
+	return nil
+}
+
+// --- 匹配 ---
+
+// silenceMatcher 一次评估周期内使用的静默规则快照
+type silenceMatcher struct {
+	silences []*protocol.AlertSilence
+	windows  []*protocol.MaintenanceWindow
+}
+
+func (am *AlertManager) loadSilenceMatcher() *silenceMatcher {
+	sm := &silenceMatcher{}
+	sm.silences, _ = am.GetSilences(true)
+	sm.windows, _ = am.GetMaintenanceWindows()
+	return sm
+}
+
+// Match 判断某条规则在某个目标上此刻是否处于静默
+func (sm *silenceMatcher) Match(ruleID int64, targetID, systemID string, now time.Time) bool {
+	ts := now.Unix()
+	for _, s := range sm.silences {
+		if ts < s.StartTime || ts > s.EndTime {
+			continue
+		}
+		if matchScope(s.RuleID, s.TargetID, s.SystemID, ruleID, targetID, systemID) {
+			return true
+		}
+	}
+	for _, mw := range sm.windows {
+		if !mw.Enabled || !inWindow(mw, now) {
+			continue
+		}
+		if matchScope(mw.RuleID, mw.TargetID, mw.SystemID, ruleID, targetID, systemID) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchScope(sRule int64, sTarget, sSystem string, ruleID int64, targetID, systemID string) bool {
+	if sRule != 0 && sRule != ruleID {
+		return false
+	}
+	if sTarget != "" && sTarget != targetID {
+		return false
+	}
+	if sSystem != "" && sSystem != systemID {
+		return false
+	}
+	return true
+}
+
+// inWindow 判断当前时间是否落在维护窗口内 (支持跨零点，如 23:00-01:00)
+func inWindow(mw *protocol.MaintenanceWindow, now time.Time) bool {
+	start, err1 := parseClock(mw.StartTime)
+	end, err2 := parseClock(mw.EndTime)
+	if err1 != nil || err2 != nil {
+		return false
+	}
+	minute := now.Hour()*60 + now.Minute()
+	weekday := int(now.Weekday())
+
+	if start <= end {
+		return minute >= start && minute < end && matchWeekday(mw.Weekdays, weekday)
+	}
+	// 跨零点：零点前属于当天窗口，零点后属于前一天开始的窗口
+	if minute >= start {
+		return matchWeekday(mw.Weekdays, weekday)
+	}
+	if minute < end {
+		return matchWeekday(mw.Weekdays, (weekday+6)%7)
+	}
+	return false
+}
+
+func matchWeekday(days []int, day int) bool {
+	if len(days) == 0 {
+		return true
+	}
+	for _, d := range days {
+		if d == day {
+			return true
+		}
+	}
+	return false
+}
+
+// parseClock "HH:MM" -> 当天分钟数
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, expect HH:MM", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
@@ -0,0 +1,64 @@
+package manager_test
+
+import (
+	"testing"
+	"time"
+
+	"ops-system/internal/master/manager"
+	"ops-system/pkg/protocol"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSilenceMatch(t *testing.T) {
+	now := time.Date(2026, 10, 14, 12, 0, 0, 0, time.Local) // 周三
+	silences := []*protocol.AlertSilence{
+		{RuleID: 1, StartTime: now.Add(-time.Hour).Unix(), EndTime: now.Add(time.Hour).Unix()},
+		{TargetID: "inst-1", StartTime: now.Add(time.Hour).Unix(), EndTime: now.Add(2 * time.Hour).Unix()},
+		{SystemID: "sys-a", StartTime: now.Add(-time.Hour).Unix(), EndTime: now.Add(time.Hour).Unix()},
+	}
+	match := func(ruleID int64, targetID, systemID string, at time.Time) bool {
+		return manager.SilenceMatch(silences, nil, ruleID, targetID, systemID, at)
+	}
+
+	// 按规则、系统匹配；未指定的条件不限制
+	assert.True(t, match(1, "inst-9", "", now))
+	assert.True(t, match(2, "inst-9", "sys-a", now))
+	assert.False(t, match(2, "inst-9", "sys-b", now))
+	// 尚未开始或已结束的静默不生效
+	assert.False(t, match(2, "inst-1", "", now))
+	assert.True(t, match(2, "inst-1", "", now.Add(90*time.Minute)))
+	assert.False(t, match(1, "inst-9", "", now.Add(2*time.Hour)))
+}
+
+func TestMaintenanceWindow(t *testing.T) {
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2026, 10, day, hour, minute, 0, 0, time.Local) // 10-14 为周三
+	}
+	windows := []*protocol.MaintenanceWindow{
+		// 每周三 23:00 至次日 01:00 (跨零点)
+		{Name: "nightly", RuleID: 1, Weekdays: []int{3}, StartTime: "23:00", EndTime: "01:00", Enabled: true},
+		// 每天 02:00-03:00
+		{Name: "backup", RuleID: 2, StartTime: "02:00", EndTime: "03:00", Enabled: true},
+		{Name: "disabled", RuleID: 3, StartTime: "00:00", EndTime: "23:59", Enabled: false},
+	}
+	match := func(ruleID int64, now time.Time) bool {
+		return manager.SilenceMatch(nil, windows, ruleID, "inst-1", "", now)
+	}
+
+	// 跨零点: 周三 23:00 后与周四 01:00 前都属于周三开始的窗口
+	assert.False(t, match(1, at(14, 22, 59)))
+	assert.True(t, match(1, at(14, 23, 0)))
+	assert.True(t, match(1, at(15, 0, 30)))
+	assert.False(t, match(1, at(15, 1, 0)))
+	// 周二开始的窗口不存在: 周三凌晨不匹配，周四 23:00 也不匹配
+	assert.False(t, match(1, at(14, 0, 30)))
+	assert.False(t, match(1, at(15, 23, 30)))
+
+	// 不限星期的普通窗口，结束时间不含
+	assert.True(t, match(2, at(16, 2, 0)))
+	assert.False(t, match(2, at(16, 3, 0)))
+	// 规则不符或窗口已禁用
+	assert.False(t, match(1, at(16, 2, 30)))
+	assert.False(t, match(3, at(16, 12, 0)))
+}
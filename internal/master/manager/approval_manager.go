@@ -0,0 +1,140 @@
+package manager
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"ops-system/pkg/protocol"
+)
+
+// 审批单状态
+const (
+	ApprovalPending  = "pending"
+	ApprovalApproved = "approved"
+	ApprovalRejected = "rejected"
+	ApprovalExpired  = "expired"
+)
+
+// ApprovalTTL 审批单有效期，超时未处理视为过期
+const ApprovalTTL = time.Hour
+
+var (
+	ErrApprovalNotFound = errors.New("审批单不存在或已处理")
+	ErrSelfApproval     = errors.New("不能审批自己提交的命令")
+)
+
+// ApprovalManager 命中审批规则的命令 (需申请人以外的用户审批)
+type ApprovalManager struct {
+	db *sql.DB
+}
+
+func NewApprovalManager(db *sql.DB) *ApprovalManager {
+	return &ApprovalManager{db: db}
+}
+
+// Create 创建待审批单，payload 为审批通过后用于下发的原始请求
+func (am *ApprovalManager) Create(kind, command, target, payload, requester, rule string) (*protocol.ExecApproval, error) {
+	now := time.Now()
+	a := &protocol.ExecApproval{
+		ID: fmt.Sprintf("appr-%d", now.UnixNano()), Kind: kind, Command: command, Target: target, Payload: payload,
+		Requester: requester, Rule: rule, Status: ApprovalPending, CreateTime: now.Unix(),
+	}
+	_, err := am.db.Exec(`INSERT INTO exec_approvals (id, kind, command, target, payload, requester, rule, status, create_time) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		a.ID, a.Kind, a.Command, a.Target, a.Payload, a.Requester, a.Rule, a.Status, a.CreateTime)
+	if err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// Get 获取审批单
+func (am *ApprovalManager) Get(id string) (*protocol.ExecApproval, bool) {
+	am.expire()
+	a, err := scanApproval(am.db.QueryRow(`SELECT `+approvalColumns+` FROM exec_approvals WHERE id = ?`, id))
+	if err != nil {
+		return nil, false
+	}
+	return a, true
+}
+
+// List 分页查询审批单 (按创建时间倒序)，status 为空时查询全部
+func (am *ApprovalManager) List(status string, page, pageSize int) (*protocol.ExecApprovalQueryResp, error) {
+	am.expire()
+	if page < 1 {
+		page = 1
+	}
+	if pageSize <= 0 {
+		pageSize = 20
+	}
+	where, args := "", []interface{}{}
+	if status != "" {
+		where, args = " WHERE status = ?", append(args, status)
+	}
+	resp := &protocol.ExecApprovalQueryResp{List: []*protocol.ExecApproval{}}
+	if err := am.db.QueryRow(`SELECT COUNT(*) FROM exec_approvals`+where, args...).Scan(&resp.Total); err != nil {
+		return nil, err
+	}
+	rows, err := am.db.Query(`SELECT `+approvalColumns+` FROM exec_approvals`+where+` ORDER BY create_time DESC, id DESC LIMIT ? OFFSET ?`,
+		append(args, pageSize, (page-1)*pageSize)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if a, err := scanApproval(rows); err == nil {
+			resp.List = append(resp.List, a)
+		}
+	}
+	return resp, nil
+}
+
+// Decide 审批 (申请人不能审批自己的命令)，仅待审批状态可处理，并发审批只有一个生效
+func (am *ApprovalManager) Decide(id, approver string, approve bool, comment string) (*protocol.ExecApproval, error) {
+	a, ok := am.Get(id)
+	if !ok || a.Status != ApprovalPending {
+		return nil, ErrApprovalNotFound
+	}
+	if a.Requester == approver {
+		return nil, ErrSelfApproval
+	}
+	status := ApprovalRejected
+	if approve {
+		status = ApprovalApproved
+	}
+	now := time.Now().Unix()
+	res, err := am.db.Exec(`UPDATE exec_approvals SET status = ?, approver = ?, comment = ?, decide_time = ? WHERE id = ? AND status = ?`,
+		status, approver, comment, now, id, ApprovalPending)
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, ErrApprovalNotFound
+	}
+	a.Status, a.Approver, a.Comment, a.DecideTime = status, approver, comment, now
+	return a, nil
+}
+
+// SetResult 记录审批通过后的下发结果
+func (am *ApprovalManager) SetResult(id, result string) {
+	am.db.Exec(`UPDATE exec_approvals SET result = ? WHERE id = ?`, result, id)
+}
+
+// expire 将超过有效期的待审批单标记为过期
+func (am *ApprovalManager) expire() {
+	am.db.Exec(`UPDATE exec_approvals SET status = ?, decide_time = ? WHERE status = ? AND create_time < ?`,
+		ApprovalExpired, time.Now().Unix(), ApprovalPending, time.Now().Add(-ApprovalTTL).Unix())
+}
+
+const approvalColumns = `id, kind, command, target, payload, requester, rule, status, approver, comment, result, create_time, decide_time`
+
+func scanApproval(row interface{ Scan(...interface{}) error }) (*protocol.ExecApproval, error) {
+	var a protocol.ExecApproval
+	err := row.Scan(&a.ID, &a.Kind, &a.Command, &a.Target, &a.Payload, &a.Requester, &a.Rule, &a.Status,
+		&a.Approver, &a.Comment, &a.Result, &a.CreateTime, &a.DecideTime)
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
@@ -0,0 +1,50 @@
+package manager_test
+
+import (
+	"testing"
+	"time"
+
+	"ops-system/internal/master/manager"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApprovalDecide(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	_, err := db.Exec(`CREATE TABLE exec_approvals (id TEXT PRIMARY KEY, kind TEXT, command TEXT, target TEXT DEFAULT '', payload TEXT DEFAULT '', requester TEXT, rule TEXT DEFAULT '', status TEXT DEFAULT 'pending', approver TEXT DEFAULT '', comment TEXT DEFAULT '', result TEXT DEFAULT '', create_time INTEGER, decide_time INTEGER DEFAULT 0);`)
+	require.NoError(t, err)
+
+	am := manager.NewApprovalManager(db)
+	a, err := am.Create("cmd", "reboot", "10.0.0.1", `{"command":"reboot"}`, "bob", "reboot")
+	require.NoError(t, err)
+
+	// 申请人不能审批自己的命令
+	_, err = am.Decide(a.ID, "bob", true, "")
+	assert.ErrorIs(t, err, manager.ErrSelfApproval)
+
+	got, err := am.Decide(a.ID, "alice", true, "ok")
+	require.NoError(t, err)
+	assert.Equal(t, manager.ApprovalApproved, got.Status)
+	assert.Equal(t, `{"command":"reboot"}`, got.Payload)
+
+	// 已处理的审批单不能再次审批
+	_, err = am.Decide(a.ID, "carol", false, "")
+	assert.ErrorIs(t, err, manager.ErrApprovalNotFound)
+
+	// 超过有效期的审批单过期
+	old, err := am.Create("cmd", "reboot", "10.0.0.2", "{}", "bob", "reboot")
+	require.NoError(t, err)
+	_, err = db.Exec(`UPDATE exec_approvals SET create_time = ? WHERE id = ?`, time.Now().Add(-2*manager.ApprovalTTL).Unix(), old.ID)
+	require.NoError(t, err)
+	_, err = am.Decide(old.ID, "alice", true, "")
+	assert.ErrorIs(t, err, manager.ErrApprovalNotFound)
+
+	pending, err := am.List(manager.ApprovalPending, 1, 10)
+	require.NoError(t, err)
+	assert.Zero(t, pending.Total)
+	all, err := am.List("", 1, 10)
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, all.Total)
+}
@@ -12,26 +12,90 @@ import (
 	"time"
 )
 
+// Nacos Open API 版本
+const (
+	NacosAPIv1 = "v1"
+	NacosAPIv2 = "v2" // Nacos 2.x Open API (/nacos/v2/cs/...)
+)
+
+// defaultNacosTokenTTL 登录响应未返回 tokenTtl 时使用 (与 Nacos 默认值一致)
+const defaultNacosTokenTTL = 18000 * time.Second
+
+// maskedPassword 查询设置时返回的密码占位符，保存时原样提交表示不修改
+const maskedPassword = "******"
+
+var nacosClient = &http.Client{Timeout: 15 * time.Second}
+
 type ConfigManager struct {
-	db         *sql.DB
-	mu         sync.RWMutex
-	nacosToken string // 内存缓存 Token
-	nacosBase  string // 内存缓存 URL
+	db          *sql.DB
+	mu          sync.RWMutex
+	nacosToken  string    // 内存缓存 Token
+	nacosBase   string    // 内存缓存 URL
+	nacosAPI    string    // 内存缓存 API 版本
+	tokenExpire time.Time // 到达该时间后主动重新登录 (提前于 Nacos 的实际过期时间)
+
+	store *ConfigStore // 内置配置存储
 }
 
 func NewConfigManager(db *sql.DB) *ConfigManager {
-	return &ConfigManager{db: db}
+	return &ConfigManager{db: db, store: NewConfigStore(db)}
+}
+
+// ProviderName 当前使用的配置中心后端 (sys_settings 中 key="config_provider")
+// 未显式设置时：配置过 Nacos 连接则使用 Nacos，否则使用内置存储
+func (cm *ConfigManager) ProviderName() string {
+	var val string
+	err := cm.db.QueryRow(`SELECT value FROM sys_settings WHERE key = 'config_provider'`).Scan(&val)
+	if err == nil && (val == ConfigProviderNacos || val == ConfigProviderBuiltin) {
+		return val
+	}
+	if _, err := cm.GetNacosConfig(); err == nil {
+		return ConfigProviderNacos
+	}
+	return ConfigProviderBuiltin
+}
+
+// SetProviderName 切换配置中心后端
+func (cm *ConfigManager) SetProviderName(name string) error {
+	if name != ConfigProviderNacos && name != ConfigProviderBuiltin {
+		return fmt.Errorf("unknown config provider: %s", name)
+	}
+	_, err := cm.db.Exec(`INSERT OR REPLACE INTO sys_settings (key, value, updated_at) VALUES (?, ?, ?)`,
+		"config_provider", name, time.Now().Unix())
+	return err
+}
+
+// Provider 返回当前生效的配置中心后端
+func (cm *ConfigManager) Provider() ConfigProvider {
+	if cm.ProviderName() == ConfigProviderNacos {
+		return &nacosProvider{cm: cm}
+	}
+	return cm.store
 }
 
 // NacosConfig 对应 sys_settings 中 key="nacos_config" 的结构
 type NacosConfig struct {
-	URL      string `json:"url"`
-	Username string `json:"username"`
-	Password string `json:"password"`
+	URL        string `json:"url"`
+	Username   string `json:"username"`
+	Password   string `json:"password"`
+	APIVersion string `json:"api_version"` // v1 (默认) / v2
 }
 
 // SaveNacosConfig 保存连接信息
 func (cm *ConfigManager) SaveNacosConfig(cfg NacosConfig) error {
+	if cfg.APIVersion == "" {
+		cfg.APIVersion = NacosAPIv1
+	}
+	if cfg.APIVersion != NacosAPIv1 && cfg.APIVersion != NacosAPIv2 {
+		return fmt.Errorf("unsupported nacos api version: %s", cfg.APIVersion)
+	}
+	// 前端回传的是掩码，保留原密码
+	if cfg.Password == maskedPassword {
+		if old, err := cm.GetNacosConfig(); err == nil {
+			cfg.Password = old.Password
+		}
+	}
+
 	cm.mu.Lock()
 	defer cm.mu.Unlock()
 
@@ -41,8 +105,7 @@ func (cm *ConfigManager) SaveNacosConfig(cfg NacosConfig) error {
 		"nacos_config", string(bytes), time.Now().Unix())
 
 	// 清理缓存，触发下次重新登录
-	cm.nacosToken = ""
-	cm.nacosBase = ""
+	cm.resetSession()
 
 	return err
 }
@@ -58,136 +121,164 @@ func (cm *ConfigManager) GetNacosConfig() (*NacosConfig, error) {
 	if err := json.Unmarshal([]byte(val), &cfg); err != nil {
 		return nil, err
 	}
+	if cfg.APIVersion == "" {
+		cfg.APIVersion = NacosAPIv1
+	}
 	return &cfg, nil
 }
 
 // --- Nacos API 封装 ---
 
-// login 内部方法：获取 Token
-func (cm *ConfigManager) login() error {
-	// 如果内存里有且没过期(简单处理)，直接用
-	if cm.nacosToken != "" && cm.nacosBase != "" {
-		return nil
+// resetSession 清空登录状态 (调用方持有写锁)
+func (cm *ConfigManager) resetSession() {
+	cm.nacosToken = ""
+	cm.nacosBase = ""
+	cm.nacosAPI = ""
+	cm.tokenExpire = time.Time{}
+}
+
+// session 返回可用的地址与 Token，Token 临近过期或 force 时重新登录
+// 未配置用户名时视为 Nacos 未开启鉴权，不登录
+func (cm *ConfigManager) session(force bool) (base, token, api string, err error) {
+	cm.mu.RLock()
+	if !force && cm.nacosBase != "" && (cm.nacosToken == "" || time.Now().Before(cm.tokenExpire)) {
+		base, token, api = cm.nacosBase, cm.nacosToken, cm.nacosAPI
+		cm.mu.RUnlock()
+		return base, token, api, nil
+	}
+	cm.mu.RUnlock()
+
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	// 双重检查：等锁期间其他请求可能已完成登录
+	if !force && cm.nacosBase != "" && (cm.nacosToken == "" || time.Now().Before(cm.tokenExpire)) {
+		return cm.nacosBase, cm.nacosToken, cm.nacosAPI, nil
 	}
 
 	cfg, err := cm.GetNacosConfig()
 	if err != nil {
-		return fmt.Errorf("nacos config not found, please configure first")
+		return "", "", "", fmt.Errorf("nacos config not found, please configure first")
 	}
-
 	baseURL := strings.TrimRight(cfg.URL, "/")
-	// Nacos Login API
-	resp, err := http.PostForm(baseURL+"/nacos/v1/auth/login", url.Values{
-		"username": {cfg.Username},
-		"password": {cfg.Password},
+
+	cm.resetSession()
+	if cfg.Username != "" {
+		token, ttl, err := nacosLogin(baseURL, cfg.Username, cfg.Password)
+		if err != nil {
+			return "", "", "", err
+		}
+		cm.nacosToken = token
+		// 在 TTL 的 90% 处主动刷新，避免请求途中过期
+		cm.tokenExpire = time.Now().Add(ttl * 9 / 10)
+	}
+	cm.nacosBase = baseURL
+	cm.nacosAPI = cfg.APIVersion
+	return cm.nacosBase, cm.nacosToken, cm.nacosAPI, nil
+}
+
+// nacosLogin 登录获取 Token 与有效期 (1.x / 2.x 均使用 v1 登录接口)
+func nacosLogin(baseURL, username, password string) (string, time.Duration, error) {
+	resp, err := nacosClient.PostForm(baseURL+"/nacos/v1/auth/login", url.Values{
+		"username": {username},
+		"password": {password},
 	})
 	if err != nil {
-		return err
+		return "", 0, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != 200 {
-		return fmt.Errorf("login failed, status: %d", resp.StatusCode)
+		return "", 0, fmt.Errorf("login failed, status: %d", resp.StatusCode)
 	}
 
 	body, _ := io.ReadAll(resp.Body)
-	var resMap map[string]interface{}
-	if err := json.Unmarshal(body, &resMap); err != nil {
-		return err
+	var res struct {
+		AccessToken string `json:"accessToken"`
+		TokenTTL    int64  `json:"tokenTtl"` // 秒
 	}
-
-	token, ok := resMap["accessToken"].(string)
-	if !ok {
-		return fmt.Errorf("invalid response, no accessToken")
-	}
-
-	cm.mu.Lock()
-	cm.nacosToken = token
-	cm.nacosBase = baseURL
-	cm.mu.Unlock()
-	return nil
-}
-
-// ProxyGet 通用 GET 请求代理
-func (cm *ConfigManager) ProxyGet(apiPath string, params url.Values) ([]byte, error) {
-	if err := cm.login(); err != nil {
-		return nil, err
+	if err := json.Unmarshal(body, &res); err != nil {
+		return "", 0, err
 	}
-
-	cm.mu.RLock()
-	targetURL := cm.nacosBase + apiPath
-	params.Set("accessToken", cm.nacosToken) // 鉴权
-	fullURL := targetURL + "?" + params.Encode()
-	cm.mu.RUnlock()
-
-	resp, err := http.Get(fullURL)
-	if err != nil {
-		return nil, err
+	if res.AccessToken == "" {
+		return "", 0, fmt.Errorf("invalid response, no accessToken")
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode == 403 {
-		// Token 过期，简单重置
-		cm.mu.Lock()
-		cm.nacosToken = ""
-		cm.mu.Unlock()
-		return nil, fmt.Errorf("nacos token expired, please retry")
+	ttl := time.Duration(res.TokenTTL) * time.Second
+	if ttl <= 0 {
+		ttl = defaultNacosTokenTTL
 	}
-
-	return io.ReadAll(resp.Body)
+	return res.AccessToken, ttl, nil
 }
 
-// ProxyPost 通用 POST 请求代理
-func (cm *ConfigManager) ProxyPost(apiPath string, data url.Values) ([]byte, error) {
-	if err := cm.login(); err != nil {
-		return nil, err
-	}
-
-	cm.mu.RLock()
-	targetURL := cm.nacosBase + apiPath
-	data.Set("accessToken", cm.nacosToken)
-	cm.mu.RUnlock()
-
-	resp, err := http.PostForm(targetURL, data)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	return io.ReadAll(resp.Body)
+// NacosAPIVersion 当前连接使用的 API 版本
+func (cm *ConfigManager) NacosAPIVersion() (string, error) {
+	_, _, api, err := cm.session(false)
+	return api, err
 }
 
-// ProxyDelete 删除配置
-func (cm *ConfigManager) ProxyDelete(dataId, group, tenant string) error {
-	if err := cm.login(); err != nil {
-		return err
+// nacosDo 发送请求：GET/DELETE 参数放在 Query，POST/PUT 以表单提交
+// 鉴权失败 (401/403) 时强制重新登录并重试一次
+func (cm *ConfigManager) nacosDo(method, apiPath string, params url.Values) ([]byte, error) {
+	for attempt := 0; ; attempt++ {
+		base, token, _, err := cm.session(attempt > 0)
+		if err != nil {
+			return nil, err
+		}
+
+		q := url.Values{}
+		for k, v := range params {
+			q[k] = v
+		}
+		if token != "" {
+			q.Set("accessToken", token) // 鉴权
+		}
+
+		var req *http.Request
+		if method == http.MethodPost || method == http.MethodPut {
+			req, err = http.NewRequest(method, base+apiPath, strings.NewReader(q.Encode()))
+			if err == nil {
+				req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			}
+		} else {
+			req, err = http.NewRequest(method, base+apiPath+"?"+q.Encode(), nil)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := nacosClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+			if attempt == 0 {
+				continue
+			}
+			return nil, fmt.Errorf("nacos auth failed (status %d): %s", resp.StatusCode, truncate(string(body), 200))
+		case resp.StatusCode != http.StatusOK:
+			return nil, fmt.Errorf("nacos %s %s failed (status %d): %s", method, apiPath, resp.StatusCode, truncate(string(body), 200))
+		}
+		return body, nil
 	}
+}
 
-	cm.mu.RLock()
-	targetURL := cm.nacosBase + "/nacos/v1/cs/configs"
-	cm.mu.RUnlock()
-
-	client := &http.Client{}
-	req, _ := http.NewRequest("DELETE", targetURL, nil)
-
-	q := req.URL.Query()
-	q.Add("accessToken", cm.nacosToken)
-	q.Add("dataId", dataId)
-	q.Add("group", group)
-	if tenant != "" {
-		q.Add("tenant", tenant)
-	}
-	req.URL.RawQuery = q.Encode()
+// ProxyGet 通用 GET 请求代理
+func (cm *ConfigManager) ProxyGet(apiPath string, params url.Values) ([]byte, error) {
+	return cm.nacosDo(http.MethodGet, apiPath, params)
+}
 
-	resp, err := client.Do(req)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
+// ProxyPost 通用 POST 请求代理 (表单)
+func (cm *ConfigManager) ProxyPost(apiPath string, data url.Values) ([]byte, error) {
+	return cm.nacosDo(http.MethodPost, apiPath, data)
+}
 
-	if resp.StatusCode != 200 {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("delete failed: %s", string(body))
-	}
-	return nil
+// ProxyDelete 通用 DELETE 请求代理
+func (cm *ConfigManager) ProxyDelete(apiPath string, params url.Values) ([]byte, error) {
+	return cm.nacosDo(http.MethodDelete, apiPath, params)
 }
@@ -0,0 +1,115 @@
+package manager_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"ops-system/internal/master/manager"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeNacos 模拟 Nacos 2.x：登录签发 Token，服务端可随时吊销
+type fakeNacos struct {
+	mu     sync.Mutex
+	logins int
+	denied int
+	valid  map[string]bool
+	ttl    int
+	config string
+}
+
+func (f *fakeNacos) revokeAll() {
+	f.mu.Lock()
+	f.valid = map[string]bool{}
+	f.mu.Unlock()
+}
+
+func (f *fakeNacos) counts() (logins, denied int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.logins, f.denied
+}
+
+func (f *fakeNacos) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	r.ParseForm()
+
+	if r.URL.Path == "/nacos/v1/auth/login" {
+		f.logins++
+		token := "t" + string(rune('0'+f.logins))
+		f.valid[token] = true
+		json.NewEncoder(w).Encode(map[string]interface{}{"accessToken": token, "tokenTtl": f.ttl})
+		return
+	}
+	if !f.valid[r.Form.Get("accessToken")] {
+		f.denied++
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	switch {
+	case r.URL.Path == "/nacos/v2/cs/config" && r.Method == http.MethodGet:
+		json.NewEncoder(w).Encode(map[string]interface{}{"code": 0, "message": "success", "data": f.config})
+	case r.URL.Path == "/nacos/v2/cs/config" && r.Method == http.MethodPost:
+		f.config = r.Form.Get("content")
+		json.NewEncoder(w).Encode(map[string]interface{}{"code": 0, "message": "success", "data": true})
+	case r.URL.Path == "/nacos/v2/cs/config" && r.Method == http.MethodDelete:
+		json.NewEncoder(w).Encode(map[string]interface{}{"code": 20004, "message": "config not exist"})
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestNacosTokenRefresh(t *testing.T) {
+	db := setupConfigDB(t)
+	defer db.Close()
+
+	fake := &fakeNacos{valid: map[string]bool{}, ttl: 1, config: "a: 1"}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	cm := manager.NewConfigManager(db)
+	require.NoError(t, cm.SaveNacosConfig(manager.NacosConfig{URL: srv.URL, Username: "nacos", Password: "pw", APIVersion: manager.NacosAPIv2}))
+	p := cm.Provider()
+	require.Equal(t, manager.ConfigProviderNacos, p.Name())
+
+	content, err := p.GetConfig("", "", "app.yaml")
+	require.NoError(t, err)
+	assert.Equal(t, "a: 1", content)
+	logins, _ := fake.counts()
+	assert.Equal(t, 1, logins)
+
+	// Token 被服务端提前吊销：各种请求都在鉴权失败后重新登录并重试一次
+	fake.revokeAll()
+	require.NoError(t, p.Publish(manager.ConfigItem{DataID: "app.yaml", Content: "a: 2"}, "alice", ""))
+	logins, _ = fake.counts()
+	assert.Equal(t, 2, logins)
+	fake.revokeAll()
+	err = p.Delete("", "", "app.yaml", "alice")
+	assert.ErrorContains(t, err, "config not exist") // v2 业务错误码透出
+	logins, denied := fake.counts()
+	assert.Equal(t, 3, logins)
+	assert.Equal(t, 2, denied)
+
+	// 临近 TTL 时主动刷新，不再触发 403
+	time.Sleep(time.Second)
+	content, err = p.GetConfig("", "", "app.yaml")
+	require.NoError(t, err)
+	assert.Equal(t, "a: 2", content)
+	logins, denied = fake.counts()
+	assert.Equal(t, 4, logins)
+	assert.Equal(t, 2, denied)
+
+	// 掩码密码保存时保留原密码
+	require.NoError(t, cm.SaveNacosConfig(manager.NacosConfig{URL: srv.URL, Username: "nacos", Password: "******"}))
+	cfg, err := cm.GetNacosConfig()
+	require.NoError(t, err)
+	assert.Equal(t, "pw", cfg.Password)
+	assert.Equal(t, manager.NacosAPIv1, cfg.APIVersion)
+}
@@ -0,0 +1,100 @@
+package manager
+
+import (
+	"encoding/json"
+)
+
+// 配置中心后端
+const (
+	ConfigProviderNacos   = "nacos"
+	ConfigProviderBuiltin = "builtin"
+)
+
+// DefaultConfigGroup 未指定 Group 时使用的默认分组 (与 Nacos 一致)
+const DefaultConfigGroup = "DEFAULT_GROUP"
+
+// ConfigProvider 配置中心后端 (外部 Nacos 或内置存储)
+// 返回结构与 Nacos Open API 保持一致，前端可以无差别切换
+type ConfigProvider interface {
+	Name() string
+	Namespaces() ([]ConfigNamespace, error)
+	ListConfigs(q ConfigQuery) (*ConfigPage, error)
+	GetConfig(tenant, group, dataId string) (string, error)
+	Publish(item ConfigItem, author, comment string) error
+	Delete(tenant, group, dataId, author string) error
+}
+
+// ConfigVersioner 支持版本管理的后端 (内置存储与 Nacos 均实现)
+// Version/Rollback 需要同时给出配置坐标，Nacos 2.x 按 坐标+版本号 查询历史
+type ConfigVersioner interface {
+	History(tenant, group, dataId string) ([]ConfigVersion, error)
+	Version(tenant, group, dataId string, id int64) (*ConfigVersion, error)
+	Rollback(tenant, group, dataId string, id int64, author, comment string) (*ConfigVersion, error)
+}
+
+// ConfigNamespaceAdmin 支持管理命名空间的后端 (内置存储；Nacos 的命名空间在其控制台维护)
+type ConfigNamespaceAdmin interface {
+	CreateNamespace(ns ConfigNamespace) error
+	DeleteNamespace(id string) error
+}
+
+// ConfigNamespace 命名空间 (字段同 Nacos /v1/console/namespaces)
+type ConfigNamespace struct {
+	Namespace         string `json:"namespace"`
+	NamespaceShowName string `json:"namespaceShowName"`
+	NamespaceDesc     string `json:"namespaceDesc,omitempty"`
+	ConfigCount       int    `json:"configCount"`
+	Type              int    `json:"type"` // 0: 默认 public, 2: 自定义
+}
+
+// ConfigQuery 配置列表查询条件
+type ConfigQuery struct {
+	Tenant   string
+	Group    string
+	DataID   string
+	PageNo   int
+	PageSize int
+}
+
+// ConfigItem 配置项 (字段同 Nacos /v1/cs/configs 的 pageItems)
+type ConfigItem struct {
+	ID         json.Number `json:"id,omitempty"`
+	DataID     string      `json:"dataId"`
+	Group      string      `json:"group"`
+	Content    string      `json:"content,omitempty"`
+	MD5        string      `json:"md5,omitempty"`
+	Tenant     string      `json:"tenant"`
+	Type       string      `json:"type,omitempty"`
+	VersionID  int64       `json:"versionId,omitempty"`  // 内置存储: 当前版本号
+	UpdateTime int64       `json:"updateTime,omitempty"` // 内置存储: 最后发布时间
+}
+
+// ConfigPage 配置分页结果 (同 Nacos Page 结构)
+type ConfigPage struct {
+	TotalCount     int          `json:"totalCount"`
+	PageNumber     int          `json:"pageNumber"`
+	PagesAvailable int          `json:"pagesAvailable"`
+	PageItems      []ConfigItem `json:"pageItems"`
+}
+
+// ConfigVersion 配置的一个历史版本 (不可变)
+type ConfigVersion struct {
+	ID         int64  `json:"id"`
+	Tenant     string `json:"tenant"`
+	Group      string `json:"group"`
+	DataID     string `json:"dataId"`
+	Type       string `json:"type"`
+	Content    string `json:"content,omitempty"` // 历史列表中不返回内容
+	MD5        string `json:"md5"`
+	Op         string `json:"op"` // publish / rollback / delete
+	Author     string `json:"author"`
+	Comment    string `json:"comment"`
+	CreateTime int64  `json:"createTime"`
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
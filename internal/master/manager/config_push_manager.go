@@ -0,0 +1,259 @@
+package manager
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"ops-system/pkg/protocol"
+	"ops-system/pkg/utils"
+)
+
+// configListPageSize 绑定整个 Group 时分页拉取配置列表的页大小
+const configListPageSize = 100
+
+// ConfigPushManager 将模块绑定的配置渲染后下发到实例目录
+// 部署时随 DeployRequest 一起下发；配置发布/回滚后推送到所有受影响的实例
+type ConfigPushManager struct {
+	sysMgr    *SystemManager
+	instMgr   *InstanceManager
+	nodeMgr   *NodeManager
+	configMgr *ConfigManager
+	logMgr    *LogManager
+}
+
+func NewConfigPushManager(sys *SystemManager, inst *InstanceManager, node *NodeManager, cfg *ConfigManager, logMgr *LogManager) *ConfigPushManager {
+	return &ConfigPushManager{sysMgr: sys, instMgr: inst, nodeMgr: node, configMgr: cfg, logMgr: logMgr}
+}
+
+// ValidateConfigBindings 校验绑定：路径必须位于实例目录内，重载策略合法
+func ValidateConfigBindings(bindings []protocol.ConfigBinding) error {
+	for i, b := range bindings {
+		if err := checkRelPath(b.Path); err != nil {
+			return fmt.Errorf("binding #%d: %v", i+1, err)
+		}
+		switch b.Reload {
+		case "", protocol.ReloadNone, protocol.ReloadSignal, protocol.ReloadRestart:
+		default:
+			return fmt.Errorf("binding #%d: unknown reload policy %q", i+1, b.Reload)
+		}
+	}
+	return nil
+}
+
+func checkRelPath(p string) error {
+	if p == "" {
+		return fmt.Errorf("path is required")
+	}
+	if path.IsAbs(p) || filepath.IsAbs(p) || filepath.VolumeName(p) != "" {
+		return fmt.Errorf("path must be relative: %s", p)
+	}
+	clean := path.Clean(filepath.ToSlash(p))
+	if clean == ".." || strings.HasPrefix(clean, "../") {
+		return fmt.Errorf("path escapes instance dir: %s", p)
+	}
+	return nil
+}
+
+// bindingMatches 判断绑定是否覆盖某个配置
+func bindingMatches(b protocol.ConfigBinding, tenant, group, dataId string) bool {
+	if normalizeTenant(b.Namespace) != normalizeTenant(tenant) || groupOrDefault(b.Group) != groupOrDefault(group) {
+		return false
+	}
+	return b.DataID == "" || b.DataID == dataId
+}
+
+// modulesOf 实例所属的模块 (同一系统下 package_name 与实例服务名相同)
+func (m *ConfigPushManager) modulesOf(inst *protocol.InstanceInfo) ([]*protocol.SystemModule, error) {
+	modules, err := m.sysMgr.GetModules(inst.SystemID)
+	if err != nil {
+		return nil, err
+	}
+	var list []*protocol.SystemModule
+	for _, mod := range modules {
+		if mod.PackageName == inst.ServiceName {
+			list = append(list, mod)
+		}
+	}
+	return list, nil
+}
+
+// RenderFiles 生成实例绑定的全部配置文件
+func (m *ConfigPushManager) RenderFiles(inst *protocol.InstanceInfo) ([]protocol.ConfigFile, error) {
+	modules, err := m.modulesOf(inst)
+	if err != nil {
+		return nil, err
+	}
+	var files []protocol.ConfigFile
+	for _, mod := range modules {
+		for _, b := range mod.ConfigBindings {
+			list, err := m.renderBinding(inst, b, "")
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, list...)
+		}
+	}
+	return files, nil
+}
+
+// renderBinding 渲染一个绑定；onlyDataId 非空时只渲染该配置 (Group 绑定时用于增量下发)
+func (m *ConfigPushManager) renderBinding(inst *protocol.InstanceInfo, b protocol.ConfigBinding, onlyDataId string) ([]protocol.ConfigFile, error) {
+	p := m.configMgr.Provider()
+	group := groupOrDefault(b.Group)
+
+	var dataIds []string
+	switch {
+	case b.DataID != "":
+		dataIds = []string{b.DataID}
+	case onlyDataId != "":
+		dataIds = []string{onlyDataId}
+	default:
+		for pageNo := 1; ; pageNo++ {
+			page, err := p.ListConfigs(ConfigQuery{Tenant: b.Namespace, Group: group, PageNo: pageNo, PageSize: configListPageSize})
+			if err != nil {
+				return nil, fmt.Errorf("list configs of group %s failed: %v", group, err)
+			}
+			for _, it := range page.PageItems {
+				dataIds = append(dataIds, it.DataID)
+			}
+			if pageNo >= page.PagesAvailable {
+				break
+			}
+		}
+	}
+
+	var files []protocol.ConfigFile
+	for _, dataId := range dataIds {
+		content, err := p.GetConfig(b.Namespace, group, dataId)
+		if err != nil {
+			return nil, fmt.Errorf("get config %s/%s failed: %v", group, dataId, err)
+		}
+		filePath := b.Path
+		if b.DataID == "" {
+			filePath = path.Join(filepath.ToSlash(b.Path), dataId)
+		}
+		files = append(files, protocol.ConfigFile{
+			Path:    filePath,
+			Content: renderConfig(content, inst),
+			Reload:  b.Reload,
+			Signal:  b.Signal,
+			Source:  group + "/" + dataId,
+		})
+	}
+	return files, nil
+}
+
+// renderConfig 替换配置内容中的实例变量
+func renderConfig(content string, inst *protocol.InstanceInfo) string {
+	return strings.NewReplacer(
+		"${ops.instance_id}", inst.ID,
+		"${ops.node_id}", inst.NodeID,
+		"${ops.node_ip}", inst.NodeIP,
+		"${ops.system_id}", inst.SystemID,
+		"${ops.service_name}", inst.ServiceName,
+		"${ops.service_version}", inst.ServiceVersion,
+	).Replace(content)
+}
+
+// OnConfigChanged 配置发布/回滚后推送到所有绑定了该配置的实例 (调用方应异步执行)
+func (m *ConfigPushManager) OnConfigChanged(tenant, group, dataId, operator string) {
+	modules, err := m.sysMgr.GetModules("")
+	if err != nil {
+		log.Printf("[ConfigPush] load modules failed: %v", err)
+		return
+	}
+
+	for _, mod := range modules {
+		var bindings []protocol.ConfigBinding
+		for _, b := range mod.ConfigBindings {
+			if bindingMatches(b, tenant, group, dataId) {
+				bindings = append(bindings, b)
+			}
+		}
+		if len(bindings) == 0 {
+			continue
+		}
+
+		instances, err := m.instMgr.GetSystemInstances(mod.SystemID)
+		if err != nil {
+			log.Printf("[ConfigPush] load instances of %s failed: %v", mod.SystemID, err)
+			continue
+		}
+		for i := range instances {
+			inst := &instances[i]
+			if inst.ServiceName != mod.PackageName {
+				continue
+			}
+			var files []protocol.ConfigFile
+			var renderErr error
+			for _, b := range bindings {
+				list, err := m.renderBinding(inst, b, dataId)
+				if err != nil {
+					renderErr = err
+					break
+				}
+				files = append(files, list...)
+			}
+			if renderErr != nil {
+				m.logMgr.RecordLog(operator, "push_config", "instance", inst.ID, "Failed: "+renderErr.Error(), "fail")
+				continue
+			}
+			m.push(inst, files, operator)
+		}
+	}
+}
+
+// PushInstance 重新下发实例绑定的全部配置
+func (m *ConfigPushManager) PushInstance(inst *protocol.InstanceInfo, operator string) (*protocol.InstanceConfigResp, error) {
+	files, err := m.RenderFiles(inst)
+	if err != nil {
+		m.logMgr.RecordLog(operator, "push_config", "instance", inst.ID, "Failed: "+err.Error(), "fail")
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("instance %s has no config bindings", inst.ID)
+	}
+	return m.push(inst, files, operator)
+}
+
+// push 发送到 Worker 并记录操作日志
+func (m *ConfigPushManager) push(inst *protocol.InstanceInfo, files []protocol.ConfigFile, operator string) (*protocol.InstanceConfigResp, error) {
+	sources := make([]string, 0, len(files))
+	for _, f := range files {
+		sources = append(sources, fmt.Sprintf("%s -> %s", f.Source, f.Path))
+	}
+	detail := strings.Join(sources, ", ")
+
+	resp, err := m.send(inst, files)
+	if err != nil {
+		m.logMgr.RecordLog(operator, "push_config", "instance", inst.ID, detail+" Failed: "+err.Error(), "fail")
+		return nil, err
+	}
+
+	detail = fmt.Sprintf("%s; changed: %d, reload: %s", detail, len(resp.Changed), resp.Reloaded)
+	if resp.Error != "" {
+		m.logMgr.RecordLog(operator, "push_config", "instance", inst.ID, detail+" Failed: "+resp.Error, "fail")
+		return resp, fmt.Errorf("apply failed: %s", resp.Error)
+	}
+	m.logMgr.RecordLog(operator, "push_config", "instance", inst.ID, detail, "success")
+	return resp, nil
+}
+
+func (m *ConfigPushManager) send(inst *protocol.InstanceInfo, files []protocol.ConfigFile) (*protocol.InstanceConfigResp, error) {
+	node, exists := m.nodeMgr.GetInstanceNode(inst)
+	if !exists {
+		return nil, fmt.Errorf("node %s offline", inst.NodeIP)
+	}
+	reqBytes, _ := json.Marshal(protocol.InstanceConfigRequest{InstanceID: inst.ID, Files: files})
+	targetURL := WorkerURL(node, "/api/instance/config")
+
+	var resp protocol.InstanceConfigResp
+	if err := utils.PostJSONResultHeader(targetURL, reqBytes, m.nodeMgr.WorkerHeader(node.ID, "/api/instance/config"), &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
@@ -0,0 +1,337 @@
+package manager
+
+import (
+	"crypto/md5"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 版本操作类型
+const (
+	ConfigOpPublish  = "publish"
+	ConfigOpRollback = "rollback"
+	ConfigOpDelete   = "delete"
+)
+
+// ConfigStore 内置配置中心，数据保存在 Master 数据库
+// config_items 保存当前生效内容，config_versions 保存每次变更的不可变快照
+type ConfigStore struct {
+	db *sql.DB
+}
+
+func NewConfigStore(db *sql.DB) *ConfigStore {
+	return &ConfigStore{db: db}
+}
+
+func (s *ConfigStore) Name() string { return ConfigProviderBuiltin }
+
+// normalizeTenant 默认命名空间统一用空串表示 (兼容 Nacos 2.x 的 "public")
+func normalizeTenant(tenant string) string {
+	if tenant == "public" {
+		return ""
+	}
+	return tenant
+}
+
+func (s *ConfigStore) Namespaces() ([]ConfigNamespace, error) {
+	counts := make(map[string]int)
+	rows, err := s.db.Query(`SELECT namespace, COUNT(*) FROM config_items GROUP BY namespace`)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var ns string
+		var n int
+		if err := rows.Scan(&ns, &n); err == nil {
+			counts[ns] = n
+		}
+	}
+	rows.Close()
+
+	list := []ConfigNamespace{{Namespace: "", NamespaceShowName: "public", ConfigCount: counts[""], Type: 0}}
+	rows, err = s.db.Query(`SELECT id, name, description FROM config_namespaces ORDER BY create_time`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var ns ConfigNamespace
+		if err := rows.Scan(&ns.Namespace, &ns.NamespaceShowName, &ns.NamespaceDesc); err != nil {
+			continue
+		}
+		ns.ConfigCount = counts[ns.Namespace]
+		ns.Type = 2
+		list = append(list, ns)
+	}
+	return list, nil
+}
+
+// CreateNamespace 新建命名空间，ID 为空时使用名称
+func (s *ConfigStore) CreateNamespace(ns ConfigNamespace) error {
+	if ns.Namespace == "" {
+		ns.Namespace = ns.NamespaceShowName
+	}
+	if ns.Namespace == "" || normalizeTenant(ns.Namespace) == "" {
+		return fmt.Errorf("invalid namespace id: %q", ns.Namespace)
+	}
+	if ns.NamespaceShowName == "" {
+		ns.NamespaceShowName = ns.Namespace
+	}
+	_, err := s.db.Exec(`INSERT INTO config_namespaces (id, name, description, create_time) VALUES (?, ?, ?, ?)`,
+		ns.Namespace, ns.NamespaceShowName, ns.NamespaceDesc, time.Now().Unix())
+	if err != nil && strings.Contains(err.Error(), "UNIQUE") {
+		return fmt.Errorf("namespace %s already exists", ns.Namespace)
+	}
+	return err
+}
+
+// DeleteNamespace 删除命名空间，其中仍有配置时拒绝
+func (s *ConfigStore) DeleteNamespace(id string) error {
+	var n int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM config_items WHERE namespace = ?`, id).Scan(&n); err != nil {
+		return err
+	}
+	if n > 0 {
+		return fmt.Errorf("namespace %s still has %d configs", id, n)
+	}
+	res, err := s.db.Exec(`DELETE FROM config_namespaces WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		return fmt.Errorf("namespace %s not found", id)
+	}
+	return nil
+}
+
+func (s *ConfigStore) namespaceExists(tenant string) (bool, error) {
+	if tenant == "" {
+		return true, nil
+	}
+	var n int
+	err := s.db.QueryRow(`SELECT COUNT(*) FROM config_namespaces WHERE id = ?`, tenant).Scan(&n)
+	return n > 0, err
+}
+
+// ListConfigs Group 精确匹配，Data ID 模糊匹配 (支持 * 通配)
+func (s *ConfigStore) ListConfigs(q ConfigQuery) (*ConfigPage, error) {
+	if q.PageNo < 1 {
+		q.PageNo = 1
+	}
+	if q.PageSize < 1 {
+		q.PageSize = 10
+	}
+
+	where := []string{"namespace = ?"}
+	args := []interface{}{normalizeTenant(q.Tenant)}
+	if q.Group != "" {
+		where = append(where, "group_name = ?")
+		args = append(args, q.Group)
+	}
+	if q.DataID != "" {
+		where = append(where, `data_id LIKE ? ESCAPE '\'`)
+		args = append(args, "%"+likePattern(q.DataID)+"%")
+	}
+	cond := strings.Join(where, " AND ")
+
+	page := &ConfigPage{PageNumber: q.PageNo, PageItems: []ConfigItem{}}
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM config_items WHERE `+cond, args...).Scan(&page.TotalCount); err != nil {
+		return nil, err
+	}
+	page.PagesAvailable = (page.TotalCount + q.PageSize - 1) / q.PageSize
+
+	rows, err := s.db.Query(`SELECT id, namespace, group_name, data_id, type, md5, version_id, update_time
+		FROM config_items WHERE `+cond+` ORDER BY group_name, data_id LIMIT ? OFFSET ?`,
+		append(args, q.PageSize, (q.PageNo-1)*q.PageSize)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var it ConfigItem
+		var id int64
+		if err := rows.Scan(&id, &it.Tenant, &it.Group, &it.DataID, &it.Type, &it.MD5, &it.VersionID, &it.UpdateTime); err != nil {
+			continue
+		}
+		it.ID = json.Number(strconv.FormatInt(id, 10))
+		page.PageItems = append(page.PageItems, it)
+	}
+	return page, nil
+}
+
+// likePattern 转义 LIKE 特殊字符，并将 * 转为 %
+func likePattern(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`, `*`, `%`)
+	return r.Replace(s)
+}
+
+func (s *ConfigStore) GetConfig(tenant, group, dataId string) (string, error) {
+	var content string
+	err := s.db.QueryRow(`SELECT content FROM config_items WHERE namespace = ? AND group_name = ? AND data_id = ?`,
+		normalizeTenant(tenant), groupOrDefault(group), dataId).Scan(&content)
+	if err == sql.ErrNoRows {
+		return "", fmt.Errorf("config %s/%s not found", groupOrDefault(group), dataId)
+	}
+	return content, err
+}
+
+// Publish 发布配置，每次发布都生成一个新版本
+func (s *ConfigStore) Publish(item ConfigItem, author, comment string) error {
+	if item.DataID == "" {
+		return fmt.Errorf("dataId is required")
+	}
+	_, err := s.save(normalizeTenant(item.Tenant), groupOrDefault(item.Group), item.DataID, item.Type, item.Content, ConfigOpPublish, author, comment)
+	return err
+}
+
+// save 在同一事务内写入版本快照并更新当前内容
+func (s *ConfigStore) save(tenant, group, dataId, typ, content, op, author, comment string) (*ConfigVersion, error) {
+	ok, err := s.namespaceExists(tenant)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("namespace %s not found", tenant)
+	}
+
+	v := &ConfigVersion{
+		Tenant: tenant, Group: group, DataID: dataId, Type: typ, Content: content,
+		MD5: md5Hex(content), Op: op, Author: author, Comment: comment, CreateTime: time.Now().Unix(),
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`INSERT INTO config_versions (namespace, group_name, data_id, type, content, md5, op, author, comment, create_time)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		v.Tenant, v.Group, v.DataID, v.Type, v.Content, v.MD5, v.Op, v.Author, v.Comment, v.CreateTime)
+	if err != nil {
+		return nil, err
+	}
+	v.ID, _ = res.LastInsertId()
+
+	_, err = tx.Exec(`INSERT INTO config_items (namespace, group_name, data_id, type, content, md5, version_id, update_time)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (namespace, group_name, data_id) DO UPDATE SET
+			type = excluded.type, content = excluded.content, md5 = excluded.md5,
+			version_id = excluded.version_id, update_time = excluded.update_time`,
+		v.Tenant, v.Group, v.DataID, v.Type, v.Content, v.MD5, v.ID, v.CreateTime)
+	if err != nil {
+		return nil, err
+	}
+	return v, tx.Commit()
+}
+
+// Delete 删除配置，历史版本保留并追加一条 delete 记录，之后仍可回滚恢复
+func (s *ConfigStore) Delete(tenant, group, dataId, author string) error {
+	tenant, group = normalizeTenant(tenant), groupOrDefault(group)
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var typ string
+	err = tx.QueryRow(`SELECT type FROM config_items WHERE namespace = ? AND group_name = ? AND data_id = ?`,
+		tenant, group, dataId).Scan(&typ)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("config %s/%s not found", group, dataId)
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`INSERT INTO config_versions (namespace, group_name, data_id, type, content, md5, op, author, comment, create_time)
+		VALUES (?, ?, ?, ?, '', '', ?, ?, '', ?)`,
+		tenant, group, dataId, typ, ConfigOpDelete, author, time.Now().Unix()); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM config_items WHERE namespace = ? AND group_name = ? AND data_id = ?`,
+		tenant, group, dataId); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// History 按时间倒序返回某个配置的全部版本 (不含内容)
+func (s *ConfigStore) History(tenant, group, dataId string) ([]ConfigVersion, error) {
+	rows, err := s.db.Query(`SELECT id, namespace, group_name, data_id, type, md5, op, author, comment, create_time
+		FROM config_versions WHERE namespace = ? AND group_name = ? AND data_id = ? ORDER BY id DESC`,
+		normalizeTenant(tenant), groupOrDefault(group), dataId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []ConfigVersion{}
+	for rows.Next() {
+		var v ConfigVersion
+		if err := rows.Scan(&v.ID, &v.Tenant, &v.Group, &v.DataID, &v.Type, &v.MD5, &v.Op, &v.Author, &v.Comment, &v.CreateTime); err != nil {
+			continue
+		}
+		list = append(list, v)
+	}
+	return list, nil
+}
+
+// Version 获取某个版本的完整内容；给出 dataId 时校验版本属于该配置
+func (s *ConfigStore) Version(tenant, group, dataId string, id int64) (*ConfigVersion, error) {
+	v, err := s.version(id)
+	if err != nil {
+		return nil, err
+	}
+	if dataId != "" && (v.Tenant != normalizeTenant(tenant) || v.Group != groupOrDefault(group) || v.DataID != dataId) {
+		return nil, fmt.Errorf("config version %d not found", id)
+	}
+	return v, nil
+}
+
+func (s *ConfigStore) version(id int64) (*ConfigVersion, error) {
+	var v ConfigVersion
+	err := s.db.QueryRow(`SELECT id, namespace, group_name, data_id, type, content, md5, op, author, comment, create_time
+		FROM config_versions WHERE id = ?`, id).
+		Scan(&v.ID, &v.Tenant, &v.Group, &v.DataID, &v.Type, &v.Content, &v.MD5, &v.Op, &v.Author, &v.Comment, &v.CreateTime)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("config version %d not found", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// Rollback 以指定版本的内容重新发布，生成一个新的 rollback 版本 (历史不会被改写)
+func (s *ConfigStore) Rollback(tenant, group, dataId string, id int64, author, comment string) (*ConfigVersion, error) {
+	target, err := s.Version(tenant, group, dataId, id)
+	if err != nil {
+		return nil, err
+	}
+	if target.Op == ConfigOpDelete {
+		return nil, fmt.Errorf("cannot rollback to a delete version")
+	}
+	if comment == "" {
+		comment = fmt.Sprintf("rollback to #%d", id)
+	}
+	return s.save(target.Tenant, target.Group, target.DataID, target.Type, target.Content, ConfigOpRollback, author, comment)
+}
+
+func groupOrDefault(group string) string {
+	if group == "" {
+		return DefaultConfigGroup
+	}
+	return group
+}
+
+func md5Hex(s string) string {
+	sum := md5.Sum([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
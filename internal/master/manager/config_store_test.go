@@ -0,0 +1,102 @@
+package manager_test
+
+import (
+	"database/sql"
+	"testing"
+
+	"ops-system/internal/master/manager"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupConfigDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite", ":memory:")
+	require.NoError(t, err)
+	db.SetMaxOpenConns(1)
+
+	sqls := []string{
+		`CREATE TABLE sys_settings (key TEXT PRIMARY KEY, value TEXT, updated_at INTEGER);`,
+		`CREATE TABLE config_namespaces (id TEXT PRIMARY KEY, name TEXT, description TEXT, create_time INTEGER);`,
+		`CREATE TABLE config_items (id INTEGER PRIMARY KEY AUTOINCREMENT, namespace TEXT, group_name TEXT, data_id TEXT, type TEXT, content TEXT, md5 TEXT, version_id INTEGER, update_time INTEGER, UNIQUE (namespace, group_name, data_id));`,
+		`CREATE TABLE config_versions (id INTEGER PRIMARY KEY AUTOINCREMENT, namespace TEXT, group_name TEXT, data_id TEXT, type TEXT, content TEXT, md5 TEXT, op TEXT, author TEXT, comment TEXT, create_time INTEGER);`,
+	}
+	for _, s := range sqls {
+		_, err := db.Exec(s)
+		require.NoError(t, err)
+	}
+	return db
+}
+
+func TestConfigStoreVersioning(t *testing.T) {
+	db := setupConfigDB(t)
+	defer db.Close()
+
+	// 未配置 Nacos 时默认使用内置存储
+	cm := manager.NewConfigManager(db)
+	assert.Equal(t, manager.ConfigProviderBuiltin, cm.ProviderName())
+	p := cm.Provider()
+	store := p.(*manager.ConfigStore)
+
+	// 不存在的命名空间不能发布
+	err := p.Publish(manager.ConfigItem{Tenant: "prod", DataID: "app.yaml", Content: "a"}, "alice", "")
+	assert.Error(t, err)
+	require.NoError(t, store.CreateNamespace(manager.ConfigNamespace{Namespace: "prod", NamespaceShowName: "生产"}))
+
+	item := manager.ConfigItem{Tenant: "prod", DataID: "app.yaml", Type: "yaml", Content: "port: 8080\n"}
+	require.NoError(t, p.Publish(item, "alice", "init"))
+	item.Content = "port: 9090\n"
+	require.NoError(t, p.Publish(item, "bob", "change port"))
+
+	content, err := p.GetConfig("prod", "", "app.yaml")
+	require.NoError(t, err)
+	assert.Equal(t, "port: 9090\n", content)
+
+	page, err := p.ListConfigs(manager.ConfigQuery{Tenant: "prod", DataID: "app", PageNo: 1, PageSize: 10})
+	require.NoError(t, err)
+	assert.Equal(t, 1, page.TotalCount)
+	assert.Equal(t, manager.DefaultConfigGroup, page.PageItems[0].Group)
+
+	nss, err := p.Namespaces()
+	require.NoError(t, err)
+	require.Len(t, nss, 2)
+	assert.Equal(t, 1, nss[1].ConfigCount)
+
+	// 历史按时间倒序
+	hist, err := store.History("prod", manager.DefaultConfigGroup, "app.yaml")
+	require.NoError(t, err)
+	require.Len(t, hist, 2)
+	assert.Equal(t, "bob", hist[0].Author)
+	assert.Equal(t, "init", hist[1].Comment)
+
+	// 删除后仍可回滚恢复，回滚生成新版本
+	require.NoError(t, p.Delete("prod", "", "app.yaml", "carol"))
+	_, err = p.GetConfig("prod", "", "app.yaml")
+	assert.Error(t, err)
+	assert.Error(t, store.DeleteNamespace("nope"))
+
+	// 版本号与配置坐标不符时拒绝
+	_, err = store.Rollback("prod", "", "other.yaml", hist[1].ID, "dave", "")
+	assert.Error(t, err)
+	v, err := store.Rollback("prod", "", "app.yaml", hist[1].ID, "dave", "")
+	require.NoError(t, err)
+	assert.Equal(t, manager.ConfigOpRollback, v.Op)
+	content, _ = p.GetConfig("prod", "", "app.yaml")
+	assert.Equal(t, "port: 8080\n", content)
+
+	hist, _ = store.History("prod", "", "app.yaml")
+	require.Len(t, hist, 4)
+	assert.Equal(t, []string{"rollback", "delete", "publish", "publish"},
+		[]string{hist[0].Op, hist[1].Op, hist[2].Op, hist[3].Op})
+
+	_, err = store.Rollback("", "", "", hist[1].ID, "dave", "")
+	assert.Error(t, err, "不能回滚到删除版本")
+
+	// 命名空间下有配置时不能删除
+	assert.Error(t, store.DeleteNamespace("prod"))
+
+	// 显式切换后端
+	require.NoError(t, cm.SetProviderName(manager.ConfigProviderNacos))
+	assert.Equal(t, manager.ConfigProviderNacos, cm.Provider().Name())
+	assert.Error(t, cm.SetProviderName("etcd"))
+}
@@ -0,0 +1,94 @@
+package manager
+
+import (
+	"sync"
+
+	"ops-system/pkg/protocol"
+)
+
+const (
+	execReplayLimit = 4096 // 每次执行保留的事件数上限 (供中途订阅的前端回放)
+	execSubBuffer   = 256  // 订阅者缓冲，写满视为消费过慢并断开
+)
+
+// ExecHub 批量命令执行的实时事件分发 (按任务 ID)
+// 仅保存进行中的执行；结束后订阅方应从任务记录读取结果
+type ExecHub struct {
+	mu   sync.Mutex
+	runs map[string]*execRun
+}
+
+type execRun struct {
+	events []protocol.ExecEvent
+	subs   map[chan protocol.ExecEvent]struct{}
+}
+
+func NewExecHub() *ExecHub {
+	return &ExecHub{runs: make(map[string]*execRun)}
+}
+
+// Start 开始一次执行
+func (h *ExecHub) Start(taskID string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.runs[taskID] = &execRun{subs: make(map[chan protocol.ExecEvent]struct{})}
+}
+
+// Publish 分发事件；超过回放上限后输出片段不再保留，exit/done 事件始终保留
+func (h *ExecHub) Publish(taskID string, ev protocol.ExecEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	run, ok := h.runs[taskID]
+	if !ok {
+		return
+	}
+	if ev.Type != "output" || len(run.events) < execReplayLimit {
+		run.events = append(run.events, ev)
+	}
+	for ch := range run.subs {
+		select {
+		case ch <- ev:
+		default:
+			delete(run.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// Finish 结束执行，关闭全部订阅
+func (h *ExecHub) Finish(taskID string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	run, ok := h.runs[taskID]
+	if !ok {
+		return
+	}
+	for ch := range run.subs {
+		delete(run.subs, ch)
+		close(ch)
+	}
+	delete(h.runs, taskID)
+}
+
+// Subscribe 订阅进行中的执行，返回已发生的事件和后续事件的通道 (执行结束或消费过慢时关闭)
+// 执行不存在或已结束时 ok 为 false
+func (h *ExecHub) Subscribe(taskID string) (replay []protocol.ExecEvent, ch <-chan protocol.ExecEvent, cancel func(), ok bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	run, ok := h.runs[taskID]
+	if !ok {
+		return nil, nil, nil, false
+	}
+	c := make(chan protocol.ExecEvent, execSubBuffer)
+	run.subs[c] = struct{}{}
+	replay = append([]protocol.ExecEvent(nil), run.events...)
+	cancel = func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		if _, ok := run.subs[c]; ok {
+			delete(run.subs, c)
+			close(c)
+		}
+	}
+	return replay, c, cancel, true
+}
@@ -0,0 +1,39 @@
+package manager_test
+
+import (
+	"testing"
+
+	"ops-system/internal/master/manager"
+	"ops-system/pkg/protocol"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExecHub(t *testing.T) {
+	hub := manager.NewExecHub()
+	_, _, _, ok := hub.Subscribe("task-1")
+	assert.False(t, ok, "未开始的执行不可订阅")
+
+	hub.Start("task-1")
+	hub.Publish("task-1", protocol.ExecEvent{Type: "output", NodeID: "n1", Data: "hello"})
+
+	// 中途订阅: 先回放已有事件，再接收后续事件
+	replay, ch, cancel, ok := hub.Subscribe("task-1")
+	require.True(t, ok)
+	defer cancel()
+	require.Len(t, replay, 1)
+	assert.Equal(t, "hello", replay[0].Data)
+
+	code := 0
+	hub.Publish("task-1", protocol.ExecEvent{Type: "exit", NodeID: "n1", ExitCode: &code})
+	ev := <-ch
+	assert.Equal(t, "exit", ev.Type)
+
+	// 结束后通道关闭，再次订阅失败 (由任务记录回放)
+	hub.Finish("task-1")
+	_, open := <-ch
+	assert.False(t, open)
+	_, _, _, ok = hub.Subscribe("task-1")
+	assert.False(t, ok)
+}
@@ -0,0 +1,17 @@
+package manager
+
+import (
+	"time"
+
+	"ops-system/pkg/protocol"
+)
+
+// EvaluateOnce 供测试直接执行一轮告警评估
+func (am *AlertManager) EvaluateOnce() {
+	am.evaluate()
+}
+
+// SilenceMatch 供测试直接匹配静默与维护窗口
+func SilenceMatch(silences []*protocol.AlertSilence, windows []*protocol.MaintenanceWindow, ruleID int64, targetID, systemID string, now time.Time) bool {
+	return (&silenceMatcher{silences: silences, windows: windows}).Match(ruleID, targetID, systemID, now)
+}
@@ -5,6 +5,7 @@ import (
 	"sync"
 	"time"
 
+	"ops-system/internal/master/monitor"
 	"ops-system/pkg/protocol"
 )
 
@@ -14,25 +15,27 @@ type realTimeMetrics struct {
 	MemUsage uint64
 	IoRead   uint64
 	IoWrite  uint64
+	protocol.ProcessMetrics
 }
 
 // InstanceManager 专门负责实例管理和监控数据
 type InstanceManager struct {
 	db           *sql.DB
 	mu           sync.Mutex
-	metricsCache sync.Map // key: InstanceID, value: realTimeMetrics
+	metricsCache sync.Map            // key: InstanceID, value: realTimeMetrics
+	tsdb         *monitor.MemoryTSDB // 时序存储 (可为 nil)
 }
 
-func NewInstanceManager(db *sql.DB) *InstanceManager {
-	return &InstanceManager{db: db}
+func NewInstanceManager(db *sql.DB, tsdb *monitor.MemoryTSDB) *InstanceManager {
+	return &InstanceManager{db: db, tsdb: tsdb}
 }
 
 // RegisterInstance 注册/更新实例基础信息
 func (im *InstanceManager) RegisterInstance(inst *protocol.InstanceInfo) {
 	im.mu.Lock()
 	defer im.mu.Unlock()
-	query := `INSERT OR REPLACE INTO instance_infos (id, system_id, node_ip, service_name, service_version, status, pid, uptime) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
-	im.db.Exec(query, inst.ID, inst.SystemID, inst.NodeIP, inst.ServiceName, inst.ServiceVersion, inst.Status, inst.PID, inst.Uptime)
+	query := `INSERT OR REPLACE INTO instance_infos (id, system_id, node_id, node_ip, service_name, service_version, status, pid, uptime) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	im.db.Exec(query, inst.ID, inst.SystemID, inst.NodeID, inst.NodeIP, inst.ServiceName, inst.ServiceVersion, inst.Status, inst.PID, inst.Uptime)
 }
 
 // UpdateInstanceStatus 简单更新状态
@@ -62,12 +65,42 @@ func (im *InstanceManager) UpdateInstanceFullStatus(report *protocol.InstanceSta
 
 	// 2. 内存更新监控数据
 	metrics := realTimeMetrics{
-		CpuUsage: report.CpuUsage,
-		MemUsage: report.MemUsage,
-		IoRead:   report.IoRead,
-		IoWrite:  report.IoWrite,
+		CpuUsage:       report.CpuUsage,
+		MemUsage:       report.MemUsage,
+		IoRead:         report.IoRead,
+		IoWrite:        report.IoWrite,
+		ProcessMetrics: report.ProcessMetrics,
 	}
 	im.metricsCache.Store(report.InstanceID, metrics)
+
+	// 3. 写入时序数据库 (仅运行中的实例有监控数据)
+	if im.tsdb != nil && report.Status == "running" {
+		id := report.InstanceID
+		im.tsdb.Write(id, "instance_cpu_usage", report.CpuUsage)
+		im.tsdb.Write(id, "instance_mem_usage", float64(report.MemUsage))
+		im.tsdb.Write(id, "instance_io_read", float64(report.IoRead))
+		im.tsdb.Write(id, "instance_io_write", float64(report.IoWrite))
+		im.tsdb.Write(id, "instance_processes", float64(report.ProcessCount))
+		im.tsdb.Write(id, "instance_threads", float64(report.Threads))
+		im.tsdb.Write(id, "instance_fds", float64(report.FDs))
+		im.tsdb.Write(id, "instance_ctx_switches", float64(report.CtxSwitchVol+report.CtxSwitchInvol))
+		im.tsdb.Write(id, "instance_restarts", float64(report.Restarts))
+		if report.FDLimit > 0 {
+			im.tsdb.Write(id, "instance_fd_usage", float64(report.FDs)/float64(report.FDLimit)*100)
+		}
+	}
+}
+
+// fillMetrics 将内存中的实时监控数据合并到实例信息中
+func (im *InstanceManager) fillMetrics(inst *protocol.InstanceInfo) {
+	if val, ok := im.metricsCache.Load(inst.ID); ok {
+		m := val.(realTimeMetrics)
+		inst.CpuUsage = m.CpuUsage
+		inst.MemUsage = m.MemUsage
+		inst.IoRead = m.IoRead
+		inst.IoWrite = m.IoWrite
+		inst.ProcessMetrics = m.ProcessMetrics
+	}
 }
 
 // RemoveInstance 删除实例
@@ -81,26 +114,20 @@ func (im *InstanceManager) RemoveInstance(id string) {
 // GetInstance 获取单个实例
 func (im *InstanceManager) GetInstance(id string) (*protocol.InstanceInfo, bool) {
 	var inst protocol.InstanceInfo
-	err := im.db.QueryRow(`SELECT id, system_id, node_ip, service_name, service_version, status, pid, uptime FROM instance_infos WHERE id = ?`, id).
-		Scan(&inst.ID, &inst.SystemID, &inst.NodeIP, &inst.ServiceName, &inst.ServiceVersion, &inst.Status, &inst.PID, &inst.Uptime)
+	err := im.db.QueryRow(`SELECT id, system_id, COALESCE(node_id, ''), node_ip, service_name, service_version, status, pid, uptime FROM instance_infos WHERE id = ?`, id).
+		Scan(&inst.ID, &inst.SystemID, &inst.NodeID, &inst.NodeIP, &inst.ServiceName, &inst.ServiceVersion, &inst.Status, &inst.PID, &inst.Uptime)
 	if err != nil {
 		return nil, false
 	}
 
 	// 合并监控数据
-	if val, ok := im.metricsCache.Load(id); ok {
-		m := val.(realTimeMetrics)
-		inst.CpuUsage = m.CpuUsage
-		inst.MemUsage = m.MemUsage
-		inst.IoRead = m.IoRead
-		inst.IoWrite = m.IoWrite
-	}
+	im.fillMetrics(&inst)
 	return &inst, true
 }
 
 // GetSystemInstances 获取某个系统下的所有实例
 func (im *InstanceManager) GetSystemInstances(systemID string) ([]protocol.InstanceInfo, error) {
-	query := `SELECT id, system_id, node_ip, service_name, service_version, status, pid, uptime FROM instance_infos WHERE system_id = ?`
+	query := `SELECT id, system_id, COALESCE(node_id, ''), node_ip, service_name, service_version, status, pid, uptime FROM instance_infos WHERE system_id = ?`
 	rows, err := im.db.Query(query, systemID)
 	if err != nil {
 		return nil, err
@@ -110,15 +137,9 @@ func (im *InstanceManager) GetSystemInstances(systemID string) ([]protocol.Insta
 	var instances []protocol.InstanceInfo
 	for rows.Next() {
 		var i protocol.InstanceInfo
-		rows.Scan(&i.ID, &i.SystemID, &i.NodeIP, &i.ServiceName, &i.ServiceVersion, &i.Status, &i.PID, &i.Uptime)
-
-		if val, ok := im.metricsCache.Load(i.ID); ok {
-			m := val.(realTimeMetrics)
-			i.CpuUsage = m.CpuUsage
-			i.MemUsage = m.MemUsage
-			i.IoRead = m.IoRead
-			i.IoWrite = m.IoWrite
-		}
+		rows.Scan(&i.ID, &i.SystemID, &i.NodeID, &i.NodeIP, &i.ServiceName, &i.ServiceVersion, &i.Status, &i.PID, &i.Uptime)
+
+		im.fillMetrics(&i)
 		instances = append(instances, i)
 	}
 	return instances, nil
@@ -126,21 +147,15 @@ func (im *InstanceManager) GetSystemInstances(systemID string) ([]protocol.Insta
 
 // GetAllInstances 获取所有实例 (供 SystemManager 组装视图使用)
 func (im *InstanceManager) GetAllInstances() map[string][]*protocol.InstanceInfo {
-	instRows, _ := im.db.Query(`SELECT id, system_id, node_ip, service_name, service_version, status, pid, uptime FROM instance_infos`)
+	instRows, _ := im.db.Query(`SELECT id, system_id, COALESCE(node_id, ''), node_ip, service_name, service_version, status, pid, uptime FROM instance_infos`)
 	defer instRows.Close()
 
 	instMap := make(map[string][]*protocol.InstanceInfo)
 	for instRows.Next() {
 		var i protocol.InstanceInfo
-		instRows.Scan(&i.ID, &i.SystemID, &i.NodeIP, &i.ServiceName, &i.ServiceVersion, &i.Status, &i.PID, &i.Uptime)
-
-		if val, ok := im.metricsCache.Load(i.ID); ok {
-			m := val.(realTimeMetrics)
-			i.CpuUsage = m.CpuUsage
-			i.MemUsage = m.MemUsage
-			i.IoRead = m.IoRead
-			i.IoWrite = m.IoWrite
-		}
+		instRows.Scan(&i.ID, &i.SystemID, &i.NodeID, &i.NodeIP, &i.ServiceName, &i.ServiceVersion, &i.Status, &i.PID, &i.Uptime)
+
+		im.fillMetrics(&i)
 
 		val := i
 		instMap[i.SystemID] = append(instMap[i.SystemID], &val)
@@ -0,0 +1,128 @@
+package manager
+
+import (
+	"database/sql"
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"ops-system/internal/master/logstore"
+	"ops-system/pkg/logparse"
+	"ops-system/pkg/protocol"
+)
+
+// LogShipManager 接收 Worker 推送的日志并写入集中存储
+// 每个日志流 (实例+日志名) 在 DB 中记录已持久化的序号：
+// Worker 重启后从本地检查点重发时，已确认的部分在这里被丢弃，保证不重复。
+// 数据先落盘再更新序号，仅当 Master 恰好在两步之间崩溃时才可能重复一批。
+type LogShipManager struct {
+	db    *sql.DB
+	store *logstore.Store
+	mu    sync.Mutex
+}
+
+func NewLogShipManager(db *sql.DB, store *logstore.Store) *LogShipManager {
+	return &LogShipManager{db: db, store: store}
+}
+
+// Ingest 写入一批日志，返回已确认的序号
+func (m *LogShipManager) Ingest(req protocol.LogShipReq) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var acked int64
+	err := m.db.QueryRow(`SELECT seq FROM log_ship_offsets WHERE instance_id = ? AND log_key = ?`,
+		req.InstanceID, req.LogKey).Scan(&acked)
+	if err != nil && err != sql.ErrNoRows {
+		return 0, err
+	}
+
+	data := req.Data
+	start, end := req.Seq, req.Seq+int64(len(req.Data))
+	switch {
+	case end <= acked:
+		return acked, nil // 重发的旧数据
+	case start < acked:
+		data = data[acked-start:] // 部分重叠，只保留未确认的部分
+	case start > acked:
+		log.Printf("[LogShip] gap detected for %s/%s: acked %d, got %d", req.InstanceID, req.LogKey, acked, start)
+	}
+
+	if err := m.store.Append(req.InstanceID, req.LogKey, toRecords(string(data), time.Now().Unix())); err != nil {
+		return acked, err
+	}
+
+	_, err = m.db.Exec(`INSERT OR REPLACE INTO log_ship_offsets (instance_id, log_key, node_ip, seq, update_time) VALUES (?, ?, ?, ?, ?)`,
+		req.InstanceID, req.LogKey, req.NodeIP, end, time.Now().Unix())
+	if err != nil {
+		return acked, err
+	}
+	return end, nil
+}
+
+// toRecords 拆分为行并确定时间：无法解析的行 (如异常堆栈) 沿用上一行，首行缺失时使用接收时间
+func toRecords(data string, now int64) []logstore.Record {
+	lines := strings.Split(strings.TrimSuffix(data, "\n"), "\n")
+	recs := make([]logstore.Record, 0, len(lines))
+	last := now
+	for _, line := range lines {
+		line = strings.TrimSuffix(line, "\r")
+		if ts := logparse.ParseTime(line); ts > 0 {
+			last = ts
+		}
+		recs = append(recs, logstore.Record{Time: last, Line: line})
+	}
+	return recs
+}
+
+// Search 在集中存储中检索 (insts 为已圈定的实例)
+func (m *LogShipManager) Search(insts []*protocol.InstanceInfo, req protocol.LogSearchReq, re *regexp.Regexp) (*protocol.LogSearchResp, error) {
+	ids := make([]string, 0, len(insts))
+	byID := make(map[string]*protocol.InstanceInfo, len(insts))
+	for _, inst := range insts {
+		ids = append(ids, inst.ID)
+		byID[inst.ID] = inst
+	}
+
+	hits, truncated, err := m.store.Search(ids, logKeyOrDefault(req.LogKey), re, req.StartTime, req.EndTime, req.MaxResults)
+	if err != nil {
+		return nil, err
+	}
+	resp := &protocol.LogSearchResp{Hits: make([]protocol.LogSearchHit, 0, len(hits)), Truncated: truncated}
+	for _, hit := range hits {
+		inst := byID[hit.InstanceID]
+		resp.Hits = append(resp.Hits, protocol.LogSearchHit{
+			InstanceID:  hit.InstanceID,
+			ServiceName: inst.ServiceName,
+			NodeIP:      inst.NodeIP,
+			File:        hit.Partition,
+			LineNo:      hit.LineNo,
+			Time:        hit.Time,
+			Line:        hit.Line,
+		})
+	}
+	return resp, nil
+}
+
+// Tail 读取集中存储中的最后 n 行 (节点宕机时仍可查看)
+func (m *LogShipManager) Tail(instID, logKey string, n int) ([]string, error) {
+	recs, err := m.store.Tail(instID, logKeyOrDefault(logKey), n)
+	if err != nil {
+		return nil, err
+	}
+	lines := make([]string, 0, len(recs))
+	for _, r := range recs {
+		lines = append(lines, r.Line)
+	}
+	return lines, nil
+}
+
+// logKeyOrDefault 空日志名即控制台日志 (与 Worker 侧约定一致)
+func logKeyOrDefault(key string) string {
+	if key == "" {
+		return "Console Log"
+	}
+	return key
+}
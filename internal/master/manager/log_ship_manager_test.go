@@ -0,0 +1,45 @@
+package manager_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"ops-system/internal/master/logstore"
+	"ops-system/internal/master/manager"
+	"ops-system/pkg/protocol"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogShipIngestRawBytes(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	_, err := db.Exec(`CREATE TABLE log_ship_offsets (instance_id TEXT, log_key TEXT, node_ip TEXT, seq INTEGER, update_time INTEGER, PRIMARY KEY (instance_id, log_key));`)
+	require.NoError(t, err)
+	store, err := logstore.New(t.TempDir(), 0)
+	require.NoError(t, err)
+	m := manager.NewLogShipManager(db, store)
+
+	// GBK 编码的 "中文" 不是合法 UTF-8，经 JSON 传输后字节数不能变化
+	gbk := []byte("\xd6\xd0\xce\xc4 start\n")
+	body, _ := json.Marshal(protocol.LogShipReq{InstanceID: "i1", LogKey: "Console Log", Data: gbk})
+	var req protocol.LogShipReq
+	require.NoError(t, json.Unmarshal(body, &req))
+
+	acked, err := m.Ingest(req)
+	require.NoError(t, err)
+	assert.EqualValues(t, len(gbk), acked)
+
+	// 与已确认部分重叠的批次只写入新数据
+	req.Data = append(append([]byte{}, gbk...), "next\n"...)
+	acked, err = m.Ingest(req)
+	require.NoError(t, err)
+	assert.EqualValues(t, len(gbk)+5, acked)
+
+	recs, err := store.Tail("i1", "Console Log", 10)
+	require.NoError(t, err)
+	require.Len(t, recs, 2)
+	assert.Equal(t, string(gbk[:len(gbk)-1]), recs[0].Line)
+	assert.Equal(t, "next", recs[1].Line)
+}
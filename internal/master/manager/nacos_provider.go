@@ -0,0 +1,317 @@
+package manager
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// nacosHistoryPageSize 历史版本列表最多返回的条数 (最近的在前)
+const nacosHistoryPageSize = 100
+
+// nacosProvider 以 ConfigProvider 形式封装 Nacos 代理，按连接设置选择 v1 或 v2 Open API
+// 说明: 配置列表与登录在 2.x 中仍只有 v1 接口
+type nacosProvider struct {
+	cm *ConfigManager
+}
+
+func (p *nacosProvider) Name() string { return ConfigProviderNacos }
+
+// api 当前连接的 API 版本 (未配置时由后续请求返回错误)
+func (p *nacosProvider) api() string {
+	v, _ := p.cm.NacosAPIVersion()
+	return v
+}
+
+// nacosV2Resp 2.x Open API 的统一返回结构
+type nacosV2Resp struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// decodeV2 解析 v2 返回结构，code 非 0 时返回错误
+func decodeV2(body []byte, out interface{}) error {
+	var res nacosV2Resp
+	if err := json.Unmarshal(body, &res); err != nil {
+		return fmt.Errorf("invalid nacos response: %s", truncate(string(body), 200))
+	}
+	if res.Code != 0 {
+		return fmt.Errorf("nacos error %d: %s", res.Code, res.Message)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(res.Data, out)
+}
+
+// coordParams 配置坐标参数，v1 使用 tenant，v2 使用 namespaceId
+func (p *nacosProvider) coordParams(api, tenant, group, dataId string) url.Values {
+	params := url.Values{}
+	params.Set("dataId", dataId)
+	params.Set("group", groupOrDefault(group))
+	if tenant != "" {
+		if api == NacosAPIv2 {
+			params.Set("namespaceId", tenant)
+		} else {
+			params.Set("tenant", tenant)
+		}
+	}
+	return params
+}
+
+func (p *nacosProvider) Namespaces() ([]ConfigNamespace, error) {
+	apiPath := "/nacos/v1/console/namespaces"
+	if p.api() == NacosAPIv2 {
+		apiPath = "/nacos/v2/console/namespace/list"
+	}
+	body, err := p.cm.ProxyGet(apiPath, url.Values{})
+	if err != nil {
+		return nil, err
+	}
+	// v1 返回 {code: 200, data}，v2 返回 {code: 0, data}，data 结构一致
+	var res struct {
+		Data []ConfigNamespace `json:"data"`
+	}
+	if err := json.Unmarshal(body, &res); err != nil {
+		return nil, fmt.Errorf("invalid nacos response: %s", truncate(string(body), 200))
+	}
+	return res.Data, nil
+}
+
+// ListConfigs 使用模糊搜索，Data ID 按包含匹配 (与内置存储一致)
+func (p *nacosProvider) ListConfigs(q ConfigQuery) (*ConfigPage, error) {
+	dataId := q.DataID
+	if dataId != "" && !strings.Contains(dataId, "*") {
+		dataId = "*" + dataId + "*"
+	}
+	params := url.Values{}
+	params.Set("search", "blur")
+	params.Set("dataId", dataId)
+	params.Set("group", q.Group)
+	params.Set("pageNo", strconv.Itoa(q.PageNo))
+	params.Set("pageSize", strconv.Itoa(q.PageSize))
+	if q.Tenant != "" {
+		params.Set("tenant", q.Tenant) // Namespace ID
+	}
+	body, err := p.cm.ProxyGet("/nacos/v1/cs/configs", params)
+	if err != nil {
+		return nil, err
+	}
+	var page ConfigPage
+	if err := json.Unmarshal(body, &page); err != nil {
+		return nil, fmt.Errorf("invalid nacos response: %s", truncate(string(body), 200))
+	}
+	if page.PageItems == nil {
+		page.PageItems = []ConfigItem{}
+	}
+	return &page, nil
+}
+
+func (p *nacosProvider) GetConfig(tenant, group, dataId string) (string, error) {
+	api := p.api()
+	if api == NacosAPIv2 {
+		body, err := p.cm.ProxyGet("/nacos/v2/cs/config", p.coordParams(api, tenant, group, dataId))
+		if err != nil {
+			return "", err
+		}
+		var content string
+		err = decodeV2(body, &content)
+		return content, err
+	}
+	// v1 获取详情直接返回配置内容字符串
+	body, err := p.cm.ProxyGet("/nacos/v1/cs/configs", p.coordParams(api, tenant, group, dataId))
+	if err != nil {
+		return "", err
+	}
+	return string(body), nil
+}
+
+// Publish v2 会把操作人记为 srcUser；备注由 Nacos 自身历史不支持，忽略
+func (p *nacosProvider) Publish(item ConfigItem, author, comment string) error {
+	api := p.api()
+	form := p.coordParams(api, item.Tenant, item.Group, item.DataID)
+	form.Set("content", item.Content)
+	form.Set("type", item.Type)
+
+	if api == NacosAPIv2 {
+		form.Set("srcUser", author)
+		body, err := p.cm.ProxyPost("/nacos/v2/cs/config", form)
+		if err != nil {
+			return err
+		}
+		return decodeV2(body, nil)
+	}
+
+	body, err := p.cm.ProxyPost("/nacos/v1/cs/configs", form)
+	if err != nil {
+		return err
+	}
+	// Nacos 发布成功返回 "true"
+	if strings.TrimSpace(string(body)) != "true" {
+		return fmt.Errorf("nacos publish failed: %s", truncate(string(body), 200))
+	}
+	return nil
+}
+
+func (p *nacosProvider) Delete(tenant, group, dataId, author string) error {
+	api := p.api()
+	if api == NacosAPIv2 {
+		body, err := p.cm.ProxyDelete("/nacos/v2/cs/config", p.coordParams(api, tenant, group, dataId))
+		if err != nil {
+			return err
+		}
+		return decodeV2(body, nil)
+	}
+	_, err := p.cm.ProxyDelete("/nacos/v1/cs/configs", p.coordParams(api, tenant, group, dataId))
+	return err
+}
+
+// nacosHistory Nacos 历史记录
+// 注意: Nacos 的更新/删除记录保存的是变更前的内容，新增记录保存新内容
+type nacosHistory struct {
+	ID               json.Number     `json:"id"`
+	DataID           string          `json:"dataId"`
+	Group            string          `json:"group"`
+	Tenant           string          `json:"tenant"`
+	Content          string          `json:"content"`
+	MD5              string          `json:"md5"`
+	SrcIP            string          `json:"srcIp"`
+	SrcUser          string          `json:"srcUser"`
+	OpType           string          `json:"opType"` // I / U / D
+	LastModifiedTime json.RawMessage `json:"lastModifiedTime"`
+}
+
+func (h *nacosHistory) toVersion() ConfigVersion {
+	id, _ := h.ID.Int64()
+	v := ConfigVersion{
+		ID: id, Tenant: h.Tenant, Group: h.Group, DataID: h.DataID, Content: h.Content, MD5: h.MD5,
+		Author: h.SrcUser, CreateTime: parseNacosTime(h.LastModifiedTime),
+	}
+	if v.Author == "" {
+		v.Author = h.SrcIP
+	}
+	switch strings.TrimSpace(h.OpType) {
+	case "D":
+		v.Op = ConfigOpDelete
+	default:
+		v.Op = ConfigOpPublish
+	}
+	return v
+}
+
+// parseNacosTime 兼容毫秒时间戳 (数字或字符串) 与 RFC3339 格式，返回 Unix 秒
+func parseNacosTime(raw json.RawMessage) int64 {
+	s := strings.Trim(string(raw), `"`)
+	if s == "" || s == "null" {
+		return 0
+	}
+	if ms, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return ms / 1000
+	}
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05.000-0700", "2006-01-02 15:04:05"} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t.Unix()
+		}
+	}
+	return 0
+}
+
+func (p *nacosProvider) History(tenant, group, dataId string) ([]ConfigVersion, error) {
+	api := p.api()
+	params := p.coordParams(api, tenant, group, dataId)
+	params.Set("pageNo", "1")
+	params.Set("pageSize", strconv.Itoa(nacosHistoryPageSize))
+
+	var page struct {
+		PageItems []nacosHistory `json:"pageItems"`
+	}
+	if api == NacosAPIv2 {
+		body, err := p.cm.ProxyGet("/nacos/v2/cs/history/list", params)
+		if err != nil {
+			return nil, err
+		}
+		if err := decodeV2(body, &page); err != nil {
+			return nil, err
+		}
+	} else {
+		params.Set("search", "accurate")
+		body, err := p.cm.ProxyGet("/nacos/v1/cs/history", params)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(body, &page); err != nil {
+			return nil, fmt.Errorf("invalid nacos response: %s", truncate(string(body), 200))
+		}
+	}
+
+	list := make([]ConfigVersion, 0, len(page.PageItems))
+	for _, h := range page.PageItems {
+		v := h.toVersion()
+		v.Content = "" // 与内置存储一致，列表不返回内容
+		list = append(list, v)
+	}
+	return list, nil
+}
+
+func (p *nacosProvider) Version(tenant, group, dataId string, id int64) (*ConfigVersion, error) {
+	api := p.api()
+	params := p.coordParams(api, tenant, group, dataId)
+	params.Set("nid", strconv.FormatInt(id, 10))
+
+	var h nacosHistory
+	if api == NacosAPIv2 {
+		body, err := p.cm.ProxyGet("/nacos/v2/cs/history", params)
+		if err != nil {
+			return nil, err
+		}
+		if err := decodeV2(body, &h); err != nil {
+			return nil, err
+		}
+	} else {
+		body, err := p.cm.ProxyGet("/nacos/v1/cs/history", params)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(body, &h); err != nil {
+			return nil, fmt.Errorf("invalid nacos response: %s", truncate(string(body), 200))
+		}
+	}
+	if h.DataID == "" {
+		return nil, fmt.Errorf("config version %d not found", id)
+	}
+	v := h.toVersion()
+	return &v, nil
+}
+
+// Rollback 以历史内容重新发布，配置类型沿用当前配置
+func (p *nacosProvider) Rollback(tenant, group, dataId string, id int64, author, comment string) (*ConfigVersion, error) {
+	target, err := p.Version(tenant, group, dataId, id)
+	if err != nil {
+		return nil, err
+	}
+
+	item := ConfigItem{Tenant: target.Tenant, Group: target.Group, DataID: target.DataID, Content: target.Content}
+	if page, err := p.ListConfigs(ConfigQuery{Tenant: target.Tenant, Group: target.Group, DataID: target.DataID, PageNo: 1, PageSize: 10}); err == nil {
+		for _, it := range page.PageItems {
+			if it.DataID == target.DataID && it.Group == target.Group {
+				item.Type = it.Type
+				break
+			}
+		}
+	}
+	if err := p.Publish(item, author, comment); err != nil {
+		return nil, err
+	}
+
+	if comment == "" {
+		comment = fmt.Sprintf("rollback to #%d", id)
+	}
+	return &ConfigVersion{
+		Tenant: item.Tenant, Group: item.Group, DataID: item.DataID, Type: item.Type, Content: item.Content,
+		MD5: md5Hex(item.Content), Op: ConfigOpRollback, Author: author, Comment: comment, CreateTime: time.Now().Unix(),
+	}, nil
+}
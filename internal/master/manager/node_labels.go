@@ -0,0 +1,256 @@
+package manager
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"ops-system/pkg/labels"
+	"ops-system/pkg/protocol"
+)
+
+// reservedNodeLabels 节点内置标签，由系统维护，不允许自定义
+var reservedNodeLabels = map[string]bool{
+	"id": true, "ip": true, "hostname": true, "name": true, "os": true, "arch": true,
+}
+
+// ValidateNodeLabels 校验自定义标签 (格式合法且不占用内置标签)
+func ValidateNodeLabels(set map[string]string) error {
+	for k := range set {
+		if reservedNodeLabels[k] {
+			return fmt.Errorf("label key %q is reserved", k)
+		}
+	}
+	return labels.Validate(set)
+}
+
+// sanitizeWorkerLabels 丢弃 Worker 上报的非法标签 (Worker 启动时已校验，这里兜底)
+func sanitizeWorkerLabels(set map[string]string) map[string]string {
+	res := make(map[string]string, len(set))
+	for k, v := range set {
+		one := map[string]string{k: v}
+		if ValidateNodeLabels(one) == nil {
+			res[k] = v
+		}
+	}
+	return res
+}
+
+// mergeNodeLabels 合并 Worker 上报的标签与接口设置的标签 (后者优先)
+func mergeNodeLabels(workerJSON, userJSON string) map[string]string {
+	var worker, user map[string]string
+	json.Unmarshal([]byte(workerJSON), &worker)
+	json.Unmarshal([]byte(userJSON), &user)
+	if len(worker) == 0 && len(user) == 0 {
+		return nil
+	}
+	return labels.Merge(worker, user)
+}
+
+// nodeLabels 节点用于选择器匹配的标签: 自定义标签 + 内置标签
+func nodeLabels(node *protocol.NodeInfo) map[string]string {
+	return labels.Merge(node.Labels, map[string]string{
+		"id":       node.ID,
+		"ip":       node.IP,
+		"hostname": node.Hostname,
+		"name":     node.Name,
+		"os":       node.OS,
+		"arch":     node.Arch,
+	})
+}
+
+// SetNodeLabels 设置节点的自定义标签 (整体替换)
+// 与 Worker 配置上报的标签同名时以这里为准
+func (nm *NodeManager) SetNodeLabels(ref string, set map[string]string) error {
+	if err := ValidateNodeLabels(set); err != nil {
+		return err
+	}
+	if set == nil {
+		set = map[string]string{}
+	}
+	data, _ := json.Marshal(set)
+
+	nm.mu.Lock()
+	defer nm.mu.Unlock()
+	id, err := nm.resolveID(ref)
+	if err != nil {
+		return err
+	}
+	_, err = nm.db.Exec("UPDATE node_infos SET labels = ? WHERE id = ?", string(data), id)
+	return err
+}
+
+// --- 节点分组 ---
+
+// SaveGroup 创建或更新分组
+func (nm *NodeManager) SaveGroup(g protocol.NodeGroup) error {
+	g.Name = strings.TrimSpace(g.Name)
+	if g.Name == "" {
+		return fmt.Errorf("group name is required")
+	}
+	if _, err := labels.Parse(g.Selector); err != nil {
+		return err
+	}
+	if g.NodeIDs == nil {
+		g.NodeIDs = []string{}
+	}
+	ids, _ := json.Marshal(g.NodeIDs)
+
+	nm.mu.Lock()
+	defer nm.mu.Unlock()
+	_, err := nm.db.Exec(`INSERT INTO node_groups (name, description, selector, node_ids, create_time) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(name) DO UPDATE SET description = excluded.description, selector = excluded.selector, node_ids = excluded.node_ids`,
+		g.Name, g.Description, g.Selector, string(ids), time.Now().Unix())
+	return err
+}
+
+// DeleteGroup 删除分组
+func (nm *NodeManager) DeleteGroup(name string) error {
+	nm.mu.Lock()
+	defer nm.mu.Unlock()
+	res, err := nm.db.Exec("DELETE FROM node_groups WHERE name = ?", name)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("group %s not found", name)
+	}
+	return nil
+}
+
+// loadGroups 读取分组定义 (不含成员)
+func (nm *NodeManager) loadGroups(name string) ([]protocol.NodeGroup, error) {
+	query := `SELECT name, COALESCE(description, ''), COALESCE(selector, ''), COALESCE(node_ids, '[]'), COALESCE(create_time, 0) FROM node_groups`
+	var args []interface{}
+	if name != "" {
+		query += " WHERE name = ?"
+		args = append(args, name)
+	}
+	rows, err := nm.db.Query(query+" ORDER BY name", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []protocol.NodeGroup{}
+	for rows.Next() {
+		var g protocol.NodeGroup
+		var ids string
+		if err := rows.Scan(&g.Name, &g.Description, &g.Selector, &ids, &g.CreateTime); err != nil {
+			continue
+		}
+		g.NodeIDs = decodeStrings(ids)
+		list = append(list, g)
+	}
+	return list, nil
+}
+
+// groupMembers 计算分组成员 (显式节点 ∪ 选择器匹配的节点)，返回节点 ID 集合
+func groupMembers(g *protocol.NodeGroup, nodes []protocol.NodeInfo) map[string]bool {
+	members := make(map[string]bool)
+	explicit := make(map[string]bool, len(g.NodeIDs))
+	for _, id := range g.NodeIDs {
+		explicit[id] = true
+	}
+	sel, err := labels.Parse(g.Selector)
+	for i := range nodes {
+		n := &nodes[i]
+		if explicit[n.ID] || (err == nil && !sel.Empty() && sel.Matches(nodeLabels(n))) {
+			members[n.ID] = true
+		}
+	}
+	return members
+}
+
+// ListGroups 获取分组列表 (含当前成员)
+func (nm *NodeManager) ListGroups() ([]protocol.NodeGroup, error) {
+	groups, err := nm.loadGroups("")
+	if err != nil {
+		return nil, err
+	}
+	nodes := nm.GetAllNodes()
+	for i := range groups {
+		groups[i].Members = sortedKeys(groupMembers(&groups[i], nodes))
+	}
+	return groups, nil
+}
+
+// GroupMembers 所有分组的成员索引: 分组名 -> 节点 ID 集合 (供告警按分组圈定范围)
+func (nm *NodeManager) GroupMembers() map[string]map[string]bool {
+	groups, err := nm.loadGroups("")
+	if err != nil || len(groups) == 0 {
+		return nil
+	}
+	nodes := nm.GetAllNodes()
+	res := make(map[string]map[string]bool, len(groups))
+	for i := range groups {
+		res[groups[i].Name] = groupMembers(&groups[i], nodes)
+	}
+	return res
+}
+
+// SelectNodes 按目标条件筛选节点 (各条件之间为 AND)，结果按 IP 排序
+func (nm *NodeManager) SelectNodes(t protocol.NodeTarget) ([]protocol.NodeInfo, error) {
+	if t.Empty() {
+		return nil, fmt.Errorf("no target nodes specified")
+	}
+	sel, err := labels.Parse(t.Selector)
+	if err != nil {
+		return nil, err
+	}
+	nodes := nm.GetAllNodes()
+
+	var members map[string]bool
+	if t.Group != "" {
+		groups, err := nm.loadGroups(t.Group)
+		if err != nil {
+			return nil, err
+		}
+		if len(groups) == 0 {
+			return nil, fmt.Errorf("group %s not found", t.Group)
+		}
+		members = groupMembers(&groups[0], nodes)
+	}
+
+	explicit := make(map[string]bool, len(t.NodeIDs)+len(t.NodeIPs))
+	for _, ref := range append(append([]string{}, t.NodeIDs...), t.NodeIPs...) {
+		explicit[ref] = true
+	}
+
+	res := []protocol.NodeInfo{}
+	for i := range nodes {
+		n := &nodes[i]
+		if len(explicit) > 0 && !explicit[n.ID] && !explicit[n.IP] {
+			continue
+		}
+		if members != nil && !members[n.ID] {
+			continue
+		}
+		if !sel.Matches(nodeLabels(n)) {
+			continue
+		}
+		res = append(res, *n)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].IP < res[j].IP })
+	return res, nil
+}
+
+// FilterNodes 按选择器和分组过滤节点列表 (均为空时返回全部)
+func (nm *NodeManager) FilterNodes(selector, group string) ([]protocol.NodeInfo, error) {
+	t := protocol.NodeTarget{Selector: selector, Group: group}
+	if t.Empty() {
+		return nm.GetAllNodes(), nil
+	}
+	return nm.SelectNodes(t)
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
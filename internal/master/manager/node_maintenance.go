@@ -0,0 +1,80 @@
+package manager
+
+import (
+	"encoding/json"
+	"time"
+
+	"ops-system/pkg/protocol"
+)
+
+// applyMaintenance 封锁中的在线节点对外展示为 maintenance
+// 离线节点仍展示 offline (不可达比维护更值得关注)，通过 Cordoned 字段区分
+func applyMaintenance(n *protocol.NodeInfo) {
+	if n.Cordoned && n.Status == "online" {
+		n.Status = "maintenance"
+	}
+}
+
+// Cordon 封锁节点: 不再接受新的部署，告警自动跳过该节点
+// 重复封锁不改变封锁时间和已记录的排空实例
+func (nm *NodeManager) Cordon(ref string) (string, error) {
+	nm.mu.Lock()
+	defer nm.mu.Unlock()
+	id, err := nm.resolveID(ref)
+	if err != nil {
+		return "", err
+	}
+	_, err = nm.db.Exec("UPDATE node_infos SET cordoned = 1, cordon_time = ? WHERE id = ? AND COALESCE(cordoned, 0) = 0",
+		time.Now().Unix(), id)
+	return id, err
+}
+
+// RecordDrained 记录排空时停止的实例 (与已有记录合并，多次排空不丢失)
+func (nm *NodeManager) RecordDrained(id string, instIDs []string) error {
+	nm.mu.Lock()
+	defer nm.mu.Unlock()
+
+	var existing string
+	err := nm.db.QueryRow("SELECT COALESCE(drained_instances, '[]') FROM node_infos WHERE id = ?", id).Scan(&existing)
+	if err != nil {
+		return err
+	}
+	merged := decodeStrings(existing)
+	known := make(map[string]bool, len(merged))
+	for _, v := range merged {
+		known[v] = true
+	}
+	for _, v := range instIDs {
+		if !known[v] {
+			known[v] = true
+			merged = append(merged, v)
+		}
+	}
+	if merged == nil {
+		merged = []string{}
+	}
+	data, _ := json.Marshal(merged)
+	_, err = nm.db.Exec("UPDATE node_infos SET drained_instances = ? WHERE id = ?", string(data), id)
+	return err
+}
+
+// Uncordon 解除封锁，返回节点 ID 和排空时记录的实例 (由调用方负责恢复启动)
+func (nm *NodeManager) Uncordon(ref string) (string, []string, error) {
+	nm.mu.Lock()
+	defer nm.mu.Unlock()
+	id, err := nm.resolveID(ref)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var drained string
+	err = nm.db.QueryRow("SELECT COALESCE(drained_instances, '[]') FROM node_infos WHERE id = ?", id).Scan(&drained)
+	if err != nil {
+		return "", nil, err
+	}
+	_, err = nm.db.Exec("UPDATE node_infos SET cordoned = 0, cordon_time = 0, drained_instances = '[]' WHERE id = ?", id)
+	if err != nil {
+		return "", nil, err
+	}
+	return id, decodeStrings(drained), nil
+}
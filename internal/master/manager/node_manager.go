@@ -1,13 +1,22 @@
 package manager
 
 import (
+	"crypto/rand"
+	"crypto/subtle"
 	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
+	"net/http"
 	"sync"
 	"time"
 
 	"ops-system/internal/master/monitor"
+	"ops-system/pkg/nodeauth"
 	"ops-system/pkg/protocol"
+	"ops-system/pkg/tunnel"
 )
 
 // 节点实时监控数据 (只存内存)
@@ -18,12 +27,16 @@ type nodeMetrics struct {
 	NetOutSpeed float64
 }
 
+// ErrNodeSecret 心跳携带的节点密钥与签发的不一致
+var ErrNodeSecret = errors.New("节点密钥不匹配")
+
 type NodeManager struct {
 	db               *sql.DB
 	mu               sync.Mutex
-	metricsCache     sync.Map            // key: IP, value: nodeMetrics
+	metricsCache     sync.Map            // key: 节点 ID, value: nodeMetrics
 	tsdb             *monitor.MemoryTSDB // 新增：时序存储
 	offlineThreshold time.Duration
+	tunnels          *tunnel.Registry // 反向通道连接的节点
 }
 
 func NewNodeManager(db *sql.DB, tsdb *monitor.MemoryTSDB, threshold time.Duration) *NodeManager {
@@ -31,91 +44,225 @@ func NewNodeManager(db *sql.DB, tsdb *monitor.MemoryTSDB, threshold time.Duratio
 		db:               db,
 		tsdb:             tsdb, // 注入
 		offlineThreshold: threshold,
+		tunnels:          tunnel.NewRegistry(),
 	}
 }
 
-// HandleHeartbeat 处理心跳
-func (nm *NodeManager) HandleHeartbeat(req protocol.RegisterRequest, remoteIP string) {
-	// 1. 更新内存中的监控数据 (无锁，高频)
+// HandleHeartbeat 处理心跳，返回本次新签发的节点密钥 (未签发时为空)
+// 节点以 Worker 上报的 UUID 识别，remoteIP 只作为当前通信地址；旧版 Worker 未上报 ID 时以 IP 代替
+// 节点 ID 会在节点列表中公开，不能单独证明身份: 已签发密钥的节点须携带匹配的密钥，否则返回 ErrNodeSecret
+func (nm *NodeManager) HandleHeartbeat(req protocol.RegisterRequest, remoteIP string) (string, error) {
+	nodeID := req.NodeID
+	if nodeID == "" {
+		nodeID = remoteIP
+	}
+
+	nm.mu.Lock()
+	defer nm.mu.Unlock()
+
+	var oldIP, secret string
+	err := nm.db.QueryRow("SELECT COALESCE(ip, ''), COALESCE(secret, '') FROM node_infos WHERE id = ?", nodeID).Scan(&oldIP, &secret)
+	if err == nil && secret != "" && subtle.ConstantTimeCompare([]byte(secret), []byte(req.Secret)) != 1 {
+		return "", ErrNodeSecret
+	}
+	if err == sql.ErrNoRows && req.NodeID != "" {
+		// 新 UUID: 认领同 IP 下尚未认领的记录 (升级前的旧数据或规划节点)
+		if legacyID, ok := nm.claimLegacyNode(nodeID, remoteIP); ok {
+			log.Printf("[Node] %s claimed legacy record %s", nodeID, legacyID)
+			oldIP, err = remoteIP, nil
+		}
+	}
+
+	// 首次注册 (含认领旧记录、升级前已注册但尚无密钥的节点) 时签发密钥，此后以密钥证明身份
+	// 旧版 Worker 不上报 ID 也不保存密钥，仍按 IP 识别
+	var issued string
+	if req.NodeID != "" && secret == "" {
+		issued = newNodeSecret()
+	}
+
+	// 1. 更新内存中的监控数据
 	metrics := nodeMetrics{
 		CPUUsage:    req.Status.CPUUsage,
 		MemUsage:    req.Status.MemUsage,
 		NetInSpeed:  req.Status.NetInSpeed,
 		NetOutSpeed: req.Status.NetOutSpeed,
 	}
-	nm.metricsCache.Store(remoteIP, metrics)
+	nm.metricsCache.Store(nodeID, metrics)
 
 	// 2. 【新增】写入时序数据库 (MemoryTSDB)
-	// 记录 CPU 和 内存
+	// 记录 CPU 和 内存 (序列以节点 ID 标识：同一 NAT 出口下的节点互不混淆，地址变化后历史也连续)
 	if nm.tsdb != nil {
-		nm.tsdb.Write(remoteIP, "node_cpu_usage", req.Status.CPUUsage)
-		nm.tsdb.Write(remoteIP, "node_mem_usage", req.Status.MemUsage)
+		nm.tsdb.Write(nodeID, "node_cpu_usage", req.Status.CPUUsage)
+		nm.tsdb.Write(nodeID, "node_mem_usage", req.Status.MemUsage)
 		// 如果需要网络流量曲线，也可以在这里加
 	}
 
-	// 2. 更新数据库中的静态信息 (有锁，低频)
+	// 3. 更新数据库中的静态信息
 	// 优化策略：其实可以判断静态信息是否有变化再写库，这里为了简单每次心跳都写，
 	// 但去掉了高频变化的监控字段，SQL 压力减小了很多。
-	nm.mu.Lock()
-	defer nm.mu.Unlock()
-
 	now := time.Now().Unix()
-
-	var existsName string
-	err := nm.db.QueryRow("SELECT name FROM node_infos WHERE ip = ?", remoteIP).Scan(&existsName)
+	ipsJSON, _ := json.Marshal(nodeIPs(remoteIP, req.Info.IPs))
+	workerLabels, _ := json.Marshal(sanitizeWorkerLabels(req.Labels))
 
 	if err == sql.ErrNoRows {
 		// 新节点插入 (SQL 中不再包含 cpu_usage 等字段)
 		insertSQL := `INSERT INTO node_infos (
-			ip, port, hostname, name, mac_addr, os, arch, cpu_cores, mem_total, disk_total, 
+			id, ip, ips, registered, secret, worker_labels, agent_version, agent_platform, port, hostname, name, mac_addr, os, arch, cpu_cores, mem_total, disk_total, 
 			status, last_heartbeat
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
 
 		name := req.Info.Hostname
 
 		nm.db.Exec(insertSQL,
-			remoteIP, req.Port, req.Info.Hostname, name, req.Info.MacAddr, req.Info.OS, req.Info.Arch, req.Info.CPUCores, req.Info.MemTotal, req.Info.DiskTotal,
-			"online", now,
-		)
-	} else {
-		// 更新静态信息和心跳时间
-		updateSQL := `UPDATE node_infos SET 
-			port=?, hostname=?, mac_addr=?, os=?, arch=?, cpu_cores=?, mem_total=?, disk_total=?,
-			status=?, last_heartbeat=?
-			WHERE ip=?`
-
-		nm.db.Exec(updateSQL,
-			req.Port, req.Info.Hostname, req.Info.MacAddr, req.Info.OS, req.Info.Arch, req.Info.CPUCores, req.Info.MemTotal, req.Info.DiskTotal,
+			nodeID, remoteIP, string(ipsJSON), req.NodeID != "", issued, string(workerLabels), req.Version, req.Platform, req.Port, req.Info.Hostname, name, req.Info.MacAddr, req.Info.OS, req.Info.Arch, req.Info.CPUCores, req.Info.MemTotal, req.Info.DiskTotal,
 			"online", now,
-			remoteIP,
 		)
+		if issued != "" {
+			log.Printf("[Node] %s registered, node secret issued", nodeID)
+		}
+		return issued, nil
+	}
+
+	// 更新静态信息、当前地址和心跳时间
+	updateSQL := `UPDATE node_infos SET 
+		ip=?, ips=?, worker_labels=?, agent_version=?, agent_platform=?, port=?, hostname=?, mac_addr=?, os=?, arch=?, cpu_cores=?, mem_total=?, disk_total=?,
+		status=?, last_heartbeat=?
+		WHERE id=?`
+
+	nm.db.Exec(updateSQL,
+		remoteIP, string(ipsJSON), string(workerLabels), req.Version, req.Platform, req.Port, req.Info.Hostname, req.Info.MacAddr, req.Info.OS, req.Info.Arch, req.Info.CPUCores, req.Info.MemTotal, req.Info.DiskTotal,
+		"online", now,
+		nodeID,
+	)
+	if issued != "" {
+		nm.db.Exec("UPDATE node_infos SET secret = ? WHERE id = ?", issued, nodeID)
+		log.Printf("[Node] %s node secret issued", nodeID)
+	}
+
+	// 以目标版本上线: 自升级完成
+	if req.Version != "" {
+		nm.db.Exec("UPDATE node_infos SET upgrade_target = '', upgrade_deadline = 0 WHERE id = ? AND upgrade_target = ?", nodeID, req.Version)
+	}
+
+	// 地址变化 (DHCP / 换网卡 / NAT 出口变化): 同步实例记录的通信地址
+	if oldIP != remoteIP {
+		log.Printf("[Node] %s address changed: %s -> %s", nodeID, oldIP, remoteIP)
+		nm.db.Exec("UPDATE instance_infos SET node_ip = ? WHERE node_id = ?", remoteIP, nodeID)
+	}
+	return issued, nil
+}
+
+// VerifyNodeSecret 校验节点密钥 (节点不存在或尚未签发密钥时不通过)
+func (nm *NodeManager) VerifyNodeSecret(nodeID, secret string) bool {
+	var stored string
+	err := nm.db.QueryRow("SELECT COALESCE(secret, '') FROM node_infos WHERE id = ?", nodeID).Scan(&stored)
+	return err == nil && stored != "" && subtle.ConstantTimeCompare([]byte(stored), []byte(secret)) == 1
+}
+
+// WorkerToken 以节点密钥签发访问该节点 Worker 敏感接口 path 的短期令牌 (节点尚无密钥时为空，Worker 会拒绝)
+func (nm *NodeManager) WorkerToken(nodeID, path string) string {
+	var secret string
+	if err := nm.db.QueryRow("SELECT COALESCE(secret, '') FROM node_infos WHERE id = ?", nodeID).Scan(&secret); err != nil || secret == "" {
+		return ""
+	}
+	return nodeauth.Sign(secret, path, time.Now())
+}
+
+// WorkerHeader 携带 WorkerToken 的请求头 (部署、启停、配置下发等须 Master 授权的 Worker 接口)
+func (nm *NodeManager) WorkerHeader(nodeID, path string) http.Header {
+	return http.Header{nodeauth.Header: {nm.WorkerToken(nodeID, path)}}
+}
+
+// newNodeSecret 生成节点密钥 (32 字节随机数的十六进制)
+func newNodeSecret() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
 	}
+	return hex.EncodeToString(b)
+}
+
+// claimLegacyNode 将同 IP 下未认领的记录改为以 UUID 为主键，并迁移其实例
+// 调用方需持有 nm.mu
+func (nm *NodeManager) claimLegacyNode(nodeID, ip string) (string, bool) {
+	var legacyID string
+	err := nm.db.QueryRow("SELECT id FROM node_infos WHERE ip = ? AND registered = 0 LIMIT 1", ip).Scan(&legacyID)
+	if err != nil {
+		return "", false
+	}
+
+	tx, err := nm.db.Begin()
+	if err != nil {
+		return "", false
+	}
+	if _, err := tx.Exec("UPDATE node_infos SET id = ?, registered = 1 WHERE id = ?", nodeID, legacyID); err != nil {
+		tx.Rollback()
+		return "", false
+	}
+	if _, err := tx.Exec("UPDATE instance_infos SET node_id = ? WHERE node_id = ?", nodeID, legacyID); err != nil {
+		tx.Rollback()
+		return "", false
+	}
+	if err := tx.Commit(); err != nil {
+		return "", false
+	}
+	nm.metricsCache.Delete(legacyID)
+	return legacyID, true
+}
+
+// nodeIPs 合并通信地址与 Worker 上报的网卡地址 (去重，通信地址在前)
+func nodeIPs(primary string, reported []string) []string {
+	ips := []string{primary}
+	seen := map[string]bool{primary: true}
+	for _, ip := range reported {
+		if ip != "" && !seen[ip] {
+			seen[ip] = true
+			ips = append(ips, ip)
+		}
+	}
+	return ips
 }
 
 // AddPlannedNode 手动添加规划节点
+// 规划节点以 IP 作为临时 ID，Worker 接入后认领为其 UUID
 func (nm *NodeManager) AddPlannedNode(ip, name string) error {
 	nm.mu.Lock()
 	defer nm.mu.Unlock()
 
 	// 检查是否已存在
 	var count int
-	nm.db.QueryRow("SELECT count(*) FROM node_infos WHERE ip = ?", ip).Scan(&count)
+	nm.db.QueryRow("SELECT count(*) FROM node_infos WHERE ip = ? OR id = ?", ip, ip).Scan(&count)
 	if count > 0 {
 		return fmt.Errorf("node ip already exists")
 	}
 
 	// 规划节点默认端口暂填 0 或 8081
-	_, err := nm.db.Exec(`INSERT INTO node_infos (ip, port, name, status, hostname) VALUES (?, 0, ?, 'planned', '待接入')`, ip, name)
+	_, err := nm.db.Exec(`INSERT INTO node_infos (id, ip, port, name, status, hostname) VALUES (?, ?, 0, ?, 'planned', '待接入')`, ip, ip, name)
 	return err
 }
 
+// resolveID 将节点 ID 或 IP 解析为节点 ID (兼容按 IP 操作的旧接口)
+func (nm *NodeManager) resolveID(ref string) (string, error) {
+	var id string
+	err := nm.db.QueryRow("SELECT id FROM node_infos WHERE id = ? OR ip = ? ORDER BY id = ? DESC LIMIT 1", ref, ref, ref).Scan(&id)
+	if err == sql.ErrNoRows {
+		return "", fmt.Errorf("node not found: %s", ref)
+	}
+	return id, err
+}
+
 // DeleteNode 删除节点 (仅允许删除非 online 节点)
-func (nm *NodeManager) DeleteNode(ip string) error {
+func (nm *NodeManager) DeleteNode(ref string) error {
 	nm.mu.Lock()
 	defer nm.mu.Unlock()
 
+	id, err := nm.resolveID(ref)
+	if err != nil {
+		return err
+	}
+
 	var status string
-	err := nm.db.QueryRow("SELECT status FROM node_infos WHERE ip = ?", ip).Scan(&status)
+	err = nm.db.QueryRow("SELECT status FROM node_infos WHERE id = ?", id).Scan(&status)
 	if err != nil {
 		return err
 	}
@@ -125,25 +272,33 @@ func (nm *NodeManager) DeleteNode(ip string) error {
 		// return fmt.Errorf("cannot delete online node")
 	}
 
-	_, err = nm.db.Exec("DELETE FROM node_infos WHERE ip = ?", ip)
-	nm.metricsCache.Delete(ip) // 顺便清理缓存
+	_, err = nm.db.Exec("DELETE FROM node_infos WHERE id = ?", id)
+	nm.metricsCache.Delete(id) // 顺便清理缓存
 	return err
 }
 
 // RenameNode 重命名节点
-func (nm *NodeManager) RenameNode(ip, newName string) error {
+func (nm *NodeManager) RenameNode(ref, newName string) error {
 	nm.mu.Lock()
 	defer nm.mu.Unlock()
-	_, err := nm.db.Exec("UPDATE node_infos SET name = ? WHERE ip = ?", newName, ip)
+	id, err := nm.resolveID(ref)
+	if err != nil {
+		return err
+	}
+	_, err = nm.db.Exec("UPDATE node_infos SET name = ? WHERE id = ?", newName, id)
 	return err
 }
 
 // ResetNodeName 重置节点名为 Hostname
-func (nm *NodeManager) ResetNodeName(ip string) error {
+func (nm *NodeManager) ResetNodeName(ref string) error {
 	nm.mu.Lock()
 	defer nm.mu.Unlock()
+	id, err := nm.resolveID(ref)
+	if err != nil {
+		return err
+	}
 	// 将 name 更新为 hostname 字段的值
-	_, err := nm.db.Exec("UPDATE node_infos SET name = hostname WHERE ip = ?", ip)
+	_, err = nm.db.Exec("UPDATE node_infos SET name = hostname WHERE id = ?", id)
 	return err
 }
 
@@ -153,7 +308,9 @@ func (nm *NodeManager) GetAllNodes() []protocol.NodeInfo {
 	// COALESCE 防止旧数据 NULL 导致 Scan 失败
 	query := `
 		SELECT 
-			ip, port, hostname, name, COALESCE(mac_addr, ''), os, COALESCE(arch, ''), 
+			id, COALESCE(ip, ''), COALESCE(ips, '[]'), COALESCE(worker_labels, '{}'), COALESCE(labels, '{}'),
+			COALESCE(cordoned, 0), COALESCE(cordon_time, 0), COALESCE(drained_instances, '[]'),
+			COALESCE(agent_version, ''), COALESCE(agent_platform, ''), COALESCE(upgrade_target, ''), COALESCE(upgrade_deadline, 0), port, hostname, name, COALESCE(mac_addr, ''), os, COALESCE(arch, ''), 
 			COALESCE(cpu_cores, 0), COALESCE(mem_total, 0), COALESCE(disk_total, 0),
 			status, last_heartbeat
 		FROM node_infos
@@ -169,17 +326,24 @@ func (nm *NodeManager) GetAllNodes() []protocol.NodeInfo {
 
 	for rows.Next() {
 		var n protocol.NodeInfo
+		var ipsJSON, workerLabels, userLabels, drained string
+		var upgradeDeadline int64
 		err := rows.Scan(
-			&n.IP, &n.Port, &n.Hostname, &n.Name, &n.MacAddr, &n.OS, &n.Arch,
+			&n.ID, &n.IP, &ipsJSON, &workerLabels, &userLabels,
+			&n.Cordoned, &n.CordonTime, &drained,
+			&n.AgentVersion, &n.AgentPlatform, &n.UpgradeTarget, &upgradeDeadline, &n.Port, &n.Hostname, &n.Name, &n.MacAddr, &n.OS, &n.Arch,
 			&n.CPUCores, &n.MemTotal, &n.DiskTotal,
 			&n.Status, &n.LastHeartbeat,
 		)
 		if err != nil {
 			continue
 		}
+		json.Unmarshal([]byte(ipsJSON), &n.IPs)
+		n.Labels = mergeNodeLabels(workerLabels, userLabels)
+		n.DrainedInstances = decodeStrings(drained)
 
 		// 填充实时监控数据
-		if val, ok := nm.metricsCache.Load(n.IP); ok {
+		if val, ok := nm.metricsCache.Load(n.ID); ok {
 			m := val.(nodeMetrics)
 			n.CPUUsage = m.CPUUsage
 			n.MemUsage = m.MemUsage
@@ -194,31 +358,52 @@ func (nm *NodeManager) GetAllNodes() []protocol.NodeInfo {
 			n.CPUUsage = 0
 			n.MemUsage = 0
 		}
+		applyMaintenance(&n)
+		applyUpgradeState(&n, upgradeDeadline, now)
+		n.Tunnel = nm.tunnels.Connected(n.ID)
 
 		nodes = append(nodes, n)
 	}
 	return nodes
 }
 
-// GetNode 获取单个节点
-func (nm *NodeManager) GetNode(ip string) (*protocol.NodeInfo, bool) {
+// GetNode 获取单个节点，ref 为节点 ID 或 IP (ID 优先)
+func (nm *NodeManager) GetNode(ref string) (*protocol.NodeInfo, bool) {
 	var n protocol.NodeInfo
-	query := `SELECT ip, port, hostname, name, COALESCE(mac_addr, ''), status FROM node_infos WHERE ip = ?`
-	err := nm.db.QueryRow(query, ip).Scan(&n.IP, &n.Port, &n.Hostname, &n.Name, &n.MacAddr, &n.Status)
+	var drained string
+	query := `SELECT id, COALESCE(ip, ''), port, hostname, name, COALESCE(mac_addr, ''), status,
+		COALESCE(cordoned, 0), COALESCE(cordon_time, 0), COALESCE(drained_instances, '[]'),
+		COALESCE(agent_version, ''), COALESCE(agent_platform, '') FROM node_infos
+		WHERE id = ? OR ip = ? ORDER BY id = ? DESC LIMIT 1`
+	err := nm.db.QueryRow(query, ref, ref, ref).Scan(&n.ID, &n.IP, &n.Port, &n.Hostname, &n.Name, &n.MacAddr, &n.Status,
+		&n.Cordoned, &n.CordonTime, &drained, &n.AgentVersion, &n.AgentPlatform)
 	if err != nil {
 		return nil, false
 	}
+	n.DrainedInstances = decodeStrings(drained)
+	applyMaintenance(&n)
+	n.Tunnel = nm.tunnels.Connected(n.ID)
 	return &n, true
 }
 
-// GetAllNodesMetrics 获取所有节点的监控快照 (供 AlertManager 使用)
+// GetInstanceNode 获取实例所在节点 (优先按 node_id，兼容未记录 node_id 的实例)
+func (nm *NodeManager) GetInstanceNode(inst *protocol.InstanceInfo) (*protocol.NodeInfo, bool) {
+	if inst.NodeID != "" {
+		if n, ok := nm.GetNode(inst.NodeID); ok {
+			return n, true
+		}
+	}
+	return nm.GetNode(inst.NodeIP)
+}
+
+// GetAllNodesMetrics 获取所有节点的监控快照，key 为节点 ID (供 AlertManager 使用)
 func (nm *NodeManager) GetAllNodesMetrics() map[string]protocol.NodeInfo {
 	// 复用 GetAllNodes 的逻辑，或者简化只读内存
 	// 这里为了简单，直接调用 GetAllNodes
 	nodes := nm.GetAllNodes()
 	res := make(map[string]protocol.NodeInfo)
 	for _, n := range nodes {
-		res[n.IP] = n
+		res[n.ID] = n
 	}
 	return res
 }
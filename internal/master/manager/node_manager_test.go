@@ -0,0 +1,268 @@
+package manager_test
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"ops-system/internal/master/manager"
+	"ops-system/internal/master/monitor"
+	"ops-system/pkg/protocol"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// setupNodeDB 在 setupTestDB 基础上补充节点相关表
+func setupNodeDB(t *testing.T) *sql.DB {
+	db := setupTestDB(t)
+	db.SetMaxOpenConns(1)
+
+	sqls := []string{
+		`CREATE TABLE node_infos (id TEXT PRIMARY KEY, ip TEXT, ips TEXT DEFAULT '[]', registered INTEGER DEFAULT 0, secret TEXT DEFAULT '', labels TEXT DEFAULT '{}', worker_labels TEXT DEFAULT '{}', cordoned INTEGER DEFAULT 0, cordon_time INTEGER DEFAULT 0, drained_instances TEXT DEFAULT '[]', agent_version TEXT DEFAULT '', agent_platform TEXT DEFAULT '', upgrade_target TEXT DEFAULT '', upgrade_deadline INTEGER DEFAULT 0, port INTEGER, hostname TEXT, name TEXT, mac_addr TEXT, os TEXT, arch TEXT, cpu_cores INTEGER, mem_total INTEGER, disk_total INTEGER, status TEXT, last_heartbeat INTEGER, cpu_usage REAL, mem_usage REAL);`,
+		`CREATE TABLE node_groups (name TEXT PRIMARY KEY, description TEXT, selector TEXT DEFAULT '', node_ids TEXT DEFAULT '[]', create_time INTEGER);`,
+	}
+	for _, s := range sqls {
+		_, err := db.Exec(s)
+		assert.NoError(t, err)
+	}
+	return db
+}
+
+func TestNodeIdentity(t *testing.T) {
+	db := setupNodeDB(t)
+	defer db.Close()
+
+	// 升级前的数据: 节点以 IP 为 ID，实例按 IP 关联
+	_, err := db.Exec(`INSERT INTO node_infos (id, ip, port, hostname, name, status) VALUES ('10.0.0.1', '10.0.0.1', 8081, 'host-a', 'web-1', 'online')`)
+	assert.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO instance_infos (id, system_id, node_id, node_ip, service_name, service_version, status, pid, uptime) VALUES ('inst-1', 'sys', '10.0.0.1', '10.0.0.1', 'api', 'v1', 'running', 1, 0)`)
+	assert.NoError(t, err)
+
+	nm := manager.NewNodeManager(db, nil, time.Minute)
+	im := manager.NewInstanceManager(db, nil)
+	const nodeID = "6f1c2f7e-1d7b-4c3e-9a51-3d0b7a1f2c90"
+	var secret string
+	beat := func(id, ip string) {
+		issued, err := nm.HandleHeartbeat(protocol.RegisterRequest{
+			NodeID: id,
+			Secret: secret,
+			Port:   8081,
+			Info:   protocol.NodeInfo{Hostname: "host-a", IPs: []string{"192.168.1.5", ip}},
+		}, ip)
+		assert.NoError(t, err)
+		if issued != "" {
+			secret = issued
+		}
+	}
+
+	// 1. 升级后的 Worker 首次心跳: 认领旧记录，保留自定义名称与实例关联
+	beat(nodeID, "10.0.0.1")
+	nodes := nm.GetAllNodes()
+	if assert.Len(t, nodes, 1) {
+		assert.Equal(t, nodeID, nodes[0].ID)
+		assert.Equal(t, "web-1", nodes[0].Name)
+		assert.Equal(t, []string{"10.0.0.1", "192.168.1.5"}, nodes[0].IPs)
+	}
+	inst, ok := im.GetInstance("inst-1")
+	assert.True(t, ok)
+	assert.Equal(t, nodeID, inst.NodeID)
+
+	// 2. 地址变化: 仍是同一节点，实例地址随之更新
+	beat(nodeID, "10.0.0.2")
+	nodes = nm.GetAllNodes()
+	if assert.Len(t, nodes, 1) {
+		assert.Equal(t, "10.0.0.2", nodes[0].IP)
+	}
+	inst, _ = im.GetInstance("inst-1")
+	assert.Equal(t, "10.0.0.2", inst.NodeIP)
+	node, ok := nm.GetInstanceNode(inst)
+	assert.True(t, ok)
+	assert.Equal(t, nodeID, node.ID)
+
+	// 3. 旧版 Worker 不上报 ID: 以 IP 识别，之后可被认领
+	beat("", "10.0.0.3")
+	node, ok = nm.GetNode("10.0.0.3")
+	assert.True(t, ok)
+	assert.Equal(t, "10.0.0.3", node.ID)
+	assert.Len(t, nm.GetAllNodes(), 2)
+
+	// 4. 按 ID 或 IP 操作节点
+	assert.NoError(t, nm.RenameNode("10.0.0.2", "web-1b"))
+	node, _ = nm.GetNode(nodeID)
+	assert.Equal(t, "web-1b", node.Name)
+	assert.NoError(t, nm.DeleteNode(nodeID))
+	_, ok = nm.GetNode(nodeID)
+	assert.False(t, ok)
+}
+
+func TestNodeSecret(t *testing.T) {
+	db := setupNodeDB(t)
+	defer db.Close()
+	nm := manager.NewNodeManager(db, nil, time.Minute)
+	const nodeID = "d4e5f6a7-0000-4000-8000-000000000001"
+
+	// 1. 首次注册签发密钥，之后的心跳须携带
+	secret, err := nm.HandleHeartbeat(protocol.RegisterRequest{NodeID: nodeID}, "10.0.0.1")
+	assert.NoError(t, err)
+	assert.Len(t, secret, 64)
+	issued, err := nm.HandleHeartbeat(protocol.RegisterRequest{NodeID: nodeID, Secret: secret}, "10.0.0.1")
+	assert.NoError(t, err)
+	assert.Empty(t, issued)
+
+	// 2. 冒用节点 ID 的心跳被拒绝，节点地址不变
+	_, err = nm.HandleHeartbeat(protocol.RegisterRequest{NodeID: nodeID}, "10.6.6.6")
+	assert.ErrorIs(t, err, manager.ErrNodeSecret)
+	_, err = nm.HandleHeartbeat(protocol.RegisterRequest{NodeID: nodeID, Secret: "guess"}, "10.6.6.6")
+	assert.ErrorIs(t, err, manager.ErrNodeSecret)
+	node, _ := nm.GetNode(nodeID)
+	assert.Equal(t, "10.0.0.1", node.IP)
+
+	// 反向通道同样以密钥认证
+	assert.True(t, nm.VerifyNodeSecret(nodeID, secret))
+	assert.False(t, nm.VerifyNodeSecret(nodeID, ""))
+	assert.False(t, nm.VerifyNodeSecret("d4e5f6a7-0000-4000-8000-000000000002", ""))
+
+	// 3. 升级前已注册、尚无密钥的节点在下次心跳时补发
+	_, err = db.Exec(`UPDATE node_infos SET secret = '' WHERE id = ?`, nodeID)
+	assert.NoError(t, err)
+	issued, err = nm.HandleHeartbeat(protocol.RegisterRequest{NodeID: nodeID}, "10.0.0.1")
+	assert.NoError(t, err)
+	assert.NotEmpty(t, issued)
+	assert.NotEqual(t, secret, issued)
+
+	// 4. 删除节点后可重新注册
+	assert.NoError(t, nm.DeleteNode(nodeID))
+	issued, err = nm.HandleHeartbeat(protocol.RegisterRequest{NodeID: nodeID}, "10.0.0.2")
+	assert.NoError(t, err)
+	assert.NotEmpty(t, issued)
+}
+
+func TestNodeMetricsBehindNAT(t *testing.T) {
+	db := setupNodeDB(t)
+	defer db.Close()
+	tsdb := monitor.NewMemoryTSDB()
+	nm := manager.NewNodeManager(db, tsdb, time.Minute)
+
+	// 同一 NAT 出口下的两个节点，监控数据按节点 ID 区分
+	nm.HandleHeartbeat(protocol.RegisterRequest{NodeID: "node-a", Status: protocol.NodeStatus{CPUUsage: 10}}, "10.0.0.1")
+	nm.HandleHeartbeat(protocol.RegisterRequest{NodeID: "node-b", Status: protocol.NodeStatus{CPUUsage: 90}}, "10.0.0.1")
+
+	metrics := nm.GetAllNodesMetrics()
+	assert.Len(t, metrics, 2)
+	assert.Equal(t, 10.0, metrics["node-a"].CPUUsage)
+	assert.Equal(t, 90.0, metrics["node-b"].CPUUsage)
+
+	now := time.Now().Unix()
+	if points := tsdb.QueryRange("node_cpu_usage", "node-b", now-60, now+60); assert.Len(t, points, 1) {
+		assert.Equal(t, 90.0, points[0].Value)
+	}
+	assert.Empty(t, tsdb.QueryRange("node_cpu_usage", "10.0.0.1", now-60, now+60))
+}
+
+func TestNodeLabelsAndGroups(t *testing.T) {
+	db := setupNodeDB(t)
+	defer db.Close()
+
+	nm := manager.NewNodeManager(db, nil, time.Minute)
+	beat := func(id, ip string, set map[string]string) {
+		nm.HandleHeartbeat(protocol.RegisterRequest{
+			NodeID: id,
+			Info:   protocol.NodeInfo{Hostname: "host-" + ip, OS: "linux"},
+			Labels: set,
+		}, ip)
+	}
+	beat("a1b2c3d4-0000-4000-8000-000000000001", "10.0.0.1", map[string]string{"env": "prod", "role": "web"})
+	beat("a1b2c3d4-0000-4000-8000-000000000002", "10.0.0.2", map[string]string{"env": "prod", "role": "db", "ip": "spoofed"})
+	beat("a1b2c3d4-0000-4000-8000-000000000003", "10.0.0.3", map[string]string{"env": "dev", "role": "web"})
+
+	ips := func(nodes []protocol.NodeInfo) []string {
+		var res []string
+		for _, n := range nodes {
+			res = append(res, n.IP)
+		}
+		return res
+	}
+
+	// 1. 接口设置的标签覆盖 Worker 上报的同名标签，内置标签不可设置
+	assert.Error(t, nm.SetNodeLabels("10.0.0.3", map[string]string{"ip": "x"}))
+	assert.NoError(t, nm.SetNodeLabels("10.0.0.3", map[string]string{"env": "prod", "rack": "a3"}))
+	node, ok := nm.GetNode("10.0.0.2")
+	assert.True(t, ok)
+	nodes, err := nm.FilterNodes("rack=a3", "")
+	assert.NoError(t, err)
+	if assert.Len(t, nodes, 1) {
+		assert.Equal(t, map[string]string{"env": "prod", "role": "web", "rack": "a3"}, nodes[0].Labels)
+	}
+
+	// 2. Worker 上报的内置标签被丢弃
+	nodes, _ = nm.FilterNodes("ip=spoofed", "")
+	assert.Empty(t, nodes)
+
+	// 3. 分组: 显式节点 ∪ 选择器匹配
+	assert.NoError(t, nm.SaveGroup(protocol.NodeGroup{Name: "db", NodeIDs: []string{node.ID}}))
+	assert.NoError(t, nm.SaveGroup(protocol.NodeGroup{Name: "web", Selector: "role=web"}))
+	groups, err := nm.ListGroups()
+	assert.NoError(t, err)
+	if assert.Len(t, groups, 2) {
+		assert.Equal(t, []string{node.ID}, groups[0].Members)
+		assert.Len(t, groups[1].Members, 2)
+	}
+
+	// 4. 目标选择: 分组与选择器取交集
+	nodes, err = nm.SelectNodes(protocol.NodeTarget{Group: "web", Selector: "rack=a3"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"10.0.0.3"}, ips(nodes))
+	nodes, err = nm.SelectNodes(protocol.NodeTarget{Selector: "env=prod"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"}, ips(nodes))
+	nodes, err = nm.SelectNodes(protocol.NodeTarget{NodeIPs: []string{"10.0.0.1", "10.0.0.2"}, Selector: "role=db"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"10.0.0.2"}, ips(nodes))
+
+	_, err = nm.SelectNodes(protocol.NodeTarget{})
+	assert.Error(t, err)
+	_, err = nm.SelectNodes(protocol.NodeTarget{Group: "missing"})
+	assert.Error(t, err)
+
+	assert.NoError(t, nm.DeleteGroup("db"))
+	assert.Error(t, nm.DeleteGroup("db"))
+}
+
+func TestNodeCordonDrain(t *testing.T) {
+	db := setupNodeDB(t)
+	defer db.Close()
+
+	nm := manager.NewNodeManager(db, nil, time.Minute)
+	const nodeID = "b2c3d4e5-0000-4000-8000-000000000001"
+	nm.HandleHeartbeat(protocol.RegisterRequest{NodeID: nodeID, Info: protocol.NodeInfo{Hostname: "host-a"}}, "10.0.0.1")
+
+	// 1. 封锁后在线节点展示为 maintenance
+	id, err := nm.Cordon("10.0.0.1")
+	assert.NoError(t, err)
+	assert.Equal(t, nodeID, id)
+	node, ok := nm.GetNode(nodeID)
+	assert.True(t, ok)
+	assert.True(t, node.Cordoned)
+	assert.Equal(t, "maintenance", node.Status)
+
+	// 2. 多次排空的记录合并去重
+	assert.NoError(t, nm.RecordDrained(id, []string{"inst-1", "inst-2"}))
+	assert.NoError(t, nm.RecordDrained(id, []string{"inst-2", "inst-3"}))
+	nodes := nm.GetAllNodes()
+	if assert.Len(t, nodes, 1) {
+		assert.Equal(t, "maintenance", nodes[0].Status)
+		assert.Equal(t, []string{"inst-1", "inst-2", "inst-3"}, nodes[0].DrainedInstances)
+	}
+
+	// 3. 解除封锁返回待恢复的实例，并清空记录
+	_, drained, err := nm.Uncordon(nodeID)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"inst-1", "inst-2", "inst-3"}, drained)
+	node, _ = nm.GetNode(nodeID)
+	assert.False(t, node.Cordoned)
+	assert.Equal(t, "online", node.Status)
+	assert.Empty(t, node.DrainedInstances)
+
+	_, err = nm.Cordon("10.9.9.9")
+	assert.Error(t, err)
+}
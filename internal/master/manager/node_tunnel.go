@@ -0,0 +1,22 @@
+package manager
+
+import (
+	"fmt"
+
+	"ops-system/pkg/protocol"
+	"ops-system/pkg/tunnel"
+)
+
+// Tunnels 反向通道会话表 (Worker 连入时登记，Master 访问节点时据此选择通道)
+func (nm *NodeManager) Tunnels() *tunnel.Registry {
+	return nm.tunnels
+}
+
+// WorkerURL 节点 Worker 接口地址，path 含查询参数
+// 经反向通道连接的节点使用 <节点ID>.tunnel，由 utils.Transport 的拨号函数转入通道；其余直连 IP:Port
+func WorkerURL(node *protocol.NodeInfo, path string) string {
+	if node.Tunnel {
+		return "http://" + tunnel.Host(node.ID) + path
+	}
+	return fmt.Sprintf("http://%s:%d%s", node.IP, node.Port, path)
+}
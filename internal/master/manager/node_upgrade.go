@@ -0,0 +1,29 @@
+package manager
+
+import (
+	"time"
+
+	"ops-system/pkg/protocol"
+)
+
+// applyUpgradeState 根据升级截止时间计算自升级状态
+// Worker 以目标版本心跳后 upgrade_target 被清空；超过截止时间仍未完成视为失败 (Worker 已自行回滚或未能启动)
+func applyUpgradeState(n *protocol.NodeInfo, deadline, now int64) {
+	if n.UpgradeTarget == "" {
+		return
+	}
+	if now > deadline {
+		n.Upgrade = "failed"
+	} else {
+		n.Upgrade = "upgrading"
+	}
+}
+
+// MarkUpgrading 记录已下发的自升级 (timeout 为 Worker 回滚时长，额外留出下载与重启的时间)
+func (nm *NodeManager) MarkUpgrading(id, version string, timeout time.Duration) error {
+	nm.mu.Lock()
+	defer nm.mu.Unlock()
+	deadline := time.Now().Add(timeout + 2*time.Minute).Unix()
+	_, err := nm.db.Exec("UPDATE node_infos SET upgrade_target = ?, upgrade_deadline = ? WHERE id = ?", version, deadline, id)
+	return err
+}
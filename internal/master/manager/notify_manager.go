@@ -0,0 +1,155 @@
+package manager
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"ops-system/internal/master/notify"
+	"ops-system/pkg/protocol"
+)
+
+// 发送失败重试策略
+const (
+	notifyMaxAttempts = 3
+	notifyRetryDelay  = 5 * time.Second
+)
+
+// NotifyManager 负责通知渠道的管理与告警消息投递
+type NotifyManager struct {
+	db *sql.DB
+}
+
+func NewNotifyManager(db *sql.DB) *NotifyManager {
+	return &NotifyManager{db: db}
+}
+
+// --- 渠道管理 ---
+
+func (nm *NotifyManager) AddChannel(ch protocol.NotifyChannel) error {
+	cfg, _ := json.Marshal(ch.Config)
+	_, err := nm.db.Exec(`INSERT INTO sys_notify_channels (name, type, config, enabled, create_time) VALUES (?, ?, ?, ?, ?)`,
+		ch.Name, ch.Type, string(cfg), ch.Enabled, time.Now().Unix())
+	return err
+}
+
+func (nm *NotifyManager) UpdateChannel(ch protocol.NotifyChannel) error {
+	cfg, _ := json.Marshal(ch.Config)
+	_, err := nm.db.Exec(`UPDATE sys_notify_channels SET name = ?, type = ?, config = ?, enabled = ? WHERE id = ?`,
+		ch.Name, ch.Type, string(cfg), ch.Enabled, ch.ID)
+	return err
+}
+
+func (nm *NotifyManager) DeleteChannel(id int64) error {
+	_, err := nm.db.Exec("DELETE FROM sys_notify_channels WHERE id = ?", id)
+	return err
+}
+
+func (nm *NotifyManager) GetChannels() ([]*protocol.NotifyChannel, error) {
+	rows, err := nm.db.Query("SELECT id, name, type, config, enabled, create_time FROM sys_notify_channels ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := []*protocol.NotifyChannel{}
+	for rows.Next() {
+		var ch protocol.NotifyChannel
+		var cfg string
+		rows.Scan(&ch.ID, &ch.Name, &ch.Type, &cfg, &ch.Enabled, &ch.CreateTime)
+		json.Unmarshal([]byte(cfg), &ch.Config)
+		list = append(list, &ch)
+	}
+	return list, nil
+}
+
+func (nm *NotifyManager) GetChannel(id int64) (*protocol.NotifyChannel, error) {
+	var ch protocol.NotifyChannel
+	var cfg string
+	err := nm.db.QueryRow("SELECT id, name, type, config, enabled, create_time FROM sys_notify_channels WHERE id = ?", id).
+		Scan(&ch.ID, &ch.Name, &ch.Type, &cfg, &ch.Enabled, &ch.CreateTime)
+	if err != nil {
+		return nil, err
+	}
+	json.Unmarshal([]byte(cfg), &ch.Config)
+	return &ch, nil
+}
+
+// GetLogs 最近的投递记录
+func (nm *NotifyManager) GetLogs(limit int) ([]*protocol.NotifyLog, error) {
+	rows, err := nm.db.Query(`SELECT id, channel_id, channel_name, event_id, kind, status, attempts, error, create_time
+		FROM sys_notify_logs ORDER BY id DESC LIMIT ?`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := []*protocol.NotifyLog{}
+	for rows.Next() {
+		var l protocol.NotifyLog
+		rows.Scan(&l.ID, &l.ChannelID, &l.ChannelName, &l.EventID, &l.Kind, &l.Status, &l.Attempts, &l.Error, &l.CreateTime)
+		list = append(list, &l)
+	}
+	return list, nil
+}
+
+// --- 投递 ---
+
+// Dispatch 异步向规则绑定的所有渠道推送告警 (不阻塞评估循环)
+func (nm *NotifyManager) Dispatch(channelIDs []int64, msg notify.Message) {
+	for _, id := range channelIDs {
+		ch, err := nm.GetChannel(id)
+		if err != nil {
+			log.Printf("[Notify] channel %d not found: %v", id, err)
+			continue
+		}
+		if !ch.Enabled {
+			continue
+		}
+		go nm.deliver(ch, msg)
+	}
+}
+
+// TestChannel 同步发送一条测试消息 (用于配置渠道时验证连通性)
+func (nm *NotifyManager) TestChannel(id int64) error {
+	ch, err := nm.GetChannel(id)
+	if err != nil {
+		return err
+	}
+	msg := notify.Message{
+		Kind:       "test",
+		RuleName:   "Test Notification",
+		TargetName: "ops-system",
+		Message:    fmt.Sprintf("This is a test message from channel [%s]", ch.Name),
+		StartTime:  time.Now().Unix(),
+	}
+	err = notify.Send(ch, msg)
+	nm.recordLog(ch, msg, 1, err)
+	return err
+}
+
+// deliver 带重试的发送，最终结果写入投递记录
+func (nm *NotifyManager) deliver(ch *protocol.NotifyChannel, msg notify.Message) {
+	var err error
+	attempts := 0
+	for attempts < notifyMaxAttempts {
+		attempts++
+		if err = notify.Send(ch, msg); err == nil {
+			break
+		}
+		log.Printf("[Notify] send to %s failed (attempt %d/%d): %v", ch.Name, attempts, notifyMaxAttempts, err)
+		if attempts < notifyMaxAttempts {
+			time.Sleep(notifyRetryDelay * time.Duration(attempts))
+		}
+	}
+	nm.recordLog(ch, msg, attempts, err)
+}
+
+func (nm *NotifyManager) recordLog(ch *protocol.NotifyChannel, msg notify.Message, attempts int, sendErr error) {
+	status, errMsg := "success", ""
+	if sendErr != nil {
+		status, errMsg = "fail", sendErr.Error()
+	}
+	nm.db.Exec(`INSERT INTO sys_notify_logs (channel_id, channel_name, event_id, kind, status, attempts, error, create_time) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		ch.ID, ch.Name, msg.EventID, msg.Kind, status, attempts, errMsg, time.Now().Unix())
+}
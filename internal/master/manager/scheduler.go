@@ -0,0 +1,234 @@
+package manager
+
+import (
+	"fmt"
+	"sort"
+
+	"ops-system/pkg/labels"
+	"ops-system/pkg/protocol"
+)
+
+// Scheduler 副本调度器: 按模块声明的副本数与调度约束选择节点
+// 只负责计算计划，实例的创建与下发由调用方完成
+type Scheduler struct {
+	sysMgr  *SystemManager
+	nodeMgr *NodeManager
+	instMgr *InstanceManager
+}
+
+func NewScheduler(sys *SystemManager, node *NodeManager, inst *InstanceManager) *Scheduler {
+	return &Scheduler{sysMgr: sys, nodeMgr: node, instMgr: inst}
+}
+
+// ValidatePlacement 校验副本数与调度约束
+func ValidatePlacement(replicas int, p protocol.PlacementPolicy) error {
+	if replicas < 0 {
+		return fmt.Errorf("replicas must not be negative")
+	}
+	if _, err := labels.Parse(p.Selector); err != nil {
+		return err
+	}
+	if p.SpreadBy != "" {
+		if err := labels.Validate(map[string]string{p.SpreadBy: "x"}); err != nil {
+			return fmt.Errorf("invalid spread_by: %v", err)
+		}
+	}
+	if p.MinFreeCPU < 0 {
+		return fmt.Errorf("min_free_cpu must not be negative")
+	}
+	return nil
+}
+
+// Plan 计算模块的调度计划: 为缺少的副本选择节点 (不创建实例)
+func (s *Scheduler) Plan(modID string) (*protocol.PlacementPlan, error) {
+	mod, err := s.sysMgr.GetModule(modID)
+	if err != nil {
+		return nil, err
+	}
+	modules, err := s.sysMgr.GetModules(mod.SystemID)
+	if err != nil {
+		return nil, err
+	}
+	return planPlacement(mod, modules, s.nodeMgr.GetAllNodes(), s.instMgr.GetAllInstancesMetrics())
+}
+
+// EvictNode 节点删除后清理其上调度模块的实例记录，返回需要重新调度的模块 ID
+// 未声明副本数的模块实例保持原样 (沿用手工部署的处理方式)
+func (s *Scheduler) EvictNode(node *protocol.NodeInfo) ([]string, error) {
+	modules, err := s.sysMgr.GetModules("")
+	if err != nil {
+		return nil, err
+	}
+	scheduled := make(map[string]string) // system_id/package_name -> module_id
+	for _, m := range modules {
+		if m.Replicas > 0 {
+			scheduled[m.SystemID+"/"+m.PackageName] = m.ID
+		}
+	}
+
+	affected := make(map[string]bool)
+	for _, inst := range s.instMgr.GetAllInstancesMetrics() {
+		onNode := inst.NodeID == node.ID || (inst.NodeID == "" && inst.NodeIP == node.IP)
+		modID, ok := scheduled[inst.SystemID+"/"+inst.ServiceName]
+		if !onNode || !ok {
+			continue
+		}
+		s.instMgr.RemoveInstance(inst.ID)
+		affected[modID] = true
+	}
+	return sortedKeys(affected), nil
+}
+
+// planPlacement 调度算法:
+//  1. 过滤: 在线、匹配选择器、具备打散标签、未运行本模块与反亲和模块、空闲资源满足要求
+//  2. 打分: 打散标签取值上副本最少 > 节点实例最少 > 空闲内存最多 > IP 顺序
+//
+// 每个节点最多放置一个副本，节点不足时剩余副本计入 Unplaced
+func planPlacement(mod *protocol.SystemModule, modules []*protocol.SystemModule, nodes []protocol.NodeInfo, instances map[string]protocol.InstanceInfo) (*protocol.PlacementPlan, error) {
+	p := mod.Placement
+	sel, err := labels.Parse(p.Selector)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &protocol.PlacementPlan{
+		ModuleID:    mod.ID,
+		SystemID:    mod.SystemID,
+		ServiceName: mod.PackageName,
+		Version:     mod.PackageVersion,
+		Replicas:    mod.Replicas,
+		Nodes:       []protocol.PlacementNode{},
+	}
+
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].IP < nodes[j].IP })
+	byID := make(map[string]*protocol.NodeInfo, len(nodes))
+	byIP := make(map[string]*protocol.NodeInfo, len(nodes))
+	zone := make(map[string]string, len(nodes)) // 节点 ID -> 打散标签取值 (可为内置标签)
+	for i := range nodes {
+		byID[nodes[i].ID] = &nodes[i]
+		byIP[nodes[i].IP] = &nodes[i]
+		if p.SpreadBy != "" {
+			zone[nodes[i].ID] = nodeLabels(&nodes[i])[p.SpreadBy]
+		}
+	}
+	locate := func(inst *protocol.InstanceInfo) *protocol.NodeInfo {
+		if n, ok := byID[inst.NodeID]; ok {
+			return n
+		}
+		if inst.NodeID == "" {
+			return byIP[inst.NodeIP]
+		}
+		return nil
+	}
+
+	// 反亲和: 同系统内按模块名解析为服务包名
+	antiNames := make(map[string]bool, len(p.AntiAffinity))
+	for _, name := range p.AntiAffinity {
+		antiNames[name] = true
+	}
+	antiPkgs := make(map[string]bool)
+	for _, m := range modules {
+		if m.SystemID == mod.SystemID && antiNames[m.ModuleName] {
+			antiPkgs[m.PackageName] = true
+		}
+	}
+
+	// 统计现有实例分布 (失败的实例与所在节点已删除的实例不计入副本)
+	hosted := make(map[string]int)
+	hasSelf := make(map[string]bool)
+	hasAnti := make(map[string]bool)
+	spread := make(map[string]int)
+	for _, inst := range instances {
+		n := locate(&inst)
+		if n == nil {
+			continue
+		}
+		hosted[n.ID]++
+		if inst.SystemID != mod.SystemID {
+			continue
+		}
+		if antiPkgs[inst.ServiceName] {
+			hasAnti[n.ID] = true
+		}
+		if inst.ServiceName == mod.PackageName && inst.Status != "error" {
+			plan.Current++
+			hasSelf[n.ID] = true
+			if p.SpreadBy != "" {
+				spread[zone[n.ID]]++
+			}
+		}
+	}
+
+	need := mod.Replicas - plan.Current
+	if need <= 0 {
+		return plan, nil
+	}
+
+	// 1. 过滤
+	var candidates []*protocol.NodeInfo
+	for i := range nodes {
+		n := &nodes[i]
+		freeMem := float64(n.MemTotal) * (100 - n.MemUsage) / 100
+		freeCPU := float64(n.CPUCores) * (100 - n.CPUUsage) / 100
+
+		var reason string
+		switch {
+		case n.Status != "online":
+			reason = "节点状态为 " + n.Status
+		case !sel.Matches(nodeLabels(n)):
+			reason = "不匹配标签选择器"
+		case p.SpreadBy != "" && zone[n.ID] == "":
+			reason = "缺少打散标签 " + p.SpreadBy
+		case hasSelf[n.ID]:
+			reason = "已运行该模块的副本"
+		case hasAnti[n.ID]:
+			reason = "运行着反亲和模块的实例"
+		case p.MinFreeMem > 0 && freeMem < float64(p.MinFreeMem):
+			reason = fmt.Sprintf("空闲内存不足 (%.0f MB)", freeMem)
+		case p.MinFreeCPU > 0 && freeCPU < p.MinFreeCPU:
+			reason = fmt.Sprintf("空闲 CPU 不足 (%.2f 核)", freeCPU)
+		}
+		if reason != "" {
+			plan.Rejected = append(plan.Rejected, protocol.PlacementNode{NodeID: n.ID, NodeIP: n.IP, Reason: reason})
+			continue
+		}
+		candidates = append(candidates, n)
+	}
+
+	// 2. 逐个副本选择得分最优的节点
+	for ; need > 0 && len(candidates) > 0; need-- {
+		best := 0
+		for i := 1; i < len(candidates); i++ {
+			if betterPlacement(candidates[i], candidates[best], zone, spread, hosted) {
+				best = i
+			}
+		}
+		n := candidates[best]
+		candidates = append(candidates[:best], candidates[best+1:]...)
+
+		pn := protocol.PlacementNode{NodeID: n.ID, NodeIP: n.IP}
+		if p.SpreadBy != "" {
+			pn.Reason = p.SpreadBy + "=" + zone[n.ID]
+			spread[zone[n.ID]]++
+		}
+		hosted[n.ID]++
+		plan.Nodes = append(plan.Nodes, pn)
+	}
+	plan.Unplaced = need
+	return plan, nil
+}
+
+// betterPlacement a 是否优于 b (候选列表已按 IP 排序，得分相同时保留靠前的节点)
+func betterPlacement(a, b *protocol.NodeInfo, zone map[string]string, spread, hosted map[string]int) bool {
+	if len(zone) > 0 {
+		if sa, sb := spread[zone[a.ID]], spread[zone[b.ID]]; sa != sb {
+			return sa < sb
+		}
+	}
+	if hosted[a.ID] != hosted[b.ID] {
+		return hosted[a.ID] < hosted[b.ID]
+	}
+	freeA := float64(a.MemTotal) * (100 - a.MemUsage) / 100
+	freeB := float64(b.MemTotal) * (100 - b.MemUsage) / 100
+	return freeA > freeB
+}
@@ -0,0 +1,95 @@
+package manager_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"ops-system/internal/master/manager"
+	"ops-system/pkg/protocol"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSchedulerPlan(t *testing.T) {
+	db := setupNodeDB(t)
+	defer db.Close()
+
+	sysMgr := manager.NewSystemManager(db)
+	instMgr := manager.NewInstanceManager(db, nil)
+	nodeMgr := manager.NewNodeManager(db, nil, time.Minute)
+	sched := manager.NewScheduler(sysMgr, nodeMgr, instMgr)
+
+	beat := func(n int, memUsage float64, set map[string]string) string {
+		id := fmt.Sprintf("c3d4e5f6-0000-4000-8000-%012d", n)
+		nodeMgr.HandleHeartbeat(protocol.RegisterRequest{
+			NodeID: id,
+			Info:   protocol.NodeInfo{Hostname: "host", CPUCores: 4, MemTotal: 4096},
+			Status: protocol.NodeStatus{CPUUsage: 10, MemUsage: memUsage},
+			Labels: set,
+		}, fmt.Sprintf("10.0.0.%d", n))
+		return id
+	}
+	n1 := beat(1, 20, map[string]string{"rack": "a"})
+	n2 := beat(2, 20, map[string]string{"rack": "a"})
+	n3 := beat(3, 20, map[string]string{"rack": "b"}) // 运行着 db
+	n4 := beat(4, 20, map[string]string{"rack": "b"})
+	beat(5, 95, map[string]string{"rack": "b"}) // 内存不足
+	beat(6, 20, nil)                            // 缺少 rack 标签
+
+	sys := sysMgr.CreateSystem("shop", "")
+	assert.NoError(t, sysMgr.AddModule(sys.ID, "api", "api-svc", "v1", ""))
+	assert.NoError(t, sysMgr.AddModule(sys.ID, "db", "db-svc", "v1", ""))
+	var modID string
+	assert.NoError(t, db.QueryRow("SELECT id FROM system_modules WHERE module_name = 'api'").Scan(&modID))
+
+	instMgr.RegisterInstance(&protocol.InstanceInfo{ID: "db-1", SystemID: sys.ID, NodeID: n3, NodeIP: "10.0.0.3", ServiceName: "db-svc", Status: "running"})
+	instMgr.RegisterInstance(&protocol.InstanceInfo{ID: "api-1", SystemID: sys.ID, NodeID: n1, NodeIP: "10.0.0.1", ServiceName: "api-svc", Status: "running"})
+
+	assert.Error(t, sysMgr.SetModulePlacement(modID, 3, protocol.PlacementPolicy{Selector: "rack in ("}))
+	assert.NoError(t, sysMgr.SetModulePlacement(modID, 3, protocol.PlacementPolicy{
+		SpreadBy:     "rack",
+		AntiAffinity: []string{"db"},
+		MinFreeMem:   1024,
+	}))
+
+	nodeIDs := func(list []protocol.PlacementNode) []string {
+		var res []string
+		for _, pn := range list {
+			res = append(res, pn.NodeID)
+		}
+		return res
+	}
+
+	// 1. 已有 1 个副本 (rack=a)，剩余 2 个先补到 rack=b
+	plan, err := sched.Plan(modID)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, plan.Current)
+	assert.Equal(t, []string{n4, n2}, nodeIDs(plan.Nodes))
+	assert.Equal(t, 0, plan.Unplaced)
+	assert.Len(t, plan.Rejected, 4) // 已有副本 / 反亲和 / 内存不足 / 缺少标签
+
+	// 2. 维护中的节点不参与调度，副本不足时计入 Unplaced
+	_, err = nodeMgr.Cordon(n4)
+	assert.NoError(t, err)
+	plan, err = sched.Plan(modID)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{n2}, nodeIDs(plan.Nodes))
+	assert.Equal(t, 1, plan.Unplaced)
+
+	// 3. 节点删除后清理其上的副本，计划重新补齐
+	node, ok := nodeMgr.GetNode(n1)
+	assert.True(t, ok)
+	assert.NoError(t, nodeMgr.DeleteNode(n1))
+	modIDs, err := sched.EvictNode(node)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{modID}, modIDs)
+	_, ok = instMgr.GetInstance("api-1")
+	assert.False(t, ok)
+	_, ok = instMgr.GetInstance("db-1")
+	assert.True(t, ok)
+	plan, err = sched.Plan(modID)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, plan.Current)
+	assert.Equal(t, 2, plan.Unplaced)
+}
@@ -2,6 +2,7 @@ package manager
 
 import (
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"sync"
 	"time"
@@ -50,10 +51,97 @@ func (sm *SystemManager) AddModule(sysID, name, pkgName, pkgVer, desc string) er
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
 	id := fmt.Sprintf("mod-%d", time.Now().UnixNano())
-	_, err := sm.db.Exec(`INSERT INTO system_modules VALUES (?, ?, ?, ?, ?, ?)`, id, sysID, name, pkgName, pkgVer, desc)
+	_, err := sm.db.Exec(`INSERT INTO system_modules (id, system_id, module_name, package_name, package_version, description) VALUES (?, ?, ?, ?, ?, ?)`,
+		id, sysID, name, pkgName, pkgVer, desc)
 	return err
 }
 
+// SetModuleBindings 更新模块的配置绑定 (整体替换)
+func (sm *SystemManager) SetModuleBindings(modID string, bindings []protocol.ConfigBinding) error {
+	if err := ValidateConfigBindings(bindings); err != nil {
+		return err
+	}
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	if bindings == nil {
+		bindings = []protocol.ConfigBinding{}
+	}
+	data, _ := json.Marshal(bindings)
+	res, err := sm.db.Exec(`UPDATE system_modules SET config_bindings = ? WHERE id = ?`, string(data), modID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("module %s not found", modID)
+	}
+	return nil
+}
+
+// GetModules 查询模块定义，systemID 为空时返回全部
+func (sm *SystemManager) GetModules(systemID string) ([]*protocol.SystemModule, error) {
+	query := `SELECT id, system_id, module_name, package_name, package_version, description, COALESCE(config_bindings, '[]'),
+		COALESCE(replicas, 0), COALESCE(placement, '{}') FROM system_modules`
+	var args []interface{}
+	if systemID != "" {
+		query += ` WHERE system_id = ?`
+		args = append(args, systemID)
+	}
+	rows, err := sm.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []*protocol.SystemModule
+	for rows.Next() {
+		var m protocol.SystemModule
+		var bindings, placement string
+		if err := rows.Scan(&m.ID, &m.SystemID, &m.ModuleName, &m.PackageName, &m.PackageVersion, &m.Description, &bindings,
+			&m.Replicas, &placement); err != nil {
+			continue
+		}
+		json.Unmarshal([]byte(bindings), &m.ConfigBindings)
+		json.Unmarshal([]byte(placement), &m.Placement)
+		if m.ConfigBindings == nil {
+			m.ConfigBindings = []protocol.ConfigBinding{}
+		}
+		list = append(list, &m)
+	}
+	return list, nil
+}
+
+// GetModule 按 ID 查询单个模块
+func (sm *SystemManager) GetModule(modID string) (*protocol.SystemModule, error) {
+	modules, err := sm.GetModules("")
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range modules {
+		if m.ID == modID {
+			return m, nil
+		}
+	}
+	return nil, fmt.Errorf("module %s not found", modID)
+}
+
+// SetModulePlacement 更新模块的副本数与调度约束
+func (sm *SystemManager) SetModulePlacement(modID string, replicas int, policy protocol.PlacementPolicy) error {
+	if err := ValidatePlacement(replicas, policy); err != nil {
+		return err
+	}
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	data, _ := json.Marshal(policy)
+	res, err := sm.db.Exec(`UPDATE system_modules SET replicas = ?, placement = ? WHERE id = ?`, replicas, string(data), modID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("module %s not found", modID)
+	}
+	return nil
+}
+
 // DeleteModule 删除模块
 func (sm *SystemManager) DeleteModule(modID string) error {
 	sm.mu.Lock()
@@ -75,14 +163,10 @@ func (sm *SystemManager) GetFullView(im *InstanceManager) interface{} {
 	}
 
 	// 2. 获取所有模块
-	modRows, _ := sm.db.Query(`SELECT id, system_id, module_name, package_name, package_version, description FROM system_modules`)
-	defer modRows.Close()
+	modules, _ := sm.GetModules("")
 	modMap := make(map[string][]*protocol.SystemModule)
-	for modRows.Next() {
-		var m protocol.SystemModule
-		modRows.Scan(&m.ID, &m.SystemID, &m.ModuleName, &m.PackageName, &m.PackageVersion, &m.Description)
-		val := m
-		modMap[m.SystemID] = append(modMap[m.SystemID], &val)
+	for _, m := range modules {
+		modMap[m.SystemID] = append(modMap[m.SystemID], m)
 	}
 
 	// 3. 获取所有实例 (调用 InstanceManager)
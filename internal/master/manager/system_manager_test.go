@@ -22,8 +22,8 @@ func setupTestDB(t *testing.T) *sql.DB {
 	// 手动初始化表结构 (复制自 db/sqlite.go，或者如果 db 包有导出 InitTables 可复用)
 	sqls := []string{
 		`CREATE TABLE IF NOT EXISTS system_infos (id TEXT PRIMARY KEY, name TEXT, description TEXT, create_time INTEGER);`,
-		`CREATE TABLE IF NOT EXISTS system_modules (id TEXT PRIMARY KEY, system_id TEXT, module_name TEXT, package_name TEXT, package_version TEXT, description TEXT);`,
-		`CREATE TABLE IF NOT EXISTS instance_infos (id TEXT PRIMARY KEY, system_id TEXT, node_ip TEXT, service_name TEXT, service_version TEXT, status TEXT, pid INTEGER, uptime INTEGER);`,
+		`CREATE TABLE IF NOT EXISTS system_modules (id TEXT PRIMARY KEY, system_id TEXT, module_name TEXT, package_name TEXT, package_version TEXT, description TEXT, config_bindings TEXT DEFAULT '[]', replicas INTEGER DEFAULT 0, placement TEXT DEFAULT '{}');`,
+		`CREATE TABLE IF NOT EXISTS instance_infos (id TEXT PRIMARY KEY, system_id TEXT, node_id TEXT DEFAULT '', node_ip TEXT, service_name TEXT, service_version TEXT, status TEXT, pid INTEGER, uptime INTEGER);`,
 	}
 
 	for _, sqlStmt := range sqls {
@@ -39,7 +39,7 @@ func TestSystemLifecycle(t *testing.T) {
 	defer db.Close()
 
 	sysMgr := manager.NewSystemManager(db)
-	instMgr := manager.NewInstanceManager(db)
+	instMgr := manager.NewInstanceManager(db, nil)
 
 	// 1. 测试创建系统
 	sys := sysMgr.CreateSystem("PaymentSys", "Core Payment")
@@ -115,3 +115,32 @@ func TestDeleteModule(t *testing.T) {
 	db.QueryRow("SELECT count(*) FROM system_modules").Scan(&count)
 	assert.Equal(t, 0, count)
 }
+
+func TestModuleConfigBindings(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	sysMgr := manager.NewSystemManager(db)
+
+	sys := sysMgr.CreateSystem("Test", "")
+	sysMgr.AddModule(sys.ID, "Mod1", "Pkg", "v1", "")
+	var modID string
+	db.QueryRow("SELECT id FROM system_modules").Scan(&modID)
+
+	// 非法路径与未知重载策略被拒绝
+	assert.Error(t, sysMgr.SetModuleBindings(modID, []protocol.ConfigBinding{{DataID: "a", Path: "../etc/passwd"}}))
+	assert.Error(t, sysMgr.SetModuleBindings(modID, []protocol.ConfigBinding{{DataID: "a", Path: "/etc/app.yaml"}}))
+	assert.Error(t, sysMgr.SetModuleBindings(modID, []protocol.ConfigBinding{{DataID: "a", Path: "app.yaml", Reload: "kill"}}))
+	assert.Error(t, sysMgr.SetModuleBindings("mod-none", nil))
+
+	bindings := []protocol.ConfigBinding{
+		{DataID: "app.yaml", Path: "conf/app.yaml", Reload: protocol.ReloadRestart},
+		{Group: "NGINX", Path: "conf.d", Reload: protocol.ReloadSignal, Signal: "HUP"},
+	}
+	assert.NoError(t, sysMgr.SetModuleBindings(modID, bindings))
+
+	mods, err := sysMgr.GetModules(sys.ID)
+	assert.NoError(t, err)
+	if assert.Len(t, mods, 1) {
+		assert.Equal(t, bindings, mods[0].ConfigBindings)
+	}
+}
@@ -0,0 +1,342 @@
+package manager
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"ops-system/pkg/protocol"
+)
+
+// TaskManager 异步任务记录
+// 每次 Master -> Worker 操作创建一个任务，按节点/实例拆分为子任务；
+// 子任务结果来自同步调用的返回或 Worker 的进度上报，全部结束后汇总任务状态
+type TaskManager struct {
+	db      *sql.DB
+	instMgr *InstanceManager
+	mu      sync.Mutex
+}
+
+func NewTaskManager(db *sql.DB, inst *InstanceManager) *TaskManager {
+	return &TaskManager{db: db, instMgr: inst}
+}
+
+// CreateTask 创建任务 (queued)，返回填充了 ID 的任务
+// timeout 后仍未结束的子任务由 SweepTimeouts 标记为 timeout；没有子任务的任务直接视为成功
+func (tm *TaskManager) CreateTask(typ, target, operator string, items []protocol.TaskItem, timeout time.Duration) (*protocol.Task, error) {
+	now := time.Now()
+	task := &protocol.Task{
+		ID:         fmt.Sprintf("task-%d", now.UnixNano()),
+		Type:       typ,
+		Target:     target,
+		Operator:   operator,
+		Status:     protocol.TaskQueued,
+		Total:      len(items),
+		CreateTime: now.Unix(),
+		Deadline:   now.Add(timeout).Unix(),
+	}
+	if len(items) == 0 {
+		task.Status = protocol.TaskSucceeded
+		task.EndTime = task.CreateTime
+	}
+
+	tx, err := tm.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`INSERT INTO tasks (id, type, target, operator, status, create_time, end_time, deadline) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		task.ID, task.Type, task.Target, task.Operator, task.Status, task.CreateTime, task.EndTime, task.Deadline)
+	if err != nil {
+		return nil, err
+	}
+	for _, it := range items {
+		it.TaskID = task.ID
+		it.Status = protocol.TaskQueued
+		res, err := tx.Exec(`INSERT INTO task_items (task_id, node_id, node_ip, instance_id, status) VALUES (?, ?, ?, ?, ?)`,
+			it.TaskID, it.NodeID, it.NodeIP, it.InstanceID, it.Status)
+		if err != nil {
+			return nil, err
+		}
+		it.ID, _ = res.LastInsertId()
+		task.Items = append(task.Items, it)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return task, nil
+}
+
+// StartItem 子任务开始执行，instanceID 非空时记录关联的实例 (部署时实例在此刻创建)
+func (tm *TaskManager) StartItem(itemID int64, instanceID string) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	now := time.Now().Unix()
+	tm.db.Exec(`UPDATE task_items SET status = ?, start_time = ?, instance_id = CASE WHEN ? = '' THEN instance_id ELSE ? END
+		WHERE id = ? AND status = ?`, protocol.TaskRunning, now, instanceID, instanceID, itemID, protocol.TaskQueued)
+	tm.db.Exec(`UPDATE tasks SET status = ?, start_time = ? WHERE id = (SELECT task_id FROM task_items WHERE id = ?) AND status = ?`,
+		protocol.TaskRunning, now, itemID, protocol.TaskQueued)
+}
+
+// FinishItem 子任务结束 (已结束的子任务不再改变，如超时后迟到的上报)
+func (tm *TaskManager) FinishItem(itemID int64, status, output, errMsg string) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	tm.finishItem(itemID, status, output, errMsg, time.Now().Unix())
+}
+
+// FinishExecItem 命令子任务结束并记录退出码: 退出码为 0 且无错误时成功
+// exitCode 为 nil 表示命令未执行完成 (如连接失败)
+func (tm *TaskManager) FinishExecItem(itemID int64, exitCode *int, output, errMsg string) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	status := protocol.TaskSucceeded
+	if exitCode == nil || *exitCode != 0 || errMsg != "" {
+		status = protocol.TaskFailed
+	}
+	if exitCode != nil {
+		tm.db.Exec(`UPDATE task_items SET exit_code = ? WHERE id = ? AND status IN (?, ?)`,
+			*exitCode, itemID, protocol.TaskQueued, protocol.TaskRunning)
+	}
+	tm.finishItem(itemID, status, output, errMsg, time.Now().Unix())
+}
+
+func (tm *TaskManager) finishItem(itemID int64, status, output, errMsg string, now int64) {
+	progress := 0
+	if status == protocol.TaskSucceeded {
+		progress = 100
+	}
+	res, err := tm.db.Exec(`UPDATE task_items SET status = ?, progress = CASE WHEN ? > progress THEN ? ELSE progress END,
+		output = ?, error = ?, start_time = CASE WHEN start_time = 0 THEN ? ELSE start_time END, end_time = ?
+		WHERE id = ? AND status IN (?, ?)`,
+		status, progress, progress, output, errMsg, now, now, itemID, protocol.TaskQueued, protocol.TaskRunning)
+	if err != nil {
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return
+	}
+	var taskID string
+	if err := tm.db.QueryRow(`SELECT task_id FROM task_items WHERE id = ?`, itemID).Scan(&taskID); err == nil {
+		tm.refreshTask(taskID, now)
+	}
+}
+
+// refreshTask 子任务全部结束后汇总任务状态: 有失败为 failed，否则有超时为 timeout
+func (tm *TaskManager) refreshTask(taskID string, now int64) {
+	var total, done, failed, timedOut int
+	err := tm.db.QueryRow(`SELECT COUNT(*),
+		COALESCE(SUM(CASE WHEN status IN (?, ?, ?) THEN 1 ELSE 0 END), 0),
+		COALESCE(SUM(CASE WHEN status = ? THEN 1 ELSE 0 END), 0),
+		COALESCE(SUM(CASE WHEN status = ? THEN 1 ELSE 0 END), 0)
+		FROM task_items WHERE task_id = ?`,
+		protocol.TaskSucceeded, protocol.TaskFailed, protocol.TaskTimeout, protocol.TaskFailed, protocol.TaskTimeout, taskID).
+		Scan(&total, &done, &failed, &timedOut)
+	if err != nil || done < total {
+		return
+	}
+
+	status, errMsg := protocol.TaskSucceeded, ""
+	switch {
+	case failed > 0:
+		status = protocol.TaskFailed
+		errMsg = fmt.Sprintf("%d/%d 个子任务失败", failed+timedOut, total)
+	case timedOut > 0:
+		status = protocol.TaskTimeout
+		errMsg = fmt.Sprintf("%d/%d 个子任务超时", timedOut, total)
+	}
+	tm.db.Exec(`UPDATE tasks SET status = ?, error = ?, start_time = CASE WHEN start_time = 0 THEN ? ELSE start_time END, end_time = ?
+		WHERE id = ? AND status IN (?, ?)`, status, errMsg, now, now, taskID, protocol.TaskQueued, protocol.TaskRunning)
+}
+
+// Report 处理 nodeID 节点上报的子任务进度 (只接受以该节点为目标的子任务)
+func (tm *TaskManager) Report(nodeID string, r protocol.TaskReport) error {
+	var taskID, itemNode string
+	err := tm.db.QueryRow(`SELECT task_id, COALESCE(node_id, '') FROM task_items WHERE id = ?`, r.ItemID).Scan(&taskID, &itemNode)
+	if err != nil || taskID != r.TaskID || itemNode != nodeID {
+		return fmt.Errorf("task item %s/%d not found on node %s", r.TaskID, r.ItemID, nodeID)
+	}
+
+	switch r.Status {
+	case protocol.TaskSucceeded, protocol.TaskFailed:
+		tm.FinishItem(r.ItemID, r.Status, "", r.Error)
+	case protocol.TaskRunning:
+		tm.StartItem(r.ItemID, "")
+		if r.Progress < 0 {
+			r.Progress = 0
+		} else if r.Progress > 100 {
+			r.Progress = 100
+		}
+		tm.db.Exec(`UPDATE task_items SET progress = ?, message = ? WHERE id = ? AND status = ?`,
+			r.Progress, r.Message, r.ItemID, protocol.TaskRunning)
+	default:
+		return fmt.Errorf("invalid task status: %s", r.Status)
+	}
+	return nil
+}
+
+// CompleteInstanceItems 实例离开 deploying 后结束其仍在执行的部署子任务
+// 兼容不上报任务进度的旧版 Worker；新版 Worker 先上报任务结果，此处不再改变已结束的子任务
+func (tm *TaskManager) CompleteInstanceItems(instanceID, instStatus string) {
+	status, errMsg := protocol.TaskSucceeded, ""
+	switch instStatus {
+	case "stopped", "running":
+	case "error":
+		status, errMsg = protocol.TaskFailed, "部署失败"
+	default:
+		return
+	}
+
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	rows, err := tm.db.Query(`SELECT i.id FROM task_items i JOIN tasks t ON t.id = i.task_id
+		WHERE i.instance_id = ? AND i.status = ? AND t.type = 'deploy'`, instanceID, protocol.TaskRunning)
+	if err != nil {
+		return
+	}
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if rows.Scan(&id) == nil {
+			ids = append(ids, id)
+		}
+	}
+	rows.Close()
+	for _, id := range ids {
+		tm.finishItem(id, status, "", errMsg, time.Now().Unix())
+	}
+}
+
+const taskColumns = `t.id, t.type, t.target, t.operator, t.status, COALESCE(t.error, ''), t.create_time, t.start_time, t.end_time, t.deadline,
+	(SELECT COUNT(*) FROM task_items i WHERE i.task_id = t.id),
+	(SELECT COUNT(*) FROM task_items i WHERE i.task_id = t.id AND i.status IN ('succeeded', 'failed', 'timeout')),
+	(SELECT COUNT(*) FROM task_items i WHERE i.task_id = t.id AND i.status IN ('failed', 'timeout'))`
+
+func scanTask(row interface{ Scan(...interface{}) error }) (*protocol.Task, error) {
+	var t protocol.Task
+	err := row.Scan(&t.ID, &t.Type, &t.Target, &t.Operator, &t.Status, &t.Error, &t.CreateTime, &t.StartTime, &t.EndTime, &t.Deadline,
+		&t.Total, &t.Done, &t.Failed)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// GetTask 获取任务及全部子任务
+func (tm *TaskManager) GetTask(id string) (*protocol.Task, bool) {
+	task, err := scanTask(tm.db.QueryRow(`SELECT `+taskColumns+` FROM tasks t WHERE t.id = ?`, id))
+	if err != nil {
+		return nil, false
+	}
+
+	rows, err := tm.db.Query(`SELECT id, task_id, node_id, node_ip, instance_id, status, progress, message, output, exit_code, error, start_time, end_time
+		FROM task_items WHERE task_id = ? ORDER BY id`, id)
+	if err != nil {
+		return task, true
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var it protocol.TaskItem
+		var exitCode sql.NullInt64
+		if err := rows.Scan(&it.ID, &it.TaskID, &it.NodeID, &it.NodeIP, &it.InstanceID, &it.Status, &it.Progress,
+			&it.Message, &it.Output, &exitCode, &it.Error, &it.StartTime, &it.EndTime); err == nil {
+			if exitCode.Valid {
+				c := int(exitCode.Int64)
+				it.ExitCode = &c
+			}
+			task.Items = append(task.Items, it)
+		}
+	}
+	return task, true
+}
+
+// ListTasks 分页查询任务 (不含子任务)，status/typ 为空表示不过滤
+func (tm *TaskManager) ListTasks(page, pageSize int, status, typ string) (*protocol.TaskQueryResp, error) {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize <= 0 {
+		pageSize = 20
+	}
+	resp := &protocol.TaskQueryResp{List: []*protocol.Task{}}
+
+	where := ` WHERE 1 = 1`
+	var args []interface{}
+	if status != "" {
+		where += ` AND t.status = ?`
+		args = append(args, status)
+	}
+	if typ != "" {
+		where += ` AND t.type = ?`
+		args = append(args, typ)
+	}
+	if err := tm.db.QueryRow(`SELECT COUNT(*) FROM tasks t`+where, args...).Scan(&resp.Total); err != nil {
+		return nil, err
+	}
+
+	args = append(args, pageSize, (page-1)*pageSize)
+	rows, err := tm.db.Query(`SELECT `+taskColumns+` FROM tasks t`+where+` ORDER BY t.create_time DESC, t.id DESC LIMIT ? OFFSET ?`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if t, err := scanTask(rows); err == nil {
+			resp.List = append(resp.List, t)
+		}
+	}
+	return resp, nil
+}
+
+// SweepTimeouts 将超过截止时间仍未结束的子任务标记为 timeout，返回处理的数量
+// 关联的实例仍处于 deploying 时置为 error，避免永远停留在部署中
+func (tm *TaskManager) SweepTimeouts(now time.Time) int {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	rows, err := tm.db.Query(`SELECT i.id, i.instance_id FROM task_items i JOIN tasks t ON t.id = i.task_id
+		WHERE i.status IN (?, ?) AND t.deadline < ?`, protocol.TaskQueued, protocol.TaskRunning, now.Unix())
+	if err != nil {
+		return 0
+	}
+	type stale struct {
+		id         int64
+		instanceID string
+	}
+	var items []stale
+	for rows.Next() {
+		var s stale
+		if rows.Scan(&s.id, &s.instanceID) == nil {
+			items = append(items, s)
+		}
+	}
+	rows.Close()
+
+	for _, s := range items {
+		tm.finishItem(s.id, protocol.TaskTimeout, "", "执行超时", now.Unix())
+		if s.instanceID == "" {
+			continue
+		}
+		if inst, ok := tm.instMgr.GetInstance(s.instanceID); ok && inst.Status == "deploying" {
+			tm.instMgr.UpdateInstanceStatus(s.instanceID, "error", 0)
+		}
+	}
+	if len(items) > 0 {
+		log.Printf("[Task] %d task items timed out", len(items))
+	}
+	return len(items)
+}
+
+// StartSweeper 定期检查超时任务，有变化时回调 (用于广播实例状态)
+func (tm *TaskManager) StartSweeper(interval time.Duration, onChange func()) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if tm.SweepTimeouts(time.Now()) > 0 && onChange != nil {
+			onChange()
+		}
+	}
+}
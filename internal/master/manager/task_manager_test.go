@@ -0,0 +1,117 @@
+package manager_test
+
+import (
+	"testing"
+	"time"
+
+	"ops-system/internal/master/manager"
+	"ops-system/pkg/protocol"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTaskLifecycle(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	for _, s := range []string{
+		`CREATE TABLE tasks (id TEXT PRIMARY KEY, type TEXT, target TEXT, operator TEXT, status TEXT, error TEXT DEFAULT '', create_time INTEGER, start_time INTEGER DEFAULT 0, end_time INTEGER DEFAULT 0, deadline INTEGER DEFAULT 0);`,
+		`CREATE TABLE task_items (id INTEGER PRIMARY KEY AUTOINCREMENT, task_id TEXT, node_id TEXT DEFAULT '', node_ip TEXT DEFAULT '', instance_id TEXT DEFAULT '', status TEXT, progress INTEGER DEFAULT 0, message TEXT DEFAULT '', output TEXT DEFAULT '', exit_code INTEGER, error TEXT DEFAULT '', start_time INTEGER DEFAULT 0, end_time INTEGER DEFAULT 0);`,
+	} {
+		_, err := db.Exec(s)
+		require.NoError(t, err)
+	}
+
+	instMgr := manager.NewInstanceManager(db, nil)
+	tm := manager.NewTaskManager(db, instMgr)
+
+	// 1. 两个节点的部署任务: 一个成功、一个失败 -> failed
+	task, err := tm.CreateTask("deploy", "web@1.0", "10.0.0.9", []protocol.TaskItem{
+		{NodeID: "n1", NodeIP: "10.0.0.1"}, {NodeID: "n2", NodeIP: "10.0.0.2"},
+	}, time.Minute)
+	require.NoError(t, err)
+	require.Len(t, task.Items, 2)
+	assert.Equal(t, protocol.TaskQueued, task.Status)
+
+	tm.StartItem(task.Items[0].ID, "inst-1")
+	assert.NoError(t, tm.Report("n1", protocol.TaskReport{TaskID: task.ID, ItemID: task.Items[0].ID, Status: protocol.TaskRunning, Progress: 60, Message: "extracting"}))
+	got, ok := tm.GetTask(task.ID)
+	require.True(t, ok)
+	assert.Equal(t, protocol.TaskRunning, got.Status)
+	assert.Equal(t, "inst-1", got.Items[0].InstanceID)
+	assert.Equal(t, 60, got.Items[0].Progress)
+
+	// 上报必须与任务及目标节点匹配
+	assert.Error(t, tm.Report("n1", protocol.TaskReport{TaskID: "task-x", ItemID: task.Items[0].ID, Status: protocol.TaskSucceeded}))
+	// 其他节点不能上报该子任务
+	assert.Error(t, tm.Report("n2", protocol.TaskReport{TaskID: task.ID, ItemID: task.Items[0].ID, Status: protocol.TaskSucceeded}))
+
+	assert.NoError(t, tm.Report("n1", protocol.TaskReport{TaskID: task.ID, ItemID: task.Items[0].ID, Status: protocol.TaskSucceeded}))
+	tm.FinishItem(task.Items[1].ID, protocol.TaskFailed, "", "节点不在线")
+	// 已结束的子任务不再改变
+	tm.FinishItem(task.Items[1].ID, protocol.TaskSucceeded, "", "")
+
+	got, _ = tm.GetTask(task.ID)
+	assert.Equal(t, protocol.TaskFailed, got.Status)
+	assert.Equal(t, 2, got.Done)
+	assert.Equal(t, 1, got.Failed)
+	assert.Equal(t, 100, got.Items[0].Progress)
+	assert.Equal(t, "节点不在线", got.Items[1].Error)
+	assert.NotZero(t, got.EndTime)
+
+	// 2. 卡在部署中的任务超时，实例置为 error
+	instMgr.RegisterInstance(&protocol.InstanceInfo{ID: "inst-2", NodeID: "n1", NodeIP: "10.0.0.1", ServiceName: "web", Status: "deploying"})
+	stuck, err := tm.CreateTask("deploy", "web@1.0", "10.0.0.9", []protocol.TaskItem{{NodeID: "n1", NodeIP: "10.0.0.1"}}, time.Minute)
+	require.NoError(t, err)
+	tm.StartItem(stuck.Items[0].ID, "inst-2")
+
+	assert.Equal(t, 0, tm.SweepTimeouts(time.Now()))
+	assert.Equal(t, 1, tm.SweepTimeouts(time.Now().Add(2*time.Minute)))
+	got, _ = tm.GetTask(stuck.ID)
+	assert.Equal(t, protocol.TaskTimeout, got.Status)
+	assert.Equal(t, protocol.TaskTimeout, got.Items[0].Status)
+	inst, ok := instMgr.GetInstance("inst-2")
+	require.True(t, ok)
+	assert.Equal(t, "error", inst.Status)
+
+	// 3. 旧版 Worker 不上报任务: 实例离开 deploying 即完成部署子任务
+	legacy, err := tm.CreateTask("deploy", "web@1.0", "10.0.0.9", []protocol.TaskItem{{NodeID: "n1"}}, time.Minute)
+	require.NoError(t, err)
+	tm.StartItem(legacy.Items[0].ID, "inst-3")
+	tm.CompleteInstanceItems("inst-3", "deploying")
+	got, _ = tm.GetTask(legacy.ID)
+	assert.Equal(t, protocol.TaskRunning, got.Status)
+	tm.CompleteInstanceItems("inst-3", "stopped")
+	got, _ = tm.GetTask(legacy.ID)
+	assert.Equal(t, protocol.TaskSucceeded, got.Status)
+
+	// 4. 批量命令: 按退出码判定成败，未执行完成的没有退出码
+	run, err := tm.CreateTask("batch_exec", "uptime", "10.0.0.9", []protocol.TaskItem{{NodeID: "n1"}, {NodeID: "n2"}, {NodeID: "n3"}}, time.Minute)
+	require.NoError(t, err)
+	zero, one := 0, 1
+	tm.FinishExecItem(run.Items[0].ID, &zero, "ok\n", "")
+	tm.FinishExecItem(run.Items[1].ID, &one, "", "")
+	tm.FinishExecItem(run.Items[2].ID, nil, "", "节点不在线")
+	got, _ = tm.GetTask(run.ID)
+	assert.Equal(t, protocol.TaskFailed, got.Status)
+	assert.Equal(t, 2, got.Failed)
+	if assert.NotNil(t, got.Items[0].ExitCode) && assert.NotNil(t, got.Items[1].ExitCode) {
+		assert.Equal(t, 0, *got.Items[0].ExitCode)
+		assert.Equal(t, 1, *got.Items[1].ExitCode)
+	}
+	assert.Equal(t, protocol.TaskSucceeded, got.Items[0].Status)
+	assert.Equal(t, protocol.TaskFailed, got.Items[1].Status)
+	assert.Nil(t, got.Items[2].ExitCode)
+
+	// 5. 列表按状态/类型过滤
+	list, err := tm.ListTasks(1, 10, "", "deploy")
+	require.NoError(t, err)
+	assert.EqualValues(t, 3, list.Total)
+	list, err = tm.ListTasks(1, 10, protocol.TaskTimeout, "")
+	require.NoError(t, err)
+	if assert.Len(t, list.List, 1) {
+		assert.Equal(t, stuck.ID, list.List[0].ID)
+		assert.Empty(t, list.List[0].Items)
+	}
+}
@@ -0,0 +1,193 @@
+package manager
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"ops-system/pkg/protocol"
+)
+
+// TerminalManager Web 终端会话记录与录像
+// 录像为 asciicast v2 (https://docs.asciinema.org/manual/asciicast/v2/)：首行为头部，之后每行一个 [秒, 类型, 数据] 事件，
+// 类型 o 为输出、i 为输入、r 为窗口大小变化，可直接用 asciinema-player 回放
+type TerminalManager struct {
+	db          *sql.DB
+	recordDir   string
+	idleTimeout time.Duration
+}
+
+func NewTerminalManager(db *sql.DB, recordDir string, idleTimeout time.Duration) *TerminalManager {
+	if err := os.MkdirAll(recordDir, 0700); err != nil {
+		log.Printf("[Terminal] Create record dir failed: %v", err)
+	}
+	// 上次运行中断的会话不会再结束，直接补记
+	db.Exec(`UPDATE terminal_sessions SET end_time = start_time, reason = ? WHERE end_time = 0`, "Master 重启")
+	return &TerminalManager{db: db, recordDir: recordDir, idleTimeout: idleTimeout}
+}
+
+// IdleTimeout 无输入自动断开的时长 (0 表示使用 Worker 默认值)
+func (tm *TerminalManager) IdleTimeout() time.Duration {
+	return tm.idleTimeout
+}
+
+// RecordingPath 会话录像文件路径
+func (tm *TerminalManager) RecordingPath(id string) string {
+	return filepath.Join(tm.recordDir, id+".cast")
+}
+
+// StartSession 登记会话并开始录像 (填充 ID 与开始时间)
+func (tm *TerminalManager) StartSession(s *protocol.TerminalSession, cols, rows int) (*TerminalRecorder, error) {
+	now := time.Now()
+	s.ID = fmt.Sprintf("term-%d", now.UnixNano())
+	s.StartTime = now.Unix()
+
+	f, err := os.OpenFile(tm.RecordingPath(s.ID), os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0600)
+	if err != nil {
+		return nil, err
+	}
+	_, err = tm.db.Exec(`INSERT INTO terminal_sessions (id, operator, client_ip, node_id, node_ip, instance_id, start_time) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		s.ID, s.Operator, s.ClientIP, s.NodeID, s.NodeIP, s.InstanceID, s.StartTime)
+	if err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, err
+	}
+
+	rec := &TerminalRecorder{tm: tm, id: s.ID, file: f, start: now}
+	title := s.NodeIP
+	if s.InstanceID != "" {
+		title += " " + s.InstanceID
+	}
+	rec.writeLine(map[string]interface{}{
+		"version":   2,
+		"width":     max(cols, 1),
+		"height":    max(rows, 1),
+		"timestamp": now.Unix(),
+		"title":     title,
+		"env":       map[string]string{"TERM": "xterm-256color"},
+	})
+	return rec, nil
+}
+
+// GetSession 获取会话
+func (tm *TerminalManager) GetSession(id string) (*protocol.TerminalSession, bool) {
+	s, err := scanTerminalSession(tm.db.QueryRow(`SELECT `+terminalColumns+` FROM terminal_sessions WHERE id = ?`, id))
+	if err != nil {
+		return nil, false
+	}
+	return s, true
+}
+
+// ListSessions 分页查询会话 (按开始时间倒序)
+func (tm *TerminalManager) ListSessions(page, pageSize int) (*protocol.TerminalQueryResp, error) {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize <= 0 {
+		pageSize = 20
+	}
+	resp := &protocol.TerminalQueryResp{List: []*protocol.TerminalSession{}}
+	if err := tm.db.QueryRow(`SELECT COUNT(*) FROM terminal_sessions`).Scan(&resp.Total); err != nil {
+		return nil, err
+	}
+	rows, err := tm.db.Query(`SELECT `+terminalColumns+` FROM terminal_sessions ORDER BY start_time DESC, id DESC LIMIT ? OFFSET ?`,
+		pageSize, (page-1)*pageSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if s, err := scanTerminalSession(rows); err == nil {
+			resp.List = append(resp.List, s)
+		}
+	}
+	return resp, nil
+}
+
+const terminalColumns = `id, operator, client_ip, node_id, node_ip, instance_id, start_time, end_time, size, reason`
+
+func scanTerminalSession(row interface{ Scan(...interface{}) error }) (*protocol.TerminalSession, error) {
+	var s protocol.TerminalSession
+	err := row.Scan(&s.ID, &s.Operator, &s.ClientIP, &s.NodeID, &s.NodeIP, &s.InstanceID, &s.StartTime, &s.EndTime, &s.Size, &s.Reason)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// TerminalRecorder 单个会话的录像写入 (并发安全)
+type TerminalRecorder struct {
+	tm    *TerminalManager
+	id    string
+	mu    sync.Mutex
+	file  *os.File // 不做缓冲，Master 异常退出时录像也完整到最后一个事件
+	start time.Time
+	size  int64
+	// 输出中被截断在帧尾的不完整 UTF-8 字符，并入下一帧 (JSON 字符串只能承载完整字符)
+	pending []byte
+}
+
+func (r *TerminalRecorder) writeLine(v interface{}) {
+	b, _ := json.Marshal(v)
+	b = append(b, '\n')
+	n, _ := r.file.Write(b)
+	r.size += int64(n)
+}
+
+func (r *TerminalRecorder) event(typ, data string) {
+	r.writeLine([]interface{}{float64(time.Since(r.start).Microseconds()) / 1e6, typ, data})
+}
+
+// Output 记录终端输出
+func (r *TerminalRecorder) Output(p []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	buf := append(r.pending, p...)
+	cut := len(buf)
+	for i := 1; i < utf8.UTFMax && i <= len(buf); i++ {
+		if utf8.RuneStart(buf[len(buf)-i]) {
+			if !utf8.FullRune(buf[len(buf)-i:]) {
+				cut = len(buf) - i
+			}
+			break
+		}
+	}
+	r.pending = append([]byte(nil), buf[cut:]...)
+	if cut > 0 {
+		r.event("o", string(buf[:cut]))
+	}
+}
+
+// Input 记录用户输入
+func (r *TerminalRecorder) Input(data string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.event("i", data)
+}
+
+// Resize 记录窗口大小变化
+func (r *TerminalRecorder) Resize(cols, rows uint16) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.event("r", fmt.Sprintf("%dx%d", cols, rows))
+}
+
+// Close 结束录像并记录结束原因
+func (r *TerminalRecorder) Close(reason string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.pending) > 0 {
+		r.event("o", string(r.pending))
+		r.pending = nil
+	}
+	r.file.Close()
+	r.tm.db.Exec(`UPDATE terminal_sessions SET end_time = ?, size = ?, reason = ? WHERE id = ?`,
+		time.Now().Unix(), r.size, reason, r.id)
+}
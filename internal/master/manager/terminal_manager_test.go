@@ -0,0 +1,91 @@
+package manager_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+
+	"ops-system/internal/master/manager"
+	"ops-system/pkg/config"
+	"ops-system/pkg/protocol"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserRoles(t *testing.T) {
+	users := manager.NewUserManager([]config.UserConfig{
+		{Name: "alice", Token: "t-admin", Role: manager.RoleAdmin},
+		{Name: "bob", Token: "t-ops", Role: manager.RoleOperator},
+		{Name: "eve", Token: "t-bad", Role: "root"}, // 未知角色被忽略
+	})
+
+	u, err := users.Authorize("t-admin", manager.RoleAdmin)
+	require.NoError(t, err)
+	assert.Equal(t, "alice", u.Name)
+
+	_, err = users.Authorize("t-ops", manager.RoleAdmin)
+	assert.ErrorIs(t, err, manager.ErrForbidden)
+	_, err = users.Authorize("t-ops", manager.RoleViewer)
+	assert.NoError(t, err)
+	_, err = users.Authorize("t-bad", manager.RoleViewer)
+	assert.ErrorIs(t, err, manager.ErrUnauthorized)
+
+	// 未配置用户时需要认证的功能整体关闭
+	_, err = manager.NewUserManager(nil).Authorize("", manager.RoleViewer)
+	assert.ErrorIs(t, err, manager.ErrAuthDisabled)
+}
+
+func TestTerminalRecording(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	_, err := db.Exec(`CREATE TABLE terminal_sessions (id TEXT PRIMARY KEY, operator TEXT, client_ip TEXT DEFAULT '', node_id TEXT DEFAULT '', node_ip TEXT DEFAULT '', instance_id TEXT DEFAULT '', start_time INTEGER, end_time INTEGER DEFAULT 0, size INTEGER DEFAULT 0, reason TEXT DEFAULT '');`)
+	require.NoError(t, err)
+
+	tm := manager.NewTerminalManager(db, t.TempDir(), time.Minute)
+	session := &protocol.TerminalSession{Operator: "alice", NodeID: "n1", NodeIP: "10.0.0.1"}
+	rec, err := tm.StartSession(session, 80, 24)
+	require.NoError(t, err)
+
+	rec.Input("ls\r")
+	// "你" 被拆在两个输出帧中，录像中应为完整字符
+	ni := []byte("你")
+	rec.Output(append([]byte("a"), ni[:2]...))
+	rec.Output(append(ni[2:], 'b'))
+	rec.Resize(120, 40)
+	rec.Close("空闲超时")
+
+	got, ok := tm.GetSession(session.ID)
+	require.True(t, ok)
+	assert.Equal(t, "空闲超时", got.Reason)
+	assert.NotZero(t, got.EndTime)
+	assert.NotZero(t, got.Size)
+
+	f, err := os.Open(tm.RecordingPath(session.ID))
+	require.NoError(t, err)
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	require.True(t, sc.Scan())
+	var header map[string]interface{}
+	require.NoError(t, json.Unmarshal(sc.Bytes(), &header))
+	assert.EqualValues(t, 2, header["version"])
+	assert.EqualValues(t, 80, header["width"])
+
+	var events [][]interface{}
+	for sc.Scan() {
+		var ev []interface{}
+		require.NoError(t, json.Unmarshal(sc.Bytes(), &ev))
+		events = append(events, ev)
+	}
+	require.Len(t, events, 4)
+	assert.Equal(t, []interface{}{"i", "ls\r"}, events[0][1:])
+	assert.Equal(t, []interface{}{"o", "a"}, events[1][1:])
+	assert.Equal(t, []interface{}{"o", "你b"}, events[2][1:])
+	assert.Equal(t, []interface{}{"r", "120x40"}, events[3][1:])
+
+	list, err := tm.ListSessions(1, 10)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, list.Total)
+}
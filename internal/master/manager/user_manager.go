@@ -0,0 +1,79 @@
+package manager
+
+import (
+	"crypto/subtle"
+	"errors"
+	"log"
+
+	"ops-system/pkg/config"
+)
+
+// 角色 (权限依次增大)
+const (
+	RoleViewer   = "viewer"
+	RoleOperator = "operator"
+	RoleAdmin    = "admin"
+)
+
+var roleRank = map[string]int{RoleViewer: 1, RoleOperator: 2, RoleAdmin: 3}
+
+var (
+	ErrAuthDisabled = errors.New("未配置用户，该功能不可用")
+	ErrUnauthorized = errors.New("令牌无效")
+	ErrForbidden    = errors.New("权限不足")
+)
+
+// User 已认证的用户
+type User struct {
+	Name string `json:"name"`
+	Role string `json:"role"`
+}
+
+// UserManager 按配置文件中的令牌识别用户
+type UserManager struct {
+	users []config.UserConfig
+}
+
+// NewUserManager 忽略缺少名称/令牌或角色未知的用户
+func NewUserManager(users []config.UserConfig) *UserManager {
+	m := &UserManager{}
+	for _, u := range users {
+		if u.Name == "" || u.Token == "" || roleRank[u.Role] == 0 {
+			log.Printf("[Auth] Ignore invalid user %q (role %q)", u.Name, u.Role)
+			continue
+		}
+		m.users = append(m.users, u)
+	}
+	return m
+}
+
+// Enabled 是否配置了用户 (未配置时不做认证)
+func (m *UserManager) Enabled() bool {
+	return len(m.users) > 0
+}
+
+// Authenticate 校验令牌 (逐个比较，耗时与匹配位置无关)
+func (m *UserManager) Authenticate(token string) (*User, bool) {
+	var found *User
+	for _, u := range m.users {
+		if subtle.ConstantTimeCompare([]byte(u.Token), []byte(token)) == 1 && found == nil {
+			found = &User{Name: u.Name, Role: u.Role}
+		}
+	}
+	return found, found != nil
+}
+
+// Authorize 校验令牌并要求至少具有 role 角色
+func (m *UserManager) Authorize(token, role string) (*User, error) {
+	if len(m.users) == 0 {
+		return nil, ErrAuthDisabled
+	}
+	u, ok := m.Authenticate(token)
+	if !ok {
+		return nil, ErrUnauthorized
+	}
+	if roleRank[u.Role] < roleRank[role] {
+		return u, ErrForbidden
+	}
+	return u, nil
+}
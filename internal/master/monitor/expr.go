@@ -0,0 +1,532 @@
+package monitor
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Source 表达式求值的数据源 (MemoryTSDB 即为一种实现)
+type Source interface {
+	QueryRange(metric, id string, start, end int64) []Point
+}
+
+// DefaultLookback 瞬时查询 (不带区间的指标名) 的最长回看时间，超过视为无数据
+// (Worker 每隔数秒上报一次，1 分钟内无新数据即可认为已停止上报)
+const DefaultLookback = time.Minute
+
+// Result 表达式在某个目标上的求值结果
+type Result struct {
+	Value     float64 // 当前值 (比较表达式取左侧的值，用于展示)
+	Triggered bool    // 条件是否成立
+	Valid     bool    // 是否有数据 (无数据时不触发)
+}
+
+// Expr 已解析的告警表达式
+// 语法为 PromQL 的一个子集，作用于单个目标 (节点 IP 或实例 ID) 的时间序列：
+//
+//	instance_cpu_usage > 80
+//	avg_over_time(instance_cpu_usage[5m]) > 80
+//	increase(instance_restarts[10m]) > 3
+//	absent_over_time(node_cpu_usage[2m])
+//	node_mem_usage > 90 and node_cpu_usage > 90
+//
+// 区间函数: avg/min/max/sum/count/last_over_time, rate, increase, delta, absent_over_time
+// 瞬时函数: absent, abs
+// 运算符: + - * /, > < >= <= == !=, and, or
+// rate 与 Prometheus 一致为每秒速率，统计次数请使用 increase
+type Expr struct {
+	src  string
+	root exprNode
+}
+
+// ParseExpr 解析表达式
+func ParseExpr(s string) (*Expr, error) {
+	p := &exprParser{src: s}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if p.pos < len(p.src) {
+		return nil, p.errorf("unexpected %q", p.src[p.pos:])
+	}
+	return &Expr{src: s, root: root}, nil
+}
+
+func (e *Expr) String() string {
+	return e.src
+}
+
+// Eval 针对单个目标求值
+func (e *Expr) Eval(src Source, id string, now int64) Result {
+	ctx := &evalCtx{src: src, id: id, now: now}
+	v, ok := e.root.eval(ctx)
+	res := Result{Value: v, Valid: ok, Triggered: ok && v != 0}
+
+	// 条件表达式的结果是 0/1，展示值取最左侧比较的左操作数
+	if cmp := leftmostCompare(e.root); cmp != nil {
+		if lv, lok := cmp.left.eval(ctx); lok {
+			res.Value = lv
+		}
+	}
+	return res
+}
+
+// --- 语法树 ---
+
+type evalCtx struct {
+	src Source
+	id  string
+	now int64
+}
+
+func (c *evalCtx) query(metric string, rng int64) []Point {
+	if c.src == nil {
+		return nil
+	}
+	return c.src.QueryRange(metric, c.id, c.now-rng, c.now)
+}
+
+type exprNode interface {
+	eval(ctx *evalCtx) (float64, bool)
+}
+
+type numberNode struct{ v float64 }
+
+func (n *numberNode) eval(*evalCtx) (float64, bool) { return n.v, true }
+
+// selectorNode 指标选择器，rng 为区间秒数 (0 表示瞬时)
+type selectorNode struct {
+	metric string
+	rng    int64
+}
+
+func (n *selectorNode) eval(ctx *evalCtx) (float64, bool) {
+	points := ctx.query(n.metric, int64(DefaultLookback.Seconds()))
+	if len(points) == 0 {
+		return 0, false
+	}
+	return points[len(points)-1].Value, true
+}
+
+type funcNode struct {
+	name string
+	arg  exprNode
+	sel  *selectorNode // 区间函数 / absent 的参数
+}
+
+func (n *funcNode) eval(ctx *evalCtx) (float64, bool) {
+	switch n.name {
+	case "abs":
+		v, ok := n.arg.eval(ctx)
+		return math.Abs(v), ok
+	case "absent":
+		_, ok := n.sel.eval(ctx)
+		return boolValue(!ok), true
+	}
+
+	points := ctx.query(n.sel.metric, n.sel.rng)
+	switch n.name {
+	case "absent_over_time":
+		return boolValue(len(points) == 0), true
+	case "count_over_time":
+		return float64(len(points)), true
+	}
+	if len(points) == 0 {
+		return 0, false
+	}
+
+	switch n.name {
+	case "avg_over_time", "sum_over_time":
+		sum := 0.0
+		for _, p := range points {
+			sum += p.Value
+		}
+		if n.name == "avg_over_time" {
+			return sum / float64(len(points)), true
+		}
+		return sum, true
+	case "min_over_time", "max_over_time":
+		v := points[0].Value
+		for _, p := range points[1:] {
+			if (n.name == "min_over_time") == (p.Value < v) {
+				v = p.Value
+			}
+		}
+		return v, true
+	case "last_over_time":
+		return points[len(points)-1].Value, true
+	case "delta":
+		return points[len(points)-1].Value - points[0].Value, true
+	case "increase", "rate":
+		if len(points) < 2 {
+			return 0, false
+		}
+		inc := 0.0
+		for i := 1; i < len(points); i++ {
+			d := points[i].Value - points[i-1].Value
+			if d < 0 { // 计数器重置
+				d = points[i].Value
+			}
+			inc += d
+		}
+		if n.name == "increase" {
+			return inc, true
+		}
+		span := points[len(points)-1].Time - points[0].Time
+		if span <= 0 {
+			return 0, false
+		}
+		return inc / float64(span), true
+	}
+	return 0, false
+}
+
+type binaryNode struct {
+	op          string
+	left, right exprNode
+}
+
+func (n *binaryNode) eval(ctx *evalCtx) (float64, bool) {
+	// 逻辑运算：无数据的一侧视为不成立
+	if n.op == "and" || n.op == "or" {
+		l, lok := n.left.eval(ctx)
+		r, rok := n.right.eval(ctx)
+		lt, rt := lok && l != 0, rok && r != 0
+		if n.op == "and" {
+			return boolValue(lt && rt), true
+		}
+		return boolValue(lt || rt), true
+	}
+
+	l, lok := n.left.eval(ctx)
+	r, rok := n.right.eval(ctx)
+	if !lok || !rok {
+		return 0, false
+	}
+	switch n.op {
+	case "+":
+		return l + r, true
+	case "-":
+		return l - r, true
+	case "*":
+		return l * r, true
+	case "/":
+		if r == 0 {
+			return 0, false
+		}
+		return l / r, true
+	case ">":
+		return boolValue(l > r), true
+	case "<":
+		return boolValue(l < r), true
+	case ">=":
+		return boolValue(l >= r), true
+	case "<=":
+		return boolValue(l <= r), true
+	case "==":
+		return boolValue(l == r), true
+	case "!=":
+		return boolValue(l != r), true
+	}
+	return 0, false
+}
+
+func isCompareOp(op string) bool {
+	switch op {
+	case ">", "<", ">=", "<=", "==", "!=":
+		return true
+	}
+	return false
+}
+
+func leftmostCompare(n exprNode) *binaryNode {
+	b, ok := n.(*binaryNode)
+	if !ok {
+		return nil
+	}
+	if isCompareOp(b.op) {
+		return b
+	}
+	if b.op == "and" || b.op == "or" {
+		return leftmostCompare(b.left)
+	}
+	return nil
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// --- 解析器 (递归下降) ---
+
+// 区间函数：参数必须是带区间的指标选择器
+var rangeFuncs = map[string]bool{
+	"avg_over_time": true, "min_over_time": true, "max_over_time": true,
+	"sum_over_time": true, "count_over_time": true, "last_over_time": true,
+	"absent_over_time": true, "rate": true, "increase": true, "delta": true,
+}
+
+type exprParser struct {
+	src string
+	pos int
+}
+
+func (p *exprParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("expr %q at %d: %s", p.src, p.pos, fmt.Sprintf(format, args...))
+}
+
+func (p *exprParser) skipSpace() {
+	for p.pos < len(p.src) && (p.src[p.pos] == ' ' || p.src[p.pos] == '\t' || p.src[p.pos] == '\n') {
+		p.pos++
+	}
+}
+
+// accept 若接下来是 tok 则消费并返回 true
+func (p *exprParser) accept(tok string) bool {
+	p.skipSpace()
+	if !strings.HasPrefix(p.src[p.pos:], tok) {
+		return false
+	}
+	// and/or 需要完整单词
+	if isIdentChar(tok[0]) {
+		end := p.pos + len(tok)
+		if end < len(p.src) && isIdentChar(p.src[end]) {
+			return false
+		}
+	}
+	p.pos += len(tok)
+	return true
+}
+
+func (p *exprParser) parseOr() (exprNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("or") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{op: "or", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseAnd() (exprNode, error) {
+	left, err := p.parseCompare()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("and") {
+		right, err := p.parseCompare()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{op: "and", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseCompare() (exprNode, error) {
+	left, err := p.parseAdd()
+	if err != nil {
+		return nil, err
+	}
+	// 注意先匹配双字符运算符
+	for _, op := range []string{">=", "<=", "==", "!=", ">", "<"} {
+		if p.accept(op) {
+			right, err := p.parseAdd()
+			if err != nil {
+				return nil, err
+			}
+			return &binaryNode{op: op, left: left, right: right}, nil
+		}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseAdd() (exprNode, error) {
+	left, err := p.parseMul()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		var op string
+		switch {
+		case p.accept("+"):
+			op = "+"
+		case p.accept("-"):
+			op = "-"
+		default:
+			return left, nil
+		}
+		right, err := p.parseMul()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{op: op, left: left, right: right}
+	}
+}
+
+func (p *exprParser) parseMul() (exprNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		var op string
+		switch {
+		case p.accept("*"):
+			op = "*"
+		case p.accept("/"):
+			op = "/"
+		default:
+			return left, nil
+		}
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{op: op, left: left, right: right}
+	}
+}
+
+func (p *exprParser) parseUnary() (exprNode, error) {
+	if p.accept("-") {
+		n, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &binaryNode{op: "-", left: &numberNode{0}, right: n}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *exprParser) parsePrimary() (exprNode, error) {
+	p.skipSpace()
+	if p.pos >= len(p.src) {
+		return nil, p.errorf("unexpected end of expression")
+	}
+
+	c := p.src[p.pos]
+	switch {
+	case c == '(':
+		p.pos++
+		n, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.accept(")") {
+			return nil, p.errorf("missing )")
+		}
+		return n, nil
+	case c >= '0' && c <= '9' || c == '.':
+		start := p.pos
+		for p.pos < len(p.src) && (p.src[p.pos] >= '0' && p.src[p.pos] <= '9' || p.src[p.pos] == '.') {
+			p.pos++
+		}
+		v, err := strconv.ParseFloat(p.src[start:p.pos], 64)
+		if err != nil {
+			return nil, p.errorf("invalid number %q", p.src[start:p.pos])
+		}
+		return &numberNode{v}, nil
+	case isIdentStart(c):
+		name := p.ident()
+		if p.accept("(") {
+			return p.parseCall(name)
+		}
+		return p.parseSelector(name)
+	}
+	return nil, p.errorf("unexpected %q", string(c))
+}
+
+func (p *exprParser) parseCall(name string) (exprNode, error) {
+	fn := &funcNode{name: name}
+	switch {
+	case rangeFuncs[name] || name == "absent":
+		p.skipSpace()
+		if p.pos >= len(p.src) || !isIdentStart(p.src[p.pos]) {
+			return nil, p.errorf("%s() expects a metric", name)
+		}
+		sel, err := p.parseSelector(p.ident())
+		if err != nil {
+			return nil, err
+		}
+		fn.sel = sel
+		if rangeFuncs[name] && sel.rng == 0 {
+			return nil, p.errorf("%s() expects a range, e.g. %s[5m]", name, sel.metric)
+		}
+		if !rangeFuncs[name] && sel.rng != 0 {
+			return nil, p.errorf("%s() does not accept a range", name)
+		}
+	case name == "abs":
+		arg, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		fn.arg = arg
+	default:
+		return nil, p.errorf("unknown function %s()", name)
+	}
+	if !p.accept(")") {
+		return nil, p.errorf("missing ) after %s(", name)
+	}
+	return fn, nil
+}
+
+func (p *exprParser) parseSelector(metric string) (*selectorNode, error) {
+	if metric == "and" || metric == "or" {
+		return nil, p.errorf("unexpected keyword %s", metric)
+	}
+	sel := &selectorNode{metric: metric}
+	if !p.accept("[") {
+		return sel, nil
+	}
+	end := strings.IndexByte(p.src[p.pos:], ']')
+	if end < 0 {
+		return nil, p.errorf("missing ]")
+	}
+	d, err := parseDuration(strings.TrimSpace(p.src[p.pos : p.pos+end]))
+	if err != nil || d <= 0 {
+		return nil, p.errorf("invalid range %q", p.src[p.pos:p.pos+end])
+	}
+	p.pos += end + 1
+	sel.rng = int64(d.Seconds())
+	return sel, nil
+}
+
+func (p *exprParser) ident() string {
+	start := p.pos
+	for p.pos < len(p.src) && isIdentChar(p.src[p.pos]) {
+		p.pos++
+	}
+	return p.src[start:p.pos]
+}
+
+// parseDuration 在 time.ParseDuration 的基础上支持天 (d)
+func parseDuration(s string) (time.Duration, error) {
+	if strings.HasSuffix(s, "d") {
+		n, err := strconv.Atoi(strings.TrimSuffix(s, "d"))
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(s)
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func isIdentChar(c byte) bool {
+	return isIdentStart(c) || c >= '0' && c <= '9' || c == ':'
+}
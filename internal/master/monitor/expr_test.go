@@ -0,0 +1,84 @@
+package monitor_test
+
+import (
+	"testing"
+
+	"ops-system/internal/master/monitor"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeSource 固定数据的数据源，key 为 metric
+type fakeSource map[string][]monitor.Point
+
+func (f fakeSource) QueryRange(metric, id string, start, end int64) []monitor.Point {
+	var res []monitor.Point
+	for _, p := range f[metric] {
+		if p.Time >= start && p.Time <= end {
+			res = append(res, p)
+		}
+	}
+	return res
+}
+
+func TestExprEval(t *testing.T) {
+	const now = 10000
+	src := fakeSource{
+		// 最近 5 分钟 CPU: 70, 80, 90
+		"cpu": {{Time: now - 240, Value: 70}, {Time: now - 120, Value: 80}, {Time: now, Value: 90}},
+		// 计数器 10 分钟内 1 -> 3 -> 重置为 1 -> 2，共增加 4
+		"restarts": {{Time: now - 500, Value: 1}, {Time: now - 300, Value: 3}, {Time: now - 200, Value: 1}, {Time: now - 100, Value: 2}},
+		// 心跳 3 分钟前就停了
+		"heartbeat": {{Time: now - 180, Value: 1}},
+	}
+
+	cases := []struct {
+		expr      string
+		value     float64
+		triggered bool
+		valid     bool
+	}{
+		{"cpu > 85", 90, true, true},
+		{"cpu == 90", 90, true, true},
+		{"avg_over_time(cpu[5m]) > 85", 80, false, true},
+		{"max_over_time(cpu[5m]) >= 90", 90, true, true},
+		{"min_over_time(cpu[3m]) < 85", 80, true, true},
+		{"count_over_time(cpu[5m])", 3, true, true},
+		{"increase(restarts[10m]) > 3", 4, true, true},
+		{"rate(restarts[10m]) * 60 > 0.5", 0.6, true, true},
+		{"absent_over_time(heartbeat[2m])", 1, true, true},
+		{"absent_over_time(heartbeat[5m])", 0, false, true},
+		{"absent(nothing)", 1, true, true},
+		{"nothing > 1", 0, false, false},
+		{"cpu > 85 and increase(restarts[10m]) > 10", 90, false, true},
+		{"cpu > 95 or absent(nothing)", 90, true, true},
+		{"(cpu - 10) / 2 > 30", 40, true, true},
+	}
+
+	for _, c := range cases {
+		expr, err := monitor.ParseExpr(c.expr)
+		if !assert.NoError(t, err, c.expr) {
+			continue
+		}
+		res := expr.Eval(src, "target", now)
+		assert.InDelta(t, c.value, res.Value, 0.0001, c.expr)
+		assert.Equal(t, c.triggered, res.Triggered, c.expr)
+		assert.Equal(t, c.valid, res.Valid, c.expr)
+	}
+}
+
+func TestExprParseErrors(t *testing.T) {
+	for _, s := range []string{
+		"",
+		"cpu >",
+		"avg_over_time(cpu)",
+		"absent(cpu[5m])",
+		"unknown_fn(cpu)",
+		"cpu[5x] > 1",
+		"(cpu > 1",
+		"cpu > 1 extra",
+	} {
+		_, err := monitor.ParseExpr(s)
+		assert.Error(t, err, s)
+	}
+}
@@ -2,6 +2,7 @@ package monitor
 
 import (
 	"fmt"
+	"sort"
 	"strconv"
 	"sync"
 	"time"
@@ -19,13 +20,13 @@ type MemoryTSDB struct {
 	series map[string][]Point
 	mu     sync.RWMutex
 
-	retention time.Duration // 数据保留时长 (10分钟)
+	retention time.Duration // 数据保留时长 (30分钟，覆盖告警表达式的最大常用窗口)
 }
 
 func NewMemoryTSDB() *MemoryTSDB {
 	return &MemoryTSDB{
 		series:    make(map[string][]Point),
-		retention: 10 * time.Minute,
+		retention: 30 * time.Minute,
 	}
 }
 
@@ -44,18 +45,11 @@ func (tsdb *MemoryTSDB) Write(ip, metric string, val float64) {
 	tsdb.series[key] = append(tsdb.series[key], Point{Time: now, Value: val})
 
 	// 2. 修剪旧数据 (简单的滑动窗口)
-	// 保留最近 10 分钟的数据。假设 3秒一个点，10分钟约 200个点。
-	// 为了性能，不每次都遍历，当长度超过 300 时清理一次
+	// 数据按时间递增追加，只需在最旧的点过期时二分找到第一个有效点
 	data := tsdb.series[key]
-	if len(data) > 300 {
-		cutoff := now - int64(tsdb.retention.Seconds())
-		validIdx := 0
-		for i, p := range data {
-			if p.Time >= cutoff {
-				validIdx = i
-				break
-			}
-		}
+	cutoff := now - int64(tsdb.retention.Seconds())
+	if data[0].Time < cutoff {
+		validIdx := sort.Search(len(data), func(i int) bool { return data[i].Time >= cutoff })
 		// 切片操作，丢弃前面的
 		tsdb.series[key] = data[validIdx:]
 	}
@@ -0,0 +1,315 @@
+package notify
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"ops-system/pkg/protocol"
+)
+
+// 渠道类型
+const (
+	TypeWebhook  = "webhook"
+	TypeEmail    = "email"
+	TypeDingTalk = "dingtalk"
+	TypeWeCom    = "wecom"
+	TypeFeishu   = "feishu"
+	TypeSlack    = "slack"
+)
+
+// Message 一次告警通知的内容 (同时作为 webhook 模板的渲染上下文)
+type Message struct {
+	Kind       string   `json:"kind"` // "firing", "resolved"
+	EventID    int64    `json:"event_id"`
+	RuleID     int64    `json:"rule_id"`
+	RuleName   string   `json:"rule_name"`
+	Severity   string   `json:"severity"` // "info", "warning", "critical"
+	TargetType string   `json:"target_type"`
+	TargetID   string   `json:"target_id"`
+	TargetName string   `json:"target_name"`
+	Value      float64  `json:"value"`
+	Message    string   `json:"message"`
+	Samples    []string `json:"samples"` // 日志告警的样例行
+	StartTime  int64    `json:"start_time"`
+	EndTime    int64    `json:"end_time"`
+}
+
+// Title 通知标题
+func (m Message) Title() string {
+	if m.Kind == "resolved" {
+		return fmt.Sprintf("[RESOLVED] %s", m.RuleName)
+	}
+	if m.Severity != "" {
+		return fmt.Sprintf("[FIRING][%s] %s", strings.ToUpper(m.Severity), m.RuleName)
+	}
+	return fmt.Sprintf("[FIRING] %s", m.RuleName)
+}
+
+// Text 纯文本正文
+func (m Message) Text() string {
+	var b strings.Builder
+	b.WriteString(m.Title() + "\n")
+	b.WriteString(fmt.Sprintf("Target: %s\n", m.TargetName))
+	b.WriteString(fmt.Sprintf("Detail: %s\n", m.Message))
+	for _, line := range m.Samples {
+		b.WriteString("  > " + line + "\n")
+	}
+	b.WriteString(fmt.Sprintf("Start:  %s\n", formatTime(m.StartTime)))
+	if m.Kind == "resolved" {
+		b.WriteString(fmt.Sprintf("End:    %s\n", formatTime(m.EndTime)))
+	}
+	return b.String()
+}
+
+// Markdown 机器人消息正文
+func (m Message) Markdown() string {
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("### %s\n\n", m.Title()))
+	b.WriteString(fmt.Sprintf("- **Target**: %s\n", m.TargetName))
+	b.WriteString(fmt.Sprintf("- **Detail**: %s\n", m.Message))
+	if len(m.Samples) > 0 {
+		b.WriteString("- **Samples**:\n\n```\n" + strings.Join(m.Samples, "\n") + "\n```\n")
+	}
+	b.WriteString(fmt.Sprintf("- **Start**: %s\n", formatTime(m.StartTime)))
+	if m.Kind == "resolved" {
+		b.WriteString(fmt.Sprintf("- **End**: %s\n", formatTime(m.EndTime)))
+	}
+	return b.String()
+}
+
+func formatTime(ts int64) string {
+	if ts == 0 {
+		return "-"
+	}
+	return time.Unix(ts, 0).Format("2006-01-02 15:04:05")
+}
+
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+// Send 按渠道类型发送通知
+func Send(ch *protocol.NotifyChannel, msg Message) error {
+	switch ch.Type {
+	case TypeWebhook:
+		return sendWebhook(ch.Config, msg)
+	case TypeEmail:
+		return sendEmail(ch.Config, msg)
+	case TypeDingTalk:
+		return sendDingTalk(ch.Config, msg)
+	case TypeWeCom:
+		return postJSON(ch.Config.URL, nil, map[string]interface{}{
+			"msgtype":  "markdown",
+			"markdown": map[string]string{"content": msg.Markdown()},
+		})
+	case TypeFeishu:
+		return sendFeishu(ch.Config, msg)
+	case TypeSlack:
+		return postJSON(ch.Config.URL, nil, map[string]string{"text": msg.Text()})
+	}
+	return fmt.Errorf("unsupported channel type: %s", ch.Type)
+}
+
+// sendWebhook 通用 Webhook，Body 支持 text/template 模板，未配置模板时发送 Message 的 JSON
+func sendWebhook(cfg protocol.NotifyChannelConfig, msg Message) error {
+	var body []byte
+	if cfg.BodyTemplate == "" {
+		body, _ = json.Marshal(msg)
+	} else {
+		tpl, err := template.New("body").Funcs(template.FuncMap{"json": toJSON}).Parse(cfg.BodyTemplate)
+		if err != nil {
+			return fmt.Errorf("parse body template failed: %v", err)
+		}
+		var buf bytes.Buffer
+		if err := tpl.Execute(&buf, msg); err != nil {
+			return fmt.Errorf("render body template failed: %v", err)
+		}
+		body = buf.Bytes()
+	}
+
+	method := cfg.Method
+	if method == "" {
+		method = http.MethodPost
+	}
+	return doRequest(method, cfg.URL, cfg.Headers, body)
+}
+
+// toJSON 模板函数：将值编码为 JSON 字符串 (用于安全地嵌入字符串字段)
+func toJSON(v interface{}) string {
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+// sendDingTalk 钉钉机器人，配置了 Secret 时按加签方式追加 timestamp/sign
+func sendDingTalk(cfg protocol.NotifyChannelConfig, msg Message) error {
+	target := cfg.URL
+	if cfg.Secret != "" {
+		ts := strconv.FormatInt(time.Now().UnixMilli(), 10)
+		mac := hmac.New(sha256.New, []byte(cfg.Secret))
+		mac.Write([]byte(ts + "\n" + cfg.Secret))
+		sign := url.QueryEscape(base64.StdEncoding.EncodeToString(mac.Sum(nil)))
+		sep := "?"
+		if strings.Contains(target, "?") {
+			sep = "&"
+		}
+		target = fmt.Sprintf("%s%stimestamp=%s&sign=%s", target, sep, ts, sign)
+	}
+	return postJSON(target, nil, map[string]interface{}{
+		"msgtype":  "markdown",
+		"markdown": map[string]string{"title": msg.Title(), "text": msg.Markdown()},
+	})
+}
+
+// sendFeishu 飞书机器人，配置了 Secret 时在 Body 中携带签名
+func sendFeishu(cfg protocol.NotifyChannelConfig, msg Message) error {
+	payload := map[string]interface{}{
+		"msg_type": "text",
+		"content":  map[string]string{"text": msg.Text()},
+	}
+	if cfg.Secret != "" {
+		ts := strconv.FormatInt(time.Now().Unix(), 10)
+		mac := hmac.New(sha256.New, []byte(ts+"\n"+cfg.Secret))
+		payload["timestamp"] = ts
+		payload["sign"] = base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	}
+	return postJSON(cfg.URL, nil, payload)
+}
+
+// sendEmail SMTP 邮件，端口 465 使用隐式 TLS，其余端口在服务端支持时升级 STARTTLS
+func sendEmail(cfg protocol.NotifyChannelConfig, msg Message) error {
+	if cfg.SMTPHost == "" || len(cfg.To) == 0 {
+		return fmt.Errorf("smtp host and recipients are required")
+	}
+	port := cfg.SMTPPort
+	if port == 0 {
+		port = 25
+	}
+	addr := net.JoinHostPort(cfg.SMTPHost, strconv.Itoa(port))
+	from := cfg.From
+	if from == "" {
+		from = cfg.Username
+	}
+
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("From: %s\r\n", from))
+	b.WriteString(fmt.Sprintf("To: %s\r\n", strings.Join(cfg.To, ", ")))
+	b.WriteString(fmt.Sprintf("Subject: %s\r\n", msg.Title()))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Text(), "\n", "\r\n"))
+
+	var conn net.Conn
+	var err error
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	if port == 465 {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, &tls.Config{ServerName: cfg.SMTPHost})
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return err
+	}
+
+	c, err := smtp.NewClient(conn, cfg.SMTPHost)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if port != 465 {
+		if ok, _ := c.Extension("STARTTLS"); ok {
+			if err := c.StartTLS(&tls.Config{ServerName: cfg.SMTPHost}); err != nil {
+				return err
+			}
+		}
+	}
+	if cfg.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.SMTPHost)); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(from); err != nil {
+		return err
+	}
+	for _, to := range cfg.To {
+		if err := c.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	wc, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := wc.Write([]byte(b.String())); err != nil {
+		wc.Close()
+		return err
+	}
+	if err := wc.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+func postJSON(target string, headers map[string]string, payload interface{}) error {
+	body, _ := json.Marshal(payload)
+	return doRequest(http.MethodPost, target, headers, body)
+}
+
+func doRequest(method, target string, headers map[string]string, body []byte) error {
+	if target == "" {
+		return fmt.Errorf("webhook url is empty")
+	}
+	req, err := http.NewRequest(method, target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("http status %d: %s", resp.StatusCode, string(respBody))
+	}
+	return checkBotResponse(respBody)
+}
+
+// checkBotResponse 钉钉/企微/飞书在 HTTP 200 时仍可能通过 errcode/code 返回业务错误
+func checkBotResponse(body []byte) error {
+	var res struct {
+		ErrCode *int   `json:"errcode"`
+		ErrMsg  string `json:"errmsg"`
+		Code    *int   `json:"code"`
+		Msg     string `json:"msg"`
+	}
+	if json.Unmarshal(body, &res) != nil {
+		return nil
+	}
+	if res.ErrCode != nil && *res.ErrCode != 0 {
+		return fmt.Errorf("errcode %d: %s", *res.ErrCode, res.ErrMsg)
+	}
+	if res.Code != nil && *res.Code != 0 {
+		return fmt.Errorf("code %d: %s", *res.Code, res.Msg)
+	}
+	return nil
+}
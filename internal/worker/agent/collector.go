@@ -54,6 +54,7 @@ func GetNodeInfo() protocol.NodeInfo {
 	ip, mac := getNetworkInfo()
 	info.IP = ip
 	info.MacAddr = mac
+	info.IPs = getAllIPs()
 
 	return info
 }
@@ -168,6 +169,39 @@ func getNetworkInfo() (string, string) {
 	return ip, mac
 }
 
+// getAllIPs 获取所有启用网卡的非回环地址 (IPv4 在前，跳过 IPv6 链路本地地址)
+func getAllIPs() []string {
+	var v4, v6 []string
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return nil
+	}
+	for _, iface := range interfaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		addrs, _ := iface.Addrs()
+		for _, addr := range addrs {
+			var ip net.IP
+			switch v := addr.(type) {
+			case *net.IPNet:
+				ip = v.IP
+			case *net.IPAddr:
+				ip = v.IP
+			}
+			if ip == nil || ip.IsLoopback() || ip.IsLinkLocalUnicast() {
+				continue
+			}
+			if ip.To4() != nil {
+				v4 = append(v4, ip.String())
+			} else {
+				v6 = append(v6, ip.String())
+			}
+		}
+	}
+	return append(v4, v6...)
+}
+
 // 备用方案：遍历网卡
 func getFallbackIP() string {
 	interfaces, err := net.Interfaces()
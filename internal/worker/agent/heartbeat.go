@@ -13,28 +13,62 @@ import (
 // StartHeartbeat 启动心跳循环
 // masterBaseURL: Master 的地址，例如 "http://192.168.1.100:8080"
 // localPort: Worker 自身监听的端口，例如 8081
-func StartHeartbeat(masterBaseURL string, localPort int) {
+// nodeID: 持久化的节点 ID (见 LoadOrCreateNodeID)
+// nodeLabels: 配置中声明的节点标签
+func StartHeartbeat(masterBaseURL string, localPort int, nodeID string, nodeLabels map[string]string) {
 	// 1. 获取静态信息 (启动时只获取一次，如 IP、MAC、操作系统)
 	nodeInfo := GetNodeInfo()
 
-	log.Printf("Worker agent started. Target Master: %s, Local Port: %d", masterBaseURL, localPort)
+	log.Printf("Worker agent started. Node: %s, Target Master: %s, Local Port: %d", nodeID, masterBaseURL, localPort)
 
 	// 2. 创建定时器，每 5 秒发送一次心跳
 	ticker := time.NewTicker(5 * time.Second)
 	defer ticker.Stop()
 
+	rejected := false
 	for range ticker.C {
 		status := GetStatus()
 		reqData := protocol.RegisterRequest{
+			NodeID: nodeID,
+			Secret: NodeSecret(),
 			Port:   localPort,
 			Info:   nodeInfo,
 			Status: status,
+			Labels: nodeLabels,
+
+			Version:  Version,
+			Platform: Platform(),
 		}
 
 		jsonData, _ := json.Marshal(reqData)
 		url := fmt.Sprintf("%s/api/worker/heartbeat", masterBaseURL)
 
-		// 使用单例方法
-		_ = utils.PostJSON(url, jsonData)
+		var resp struct {
+			Code int                    `json:"code"`
+			Msg  string                 `json:"msg"`
+			Data protocol.HeartbeatResp `json:"data"`
+		}
+		if err := utils.PostJSONResult(url, jsonData, &resp); err != nil {
+			continue
+		}
+		if resp.Code != 0 {
+			// 密钥被拒绝 (密钥文件丢失或节点被他人抢注): 需在 Master 删除该节点后重新注册
+			if !rejected {
+				log.Printf("Heartbeat rejected by master: %s (delete node %s on master to re-register)", resp.Msg, nodeID)
+			}
+			rejected = true
+			continue
+		}
+		rejected = false
+
+		if resp.Data.Secret != "" && resp.Data.Secret != NodeSecret() {
+			if err := saveNodeSecret(resp.Data.Secret); err != nil {
+				log.Printf("Save node secret failed: %v", err)
+			} else {
+				log.Printf("Node secret received from master")
+			}
+		}
+		// 自升级观察期内首次心跳成功即确认升级
+		ConfirmUpgrade()
 	}
 }
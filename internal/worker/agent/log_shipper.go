@@ -0,0 +1,364 @@
+package agent
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"ops-system/internal/worker/executor"
+	"ops-system/pkg/config"
+	"ops-system/pkg/protocol"
+	"ops-system/pkg/utils"
+)
+
+const (
+	shipCheckpointFile = ".logship.json" // 检查点文件 (位于实例根目录)
+	shipFingerprintLen = 256             // 用文件头部字节识别文件，轮转改名后仍能找到
+)
+
+// shipCursor 单个日志流的推送进度
+type shipCursor struct {
+	File   string `json:"file"`   // 当前跟踪的文件路径
+	FP     string `json:"fp"`     // 文件头指纹 (sha1)
+	FPLen  int    `json:"fp_len"` // 指纹覆盖的字节数 (文件不足 256 字节时随增长更新)
+	Offset int64  `json:"offset"` // 已确认的文件内偏移
+	Seq    int64  `json:"seq"`    // 已确认的流序号 (跨轮转累计字节数)
+	// 已推送完的历史分段的最大修改时间 (纳秒)，比它新的分段都需要推送
+	Rotated     int64  `json:"rotated"`
+	RotatedFile string `json:"rotated_file"` // 最后推送完的分段
+}
+
+// logShipper 将实例日志按批推送到 Master 集中存储
+// 检查点在 Master 确认后才推进并落盘，Worker 重启后从检查点继续：
+// 未确认的批次会重发，由 Master 按序号去重，因此既不丢失也不重复。
+type logShipper struct {
+	masterURL string
+	nodeIP    string
+	path      string // 检查点文件
+	batchSize int
+	cursors   map[string]*shipCursor // key: 实例ID|日志名
+}
+
+// StartLogShipper 启动日志推送协程 (log_ship.enabled 开启时)
+func StartLogShipper(masterBaseURL, workDir string, cfg config.LogShipConfig) {
+	s := &logShipper{
+		masterURL: masterBaseURL,
+		nodeIP:    GetNodeInfo().IP,
+		path:      filepath.Join(workDir, shipCheckpointFile),
+		batchSize: cfg.BatchSize,
+		cursors:   make(map[string]*shipCursor),
+	}
+	if s.batchSize <= 0 {
+		s.batchSize = 512 * 1024
+	}
+	if data, err := os.ReadFile(s.path); err == nil {
+		json.Unmarshal(data, &s.cursors)
+	}
+
+	interval := cfg.Interval
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for ; ; <-ticker.C {
+		s.shipAll()
+	}
+}
+
+func (s *logShipper) shipAll() {
+	seen := make(map[string]bool)
+	for _, inst := range executor.GetAllLocalInstances() {
+		keys, err := executor.GetLogFiles(inst.InstanceID)
+		if err != nil {
+			continue
+		}
+		for _, key := range keys {
+			path, err := executor.GetLogPath(inst.InstanceID, key)
+			if err != nil {
+				continue
+			}
+			id := inst.InstanceID + "|" + key
+			seen[id] = true
+			cur, ok := s.cursors[id]
+			if !ok {
+				// 新发现的日志流从当前文件开始，不补推开启前已轮转的历史
+				cur = &shipCursor{File: path, Rotated: time.Now().UnixNano()}
+				s.cursors[id] = cur
+			} else if cur.File != path {
+				// 日志路径配置变更：从新文件开头推送，序号延续以免被 Master 当作重复数据丢弃
+				cur.File, cur.FP, cur.FPLen, cur.Offset = path, "", 0, 0
+				cur.Rotated, cur.RotatedFile = time.Now().UnixNano(), ""
+			}
+			if err := s.shipStream(inst.InstanceID, key, cur); err != nil {
+				log.Printf("[LogShip] %s/%s: %v", inst.InstanceID, key, err)
+			}
+		}
+	}
+
+	// 实例已删除，丢弃其检查点
+	changed := false
+	for id := range s.cursors {
+		if !seen[id] {
+			delete(s.cursors, id)
+			changed = true
+		}
+	}
+	if changed {
+		s.save()
+	}
+}
+
+// shipStream 推送单个日志流的新增内容
+func (s *logShipper) shipStream(instID, key string, cur *shipCursor) error {
+	// 1. 文件已轮转 (或上次重置时文件为空)：按顺序推完历史分段中尚未推送的内容
+	if cur.FP == "" || !s.sameFile(cur.File, cur) {
+		if err := s.shipRotated(instID, key, cur); err != nil {
+			return err
+		}
+	}
+
+	// 2. 当前文件只推送完整的行，最后一行等写完再发
+	return s.shipFile(instID, key, cur, cur.File, false)
+}
+
+// shipRotated 推送轮转出的历史分段，完成后切换到当前文件
+// 两轮之间可能轮转多次，依次处理：检查点指向的分段 (续传) -> 比 Rotated 更新的分段 (全量)
+func (s *logShipper) shipRotated(instID, key string, cur *shipCursor) error {
+	segs := executor.LogSegments(cur.File)
+	segs = segs[:len(segs)-1] // 最后一个是当前文件
+
+	if cur.FP != "" {
+		if seg := s.findRotated(segs, cur); seg != "" {
+			if err := s.shipFile(instID, key, cur, seg, true); err != nil {
+				return err
+			}
+			s.finishSegment(cur, seg)
+		} else {
+			log.Printf("[LogShip] %s/%s: rotated file not found, data after offset %d may be lost", instID, key, cur.Offset)
+		}
+	}
+
+	// 修改时间相同 (快速连续轮转) 时按 LogSegments 的顺序排在上次完成的分段之后才算新分段
+	after := -1
+	for i, seg := range segs {
+		if segmentName(seg) == cur.RotatedFile {
+			after = i
+		}
+	}
+	for i, seg := range segs {
+		seg = resolveSegment(seg)
+		fi, err := os.Stat(seg)
+		if err != nil {
+			return err // 下一轮重新列目录后重试，不能跳过
+		}
+		if mt := fi.ModTime().UnixNano(); mt < cur.Rotated || (mt == cur.Rotated && i <= after) || segmentName(seg) == cur.RotatedFile {
+			continue
+		}
+		// 先把检查点指向该分段，推送中途重启时可按指纹续传
+		fp, n, err := fingerprint(seg, shipFingerprintLen)
+		if err != nil {
+			return err
+		}
+		cur.FP, cur.FPLen, cur.Offset = fp, n, 0
+		if err := s.shipFile(instID, key, cur, seg, true); err != nil {
+			return err
+		}
+		s.finishSegment(cur, seg)
+	}
+
+	cur.FP, cur.FPLen, cur.Offset = "", 0, 0
+	s.refreshFingerprint(cur)
+	s.save()
+	return nil
+}
+
+// finishSegment 记录已推送完的分段 (以修改时间为水位线)
+func (s *logShipper) finishSegment(cur *shipCursor, seg string) {
+	if fi, err := os.Stat(seg); err == nil && fi.ModTime().UnixNano() >= cur.Rotated {
+		cur.Rotated = fi.ModTime().UnixNano()
+		cur.RotatedFile = segmentName(seg)
+	}
+	s.save()
+}
+
+// segmentName 分段文件名 (去掉压缩后缀，压缩前后视为同一分段)
+func segmentName(path string) string {
+	return strings.TrimSuffix(filepath.Base(path), ".gz")
+}
+
+// shipFile 从 cur.Offset 开始分批推送 path，final 表示文件不会再增长 (末尾不完整的行也一并发送)
+func (s *logShipper) shipFile(instID, key string, cur *shipCursor, path string, final bool) error {
+	rc, err := executor.OpenLogFile(path)
+	if err != nil {
+		if os.IsNotExist(err) && !final {
+			return nil // 当前文件尚未生成
+		}
+		return err
+	}
+	defer rc.Close()
+
+	// gzip 分段无法 Seek，统一跳过已确认的字节
+	if _, err := io.CopyN(io.Discard, rc, cur.Offset); err != nil {
+		if err == io.EOF {
+			return nil // 文件比检查点短 (被截断)，下一轮按轮转处理
+		}
+		return err
+	}
+
+	br := bufio.NewReaderSize(rc, 64*1024)
+	var batch []byte
+	fileBytes := 0 // 本批对应的文件字节数 (补齐的换行不计入偏移)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := s.post(instID, key, cur, batch, fileBytes); err != nil {
+			return err
+		}
+		if !final {
+			s.refreshFingerprint(cur)
+		}
+		s.save()
+		batch, fileBytes = batch[:0], 0
+		return nil
+	}
+
+	for {
+		line, err := br.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if err == io.EOF {
+			// 当前文件末尾不完整的行等写完再发；历史分段不会再增长，补齐换行后发送
+			if len(line) > 0 && final {
+				batch = append(append(batch, line...), '\n')
+				fileBytes += len(line)
+			}
+			return flush()
+		}
+		batch = append(batch, line...)
+		fileBytes += len(line)
+		if len(batch) >= s.batchSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// post 推送一批数据，Master 确认后推进检查点
+func (s *logShipper) post(instID, key string, cur *shipCursor, data []byte, fileBytes int) error {
+	req := protocol.LogShipReq{
+		NodeIP:     s.nodeIP,
+		InstanceID: instID,
+		LogKey:     key,
+		Seq:        cur.Seq,
+		Data:       data,
+	}
+	body, _ := json.Marshal(req)
+	var resp struct {
+		Code int                  `json:"code"`
+		Msg  string               `json:"msg"`
+		Data protocol.LogShipResp `json:"data"`
+	}
+	if err := utils.PostJSONResult(s.masterURL+"/api/logs/ship", body, &resp); err != nil {
+		return err
+	}
+	if resp.Code != 0 {
+		return fmt.Errorf("master error: %s", resp.Msg)
+	}
+
+	// 按 Master 确认的序号推进 (序号为原始字节数，两端一致)；Master 已有更多数据时本批为重发，同样视为确认
+	acked, end := resp.Data.Acked, cur.Seq+int64(len(data))
+	switch {
+	case acked >= end:
+		cur.Offset += int64(fileBytes)
+		cur.Seq = end
+		return nil
+	case acked > cur.Seq:
+		// 只确认了一部分: 推进到确认位置，下一轮从该处重读
+		cur.Offset += acked - cur.Seq
+		cur.Seq = acked
+	}
+	return fmt.Errorf("master acked %d of batch [%d, %d)", acked, end-int64(len(data)), end)
+}
+
+// refreshFingerprint 文件头不足指纹长度时，随文件增长更新指纹
+func (s *logShipper) refreshFingerprint(cur *shipCursor) {
+	if cur.FP != "" && (cur.FPLen >= shipFingerprintLen || !s.sameFile(cur.File, cur)) {
+		return
+	}
+	if fp, n, err := fingerprint(cur.File, shipFingerprintLen); err == nil && n > 0 {
+		cur.FP, cur.FPLen = fp, n
+	}
+}
+
+// sameFile 判断 path 是否仍是检查点记录的那个文件
+func (s *logShipper) sameFile(path string, cur *shipCursor) bool {
+	fp, n, err := fingerprint(path, cur.FPLen)
+	if err != nil || n < cur.FPLen || fp != cur.FP {
+		return false
+	}
+	if fi, err := os.Stat(path); err != nil || fi.Size() < cur.Offset {
+		return false
+	}
+	return true
+}
+
+// findRotated 在历史分段中查找检查点对应的文件 (从新到旧)
+func (s *logShipper) findRotated(segs []string, cur *shipCursor) string {
+	for i := len(segs) - 1; i >= 0; i-- {
+		seg := resolveSegment(segs[i])
+		if fp, n, err := fingerprint(seg, cur.FPLen); err == nil && n == cur.FPLen && fp == cur.FP {
+			return seg
+		}
+	}
+	return ""
+}
+
+// resolveSegment 列目录之后分段可能刚被压缩 (原文件已删除)，此时改用 .gz
+func resolveSegment(path string) string {
+	if _, err := os.Stat(path); os.IsNotExist(err) && !strings.HasSuffix(path, ".gz") {
+		return path + ".gz"
+	}
+	return path
+}
+
+// fingerprint 计算文件前 n 个字节的 sha1 (.gz 分段按解压后内容)
+func fingerprint(path string, n int) (string, int, error) {
+	rc, err := executor.OpenLogFile(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer rc.Close()
+
+	head := make([]byte, n)
+	read, err := io.ReadFull(rc, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", 0, err
+	}
+	sum := sha1.Sum(head[:read])
+	return hex.EncodeToString(sum[:]), read, nil
+}
+
+// save 原子写入检查点
+func (s *logShipper) save() {
+	data, _ := json.Marshal(s.cursors)
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		log.Printf("[LogShip] save checkpoint failed: %v", err)
+		return
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		log.Printf("[LogShip] save checkpoint failed: %v", err)
+	}
+}
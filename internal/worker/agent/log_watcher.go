@@ -0,0 +1,184 @@
+package agent
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"regexp"
+	"sync"
+	"time"
+
+	"ops-system/internal/worker/executor"
+	"ops-system/pkg/protocol"
+	"ops-system/pkg/utils"
+
+	"github.com/hpcloud/tail"
+)
+
+const (
+	logRuleSyncInterval = 30 * time.Second // 拉取日志规则的间隔
+	logReportInterval   = 10 * time.Second // 上报匹配统计的间隔
+	logSampleLines      = 5                // 每个任务保留的样例行数
+	logSampleMaxLen     = 512              // 样例行最大长度
+)
+
+// logWatcher 单个日志匹配任务 (规则 × 实例)
+type logWatcher struct {
+	rule protocol.LogWatchRule
+	re   *regexp.Regexp
+	t    *tail.Tail
+
+	mu      sync.Mutex
+	hits    []int64  // 窗口内每次匹配的时间
+	samples []string // 最近的匹配行
+}
+
+// StartLogWatcher 启动日志告警协程：定期从 Master 拉取规则，持续 tail 日志并上报匹配统计
+func StartLogWatcher(masterBaseURL string) {
+	nodeIP := GetNodeInfo().IP
+	watchers := make(map[protocol.LogWatchRule]*logWatcher)
+
+	syncTicker := time.NewTicker(logRuleSyncInterval)
+	reportTicker := time.NewTicker(logReportInterval)
+	defer syncTicker.Stop()
+	defer reportTicker.Stop()
+
+	syncLogWatchers(masterBaseURL, watchers)
+	for {
+		select {
+		case <-syncTicker.C:
+			syncLogWatchers(masterBaseURL, watchers)
+		case <-reportTicker.C:
+			reportLogMatches(masterBaseURL, nodeIP, watchers)
+		}
+	}
+}
+
+// syncLogWatchers 按 Master 下发的任务列表启停 tail (Master 按请求携带的节点凭据确定本节点)
+func syncLogWatchers(masterBaseURL string, watchers map[protocol.LogWatchRule]*logWatcher) {
+	var resp struct {
+		Code int                     `json:"code"`
+		Msg  string                  `json:"msg"`
+		Data []protocol.LogWatchRule `json:"data"`
+	}
+	if err := utils.GetJSON(masterBaseURL+"/api/alerts/log_rules", &resp); err != nil || resp.Code != 0 {
+		// Master 暂时不可达时保持现有任务
+		return
+	}
+
+	wanted := make(map[protocol.LogWatchRule]bool, len(resp.Data))
+	for _, rule := range resp.Data {
+		wanted[rule] = true
+	}
+
+	// 1. 停止已撤销的任务 (规则删除/修改后 key 会变化)
+	for rule, w := range watchers {
+		if !wanted[rule] {
+			w.t.Stop()
+			delete(watchers, rule)
+		}
+	}
+
+	// 2. 启动新任务
+	for rule := range wanted {
+		if _, ok := watchers[rule]; ok {
+			continue
+		}
+		w, err := newLogWatcher(rule)
+		if err != nil {
+			log.Printf("[LogAlert] rule %d on %s: %v", rule.RuleID, rule.InstanceID, err)
+			continue
+		}
+		watchers[rule] = w
+	}
+}
+
+func newLogWatcher(rule protocol.LogWatchRule) (*logWatcher, error) {
+	re, err := regexp.Compile(rule.Pattern)
+	if err != nil {
+		return nil, err
+	}
+	path, err := executor.GetLogPath(rule.InstanceID, rule.LogKey)
+	if err != nil {
+		return nil, err
+	}
+
+	// 从文件末尾开始，只关注新产生的日志
+	t, err := tail.TailFile(path, tail.Config{
+		Follow:    true,
+		ReOpen:    true,
+		MustExist: false,
+		Poll:      true,
+		Location:  &tail.SeekInfo{Offset: 0, Whence: io.SeekEnd},
+		Logger:    tail.DiscardingLogger,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	w := &logWatcher{rule: rule, re: re, t: t}
+	go w.run()
+	return w, nil
+}
+
+func (w *logWatcher) run() {
+	for line := range w.t.Lines {
+		if line.Err != nil || !w.re.MatchString(line.Text) {
+			continue
+		}
+		text := line.Text
+		if len(text) > logSampleMaxLen {
+			text = text[:logSampleMaxLen] + "..."
+		}
+
+		w.mu.Lock()
+		w.hits = append(w.hits, time.Now().Unix())
+		w.samples = append(w.samples, text)
+		if len(w.samples) > logSampleLines {
+			w.samples = w.samples[len(w.samples)-logSampleLines:]
+		}
+		w.pruneLocked(time.Now().Unix())
+		w.mu.Unlock()
+	}
+}
+
+// pruneLocked 丢弃窗口外的匹配记录 (调用方需持有 w.mu)
+func (w *logWatcher) pruneLocked(now int64) {
+	cutoff := now - int64(w.rule.Window)
+	idx := 0
+	for idx < len(w.hits) && w.hits[idx] < cutoff {
+		idx++
+	}
+	w.hits = w.hits[idx:]
+	if len(w.hits) == 0 {
+		w.samples = nil
+	}
+}
+
+// snapshot 当前窗口内的统计
+func (w *logWatcher) snapshot() protocol.LogMatchReport {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.pruneLocked(time.Now().Unix())
+	return protocol.LogMatchReport{
+		RuleID:     w.rule.RuleID,
+		InstanceID: w.rule.InstanceID,
+		Count:      len(w.hits),
+		Samples:    append([]string(nil), w.samples...),
+	}
+}
+
+// reportLogMatches 上报所有任务的统计 (包括 0 次，以便 Master 判定恢复)
+func reportLogMatches(masterBaseURL, nodeIP string, watchers map[protocol.LogWatchRule]*logWatcher) {
+	if len(watchers) == 0 {
+		return
+	}
+	req := protocol.LogMatchReportRequest{IP: nodeIP}
+	for _, w := range watchers {
+		req.Items = append(req.Items, w.snapshot())
+	}
+	data, _ := json.Marshal(req)
+	if err := utils.PostJSON(masterBaseURL+"/api/alerts/log_report", data); err != nil {
+		log.Printf("[LogAlert] report failed: %v", err)
+	}
+}
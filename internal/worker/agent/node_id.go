@@ -0,0 +1,45 @@
+package agent
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// LoadOrCreateNodeID 读取节点 ID 文件，不存在时生成 UUID 并写入
+// 节点 ID 是 Master 识别节点的唯一依据，IP 变化 (DHCP / NAT / 多网卡) 不影响节点身份
+// 注意: 克隆虚机镜像时需删除该文件 (及同目录的 node_secret)，否则多台机器会被识别为同一节点
+func LoadOrCreateNodeID(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		id := strings.TrimSpace(string(data))
+		if _, err := uuid.Parse(id); err != nil {
+			// 内容损坏时不自动重新生成，避免节点身份悄然改变
+			return "", fmt.Errorf("invalid node id in %s: %q", path, id)
+		}
+		return id, nil
+	}
+	if !os.IsNotExist(err) {
+		return "", err
+	}
+
+	id := uuid.NewString()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", err
+	}
+	// 先写临时文件再 rename，避免写入中断留下半个 ID
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(id+"\n"), 0644); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return "", err
+	}
+	log.Printf("Generated node id %s (%s)", id, path)
+	return id, nil
+}
@@ -0,0 +1,93 @@
+package agent
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"ops-system/pkg/protocol"
+	"ops-system/pkg/utils"
+)
+
+// 节点密钥: 首次注册时由 Master 签发，心跳、反向通道与各类回调以此证明节点身份
+var nodeSecret struct {
+	mu    sync.RWMutex
+	path  string
+	value string
+}
+
+// LoadNodeSecret 读取节点密钥文件 (不存在时为空，首次心跳后由 Master 签发并写入)
+func LoadNodeSecret(path string) error {
+	nodeSecret.mu.Lock()
+	defer nodeSecret.mu.Unlock()
+	nodeSecret.path = path
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	nodeSecret.value = strings.TrimSpace(string(data))
+	return nil
+}
+
+// NodeSecret 当前的节点密钥 (尚未注册时为空)
+func NodeSecret() string {
+	nodeSecret.mu.RLock()
+	defer nodeSecret.mu.RUnlock()
+	return nodeSecret.value
+}
+
+// saveNodeSecret 保存 Master 签发的密钥并持久化 (仅属主可读)
+// 写文件失败时本次运行期间仍使用新密钥，重启后需在 Master 删除节点重新注册
+func saveNodeSecret(secret string) error {
+	nodeSecret.mu.Lock()
+	defer nodeSecret.mu.Unlock()
+	nodeSecret.value = secret
+	if nodeSecret.path == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(nodeSecret.path), 0755); err != nil {
+		return err
+	}
+	tmp := nodeSecret.path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(secret+"\n"), 0600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, nodeSecret.path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// InstallNodeAuth 使 utils 的 HTTP Client 发往 Master 的请求携带节点 ID 与密钥
+// 须在启动各上报协程前调用；密钥只发往 Master，不会泄露给其他地址
+func InstallNodeAuth(masterBaseURL, nodeID string) error {
+	u, err := url.Parse(masterBaseURL)
+	if err != nil {
+		return err
+	}
+	utils.GlobalClient.Transport = &nodeAuthTransport{host: u.Host, nodeID: nodeID, next: utils.GlobalClient.Transport}
+	return nil
+}
+
+// nodeAuthTransport 为发往 Master 的请求附加节点凭据 (尚未签发密钥时原样发送)
+type nodeAuthTransport struct {
+	host   string
+	nodeID string
+	next   http.RoundTripper
+}
+
+func (t *nodeAuthTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	secret := NodeSecret()
+	if req.URL.Host != t.host || secret == "" {
+		return t.next.RoundTrip(req)
+	}
+	// RoundTripper 不得修改调用方的请求
+	req = req.Clone(req.Context())
+	req.Header.Set(protocol.NodeIDHeader, t.nodeID)
+	req.Header.Set(protocol.NodeSecretHeader, secret)
+	return t.next.RoundTrip(req)
+}
@@ -0,0 +1,33 @@
+package agent
+
+import (
+	"log"
+	"slices"
+	"time"
+
+	"ops-system/pkg/protocol"
+	"ops-system/pkg/utils"
+)
+
+const policySyncInterval = 30 * time.Second // 拉取执行策略的间隔
+
+// StartPolicySync 定期从 Master 拉取执行策略的拒绝规则，变化时交给 apply 生效
+// Master 不可达时保持现有规则 (内置拒绝规则始终生效)
+func StartPolicySync(masterBaseURL string, apply func(deny []string)) {
+	var current []string
+	ticker := time.NewTicker(policySyncInterval)
+	defer ticker.Stop()
+	for {
+		var resp struct {
+			Code int                     `json:"code"`
+			Data protocol.ExecPolicyResp `json:"data"`
+		}
+		if err := utils.GetJSON(masterBaseURL+"/api/exec/policy", &resp); err == nil && resp.Code == 0 &&
+			(current == nil || !slices.Equal(current, resp.Data.Deny)) {
+			current = append([]string{}, resp.Data.Deny...)
+			apply(current)
+			log.Printf("[Policy] Exec policy synced (%d custom deny rules)", len(current))
+		}
+		<-ticker.C
+	}
+}
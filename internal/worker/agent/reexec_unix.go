@@ -0,0 +1,15 @@
+//go:build !windows
+
+package agent
+
+import (
+	"os"
+	"syscall"
+)
+
+const reexecSupported = true
+
+// reexec 以相同参数和环境原地执行新程序 (PID 不变，自启服务无感知)
+func reexec(path string) error {
+	return syscall.Exec(path, os.Args, os.Environ())
+}
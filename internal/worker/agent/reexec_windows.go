@@ -0,0 +1,10 @@
+package agent
+
+import "fmt"
+
+// Windows 无法原地执行新程序，且运行中的程序无法释放监听端口给新进程，暂不支持自升级
+const reexecSupported = false
+
+func reexec(path string) error {
+	return fmt.Errorf("re-exec is not supported on windows")
+}
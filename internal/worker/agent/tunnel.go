@@ -0,0 +1,90 @@
+package agent
+
+import (
+	"context"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"ops-system/pkg/protocol"
+	"ops-system/pkg/tunnel"
+	"ops-system/pkg/utils"
+
+	"github.com/gorilla/websocket"
+)
+
+// StartTunnel 以反向通道模式连接 Master (安装拨号函数后返回，后台维持连接、断线退避重连)
+// 须在其他使用 utils.Transport 的协程启动前调用
+// 通道上 Master 打开的流 (命令、部署、日志查看、exec) 交给 handler 处理；
+// 发往 Master 的请求 (心跳、状态上报、日志推送) 经 utils.Transport 转入通道。
+// Master 为 https 时通道本身走 wss，发往 Master 的请求仍直连 (均为出站连接，不受 NAT 影响)
+// 通道以节点密钥认证，首次心跳注册拿到密钥后才建立
+func StartTunnel(masterBaseURL, nodeID string, handler http.Handler) {
+	u, err := url.Parse(masterBaseURL)
+	if err != nil || u.Host == "" {
+		log.Printf("[Tunnel] Invalid master url %q: %v", masterBaseURL, err)
+		return
+	}
+	wsURL := "ws" + strings.TrimPrefix(u.Scheme, "http") + "://" + u.Host +
+		"/api/worker/tunnel?node_id=" + url.QueryEscape(nodeID)
+
+	var current atomic.Pointer[tunnel.Session]
+	if u.Scheme == "http" {
+		masterAddr := u.Host
+		if u.Port() == "" {
+			masterAddr = net.JoinHostPort(u.Hostname(), "80")
+		}
+		direct := utils.Transport.DialContext
+		utils.Transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			if addr == masterAddr {
+				if sess := current.Load(); sess != nil {
+					if conn, err := sess.Open(); err == nil {
+						return conn, nil
+					}
+				}
+			}
+			return direct(ctx, network, addr)
+		}
+	}
+
+	go keepTunnel(u.Host, wsURL, &current, handler)
+}
+
+func keepTunnel(host, wsURL string, current *atomic.Pointer[tunnel.Session], handler http.Handler) {
+	backoff := time.Second
+	for {
+		// 通道以节点密钥认证: 首次心跳注册拿到密钥前，心跳直连 Master
+		secret := NodeSecret()
+		if secret == "" {
+			time.Sleep(time.Second)
+			continue
+		}
+		conn, _, err := websocket.DefaultDialer.Dial(wsURL, http.Header{protocol.NodeSecretHeader: {secret}})
+		if err != nil {
+			log.Printf("[Tunnel] Connect failed: %v (retry in %s)", err, backoff)
+			time.Sleep(backoff)
+			if backoff *= 2; backoff > 30*time.Second {
+				backoff = 30 * time.Second
+			}
+			continue
+		}
+		backoff = time.Second
+
+		sess := tunnel.Client(conn)
+		current.Store(sess)
+		// 丢弃通道建立前的直连空闲连接，后续请求改走通道
+		utils.Transport.CloseIdleConnections()
+		log.Printf("[Tunnel] Connected to %s", host)
+
+		(&http.Server{Handler: handler}).Serve(sess)
+
+		current.CompareAndSwap(sess, nil)
+		utils.Transport.CloseIdleConnections()
+		log.Printf("[Tunnel] Disconnected from %s, reconnecting", host)
+		time.Sleep(time.Second)
+	}
+}
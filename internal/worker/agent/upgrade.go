@@ -0,0 +1,228 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"ops-system/pkg/protocol"
+)
+
+// 自升级流程:
+//  1. 下载新程序到 <exe>.new 并校验 sha256
+//  2. 预检: 执行 `<exe>.new version`，输出须为目标版本
+//  3. 原子替换: <exe> -> <exe>.old，<exe>.new -> <exe>，写入升级标记 <exe>.upgrade.json
+//  4. 原地重新执行 (PID 不变，实例进程已 Setsid 脱离，不受影响)
+//  5. 新程序启动后在截止时间内心跳成功则删除标记；否则用 <exe>.old 回滚并重新执行
+//
+// 新程序启动即崩溃时由自启服务拉起，启动检查发现标记已超时同样会回滚
+
+// VersionCommand 打印版本号的子命令 (供升级预检使用)
+const VersionCommand = "version"
+
+// upgradeMarker 升级标记 (存在即表示新程序处于观察期)
+type upgradeMarker struct {
+	From     string `json:"from"`
+	To       string `json:"to"`
+	Deadline int64  `json:"deadline"`
+}
+
+var (
+	exePath      string
+	upgradeMu    sync.Mutex
+	upgrading    bool
+	pendingCheck atomic.Bool // 观察期内，等待首次心跳成功
+)
+
+func markerPath() string { return exePath + ".upgrade.json" }
+func backupPath() string { return exePath + ".old" }
+
+// InitUpgrade 启动时调用: 记录程序路径，并处理上一次升级的观察期
+func InitUpgrade(exe string) {
+	exePath = exe
+
+	data, err := os.ReadFile(markerPath())
+	if err != nil {
+		return
+	}
+	var m upgradeMarker
+	if err := json.Unmarshal(data, &m); err != nil || m.To != Version {
+		// 标记与当前版本不符 (已回滚或标记损坏)，清理即可
+		os.Remove(markerPath())
+		return
+	}
+
+	remaining := time.Until(time.Unix(m.Deadline, 0))
+	if remaining <= 0 {
+		rollback(fmt.Sprintf("upgrade to %s did not confirm before deadline", m.To))
+		return
+	}
+	log.Printf("[Upgrade] Running %s (from %s), waiting for heartbeat within %s", m.To, m.From, remaining.Round(time.Second))
+	pendingCheck.Store(true)
+	time.AfterFunc(remaining, func() {
+		if pendingCheck.Load() {
+			rollback(fmt.Sprintf("no successful heartbeat within rollback timeout after upgrading to %s", m.To))
+		}
+	})
+}
+
+// ConfirmUpgrade 心跳成功后调用: 结束观察期
+func ConfirmUpgrade() {
+	if !pendingCheck.CompareAndSwap(true, false) {
+		return
+	}
+	os.Remove(markerPath())
+	log.Printf("[Upgrade] Upgrade to %s confirmed", Version)
+}
+
+// StartUpgrade 校验升级请求并在后台执行 (下载可能较慢，不阻塞 Master)
+func StartUpgrade(req protocol.AgentUpgradeRequest) error {
+	if !reexecSupported {
+		return fmt.Errorf("self-upgrade is not supported on this platform")
+	}
+	if exePath == "" {
+		return fmt.Errorf("upgrade not initialized")
+	}
+	if req.Version == "" || req.DownloadURL == "" || len(req.SHA256) != sha256.Size*2 {
+		return fmt.Errorf("invalid upgrade request")
+	}
+	if req.Version == Version {
+		return fmt.Errorf("already running %s", Version)
+	}
+	if pendingCheck.Load() {
+		return fmt.Errorf("previous upgrade is not confirmed yet")
+	}
+
+	upgradeMu.Lock()
+	defer upgradeMu.Unlock()
+	if upgrading {
+		return fmt.Errorf("upgrade already in progress")
+	}
+	upgrading = true
+
+	go func() {
+		err := runUpgrade(req)
+		// 成功时进程已被替换，走到这里一定是失败
+		log.Printf("[Upgrade] Upgrade to %s aborted: %v", req.Version, err)
+		upgradeMu.Lock()
+		upgrading = false
+		upgradeMu.Unlock()
+	}()
+	return nil
+}
+
+func runUpgrade(req protocol.AgentUpgradeRequest) error {
+	newPath := exePath + ".new"
+	defer os.Remove(newPath)
+
+	log.Printf("[Upgrade] Downloading %s from %s", req.Version, req.DownloadURL)
+	if err := downloadVerified(req.DownloadURL, req.SHA256, newPath); err != nil {
+		return err
+	}
+	if err := preflight(newPath, req.Version); err != nil {
+		return fmt.Errorf("preflight failed: %v", err)
+	}
+
+	timeout := time.Duration(req.RollbackTimeout) * time.Second
+	if timeout <= 0 {
+		timeout = 2 * time.Minute
+	}
+	marker, _ := json.Marshal(upgradeMarker{From: Version, To: req.Version, Deadline: time.Now().Add(timeout).Unix()})
+	if err := os.WriteFile(markerPath(), marker, 0644); err != nil {
+		return err
+	}
+
+	// 原子替换: 先备份当前程序，再将新程序移到原位置
+	os.Remove(backupPath())
+	if err := os.Rename(exePath, backupPath()); err != nil {
+		os.Remove(markerPath())
+		return err
+	}
+	if err := os.Rename(newPath, exePath); err != nil {
+		os.Rename(backupPath(), exePath)
+		os.Remove(markerPath())
+		return err
+	}
+
+	log.Printf("[Upgrade] Re-executing as %s", req.Version)
+	if err := reexec(exePath); err != nil {
+		rollback(fmt.Sprintf("re-exec failed: %v", err))
+		return err
+	}
+	return nil
+}
+
+// downloadVerified 下载文件并校验 sha256，校验通过才落盘为可执行文件
+func downloadVerified(url, wantSum, dst string) error {
+	resp, err := http.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("download failed: %s", resp.Status)
+	}
+
+	tmp := dst + ".part"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0755)
+	if err != nil {
+		return err
+	}
+	h := sha256.New()
+	_, err = io.Copy(io.MultiWriter(f, h), resp.Body)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if sum := hex.EncodeToString(h.Sum(nil)); !strings.EqualFold(sum, wantSum) {
+		os.Remove(tmp)
+		return fmt.Errorf("sha256 mismatch: got %s, want %s", sum, wantSum)
+	}
+	return os.Rename(tmp, dst)
+}
+
+// preflight 确认新程序能在本机运行且版本正确
+func preflight(path, version string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, path, VersionCommand).Output()
+	if err != nil {
+		return err
+	}
+	if got := string(bytes.TrimSpace(out)); got != version {
+		return fmt.Errorf("binary reports version %q, want %q", got, version)
+	}
+	return nil
+}
+
+// rollback 恢复备份的程序并重新执行
+func rollback(reason string) {
+	log.Printf("[Upgrade] Rolling back: %s", reason)
+	os.Remove(markerPath())
+	if _, err := os.Stat(backupPath()); err != nil {
+		log.Printf("[Upgrade] Rollback impossible, backup missing: %v", err)
+		return
+	}
+	if err := os.Rename(backupPath(), exePath); err != nil {
+		log.Printf("[Upgrade] Rollback failed: %v", err)
+		return
+	}
+	if err := reexec(exePath); err != nil {
+		log.Printf("[Upgrade] Re-exec after rollback failed: %v", err)
+	}
+}
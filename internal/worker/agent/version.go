@@ -0,0 +1,12 @@
+package agent
+
+import "runtime"
+
+// Version Worker 构建版本，发布时注入:
+// go build -ldflags "-X ops-system/internal/worker/agent.Version=v1.2.0" -o worker ./cmd/worker
+var Version = "dev"
+
+// Platform 当前程序的 GOOS/GOARCH (用于匹配升级包)
+func Platform() string {
+	return runtime.GOOS + "/" + runtime.GOARCH
+}
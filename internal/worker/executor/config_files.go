@@ -0,0 +1,158 @@
+package executor
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"ops-system/pkg/protocol"
+)
+
+// configBaseDir 配置文件的根目录：托管实例为实例目录，纳管服务为其实际工作目录
+func configBaseDir(workDir string) string {
+	if m, err := readManifest(workDir); err == nil && m.IsExternal && m.ExternalWorkDir != "" {
+		return m.ExternalWorkDir
+	}
+	return workDir
+}
+
+// resolveConfigPath 解析相对路径，拒绝逃逸出根目录
+func resolveConfigPath(base, rel string) (string, error) {
+	if rel == "" || filepath.IsAbs(rel) || filepath.VolumeName(rel) != "" {
+		return "", fmt.Errorf("invalid config path: %q", rel)
+	}
+	target := filepath.Join(base, filepath.FromSlash(rel))
+	r, err := filepath.Rel(base, target)
+	if err != nil || r == ".." || strings.HasPrefix(r, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("config path escapes instance dir: %q", rel)
+	}
+	return target, nil
+}
+
+// WriteConfigFiles 将配置文件原子写入 (临时文件 + rename)，内容未变化的文件跳过
+// 返回实际写入的文件
+func WriteConfigFiles(workDir string, files []protocol.ConfigFile) ([]protocol.ConfigFile, error) {
+	base := configBaseDir(workDir)
+	var changed []protocol.ConfigFile
+	for _, f := range files {
+		target, err := resolveConfigPath(base, f.Path)
+		if err != nil {
+			return changed, err
+		}
+		if old, err := os.ReadFile(target); err == nil && bytes.Equal(old, []byte(f.Content)) {
+			continue
+		}
+		if err := writeFileAtomic(target, []byte(f.Content)); err != nil {
+			return changed, fmt.Errorf("write %s failed: %v", f.Path, err)
+		}
+		log.Printf("[Config] Updated %s (%s)", target, f.Source)
+		changed = append(changed, f)
+	}
+	return changed, nil
+}
+
+// writeFileAtomic 先写同目录下的临时文件并 fsync，再 rename 覆盖，进程不会读到半个文件
+// 已存在的文件保留原有权限
+func writeFileAtomic(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	defer os.Remove(tmpName) // rename 成功后为空操作
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmpName, mode); err != nil {
+		return err
+	}
+	return os.Rename(tmpName, path)
+}
+
+// ApplyConfig 写入配置文件，并按变化文件的绑定策略重载实例 (restart 优先于 signal)
+// 实例未运行时只写文件
+func ApplyConfig(req protocol.InstanceConfigRequest) (*protocol.InstanceConfigResp, error) {
+	workDir, found := FindInstanceDir(req.InstanceID)
+	if !found {
+		return nil, fmt.Errorf("instance dir not found for ID: %s", req.InstanceID)
+	}
+
+	// 中途写入失败时，已写入的文件照常重载，并与错误一同返回
+	changed, writeErr := WriteConfigFiles(workDir, req.Files)
+	resp := &protocol.InstanceConfigResp{Changed: []string{}, Reloaded: protocol.ReloadNone}
+	for _, f := range changed {
+		resp.Changed = append(resp.Changed, f.Path)
+	}
+	if len(changed) == 0 || !isRunning(workDir) {
+		return resp, writeErr
+	}
+
+	restart := false
+	var signals []string
+	for _, f := range changed {
+		switch f.Reload {
+		case protocol.ReloadRestart:
+			restart = true
+		case protocol.ReloadSignal:
+			sig := f.Signal
+			if sig == "" {
+				sig = "HUP"
+			}
+			if !containsString(signals, sig) {
+				signals = append(signals, sig)
+			}
+		}
+	}
+
+	switch {
+	case restart:
+		resp.Reloaded = protocol.ReloadRestart
+		log.Printf("[Config] Restarting %s after config change", req.InstanceID)
+		res := RestartProcess(workDir)
+		ReportStatus(req.InstanceID, res.Status, res.PID, res.Uptime)
+		if res.Error != nil {
+			resp.Error = res.Error.Error()
+		}
+	case len(signals) > 0:
+		resp.Reloaded = protocol.ReloadSignal
+		pid := getPID(workDir)
+		for _, sig := range signals {
+			log.Printf("[Config] Sending SIG%s to %s (pid %d)", strings.TrimPrefix(strings.ToUpper(sig), "SIG"), req.InstanceID, pid)
+			if err := signalProcess(pid, sig); err != nil {
+				resp.Error = err.Error()
+				break
+			}
+		}
+	}
+	return resp, writeErr
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,99 @@
+package executor
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+
+	"ops-system/pkg/logrotate"
+	"ops-system/pkg/protocol"
+)
+
+// LogPipeCommand Worker 的日志转储子命令: worker logpipe -file app.log ...
+// 实例进程的 stdout/stderr 写入管道，由独立的 logpipe 进程负责轮转落盘。
+// logpipe 与实例一样脱离 Worker 运行，Worker 重启不会导致实例因管道断开 (SIGPIPE) 退出。
+const LogPipeCommand = "logpipe"
+
+// consoleLogOptions 从 manifest 读取控制台日志轮转策略
+func consoleLogOptions(m *protocol.ServiceManifest) logrotate.Options {
+	opts := logrotate.Options{Compress: true}
+	if c := m.LogRotate; c != nil {
+		opts.MaxSize = int64(c.MaxSizeMB) * 1024 * 1024
+		opts.MaxFiles = c.MaxFiles
+		opts.Daily = c.Daily
+		opts.Compress = !c.NoCompress
+	}
+	return opts
+}
+
+// openConsoleLog 为实例进程准备控制台输出
+// 返回的 *os.File 作为子进程的 stdout/stderr，子进程启动后调用方需关闭它
+func openConsoleLog(workDir string, m *protocol.ServiceManifest) (*os.File, error) {
+	path := filepath.Join(workDir, "app.log")
+	opts := consoleLogOptions(m)
+
+	self, err := os.Executable()
+	if err == nil {
+		var pr, pw *os.File
+		if pr, pw, err = os.Pipe(); err == nil {
+			pipe := exec.Command(self, LogPipeCommand,
+				"-file", path,
+				"-max-size", strconv.FormatInt(opts.MaxSize, 10),
+				"-max-files", strconv.Itoa(opts.MaxFiles),
+				"-daily="+strconv.FormatBool(opts.Daily),
+				"-compress="+strconv.FormatBool(opts.Compress),
+			)
+			pipe.Stdin = pr
+			setProcessAttributes(pipe)
+			err = pipe.Start()
+			pr.Close()
+			if err == nil {
+				go pipe.Wait()
+				return pw, nil
+			}
+			pw.Close()
+		}
+	}
+
+	// 降级：无法启动 logpipe 时直接写文件 (仍保留上一次运行的输出，但不再按大小轮转)
+	log.Printf("[ConsoleLog] logpipe unavailable, fallback to plain file: %v", err)
+	w, err := logrotate.Open(path, opts)
+	if err != nil {
+		return nil, err
+	}
+	w.Close()
+	return os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+}
+
+// RunLogPipe logpipe 子命令入口：从 stdin 读取并写入轮转文件，stdin 关闭 (实例退出) 后结束
+func RunLogPipe(args []string) error {
+	fs := flag.NewFlagSet(LogPipeCommand, flag.ContinueOnError)
+	file := fs.String("file", "", "log file path")
+	maxSize := fs.Int64("max-size", logrotate.DefaultMaxSize, "max bytes per file")
+	maxFiles := fs.Int("max-files", logrotate.DefaultMaxFiles, "rotated files to keep")
+	daily := fs.Bool("daily", false, "rotate daily")
+	compress := fs.Bool("compress", true, "gzip rotated files")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *file == "" {
+		return fmt.Errorf("missing -file")
+	}
+
+	w, err := logrotate.Open(*file, logrotate.Options{
+		MaxSize: *maxSize, MaxFiles: *maxFiles, Daily: *daily, Compress: *compress,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, os.Stdin)
+	if cerr := w.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
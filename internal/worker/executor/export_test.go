@@ -0,0 +1,20 @@
+package executor
+
+import (
+	"ops-system/pkg/protocol"
+
+	"github.com/shirou/gopsutil/v3/process"
+)
+
+// 供测试直接调用的内部函数
+var (
+	IncrRestartCount  = incrRestartCount
+	ResetRestartCount = resetRestartCount
+	ReadRestartCount  = readRestartCount
+	ListenPorts       = listenPorts
+)
+
+// CollectProcessTree 供测试直接采集进程树指标
+func CollectProcessTree(instID string, root *process.Process) protocol.InstanceStatusReport {
+	return collectProcessTree(instID, root)
+}
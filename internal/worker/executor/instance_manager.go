@@ -130,6 +130,7 @@ func DeployInstance(req protocol.DeployRequest) error {
 	if err != nil {
 		return fmt.Errorf("cache package failed: %v", err)
 	}
+	ReportTask(protocol.TaskReport{TaskID: req.TaskID, ItemID: req.TaskItemID, Status: protocol.TaskRunning, Progress: 60, Message: "extracting"})
 	dirName := fmt.Sprintf("%s_%s", req.ServiceName, req.InstanceID)
 	workDir := filepath.Join(baseWorkDir, req.SystemName, dirName)
 
@@ -142,6 +143,10 @@ func DeployInstance(req protocol.DeployRequest) error {
 	if err := unzip(cachedZipPath, workDir); err != nil {
 		return fmt.Errorf("unzip failed: %v", err)
 	}
+	// 写入模块绑定的配置文件 (此时尚未启动，无需重载)
+	if _, err := WriteConfigFiles(workDir, req.ConfigFiles); err != nil {
+		return fmt.Errorf("write config files failed: %v", err)
+	}
 	return nil
 }
 
@@ -210,8 +215,26 @@ func HandleAction(req protocol.InstanceActionRequest) error {
 	return nil
 }
 
-// StartProcess 启动
+// StartProcess 启动 (人工操作: 部署、启停接口)，重启计数清零
 func StartProcess(workDir string) StartProcessResult {
+	res := startProcess(workDir)
+	if res.Error == nil {
+		resetRestartCount(workDir)
+	}
+	return res
+}
+
+// RestartProcess 自动重启 (非人工操作，如配置变更按 restart 策略重载)，累加重启计数
+func RestartProcess(workDir string) StartProcessResult {
+	StopProcess(workDir)
+	res := startProcess(workDir)
+	if res.Error == nil {
+		incrRestartCount(workDir)
+	}
+	return res
+}
+
+func startProcess(workDir string) StartProcessResult {
 	m, err := readManifest(workDir)
 	if err != nil {
 		return StartProcessResult{Status: "error", Error: err}
@@ -243,7 +266,12 @@ func StartProcess(workDir string) StartProcessResult {
 	cmd.Dir = execDir
 	cmd.Env = buildEnv(m.Env)
 
-	logFile, _ := os.Create(filepath.Join(workDir, "app.log"))
+	// 控制台输出经 logpipe 轮转落盘，上一次运行的日志会先被归档而不是截断
+	logFile, err := openConsoleLog(workDir, m)
+	if err != nil {
+		return StartProcessResult{Status: "error", Error: fmt.Errorf("open console log failed: %v", err)}
+	}
+	defer logFile.Close() // 子进程已继承句柄，父进程这一端可以关闭
 	cmd.Stdout = logFile
 	cmd.Stderr = logFile
 
@@ -254,7 +282,6 @@ func StartProcess(workDir string) StartProcessResult {
 	setProcessAttributes(cmd)
 
 	if err := cmd.Start(); err != nil {
-		logFile.Close()
 		return StartProcessResult{Status: "error", Error: fmt.Errorf("start failed: %v", err)}
 	}
 
@@ -283,6 +310,29 @@ func StartProcess(workDir string) StartProcessResult {
 	return StartProcessResult{Status: "running", PID: targetPID, Uptime: time.Now().Unix()}
 }
 
+// 重启计数存放在实例目录的 restart_count 文件中: 只统计自动重启，人工启动与重新部署后清零
+const restartCountFile = "restart_count"
+
+// incrRestartCount 累加自动重启次数
+func incrRestartCount(workDir string) {
+	os.WriteFile(filepath.Join(workDir, restartCountFile), []byte(strconv.Itoa(readRestartCount(workDir)+1)), 0644)
+}
+
+// resetRestartCount 清零重启次数
+func resetRestartCount(workDir string) {
+	os.Remove(filepath.Join(workDir, restartCountFile))
+}
+
+// readRestartCount 读取自动重启次数
+func readRestartCount(workDir string) int {
+	data, err := os.ReadFile(filepath.Join(workDir, restartCountFile))
+	if err != nil {
+		return 0
+	}
+	count, _ := strconv.Atoi(strings.TrimSpace(string(data)))
+	return count
+}
+
 // StopProcess 停止
 func StopProcess(workDir string) (status string, pid int, err error) {
 	m, err := readManifest(workDir)
@@ -5,6 +5,9 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
+
+	"ops-system/pkg/logparse"
+	"ops-system/pkg/protocol"
 )
 
 const LogKeyConsole = "Console Log"
@@ -40,6 +43,50 @@ func GetLogFiles(instID string) ([]string, error) {
 	return files, nil
 }
 
+// GetLogSegments 获取各日志的文件分段 (含轮转历史文件，从旧到新)
+func GetLogSegments(instID string, keys []string) map[string][]protocol.LogSegment {
+	res := make(map[string][]protocol.LogSegment, len(keys))
+	for _, key := range keys {
+		path, err := GetLogPath(instID, key)
+		if err != nil {
+			continue
+		}
+		var segs []protocol.LogSegment
+		for _, file := range LogSegments(path) {
+			fi, err := os.Stat(file)
+			if err != nil {
+				continue // 当前文件可能尚未生成
+			}
+			segs = append(segs, protocol.LogSegment{
+				File:    filepath.Base(file),
+				Size:    fi.Size(),
+				ModTime: fi.ModTime().Unix(),
+			})
+		}
+		res[key] = segs
+	}
+	return res
+}
+
+// GetLogFormat 获取日志格式 (未配置时为 text)
+func GetLogFormat(instID, logKey string) string {
+	workDir, found := FindInstanceDir(instID)
+	if !found {
+		return logparse.FormatText
+	}
+	m, err := readManifest(workDir)
+	if err != nil {
+		return logparse.FormatText
+	}
+	if logKey == "" {
+		logKey = LogKeyConsole
+	}
+	if f := m.LogFormats[logKey]; f != "" {
+		return f
+	}
+	return logparse.FormatText
+}
+
 // GetLogPath 根据日志名称获取物理文件绝对路径
 func GetLogPath(instID string, logKey string) (string, error) {
 	workDir, found := FindInstanceDir(instID)
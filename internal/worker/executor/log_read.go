@@ -0,0 +1,181 @@
+package executor
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"ops-system/pkg/protocol"
+)
+
+const (
+	readChunkSize   = 64 * 1024
+	defaultPageSize = 200
+	maxPageLines    = 2000
+	// 分页读取 .gz 轮转文件时需整体解压到内存，解压后超过该大小时拒绝
+	maxGzipPageSize = 256 << 20
+)
+
+// ResolveLogFile 解析实例日志的物理路径
+// file 为空表示当前文件，否则必须是该日志的某个轮转文件名 (防止通过参数读取任意文件)
+func ResolveLogFile(instID, logKey, file string) (string, error) {
+	path, err := GetLogPath(instID, logKey)
+	if err != nil {
+		return "", err
+	}
+	if file == "" || file == filepath.Base(path) {
+		return path, nil
+	}
+	for _, seg := range LogSegments(path) {
+		if filepath.Base(seg) == file {
+			return seg, nil
+		}
+	}
+	return "", fmt.Errorf("log file '%s' not found", file)
+}
+
+// TailOffset 计算文件最后 n 行的起始偏移量
+func TailOffset(path string, n int) (int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	start, _, err := scanLinesBackward(f, fi.Size(), n)
+	return start, err
+}
+
+// ReadLogPage 从 before 偏移处向前读取最多 lines 行 (before <= 0 表示文件末尾)
+// 用于前端向上滚动加载历史日志，下一页以返回的 Start 作为 before
+func ReadLogPage(path string, before int64, lines int) (*protocol.LogPage, error) {
+	if lines <= 0 {
+		lines = defaultPageSize
+	}
+	if lines > maxPageLines {
+		lines = maxPageLines
+	}
+
+	f, size, err := openPageSource(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if before <= 0 || before > size {
+		before = size
+	}
+
+	start, data, err := scanLinesBackward(f, before, lines)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &protocol.LogPage{
+		File:    filepath.Base(path),
+		Size:    size,
+		Start:   start,
+		End:     before,
+		HasMore: start > 0,
+		Lines:   []string{},
+	}
+	text := string(bytes.TrimSuffix(data, []byte("\n")))
+	if text != "" || len(data) > 0 {
+		for _, line := range bytes.Split([]byte(text), []byte("\n")) {
+			page.Lines = append(page.Lines, string(bytes.TrimSuffix(line, []byte("\r"))))
+		}
+	}
+	return page, nil
+}
+
+// pageSource 支持随机读取的日志内容
+type pageSource interface {
+	io.ReaderAt
+	io.Closer
+}
+
+type gzipPage struct{ *bytes.Reader }
+
+func (gzipPage) Close() error { return nil }
+
+// openPageSource 打开用于分页读取的日志，返回内容与大小
+// .gz 轮转文件解压后读取 (偏移量与大小均指解压后的内容)
+func openPageSource(path string) (pageSource, int64, error) {
+	if !strings.HasSuffix(path, ".gz") {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, 0, err
+		}
+		fi, err := f.Stat()
+		if err != nil {
+			f.Close()
+			return nil, 0, err
+		}
+		return f, fi.Size(), nil
+	}
+
+	rc, err := OpenLogFile(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(io.LimitReader(rc, maxGzipPageSize+1))
+	if err != nil {
+		return nil, 0, fmt.Errorf("decompress %s failed: %v", filepath.Base(path), err)
+	}
+	if len(data) > maxGzipPageSize {
+		return nil, 0, fmt.Errorf("%s is larger than %dMB after decompression, download it instead", filepath.Base(path), maxGzipPageSize>>20)
+	}
+	return gzipPage{bytes.NewReader(data)}, int64(len(data)), nil
+}
+
+// scanLinesBackward 从 end 向前找出 n 行，返回起始偏移与 [start, end) 的内容
+// end 处若不是行首 (即 end-1 不是换行)，末尾的半行也计为一行
+func scanLinesBackward(f io.ReaderAt, end int64, n int) (int64, []byte, error) {
+	if end <= 0 || n <= 0 {
+		return end, nil, nil
+	}
+
+	var buf []byte
+	pos := end
+	newlines := 0
+	// 末尾换行符属于最后一行，不参与计数
+	skipTrailing := true
+
+	for pos > 0 {
+		size := int64(readChunkSize)
+		if pos < size {
+			size = pos
+		}
+		pos -= size
+		chunk := make([]byte, size)
+		if _, err := f.ReadAt(chunk, pos); err != nil && err != io.EOF {
+			return 0, nil, err
+		}
+
+		for i := len(chunk) - 1; i >= 0; i-- {
+			if chunk[i] != '\n' {
+				skipTrailing = false
+				continue
+			}
+			if skipTrailing {
+				skipTrailing = false
+				continue
+			}
+			newlines++
+			if newlines == n {
+				start := pos + int64(i) + 1
+				buf = append(chunk[i+1:], buf...)
+				return start, buf, nil
+			}
+		}
+		buf = append(chunk, buf...)
+	}
+	return 0, buf, nil
+}
@@ -0,0 +1,227 @@
+package executor
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"ops-system/pkg/logparse"
+	"ops-system/pkg/protocol"
+)
+
+const (
+	defaultSearchResults = 500
+	maxSearchResults     = 5000
+	maxSearchLineLen     = 4096 // 单行超出部分截断
+)
+
+// SearchLogs 在本机实例的日志 (含轮转文件) 中按正则检索
+func SearchLogs(req protocol.LogSearchReq) protocol.LogSearchResp {
+	resp := protocol.LogSearchResp{Hits: []protocol.LogSearchHit{}}
+
+	pattern := req.Query
+	if req.IgnoreCase {
+		pattern = "(?i)" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		resp.Errors = append(resp.Errors, fmt.Sprintf("invalid query: %v", err))
+		return resp
+	}
+
+	filter, err := logparse.NewFilter(req.MinLevel, req.Filter)
+	if err != nil {
+		resp.Errors = append(resp.Errors, fmt.Sprintf("invalid filter: %v", err))
+		return resp
+	}
+
+	limit := req.MaxResults
+	if limit <= 0 {
+		limit = defaultSearchResults
+	}
+	if limit > maxSearchResults {
+		limit = maxSearchResults
+	}
+
+	for _, instID := range req.InstanceIDs {
+		path, err := GetLogPath(instID, req.LogKey)
+		if err != nil {
+			resp.Errors = append(resp.Errors, fmt.Sprintf("%s: %v", instID, err))
+			continue
+		}
+		format := GetLogFormat(instID, req.LogKey)
+		for _, file := range LogSegments(path) {
+			// 文件最后修改时间早于起始时间，整段跳过
+			if req.StartTime > 0 {
+				if fi, err := os.Stat(file); err == nil && fi.ModTime().Unix() < req.StartTime {
+					continue
+				}
+			}
+			stream := logparse.NewStream(format, filter)
+			full, err := searchFile(file, instID, re, stream, req.StartTime, req.EndTime, limit-len(resp.Hits), &resp.Hits)
+			if err != nil {
+				resp.Errors = append(resp.Errors, fmt.Sprintf("%s: %v", filepath.Base(file), err))
+			}
+			if full {
+				resp.Truncated = true
+				return resp
+			}
+		}
+	}
+	return resp
+}
+
+// LogSegments 返回日志文件及其轮转文件，按时间从旧到新排列 (当前文件在最后)
+// 识别的轮转命名: app.log.1、app.log.2024-01-02、app.log.1.gz、app-2024-01-02.log(.gz)
+func LogSegments(path string) []string {
+	dir, base := filepath.Split(path)
+	ext := filepath.Ext(base)
+	stem := strings.TrimSuffix(base, ext)
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return []string{path}
+	}
+
+	type seg struct {
+		path  string
+		mtime int64
+	}
+	names := make(map[string]bool, len(entries))
+	for _, e := range entries {
+		names[e.Name()] = true
+	}
+
+	var segs []seg
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || name == base {
+			continue
+		}
+		if !isLogSegment(name, base, stem, ext) {
+			continue
+		}
+		// 压缩过程中原文件与 .gz 可能短暂并存，只保留 .gz
+		if names[name+".gz"] {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil && os.IsNotExist(err) && !strings.HasSuffix(name, ".gz") {
+			// 列目录之后刚被压缩，改用 .gz
+			name += ".gz"
+			info, err = os.Stat(filepath.Join(dir, name))
+		}
+		if err != nil {
+			continue
+		}
+		segs = append(segs, seg{path: filepath.Join(dir, name), mtime: info.ModTime().UnixNano()})
+	}
+	// 修改时间相同 (快速连续轮转) 时按文件名排序，带时间戳的命名即轮转顺序
+	sort.Slice(segs, func(i, j int) bool {
+		if segs[i].mtime != segs[j].mtime {
+			return segs[i].mtime < segs[j].mtime
+		}
+		return segs[i].path < segs[j].path
+	})
+
+	files := make([]string, 0, len(segs)+1)
+	for _, s := range segs {
+		files = append(files, s.path)
+	}
+	return append(files, path)
+}
+
+func isLogSegment(name, base, stem, ext string) bool {
+	if strings.HasSuffix(name, ".tmp") {
+		return false // 压缩中的临时文件
+	}
+	if strings.HasPrefix(name, base+".") {
+		return true
+	}
+	// app-2024-01-02.log: 前缀后紧跟日期，避免误匹配 app-server.log 之类的其它日志
+	rest := strings.TrimPrefix(name, stem+"-")
+	return ext != "" && rest != name && rest != "" && rest[0] >= '0' && rest[0] <= '9' && strings.Contains(rest, ext)
+}
+
+// OpenLogFile 打开日志文件，.gz 文件自动解压
+func OpenLogFile(path string) (io.ReadCloser, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(path, ".gz") {
+		return f, nil
+	}
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{gz, f}, nil
+}
+
+// searchFile 逐行匹配，结果追加到 hits；返回值表示是否已达到上限
+// stream 负责按日志格式解析与结构化过滤，正则匹配作用于原始行
+func searchFile(path, instID string, re *regexp.Regexp, stream *logparse.Stream, start, end int64, remain int, hits *[]protocol.LogSearchHit) (bool, error) {
+	if remain <= 0 {
+		return true, nil
+	}
+	rc, err := OpenLogFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	defer rc.Close()
+
+	scanner := bufio.NewScanner(rc)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var lineTime int64 // 没有时间戳的行 (如异常堆栈) 沿用上一行的时间
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := scanner.Text()
+		entry, keep := stream.Feed(line)
+		if entry.Time > 0 {
+			lineTime = entry.Time
+		}
+		if lineTime > 0 {
+			if start > 0 && lineTime < start {
+				continue
+			}
+			if end > 0 && lineTime > end {
+				break // 日志按时间追加，后续行都在窗口之外
+			}
+		}
+		if !keep || !re.MatchString(line) {
+			continue
+		}
+		if len(line) > maxSearchLineLen {
+			line = line[:maxSearchLineLen] + "..."
+		}
+		*hits = append(*hits, protocol.LogSearchHit{
+			InstanceID: instID,
+			File:       filepath.Base(path),
+			LineNo:     lineNo,
+			Time:       lineTime,
+			Line:       line,
+			Level:      entry.Level,
+			Fields:     entry.Fields,
+		})
+		if remain--; remain == 0 {
+			return true, nil
+		}
+	}
+	return false, scanner.Err()
+}
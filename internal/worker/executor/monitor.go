@@ -14,16 +14,17 @@ import (
 	"github.com/shirou/gopsutil/v3/process"
 )
 
-// 用于计算 IO 速率的缓存
-type ioStatCache struct {
+// 用于计算 CPU / IO 速率的缓存 (按整个进程树累计)
+type procStatCache struct {
+	CPUSeconds    float64
 	BytesRead     uint64
 	BytesWrite    uint64
 	LastCheckTime time.Time
 }
 
 var (
-	ioCache         = make(map[string]*ioStatCache) // key: InstanceID
-	cachedMasterURL string                          // 缓存 Master 地址，供 ReportStatus 使用
+	statCache       = make(map[string]*procStatCache) // key: InstanceID
+	cachedMasterURL string                            // 缓存 Master 地址，供 ReportStatus 使用
 )
 
 // StartMonitor 启动后台监控协程
@@ -46,8 +47,21 @@ func ReportStatus(instID, status string, pid int, uptime int64) {
 	if cachedMasterURL == "" {
 		return
 	}
-	// 填入 0 的监控数据
-	reportStatus(cachedMasterURL, instID, status, pid, uptime, 0, 0, 0, 0)
+	reportStatus(cachedMasterURL, protocol.InstanceStatusReport{
+		InstanceID: instID,
+		Status:     status,
+		PID:        pid,
+		Uptime:     uptime,
+	})
+}
+
+// ReportTask 上报子任务进度 (部署请求未携带任务 ID 时忽略，兼容旧版 Master)
+func ReportTask(report protocol.TaskReport) {
+	if cachedMasterURL == "" || report.TaskID == "" {
+		return
+	}
+	jsonData, _ := json.Marshal(report)
+	_ = utils.PostJSON(fmt.Sprintf("%s/api/tasks/report", cachedMasterURL), jsonData)
 }
 
 // checkAndReport 内部轮询逻辑
@@ -61,7 +75,7 @@ func checkAndReport(masterURL string) {
 		data, err := os.ReadFile(pidPath)
 		if err != nil {
 			// 没有 PID 文件，说明是停止状态，清理缓存并跳过
-			delete(ioCache, inst.InstanceID)
+			delete(statCache, inst.InstanceID)
 			continue
 		}
 
@@ -74,74 +88,145 @@ func checkAndReport(masterURL string) {
 		proc, err := process.NewProcess(int32(pidInt))
 		if err != nil {
 			// 进程不存在 (僵尸 PID 文件)，视为停止
-			// 9个参数补全
-			reportStatus(masterURL, inst.InstanceID, "stopped", 0, 0, 0, 0, 0, 0)
+			delete(statCache, inst.InstanceID)
+			reportStatus(masterURL, protocol.InstanceStatusReport{InstanceID: inst.InstanceID, Status: "stopped"})
 			continue
 		}
 
-		// 4. 采集指标
+		// 4. 采集整个进程树的指标
+		report := collectProcessTree(inst.InstanceID, proc)
+		report.InstanceID = inst.InstanceID
+		report.Status = "running"
+		report.PID = pidInt
+		report.Restarts = readRestartCount(inst.WorkDir)
+
+		// 5. 发送上报 (Running 状态)
+		reportStatus(masterURL, report)
+	}
+}
+
+// collectProcessTree 汇总主进程及其全部子孙进程的资源占用
+// nginx / gunicorn 这类 fork worker 的服务，真实负载主要在子进程上
+func collectProcessTree(instID string, root *process.Process) protocol.InstanceStatusReport {
+	var report protocol.InstanceStatusReport
+
+	// 4.1 启动时间 (以主进程为准)
+	createTime, _ := root.CreateTime() // 毫秒
+	report.Uptime = createTime / 1000
+
+	// 4.2 FD 上限 (以主进程为准)
+	if limits, err := root.Rlimit(); err == nil {
+		for _, l := range limits {
+			if l.Resource == process.RLIMIT_NOFILE {
+				report.FDLimit = l.Soft
+				break
+			}
+		}
+	}
+
+	var cpuSeconds float64
+	var rssBytes, readBytes, writeBytes uint64
 
-		// 4.1 CPU (非阻塞)
-		cpuPercent, _ := proc.CPUPercent()
+	for _, p := range processTree(root) {
+		report.ProcessCount++
 
-		// 4.2 内存
-		memInfo, _ := proc.MemoryInfo()
-		memUsageMB := uint64(0)
-		if memInfo != nil {
-			memUsageMB = memInfo.RSS / 1024 / 1024 // RSS 转 MB
+		if t, err := p.Times(); err == nil {
+			cpuSeconds += t.User + t.System
 		}
+		if memInfo, err := p.MemoryInfo(); err == nil && memInfo != nil {
+			rssBytes += memInfo.RSS
+		}
+		if io, err := p.IOCounters(); err == nil && io != nil {
+			readBytes += io.ReadBytes
+			writeBytes += io.WriteBytes
+		}
+		if n, err := p.NumThreads(); err == nil {
+			report.Threads += int(n)
+		}
+		if n, err := p.NumFDs(); err == nil {
+			report.FDs += int(n)
+		}
+		if cs, err := p.NumCtxSwitches(); err == nil && cs != nil {
+			report.CtxSwitchVol += uint64(cs.Voluntary)
+			report.CtxSwitchInvol += uint64(cs.Involuntary)
+		}
+		report.ListenPorts = append(report.ListenPorts, listenPorts(p)...)
+	}
 
-		// 4.3 启动时间
-		createTime, _ := proc.CreateTime() // 毫秒
-		// 修复之前的未使用变量错误，直接使用
-		startTimeUnix := createTime / 1000
-
-		// 4.4 IO 速率计算
-		ioCounters, _ := proc.IOCounters()
-		var ioReadSpeed, ioWriteSpeed uint64
-
-		if ioCounters != nil {
-			now := time.Now()
-			if last, ok := ioCache[inst.InstanceID]; ok {
-				duration := now.Sub(last.LastCheckTime).Seconds()
-				if duration > 0 {
-					if ioCounters.ReadBytes >= last.BytesRead {
-						ioReadSpeed = uint64(float64(ioCounters.ReadBytes-last.BytesRead) / duration / 1024)
-					}
-					if ioCounters.WriteBytes >= last.BytesWrite {
-						ioWriteSpeed = uint64(float64(ioCounters.WriteBytes-last.BytesWrite) / duration / 1024)
-					}
-				}
-				last.BytesRead = ioCounters.ReadBytes
-				last.BytesWrite = ioCounters.WriteBytes
-				last.LastCheckTime = now
-			} else {
-				ioCache[inst.InstanceID] = &ioStatCache{
-					BytesRead:     ioCounters.ReadBytes,
-					BytesWrite:    ioCounters.WriteBytes,
-					LastCheckTime: now,
-				}
+	report.MemUsage = rssBytes / 1024 / 1024 // RSS 转 MB
+
+	// 4.3 CPU / IO 速率计算 (基于两次采样差值)
+	now := time.Now()
+	if last, ok := statCache[instID]; ok {
+		duration := now.Sub(last.LastCheckTime).Seconds()
+		if duration > 0 {
+			if cpuSeconds >= last.CPUSeconds {
+				report.CpuUsage = (cpuSeconds - last.CPUSeconds) / duration * 100
+			}
+			if readBytes >= last.BytesRead {
+				report.IoRead = uint64(float64(readBytes-last.BytesRead) / duration / 1024)
+			}
+			if writeBytes >= last.BytesWrite {
+				report.IoWrite = uint64(float64(writeBytes-last.BytesWrite) / duration / 1024)
 			}
 		}
+	}
+	statCache[instID] = &procStatCache{
+		CPUSeconds:    cpuSeconds,
+		BytesRead:     readBytes,
+		BytesWrite:    writeBytes,
+		LastCheckTime: now,
+	}
 
-		// 5. 发送上报 (Running 状态)
-		reportStatus(masterURL, inst.InstanceID, "running", pidInt, startTimeUnix, cpuPercent, memUsageMB, ioReadSpeed, ioWriteSpeed)
+	return report
+}
+
+// processTree 广度优先遍历，返回主进程及所有子孙进程
+func processTree(root *process.Process) []*process.Process {
+	list := []*process.Process{root}
+	seen := map[int32]bool{root.Pid: true}
+	for i := 0; i < len(list); i++ {
+		children, err := list[i].Children()
+		if err != nil {
+			continue
+		}
+		for _, c := range children {
+			if seen[c.Pid] {
+				continue
+			}
+			seen[c.Pid] = true
+			list = append(list, c)
+		}
 	}
+	return list
 }
 
-// 内部底层上报逻辑
-func reportStatus(masterBaseURL, instID, status string, pid int, uptime int64, cpu float64, mem, ioRead, ioWrite uint64) {
-	report := protocol.InstanceStatusReport{
-		InstanceID: instID,
-		Status:     status,
-		PID:        pid,
-		Uptime:     uptime,
-		CpuUsage:   cpu,
-		MemUsage:   mem,
-		IoRead:     ioRead,
-		IoWrite:    ioWrite,
+// listenPorts 获取进程监听的 TCP/UDP 端口
+func listenPorts(p *process.Process) []protocol.ListenPort {
+	conns, err := p.Connections()
+	if err != nil {
+		return nil
 	}
+	var ports []protocol.ListenPort
+	for _, c := range conns {
+		switch c.Type {
+		case 1: // SOCK_STREAM
+			if c.Status != "LISTEN" {
+				continue
+			}
+			ports = append(ports, protocol.ListenPort{Proto: "tcp", IP: c.Laddr.IP, Port: c.Laddr.Port, PID: p.Pid})
+		case 2: // SOCK_DGRAM: 未 connect 的 UDP socket 视为监听
+			if c.Laddr.Port == 0 || c.Raddr.Port != 0 {
+				continue
+			}
+			ports = append(ports, protocol.ListenPort{Proto: "udp", IP: c.Laddr.IP, Port: c.Laddr.Port, PID: p.Pid})
+		}
+	}
+	return ports
+}
 
+// 内部底层上报逻辑
+func reportStatus(masterBaseURL string, report protocol.InstanceStatusReport) {
 	url := fmt.Sprintf("%s/api/instance/status_report", masterBaseURL)
 	jsonData, _ := json.Marshal(report)
 
@@ -0,0 +1,97 @@
+package executor_test
+
+import (
+	"net"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"testing"
+	"time"
+
+	"ops-system/internal/worker/executor"
+	"ops-system/pkg/protocol"
+
+	"github.com/shirou/gopsutil/v3/process"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCollectProcessTree(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires sh")
+	}
+	// 主进程 fork 出两个子进程，指标按整棵进程树汇总
+	cmd := exec.Command("sh", "-c", "sleep 30 & sleep 30 & wait")
+	if !assert.NoError(t, cmd.Start()) {
+		return
+	}
+	defer func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	}()
+	root, err := process.NewProcess(int32(cmd.Process.Pid))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	var report protocol.InstanceStatusReport
+	for i := 0; i < 50; i++ {
+		if report = executor.CollectProcessTree("inst-tree", root); report.ProcessCount >= 3 {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	assert.Equal(t, 3, report.ProcessCount)
+	assert.GreaterOrEqual(t, report.Threads, 3)
+	assert.Greater(t, report.Uptime, int64(0))
+	if runtime.GOOS == "linux" {
+		assert.GreaterOrEqual(t, report.FDs, 3)
+		assert.Greater(t, report.FDLimit, uint64(0))
+	}
+}
+
+func TestListenPorts(t *testing.T) {
+	tcp, err := net.Listen("tcp", "127.0.0.1:0")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer tcp.Close()
+	udp, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer udp.Close()
+	// 已 connect 的 UDP socket 不是监听端口
+	dialed, err := net.Dial("udp", "127.0.0.1:9")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer dialed.Close()
+
+	self, err := process.NewProcess(int32(os.Getpid()))
+	if !assert.NoError(t, err) {
+		return
+	}
+	ports := map[string]bool{}
+	for _, p := range executor.ListenPorts(self) {
+		assert.Equal(t, int32(os.Getpid()), p.PID)
+		ports[p.Proto+":"+net.JoinHostPort(p.IP, strconv.FormatUint(uint64(p.Port), 10))] = true
+	}
+	assert.True(t, ports["tcp:"+tcp.Addr().String()], "tcp listener missing: %v", ports)
+	assert.True(t, ports["udp:"+udp.LocalAddr().String()], "udp socket missing: %v", ports)
+	assert.False(t, ports["udp:"+dialed.LocalAddr().String()])
+}
+
+func TestRestartCount(t *testing.T) {
+	dir := t.TempDir()
+	assert.Equal(t, 0, executor.ReadRestartCount(dir))
+
+	// 自动重启累加，人工启动清零
+	executor.IncrRestartCount(dir)
+	executor.IncrRestartCount(dir)
+	assert.Equal(t, 2, executor.ReadRestartCount(dir))
+	executor.ResetRestartCount(dir)
+	assert.Equal(t, 0, executor.ReadRestartCount(dir))
+	executor.IncrRestartCount(dir)
+	assert.Equal(t, 1, executor.ReadRestartCount(dir))
+}
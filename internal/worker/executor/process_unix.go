@@ -1,7 +1,11 @@
+//go:build !windows
+
 package executor
 
 import (
+	"fmt"
 	"os/exec"
+	"strings"
 	"syscall"
 )
 
@@ -12,3 +16,25 @@ func setProcessAttributes(cmd *exec.Cmd) {
 		Setsid: true,
 	}
 }
+
+// reloadSignals 配置重载支持的信号
+var reloadSignals = map[string]syscall.Signal{
+	"HUP":  syscall.SIGHUP,
+	"USR1": syscall.SIGUSR1,
+	"USR2": syscall.SIGUSR2,
+	"INT":  syscall.SIGINT,
+	"TERM": syscall.SIGTERM,
+	"QUIT": syscall.SIGQUIT,
+}
+
+// signalProcess 向实例进程发送信号 (名称不区分大小写，可带 SIG 前缀)
+func signalProcess(pid int, name string) error {
+	sig, ok := reloadSignals[strings.TrimPrefix(strings.ToUpper(name), "SIG")]
+	if !ok {
+		return fmt.Errorf("unsupported signal: %s", name)
+	}
+	if pid <= 0 {
+		return fmt.Errorf("invalid pid: %d", pid)
+	}
+	return syscall.Kill(pid, sig)
+}
@@ -1,6 +1,7 @@
 package executor
 
 import (
+	"fmt"
 	"os/exec"
 	"syscall"
 )
@@ -12,3 +13,8 @@ func setProcessAttributes(cmd *exec.Cmd) {
 		CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP,
 	}
 }
+
+// signalProcess Windows 不支持 Unix 信号，需改用 restart 策略
+func signalProcess(pid int, name string) error {
+	return fmt.Errorf("signal reload is not supported on windows, use restart instead")
+}
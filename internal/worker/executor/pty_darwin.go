@@ -0,0 +1,47 @@
+package executor
+
+import (
+	"bytes"
+	"os"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// openPTY 打开一对伪终端 (等价于 posix_openpt + grantpt + unlockpt + ptsname)
+func openPTY() (ptm, pts *os.File, err error) {
+	ptm, err = os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_NOCTTY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	raw, err := ptm.SyscallConn()
+	if err != nil {
+		ptm.Close()
+		return nil, nil, err
+	}
+	var name [128]byte
+	var ioErr error
+	raw.Control(func(fd uintptr) {
+		for _, req := range []uintptr{unix.TIOCPTYGRANT, unix.TIOCPTYUNLK} {
+			if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, req, 0); errno != 0 {
+				ioErr = errno
+				return
+			}
+		}
+		if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, unix.TIOCPTYGNAME, uintptr(unsafe.Pointer(&name[0]))); errno != 0 {
+			ioErr = errno
+		}
+	})
+	if ioErr != nil {
+		ptm.Close()
+		return nil, nil, ioErr
+	}
+	path := string(name[:bytes.IndexByte(name[:], 0)])
+	pts, err = os.OpenFile(path, os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		ptm.Close()
+		return nil, nil, err
+	}
+	return ptm, pts, nil
+}
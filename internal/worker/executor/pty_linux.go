@@ -0,0 +1,40 @@
+package executor
+
+import (
+	"os"
+	"strconv"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// openPTY 打开一对伪终端 (主设备保持非阻塞，关闭时可中断读取)
+func openPTY() (ptm, pts *os.File, err error) {
+	ptm, err = os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_NOCTTY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	raw, err := ptm.SyscallConn()
+	if err != nil {
+		ptm.Close()
+		return nil, nil, err
+	}
+	var n uint32
+	var ioErr error
+	raw.Control(func(fd uintptr) {
+		if ioErr = unix.IoctlSetPointerInt(int(fd), unix.TIOCSPTLCK, 0); ioErr != nil {
+			return
+		}
+		n, ioErr = unix.IoctlGetUint32(int(fd), unix.TIOCGPTN)
+	})
+	if ioErr != nil {
+		ptm.Close()
+		return nil, nil, ioErr
+	}
+	pts, err = os.OpenFile("/dev/pts/"+strconv.Itoa(int(n)), os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		ptm.Close()
+		return nil, nil, err
+	}
+	return ptm, pts, nil
+}
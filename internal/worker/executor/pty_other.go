@@ -0,0 +1,9 @@
+//go:build !windows && !linux && !darwin
+
+package executor
+
+import "os"
+
+func openPTY() (ptm, pts *os.File, err error) {
+	return nil, nil, ErrTerminalUnsupported
+}
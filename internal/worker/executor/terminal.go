@@ -0,0 +1,15 @@
+package executor
+
+import "errors"
+
+// ErrTerminalUnsupported 当前系统不支持 PTY 终端
+var ErrTerminalUnsupported = errors.New("当前系统不支持终端")
+
+// InstanceShellDir 实例终端的工作目录：托管实例为实例目录，纳管服务为其实际工作目录
+func InstanceShellDir(instID string) (string, bool) {
+	workDir, ok := FindInstanceDir(instID)
+	if !ok {
+		return "", false
+	}
+	return configBaseDir(workDir), true
+}
@@ -0,0 +1,86 @@
+//go:build !windows
+
+package executor
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// Terminal 运行在 PTY 中的交互式 Shell
+type Terminal struct {
+	pty *os.File
+	cmd *exec.Cmd
+}
+
+// StartTerminal 在 dir 下启动登录用户的 Shell ($SHELL，默认 bash/sh)，终端大小为 cols x rows
+func StartTerminal(dir string, cols, rows uint16) (*Terminal, error) {
+	ptm, pts, err := openPTY()
+	if err != nil {
+		return nil, err
+	}
+	defer pts.Close()
+
+	cmd := exec.Command(defaultShell())
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "TERM=xterm-256color")
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = pts, pts, pts
+	// 新会话并以 PTY 为控制终端，Shell 的作业控制与 Ctrl+C 才能正常工作
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true, Setctty: true}
+
+	t := &Terminal{pty: ptm, cmd: cmd}
+	t.Resize(cols, rows)
+	if err := cmd.Start(); err != nil {
+		ptm.Close()
+		return nil, err
+	}
+	return t, nil
+}
+
+func defaultShell() string {
+	if sh := os.Getenv("SHELL"); sh != "" {
+		return sh
+	}
+	if _, err := os.Stat("/bin/bash"); err == nil {
+		return "/bin/bash"
+	}
+	return "/bin/sh"
+}
+
+func (t *Terminal) Read(p []byte) (int, error)  { return t.pty.Read(p) }
+func (t *Terminal) Write(p []byte) (int, error) { return t.pty.Write(p) }
+
+// Resize 调整终端大小 (0 表示保持不变)
+func (t *Terminal) Resize(cols, rows uint16) error {
+	if cols == 0 || rows == 0 {
+		return nil
+	}
+	raw, err := t.pty.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var ioErr error
+	err = raw.Control(func(fd uintptr) {
+		ioErr = unix.IoctlSetWinsize(int(fd), unix.TIOCSWINSZ, &unix.Winsize{Col: cols, Row: rows})
+	})
+	if err != nil {
+		return err
+	}
+	return ioErr
+}
+
+// Wait 等待 Shell 退出
+func (t *Terminal) Wait() error {
+	return t.cmd.Wait()
+}
+
+// Close 挂断终端: 向整个会话发送 SIGHUP (包括 Shell 启动的前台/后台任务)，并关闭 PTY
+func (t *Terminal) Close() error {
+	if t.cmd.Process != nil {
+		syscall.Kill(-t.cmd.Process.Pid, syscall.SIGHUP)
+	}
+	return t.pty.Close()
+}
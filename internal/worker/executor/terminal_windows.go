@@ -0,0 +1,14 @@
+package executor
+
+// Terminal Windows 暂不支持 PTY 终端
+type Terminal struct{}
+
+func StartTerminal(dir string, cols, rows uint16) (*Terminal, error) {
+	return nil, ErrTerminalUnsupported
+}
+
+func (t *Terminal) Read(p []byte) (int, error)     { return 0, ErrTerminalUnsupported }
+func (t *Terminal) Write(p []byte) (int, error)    { return 0, ErrTerminalUnsupported }
+func (t *Terminal) Resize(cols, rows uint16) error { return nil }
+func (t *Terminal) Wait() error                    { return nil }
+func (t *Terminal) Close() error                   { return nil }
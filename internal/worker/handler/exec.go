@@ -0,0 +1,158 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"runtime"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"ops-system/pkg/protocol"
+)
+
+const pipeWaitDelay = 2 * time.Second
+
+// shellCommand 构造在系统 Shell 中执行的命令，并校验工作目录
+// 命令被终止后最多再等待 pipeWaitDelay 关闭输出管道 (防止残留子进程持有管道导致永久阻塞)
+func shellCommand(ctx context.Context, req protocol.CommandRequest) (*exec.Cmd, error) {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", req.Command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", req.Command)
+	}
+	if req.WorkDir != "" {
+		info, err := os.Stat(req.WorkDir)
+		if err != nil {
+			return nil, fmt.Errorf("工作目录不可用: %v", err)
+		}
+		if !info.IsDir() {
+			return nil, fmt.Errorf("工作目录不是目录: %s", req.WorkDir)
+		}
+		cmd.Dir = req.WorkDir
+	}
+	cmd.WaitDelay = pipeWaitDelay
+	return cmd, nil
+}
+
+// commandContext 按请求的超时 (秒) 派生上下文，0 表示不限制
+func commandContext(parent context.Context, timeout int) (context.Context, context.CancelFunc) {
+	if timeout > 0 {
+		return context.WithTimeout(parent, time.Duration(timeout)*time.Second)
+	}
+	return context.WithCancel(parent)
+}
+
+// commandError 命令执行错误的描述，超时单独标注
+func commandError(ctx context.Context, err error) string {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return "执行超时"
+	}
+	return err.Error()
+}
+
+// execEventWriter 将命令输出编码为 ExecEvent 逐行写出 (NDJSON)，stdout/stderr 共用一个实例
+type execEventWriter struct {
+	mu      sync.Mutex
+	enc     *json.Encoder
+	flusher http.Flusher
+}
+
+func (ew *execEventWriter) emit(ev protocol.ExecEvent) {
+	ew.mu.Lock()
+	defer ew.mu.Unlock()
+	ew.enc.Encode(ev)
+	if ew.flusher != nil {
+		ew.flusher.Flush()
+	}
+}
+
+// streamWriter 单个输出流，末尾不完整的 UTF-8 字符留到下一次写入，避免被截断成乱码
+type streamWriter struct {
+	ew      *execEventWriter
+	stream  string
+	pending []byte
+}
+
+func (sw *streamWriter) Write(p []byte) (int, error) {
+	buf := append(sw.pending, p...)
+	cut := len(buf)
+	for i := 1; i < utf8.UTFMax && i <= len(buf); i++ {
+		if utf8.RuneStart(buf[len(buf)-i]) {
+			if !utf8.FullRune(buf[len(buf)-i:]) {
+				cut = len(buf) - i
+			}
+			break
+		}
+	}
+	sw.pending = append([]byte(nil), buf[cut:]...)
+	if cut > 0 {
+		sw.ew.emit(protocol.ExecEvent{Type: "output", Stream: sw.stream, Data: string(buf[:cut])})
+	}
+	return len(p), nil
+}
+
+func (sw *streamWriter) flush() {
+	if len(sw.pending) > 0 {
+		sw.ew.emit(protocol.ExecEvent{Type: "output", Stream: sw.stream, Data: string(sw.pending)})
+		sw.pending = nil
+	}
+}
+
+// handleExecStream 流式执行命令: 输出以 NDJSON 事件实时返回，最后一条为 exit 事件 (含退出码)
+// 调用方断开连接时终止命令
+// POST /api/exec/stream
+func handleExecStream(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", 405)
+		return
+	}
+	var req protocol.CommandRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Command == "" {
+		http.Error(w, "Invalid request", 400)
+		return
+	}
+	if err := checkCommand(req.Command); err != nil {
+		log.Printf("[Exec] Denied %q: %v", req.Command, err)
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	ctx, cancel := commandContext(r.Context(), req.Timeout)
+	defer cancel()
+	cmd, err := shellCommand(ctx, req)
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	flusher, _ := w.(http.Flusher)
+	ew := &execEventWriter{enc: json.NewEncoder(w), flusher: flusher}
+	stdout := &streamWriter{ew: ew, stream: "stdout"}
+	stderr := &streamWriter{ew: ew, stream: "stderr"}
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+
+	err = cmd.Run()
+	stdout.flush()
+	stderr.flush()
+
+	exit := protocol.ExecEvent{Type: "exit"}
+	if cmd.ProcessState != nil {
+		code := cmd.ProcessState.ExitCode()
+		exit.ExitCode = &code
+	}
+	// 正常退出的非零状态由退出码体现，仅记录超时、启动失败等错误
+	var exitErr *exec.ExitError
+	if err != nil && (!errors.As(err, &exitErr) || ctx.Err() != nil) {
+		exit.Error = commandError(ctx, err)
+	}
+	ew.emit(exit)
+}
@@ -1,12 +1,18 @@
 package handler
 
 import (
+	"compress/gzip"
+	"encoding/json"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"os"
+	"path/filepath"
+	"strconv"
 
 	"ops-system/internal/worker/executor"
+	"ops-system/pkg/logparse"
 
 	"github.com/gorilla/websocket"
 	"github.com/hpcloud/tail"
@@ -16,8 +22,13 @@ var upgrader = websocket.Upgrader{
 	CheckOrigin: func(r *http.Request) bool { return true },
 }
 
+// defaultTailLines 打开日志时默认回放的行数
+const defaultTailLines = 200
+
 // handleLogStream 处理日志 WebSocket 连接
-// URL: /api/log/ws?instance_id=...&log_key=...
+// URL: /api/log/ws?instance_id=...&log_key=...&lines=200&min_level=warn&filter=request_id=abc&structured=1
+// min_level/filter 按 service.json 中 log_formats 配置的格式解析后在本地过滤；
+// structured=1 时每条消息为 logparse.Entry 的 JSON，否则为原始行
 func handleLogStream(w http.ResponseWriter, r *http.Request) {
 	// 1. 升级 WS
 	conn, err := upgrader.Upgrade(w, r, nil)
@@ -31,6 +42,15 @@ func handleLogStream(w http.ResponseWriter, r *http.Request) {
 	instID := r.URL.Query().Get("instance_id")
 	logKey := r.URL.Query().Get("log_key") // e.g. "Console Log"
 
+	q := r.URL.Query()
+	filter, err := logparse.NewFilter(q.Get("min_level"), q.Get("filter"))
+	if err != nil {
+		conn.WriteMessage(websocket.TextMessage, []byte("Filter Error: "+err.Error()))
+		return
+	}
+	structured := q.Get("structured") == "1"
+	stream := logparse.NewStream(executor.GetLogFormat(instID, logKey), filter)
+
 	// 3. 获取物理路径
 	logPath, err := executor.GetLogPath(instID, logKey)
 	if err != nil {
@@ -44,13 +64,24 @@ func handleLogStream(w http.ResponseWriter, r *http.Request) {
 		conn.WriteMessage(websocket.TextMessage, []byte(fmt.Sprintf("Waiting for log file to be created: %s...\n", logPath)))
 	}
 
-	// 5. 开始 Tail
+	// 5. 计算起始位置：先回放最后 N 行，再持续跟踪
+	lines := defaultTailLines
+	if v, err := strconv.Atoi(r.URL.Query().Get("lines")); err == nil && v >= 0 {
+		lines = v
+	}
+	var location *tail.SeekInfo
+	if offset, err := executor.TailOffset(logPath, lines); err == nil {
+		location = &tail.SeekInfo{Offset: offset, Whence: io.SeekStart}
+	}
+
+	// 6. 开始 Tail
 	// Config: Follow=true (持续监听), ReOpen=true (支持日志轮转/文件重建), MustExist=false (容忍文件暂不存在)
 	t, err := tail.TailFile(logPath, tail.Config{
 		Follow:    true,
 		ReOpen:    true,
 		MustExist: false,
 		Poll:      true, // Windows下建议开启 Poll 模式以获得更好兼容性
+		Location:  location,
 	})
 	if err != nil {
 		conn.WriteMessage(websocket.TextMessage, []byte("Tail Error: "+err.Error()))
@@ -60,7 +91,7 @@ func handleLogStream(w http.ResponseWriter, r *http.Request) {
 	// 清理函数
 	defer t.Stop()
 
-	// 6. 循环推送
+	// 7. 循环推送
 	// 启动一个协程监听客户端关闭，以便退出 tail 循环
 	go func() {
 		for {
@@ -72,8 +103,76 @@ func handleLogStream(w http.ResponseWriter, r *http.Request) {
 	}()
 
 	for line := range t.Lines {
-		if err := conn.WriteMessage(websocket.TextMessage, []byte(line.Text)); err != nil {
+		entry, keep := stream.Feed(line.Text)
+		if !keep {
+			continue
+		}
+		msg := []byte(line.Text)
+		if structured {
+			msg, _ = json.Marshal(entry)
+		}
+		if err := conn.WriteMessage(websocket.TextMessage, msg); err != nil {
 			break // 发送失败（客户端断开），退出
 		}
 	}
 }
+
+// handleLogPage 向前分页读取历史日志
+// URL: /api/log/page?instance_id=...&log_key=...&file=...&before=...&lines=...
+func handleLogPage(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	path, err := executor.ResolveLogFile(q.Get("instance_id"), q.Get("log_key"), q.Get("file"))
+	if err != nil {
+		http.Error(w, err.Error(), 404)
+		return
+	}
+
+	before, _ := strconv.ParseInt(q.Get("before"), 10, 64)
+	lines, _ := strconv.Atoi(q.Get("lines"))
+	page, err := executor.ReadLogPage(path, before, lines)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
+}
+
+// handleLogDownload 下载完整日志文件 (gzip=1 时压缩传输)
+// URL: /api/log/download?instance_id=...&log_key=...&file=...&gzip=1
+func handleLogDownload(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	path, err := executor.ResolveLogFile(q.Get("instance_id"), q.Get("log_key"), q.Get("file"))
+	if err != nil {
+		http.Error(w, err.Error(), 404)
+		return
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		http.Error(w, err.Error(), 404)
+		return
+	}
+	defer f.Close()
+
+	name := filepath.Base(path)
+	// 已经是压缩文件的轮转日志直接原样传输
+	if q.Get("gzip") != "1" || filepath.Ext(name) == ".gz" {
+		if fi, err := f.Stat(); err == nil {
+			w.Header().Set("Content-Length", strconv.FormatInt(fi.Size(), 10))
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+		io.Copy(w, f)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name+".gz"))
+	gz := gzip.NewWriter(w)
+	if _, err := io.Copy(gz, f); err != nil {
+		log.Printf("[LogDownload] %s: %v", path, err)
+	}
+	gz.Close()
+}
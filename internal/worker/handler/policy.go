@@ -0,0 +1,36 @@
+package handler
+
+import (
+	"errors"
+	"log"
+	"sync/atomic"
+
+	"ops-system/pkg/policy"
+)
+
+// execPolicy 当前生效的执行策略
+// Worker 无法识别用户，只执行拒绝规则 (内置规则 + Master 同步的规则)，角色允许列表与审批由 Master 判定
+var execPolicy atomic.Pointer[policy.Engine]
+
+func init() {
+	engine, _ := policy.New(policy.Rules{})
+	execPolicy.Store(engine)
+}
+
+// SetExecPolicy 应用 Master 同步的拒绝规则，规则无效时保留当前策略
+func SetExecPolicy(deny []string) {
+	engine, err := policy.New(policy.Rules{Deny: deny})
+	if err != nil {
+		log.Printf("[Policy] Ignore invalid exec policy: %v", err)
+		return
+	}
+	execPolicy.Store(engine)
+}
+
+// checkCommand 命令命中拒绝规则时返回错误
+func checkCommand(command string) error {
+	if res := execPolicy.Load().Check(command, ""); res.Decision == policy.Deny {
+		return errors.New("命令被执行策略拒绝: " + res.Reason)
+	}
+	return nil
+}
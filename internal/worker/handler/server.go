@@ -7,12 +7,14 @@ import (
 	"io"
 	"log"
 	"net/http"
-	"os/exec" // 用于执行系统命令
-	"runtime" // 用于判断操作系统
+	"sync"
 	"time"
 
+	"ops-system/internal/worker/agent"
 	"ops-system/internal/worker/executor"
+	"ops-system/pkg/nodeauth"
 	"ops-system/pkg/protocol"
+	"ops-system/pkg/utils"
 )
 
 var masterBaseURL string // 存储 Master 地址
@@ -23,16 +25,79 @@ func InitHandler(url string) {
 }
 
 // StartWorkerServer 启动 Worker HTTP Server
-func StartWorkerServer(port string) {
-	http.HandleFunc("/api/exec", handleExec)
-	http.HandleFunc("/api/deploy", handleDeploy)
-	http.HandleFunc("/api/instance/action", handleInstanceAction) // 处理实例启停
-	http.HandleFunc("/api/external/register", handleRegisterExternal)
+var routesOnce sync.Once
+
+// Routes 注册 Worker 接口并返回处理器 (本地监听与反向通道共用)
+func Routes() http.Handler {
+	routesOnce.Do(func() {
+		// 执行命令、部署、启停、纳管与配置下发均可在节点上运行任意程序，仅接受 Master 签发的令牌
+		http.HandleFunc("/api/exec", requireMasterToken(handleExec))
+		http.HandleFunc("/api/exec/stream", requireMasterToken(handleExecStream)) // 流式执行 (批量命令)
+		http.HandleFunc("/api/deploy", requireMasterToken(handleDeploy))
+		http.HandleFunc("/api/instance/action", requireMasterToken(handleInstanceAction)) // 处理实例启停
+		http.HandleFunc("/api/external/register", requireMasterToken(handleRegisterExternal))
+		http.HandleFunc("/api/instance/config", requireMasterToken(handleInstanceConfig)) // 配置下发
+		http.HandleFunc("/api/agent/upgrade", handleAgentUpgrade)                         // Worker 自升级，仅接受 Master 签发的令牌
 
-	http.HandleFunc("/api/log/ws", handleLogStream)
-	http.HandleFunc("/api/log/files", handleGetLogFiles)
+		http.HandleFunc("/api/terminal/ws", requireMasterToken(handleTerminal)) // Web 终端 (PTY)，仅接受 Master 签发的令牌
+
+		http.HandleFunc("/api/log/ws", handleLogStream)
+		http.HandleFunc("/api/log/files", handleGetLogFiles)
+		http.HandleFunc("/api/log/search", handleLogSearch)
+		http.HandleFunc("/api/log/page", handleLogPage)
+		http.HandleFunc("/api/log/download", handleLogDownload)
+	})
+	return http.DefaultServeMux
+}
+
+func StartWorkerServer(port string) {
+	handler := Routes()
 	log.Printf("Worker HTTP Server started on %s", port)
-	http.ListenAndServe(port, nil)
+	http.ListenAndServe(port, handler)
+}
+
+// requireMasterToken 校验 Master 以节点密钥签发的短期令牌 (见 nodeauth)
+// 终端等敏感接口只能经 Master 访问: Master 负责鉴权、录像与执行策略，直连 Worker 端口会绕过这些检查
+func requireMasterToken(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if verifyMasterToken(w, r, nodeauth.Scope(r.URL.Path)) {
+			next(w, r)
+		}
+	}
+}
+
+// verifyMasterToken 校验令牌是否为 scope 签发，失败时返回 401
+func verifyMasterToken(w http.ResponseWriter, r *http.Request, scope string) bool {
+	if err := nodeauth.Verify(agent.NodeSecret(), scope, r.Header.Get(nodeauth.Header), time.Now()); err != nil {
+		log.Printf("[Auth] Reject %s from %s: %v", r.URL.Path, r.RemoteAddr, err)
+		http.Error(w, "Unauthorized: "+err.Error(), http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
+// handleAgentUpgrade 接收自升级指令 (下载与替换在后台进行，完成后进程被替换)
+// 只接受 Master 签发的令牌
+func handleAgentUpgrade(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", 405)
+		return
+	}
+	var req protocol.AgentUpgradeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", 400)
+		return
+	}
+	// 令牌同时覆盖下载地址与校验和: 否则调用方可自行指定程序与摘要，校验和形同虚设
+	if !verifyMasterToken(w, r, nodeauth.Scope(r.URL.Path, req.Version, req.DownloadURL, req.SHA256)) {
+		return
+	}
+	if err := agent.StartUpgrade(req); err != nil {
+		log.Printf("[Upgrade] Rejected: %v", err)
+		http.Error(w, err.Error(), 409)
+		return
+	}
+	w.Write([]byte(`{"status":"ok"}`))
 }
 
 // handleRegisterExternal 处理纳管服务注册 (新增)
@@ -67,28 +132,69 @@ func handleDeploy(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 1. 立即返回 OK，不让 Master 等待
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte("ok"))
+	if req.InstanceID == "" || req.DownloadURL == "" {
+		http.Error(w, "instance_id and download_url are required", 400)
+		return
+	}
+
+	// 1. 校验通过即返回，不让 Master 等待；结果通过任务进度与实例状态上报
+	w.Write([]byte(`{"status":"accepted"}`))
 
 	// 2. 启动协程在后台执行耗时操作 (下载、解压)
 	go func() {
+		task := protocol.TaskReport{TaskID: req.TaskID, ItemID: req.TaskItemID}
 		// 可选：再次确认上报 deploying (防止 Master 那边没置位)
 		executor.ReportStatus(req.InstanceID, "deploying", 0, 0)
+		task.Status, task.Progress, task.Message = protocol.TaskRunning, 10, "downloading"
+		executor.ReportTask(task)
 
-		// 执行下载解压
+		// 执行下载解压 (先上报任务结果，再上报实例状态，Master 以任务上报的错误信息为准)
 		if err := executor.DeployInstance(req); err != nil {
 			log.Printf("[Deploy Error] %v", err)
+			task.Status, task.Error = protocol.TaskFailed, err.Error()
+			executor.ReportTask(task)
 			// 失败：上报 error
 			executor.ReportStatus(req.InstanceID, "error", 0, 0)
 		} else {
 			log.Printf("[Deploy Success] %s", req.InstanceID)
+			task.Status, task.Progress, task.Message = protocol.TaskSucceeded, 100, ""
+			executor.ReportTask(task)
 			// 成功：上报 stopped (表示已就绪，等待启动)
 			executor.ReportStatus(req.InstanceID, "stopped", 0, 0)
 		}
 	}()
 }
 
+// handleInstanceConfig 写入配置文件并按策略重载实例
+func handleInstanceConfig(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", 405)
+		return
+	}
+	var req protocol.InstanceConfigRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+
+	resp, err := executor.ApplyConfig(req)
+	if err != nil {
+		log.Printf("[Config] Apply failed for %s: %v", req.InstanceID, err)
+		if resp == nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		// 部分文件已写入: 返回已写入的文件，错误由 Master 记录
+		if resp.Error != "" {
+			resp.Error = err.Error() + "; " + resp.Error
+		} else {
+			resp.Error = err.Error()
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
 // handleInstanceAction 处理实例启停
 func handleInstanceAction(w http.ResponseWriter, r *http.Request) {
 	var req protocol.InstanceActionRequest
@@ -147,7 +253,7 @@ func handleInstanceAction(w http.ResponseWriter, r *http.Request) {
 	reportURL := fmt.Sprintf("%s/api/instance/status_report", masterBaseURL)
 	reportBytes, _ := json.Marshal(report)
 
-	client := &http.Client{Timeout: 5 * time.Second}
+	client := utils.NewClient(5 * time.Second) // 复用连接池，反向通道模式下经通道上报
 	resp, err := client.Post(reportURL, "application/json", bytes.NewBuffer(reportBytes))
 
 	if err != nil {
@@ -164,7 +270,7 @@ func handleInstanceAction(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte(`{"status":"ok"}`))
 }
 
-// handleExec 处理 CMD 命令
+// handleExec 处理 CMD 命令 (同步返回全部输出，长时间运行的命令使用 /api/exec/stream)
 func handleExec(w http.ResponseWriter, r *http.Request) {
 	var req protocol.CommandRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -172,20 +278,23 @@ func handleExec(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var cmd *exec.Cmd
-	if runtime.GOOS == "windows" {
-		cmd = exec.Command("cmd", "/C", req.Command)
-	} else {
-		cmd = exec.Command("sh", "-c", req.Command)
-	}
-
-	output, err := cmd.CombinedOutput()
 	result := map[string]string{
-		"output": string(output),
+		"output": "",
 		"error":  "",
 	}
-	if err != nil {
+	ctx, cancel := commandContext(r.Context(), req.Timeout)
+	defer cancel()
+	if err := checkCommand(req.Command); err != nil {
+		log.Printf("[Exec] Denied %q: %v", req.Command, err)
 		result["error"] = err.Error()
+	} else if cmd, err := shellCommand(ctx, req); err != nil {
+		result["error"] = err.Error()
+	} else {
+		output, err := cmd.CombinedOutput()
+		result["output"] = string(output)
+		if err != nil {
+			result["error"] = commandError(ctx, err)
+		}
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -208,6 +317,7 @@ func handleGetLogFiles(w http.ResponseWriter, r *http.Request) {
 	resp := protocol.LogFilesResp{
 		InstanceID: id,
 		Files:      files,
+		Segments:   executor.GetLogSegments(id, files),
 	}
 
 	w.Header().Set("Content-Type", "application/json")
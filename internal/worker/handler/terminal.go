@@ -0,0 +1,136 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"ops-system/internal/worker/executor"
+	"ops-system/pkg/protocol"
+
+	"github.com/gorilla/websocket"
+)
+
+// terminalUpgrader 终端只由 Master 连接 (不带 Origin)，使用默认的同源检查拒绝浏览器跨站连接
+var terminalUpgrader = websocket.Upgrader{}
+
+// defaultTerminalIdle 未指定时的终端空闲超时 (无输入)
+const defaultTerminalIdle = 10 * time.Minute
+
+// handleTerminal 交互式终端 (PTY)
+// 二进制帧为终端输出；文本帧为 protocol.TerminalMessage (input / resize，结束时 Worker 发送 exit)
+// 超过 idle 秒没有输入时断开；指定 instance_id 时工作目录为实例目录
+// 只接受 Master 签发的令牌 (见 requireMasterToken)，鉴权、录像与执行策略均在 Master 完成
+// URL: /api/terminal/ws?instance_id=...&cols=120&rows=40&idle=600
+func handleTerminal(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	dir, _ := os.UserHomeDir()
+	if instID := q.Get("instance_id"); instID != "" {
+		d, ok := executor.InstanceShellDir(instID)
+		if !ok {
+			http.Error(w, "Instance not found", 404)
+			return
+		}
+		dir = d
+	}
+	cols, _ := strconv.Atoi(q.Get("cols"))
+	rows, _ := strconv.Atoi(q.Get("rows"))
+	idle := defaultTerminalIdle
+	if sec, _ := strconv.Atoi(q.Get("idle")); sec > 0 {
+		idle = time.Duration(sec) * time.Second
+	}
+
+	conn, err := terminalUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("[Terminal] WS upgrade failed: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	// 输出协程与结束消息共用连接，写入需串行
+	var writeMu sync.Mutex
+	write := func(mt int, data []byte) error {
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		return conn.WriteMessage(mt, data)
+	}
+	exit := func(reason string) {
+		msg, _ := json.Marshal(protocol.TerminalMessage{Type: "exit", Data: reason})
+		write(websocket.TextMessage, msg)
+		writeMu.Lock()
+		conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+		writeMu.Unlock()
+	}
+
+	term, err := executor.StartTerminal(dir, uint16(cols), uint16(rows))
+	if err != nil {
+		exit("启动终端失败: " + err.Error())
+		return
+	}
+	log.Printf("[Terminal] Session started in %s (%s)", dir, r.RemoteAddr)
+
+	// PTY -> 前端; Shell 退出后 PTY 读取返回错误
+	shellDone := make(chan struct{})
+	go func() {
+		defer close(shellDone)
+		buf := make([]byte, 32*1024)
+		for {
+			n, err := term.Read(buf)
+			if n > 0 {
+				if write(websocket.BinaryMessage, buf[:n]) != nil {
+					return
+				}
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	// 前端 -> PTY; 读取截止时间即空闲超时
+	inputDone := make(chan string, 1)
+	go func() {
+		for {
+			conn.SetReadDeadline(time.Now().Add(idle))
+			mt, data, err := conn.ReadMessage()
+			if err != nil {
+				var ne interface{ Timeout() bool }
+				if errors.As(err, &ne) && ne.Timeout() {
+					inputDone <- "空闲超时"
+				} else {
+					inputDone <- "连接断开"
+				}
+				return
+			}
+			if mt == websocket.BinaryMessage {
+				term.Write(data)
+				continue
+			}
+			var msg protocol.TerminalMessage
+			if json.Unmarshal(data, &msg) != nil {
+				continue
+			}
+			switch msg.Type {
+			case "input":
+				term.Write([]byte(msg.Data))
+			case "resize":
+				term.Resize(msg.Cols, msg.Rows)
+			}
+		}
+	}()
+
+	reason := "Shell 已退出"
+	select {
+	case <-shellDone:
+	case reason = <-inputDone:
+	}
+	term.Close()
+	term.Wait()
+	exit(reason)
+	log.Printf("[Terminal] Session in %s closed: %s", dir, reason)
+}
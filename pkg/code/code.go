@@ -23,6 +23,10 @@ const (
 	NodeNotFound       = 20002
 	NodeRegisterFailed = 20003
 	NodeExecFailed     = 20004
+	NodeCordoned       = 20005 // 节点维护中
+	TerminalNotFound   = 20006 // 终端会话不存在
+	CommandDenied      = 20007 // 命令被执行策略拒绝
+	ApprovalNotFound   = 20008 // 审批单不存在或已处理
 
 	// 30xxx: 业务系统 & 实例
 	SystemNotFound   = 30001
@@ -30,6 +34,7 @@ const (
 	DeployFailed     = 30003
 	ActionFailed     = 30004
 	ModuleNotFound   = 30005
+	TaskNotFound     = 30006
 
 	// 40xxx: 服务包管理
 	PackageUploadFailed = 40001
@@ -42,6 +47,8 @@ const (
 	NacosError      = 50001
 	AlertRuleError  = 50002
 	LogFileNotFound = 50003
+	NotifyError     = 50004
+	ConfigError     = 50005
 )
 
 // ====================================================
@@ -63,12 +70,17 @@ var Msg = map[int]string{
 	NodeNotFound:       "节点不存在",
 	NodeRegisterFailed: "节点注册失败",
 	NodeExecFailed:     "远程指令执行失败",
+	NodeCordoned:       "节点维护中",
+	TerminalNotFound:   "终端会话不存在",
+	CommandDenied:      "命令被执行策略拒绝",
+	ApprovalNotFound:   "审批单不存在或已处理",
 
 	SystemNotFound:   "业务系统不存在",
 	InstanceNotFound: "实例不存在",
 	DeployFailed:     "服务部署失败",
 	ActionFailed:     "实例操作失败",
 	ModuleNotFound:   "服务组件定义不存在",
+	TaskNotFound:     "任务不存在",
 
 	PackageUploadFailed: "服务包上传失败",
 	PackageNotFound:     "服务包不存在",
@@ -79,6 +91,8 @@ var Msg = map[int]string{
 	NacosError:      "Nacos 交互失败",
 	AlertRuleError:  "告警规则操作失败",
 	LogFileNotFound: "日志文件不存在",
+	NotifyError:     "告警通知发送失败",
+	ConfigError:     "配置中心操作失败",
 }
 
 // GetMsg 获取错误码对应的默认信息
@@ -2,15 +2,20 @@ package config
 
 import (
 	"time"
+
+	"ops-system/pkg/policy"
 )
 
 // ================= Master Config =================
 
 type MasterConfig struct {
-	Server  ServerConfig  `mapstructure:"server"`
-	Storage StorageConfig `mapstructure:"storage"`
-	Logic   LogicConfig   `mapstructure:"logic"`
-	Log     LogConfig     `mapstructure:"log"`
+	Server   ServerConfig   `mapstructure:"server"`
+	Storage  StorageConfig  `mapstructure:"storage"`
+	Logic    LogicConfig    `mapstructure:"logic"`
+	Log      LogConfig      `mapstructure:"log"`
+	LogStore LogStoreConfig `mapstructure:"log_store"`
+	Security SecurityConfig `mapstructure:"security"`
+	Terminal TerminalConfig `mapstructure:"terminal"`
 }
 
 type ServerConfig struct {
@@ -40,6 +45,31 @@ type LogicConfig struct {
 	HTTPClientTimeout    time.Duration `mapstructure:"http_client_timeout"`    // Master 请求 Worker 的超时
 }
 
+// LogStoreConfig 集中日志存储 (Worker 开启 log_ship 后推送)
+type LogStoreConfig struct {
+	Dir           string `mapstructure:"dir"`
+	RetentionDays int    `mapstructure:"retention_days"` // 保留天数 (默认 7)
+}
+
+// SecurityConfig 用户与角色 (未配置用户时，需要认证的功能如 Web 终端不可用)
+type SecurityConfig struct {
+	Users      []UserConfig `mapstructure:"users"`
+	ExecPolicy policy.Rules `mapstructure:"exec_policy"` // 远程命令的拒绝/允许/审批规则
+}
+
+// UserConfig 用户令牌，请求以 Authorization: Bearer <token> 携带 (WebSocket 可用 ?token=)
+type UserConfig struct {
+	Name  string `mapstructure:"name"`
+	Token string `mapstructure:"token"`
+	Role  string `mapstructure:"role"` // viewer / operator / admin (权限依次增大)
+}
+
+// TerminalConfig Web 终端 (仅 admin 可用，会话全程录像)
+type TerminalConfig struct {
+	RecordDir   string        `mapstructure:"record_dir"`   // 录像目录
+	IdleTimeout time.Duration `mapstructure:"idle_timeout"` // 无输入自动断开 (默认 10m)
+}
+
 // ================= Worker Config =================
 
 type WorkerConfig struct {
@@ -47,15 +77,22 @@ type WorkerConfig struct {
 	Connect ConnectConfig      `mapstructure:"connect"`
 	Logic   WorkerLogicConfig  `mapstructure:"logic"`
 	Log     LogConfig          `mapstructure:"log"`
+	LogShip LogShipConfig      `mapstructure:"log_ship"`
+
+	// 节点标签，注册时上报 Master (如 env: prod, rack: a3)
+	Labels map[string]string `mapstructure:"labels"`
 }
 
 type WorkerServerConfig struct {
-	Port    int    `mapstructure:"port"`
-	WorkDir string `mapstructure:"work_dir"`
+	Port       int    `mapstructure:"port"`
+	WorkDir    string `mapstructure:"work_dir"`
+	NodeIDFile string `mapstructure:"node_id_file"` // 节点 ID 文件 (默认 worker 可执行文件旁的 node_id)
 }
 
 type ConnectConfig struct {
 	MasterURL string `mapstructure:"master_url"`
+	// 连接方式: direct (Master 直连 Worker 端口) / tunnel (Worker 主动与 Master 保持长连接，适用于 NAT 后的节点)
+	Mode string `mapstructure:"mode"`
 }
 
 type WorkerLogicConfig struct {
@@ -64,6 +101,13 @@ type WorkerLogicConfig struct {
 	HTTPClientTimeout time.Duration `mapstructure:"http_client_timeout"`
 }
 
+// LogShipConfig 日志推送到 Master 集中存储 (默认关闭)
+type LogShipConfig struct {
+	Enabled   bool          `mapstructure:"enabled"`
+	Interval  time.Duration `mapstructure:"interval"`   // 推送间隔 (默认 5s)
+	BatchSize int           `mapstructure:"batch_size"` // 单批最大字节数 (默认 512KB)
+}
+
 // ================= Common =================
 
 type LogConfig struct {
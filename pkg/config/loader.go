@@ -31,6 +31,10 @@ func LoadMasterConfig(cfgFile string) (*MasterConfig, error) {
 	v.SetDefault("logic.batch_concurrency", 50)
 	v.SetDefault("logic.http_client_timeout", "5s")
 
+	v.SetDefault("log_store.retention_days", 7)
+
+	v.SetDefault("terminal.idle_timeout", "10m")
+
 	// 3. 绑定环境变量
 	v.SetEnvPrefix("OPS_MASTER")
 	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
@@ -70,10 +74,15 @@ func LoadWorkerConfig(cfgFile string) (*WorkerConfig, error) {
 	v := viper.GetViper()
 
 	v.SetDefault("server.port", 8081)
+	v.SetDefault("connect.mode", "direct")
 	v.SetDefault("logic.heartbeat_interval", "5s")
 	v.SetDefault("logic.monitor_interval", "3s")
 	v.SetDefault("logic.http_client_timeout", "10s")
 
+	v.SetDefault("log_ship.enabled", false)
+	v.SetDefault("log_ship.interval", "5s")
+	v.SetDefault("log_ship.batch_size", 512*1024)
+
 	v.SetEnvPrefix("OPS_WORKER")
 	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
 	v.AutomaticEnv()
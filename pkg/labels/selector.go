@@ -0,0 +1,211 @@
+package labels
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// 选择器操作符
+const (
+	opEquals    = "="
+	opNotEquals = "!="
+	opIn        = "in"
+	opNotIn     = "notin"
+	opExists    = "exists"
+	opNotExists = "!exists"
+)
+
+type requirement struct {
+	Key    string
+	Op     string
+	Values []string
+}
+
+// Selector 标签选择器 (语法参考 Kubernetes)
+// 多个条件以逗号分隔，全部满足才算匹配：
+//
+//	env=prod, role!=db, rack in (a1,a2), zone notin (bj), gpu, !deprecated
+type Selector []requirement
+
+// Parse 解析选择器字符串，空字符串返回匹配一切的空选择器
+func Parse(s string) (Selector, error) {
+	var sel Selector
+	for _, term := range splitTerms(s) {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			continue
+		}
+		req, err := parseTerm(term)
+		if err != nil {
+			return nil, err
+		}
+		sel = append(sel, req)
+	}
+	return sel, nil
+}
+
+// MustParse 解析失败时 panic (仅用于常量选择器)
+func MustParse(s string) Selector {
+	sel, err := Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return sel
+}
+
+// Empty 是否为空选择器
+func (sel Selector) Empty() bool {
+	return len(sel) == 0
+}
+
+// Matches 判断标签集合是否满足选择器
+func (sel Selector) Matches(labels map[string]string) bool {
+	for _, req := range sel {
+		val, ok := labels[req.Key]
+		switch req.Op {
+		case opEquals:
+			if !ok || val != req.Values[0] {
+				return false
+			}
+		case opNotEquals:
+			if ok && val == req.Values[0] {
+				return false
+			}
+		case opIn:
+			if !ok || !contains(req.Values, val) {
+				return false
+			}
+		case opNotIn:
+			if ok && contains(req.Values, val) {
+				return false
+			}
+		case opExists:
+			if !ok {
+				return false
+			}
+		case opNotExists:
+			if ok {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// String 还原为规范化的字符串形式
+func (sel Selector) String() string {
+	parts := make([]string, 0, len(sel))
+	for _, req := range sel {
+		switch req.Op {
+		case opEquals, opNotEquals:
+			parts = append(parts, req.Key+req.Op+req.Values[0])
+		case opIn, opNotIn:
+			parts = append(parts, fmt.Sprintf("%s %s (%s)", req.Key, req.Op, strings.Join(req.Values, ",")))
+		case opExists:
+			parts = append(parts, req.Key)
+		case opNotExists:
+			parts = append(parts, "!"+req.Key)
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// FromMap 由 key=value 映射构造等值选择器
+func FromMap(m map[string]string) Selector {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	sel := make(Selector, 0, len(m))
+	for _, k := range keys {
+		sel = append(sel, requirement{Key: k, Op: opEquals, Values: []string{m[k]}})
+	}
+	return sel
+}
+
+// splitTerms 按逗号切分，但忽略括号内的逗号
+func splitTerms(s string) []string {
+	var terms []string
+	depth, start := 0, 0
+	for i, c := range s {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				terms = append(terms, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(terms, s[start:])
+}
+
+func parseTerm(term string) (requirement, error) {
+	// key in (a,b) / key notin (a,b)
+	fields := strings.Fields(term)
+	if len(fields) >= 2 && (fields[1] == opIn || fields[1] == opNotIn) {
+		rest := strings.TrimSpace(strings.Join(fields[2:], " "))
+		if !strings.HasPrefix(rest, "(") || !strings.HasSuffix(rest, ")") {
+			return requirement{}, fmt.Errorf("invalid selector %q: values must be in parentheses", term)
+		}
+		var values []string
+		for _, v := range strings.Split(rest[1:len(rest)-1], ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				values = append(values, v)
+			}
+		}
+		if len(values) == 0 {
+			return requirement{}, fmt.Errorf("invalid selector %q: empty value list", term)
+		}
+		return requirement{Key: fields[0], Op: fields[1], Values: values}, nil
+	}
+
+	if idx := strings.Index(term, "!="); idx > 0 {
+		return binaryTerm(term, term[:idx], opNotEquals, term[idx+2:])
+	}
+	if idx := strings.Index(term, "=="); idx > 0 {
+		return binaryTerm(term, term[:idx], opEquals, term[idx+2:])
+	}
+	if idx := strings.Index(term, "="); idx > 0 {
+		return binaryTerm(term, term[:idx], opEquals, term[idx+1:])
+	}
+
+	if strings.HasPrefix(term, "!") {
+		key := strings.TrimSpace(term[1:])
+		if !validKey(key) {
+			return requirement{}, fmt.Errorf("invalid selector %q", term)
+		}
+		return requirement{Key: key, Op: opNotExists}, nil
+	}
+	if !validKey(term) {
+		return requirement{}, fmt.Errorf("invalid selector %q", term)
+	}
+	return requirement{Key: term, Op: opExists}, nil
+}
+
+func binaryTerm(term, key, op, value string) (requirement, error) {
+	key = strings.TrimSpace(key)
+	if !validKey(key) {
+		return requirement{}, fmt.Errorf("invalid selector %q", term)
+	}
+	return requirement{Key: key, Op: op, Values: []string{strings.TrimSpace(value)}}, nil
+}
+
+func validKey(key string) bool {
+	return key != "" && !strings.ContainsAny(key, " =!(),")
+}
+
+func contains(list []string, v string) bool {
+	for _, s := range list {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
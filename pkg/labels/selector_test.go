@@ -0,0 +1,64 @@
+package labels_test
+
+import (
+	"testing"
+
+	"ops-system/pkg/labels"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSelectorMatches(t *testing.T) {
+	node := map[string]string{"env": "prod", "rack": "a3", "role": "web", "gpu": ""}
+
+	cases := []struct {
+		selector string
+		match    bool
+	}{
+		{"", true},
+		{"env=prod", true},
+		{"env==prod", true},
+		{"env=dev", false},
+		{"role!=db", true},
+		{"role!=web", false},
+		{"rack in (a1, a3)", true},
+		{"rack notin (a1,a3)", false},
+		{"env=prod, rack in (a1,a3), role!=db", true},
+		{"env=prod,zone=bj", false},
+		{"gpu", true},
+		{"!gpu", false},
+		{"!deprecated", true},
+		{"zone!=bj", true},
+	}
+
+	for _, c := range cases {
+		sel, err := labels.Parse(c.selector)
+		assert.NoError(t, err, c.selector)
+		assert.Equal(t, c.match, sel.Matches(node), c.selector)
+	}
+}
+
+func TestSelectorParseErrors(t *testing.T) {
+	for _, s := range []string{"=prod", "rack in a1", "rack in ()", "a b"} {
+		_, err := labels.Parse(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestSelectorString(t *testing.T) {
+	sel, err := labels.Parse("env = prod ,rack in (a1,a2), !old")
+	assert.NoError(t, err)
+	assert.Equal(t, "env=prod,rack in (a1,a2),!old", sel.String())
+
+	assert.Equal(t, "a=1,b=2", labels.FromMap(map[string]string{"b": "2", "a": "1"}).String())
+}
+
+func TestValidateAndMerge(t *testing.T) {
+	assert.NoError(t, labels.Validate(map[string]string{"env": "prod", "rack": "a3", "gpu": ""}))
+	for _, bad := range []map[string]string{{"": "x"}, {"a b": "x"}, {"env": "a,b"}, {"env": " prod"}, {"role": "(db)"}} {
+		assert.Error(t, labels.Validate(bad), bad)
+	}
+
+	merged := labels.Merge(map[string]string{"env": "dev", "rack": "a3"}, map[string]string{"env": "prod"})
+	assert.Equal(t, map[string]string{"env": "prod", "rack": "a3"}, merged)
+}
@@ -0,0 +1,36 @@
+package labels
+
+import (
+	"fmt"
+	"strings"
+)
+
+// 标签键/值的长度上限
+const (
+	maxKeyLen   = 63
+	maxValueLen = 128
+)
+
+// Validate 校验标签集合，保证每个键值对都能写进选择器
+func Validate(set map[string]string) error {
+	for k, v := range set {
+		if !validKey(k) || len(k) > maxKeyLen {
+			return fmt.Errorf("invalid label key %q", k)
+		}
+		if len(v) > maxValueLen || v != strings.TrimSpace(v) || strings.ContainsAny(v, ",()") {
+			return fmt.Errorf("invalid label value %q for key %q", v, k)
+		}
+	}
+	return nil
+}
+
+// Merge 依次合并多个标签集合，后者覆盖前者的同名键
+func Merge(sets ...map[string]string) map[string]string {
+	res := make(map[string]string)
+	for _, set := range sets {
+		for k, v := range set {
+			res[k] = v
+		}
+	}
+	return res
+}
@@ -0,0 +1,83 @@
+package logparse
+
+import (
+	"ops-system/pkg/labels"
+)
+
+// Filter 结构化过滤条件：最低级别 + 字段选择器 (语法同 pkg/labels，如 "request_id=abc,user in (1,2)")
+// 选择器中可以使用 level、msg 以及解析出的任意字段
+type Filter struct {
+	MinLevel string
+	Selector labels.Selector
+}
+
+// NewFilter 解析过滤条件
+func NewFilter(minLevel, selector string) (*Filter, error) {
+	if err := CheckLevel(minLevel); err != nil {
+		return nil, err
+	}
+	sel, err := labels.Parse(selector)
+	if err != nil {
+		return nil, err
+	}
+	return &Filter{MinLevel: minLevel, Selector: sel}, nil
+}
+
+// Empty 没有任何过滤条件
+func (f *Filter) Empty() bool {
+	return f == nil || (f.MinLevel == "" && f.Selector.Empty())
+}
+
+// Match 判断一条记录是否满足条件
+func (f *Filter) Match(e *Entry) bool {
+	if f.Empty() {
+		return true
+	}
+	if f.MinLevel != "" && !LevelAtLeast(e.Level, f.MinLevel) {
+		return false
+	}
+	if f.Selector.Empty() {
+		return true
+	}
+	set := make(map[string]string, len(e.Fields)+2)
+	for k, v := range e.Fields {
+		set[k] = v
+	}
+	if e.Level != "" {
+		set["level"] = e.Level
+	}
+	if e.Message != "" {
+		set["msg"] = e.Message
+	}
+	return f.Selector.Matches(set)
+}
+
+// Stream 逐行解析并过滤，续行 (如异常堆栈) 跟随上一条记录的过滤结果
+type Stream struct {
+	format string
+	filter *Filter
+	keep   bool // 上一条记录是否保留
+	last   *Entry
+}
+
+// NewStream 创建解析流，filter 可以为 nil
+func NewStream(format string, filter *Filter) *Stream {
+	// 有过滤条件时，第一条记录之前的孤立续行不输出
+	return &Stream{format: format, filter: filter, keep: filter.Empty()}
+}
+
+// Feed 输入一行，返回解析结果与是否保留
+// 续行返回的 Entry 只有 Raw，时间与级别沿用上一条记录
+func (s *Stream) Feed(line string) (*Entry, bool) {
+	e, ok := Parse(s.format, line)
+	if !ok {
+		e = &Entry{Raw: line}
+		if s.last != nil {
+			e.Time, e.Level = s.last.Time, s.last.Level
+		}
+		return e, s.keep
+	}
+	s.last = e
+	s.keep = s.filter.Match(e)
+	return e, s.keep
+}
@@ -0,0 +1,260 @@
+package logparse
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// 日志格式 (service.json 的 log_formats 中按日志名配置)
+const (
+	FormatText   = "text"
+	FormatJSON   = "json"
+	FormatLogfmt = "logfmt"
+)
+
+// Entry 解析后的一条日志
+type Entry struct {
+	Time    int64             `json:"time,omitempty"`  // Unix 秒，无法解析时为 0
+	Level   string            `json:"level,omitempty"` // 归一化级别，见 NormalizeLevel
+	Message string            `json:"msg,omitempty"`
+	Fields  map[string]string `json:"fields,omitempty"` // 除时间/级别/消息以外的字段
+	Raw     string            `json:"raw"`
+}
+
+// 常见的时间/级别/消息字段名
+var (
+	timeKeys  = []string{"time", "ts", "timestamp", "@timestamp", "datetime"}
+	levelKeys = []string{"level", "lvl", "severity", "log.level", "loglevel"}
+	msgKeys   = []string{"msg", "message", "log"}
+)
+
+// textLevelRe 文本日志中的级别关键字
+var textLevelRe = regexp.MustCompile(`\b(TRACE|DEBUG|INFO|WARN|WARNING|ERROR|FATAL|PANIC|CRITICAL)\b`)
+
+// ValidFormat 判断格式名是否合法 (空表示 text)
+func ValidFormat(format string) bool {
+	switch format {
+	case "", FormatText, FormatJSON, FormatLogfmt:
+		return true
+	}
+	return false
+}
+
+// Parse 按格式解析一行
+// ok=false 表示该行不是一条新记录的开头 (如异常堆栈的续行)，调用方应将其归入上一条
+func Parse(format, line string) (*Entry, bool) {
+	switch format {
+	case FormatJSON:
+		return parseJSON(line)
+	case FormatLogfmt:
+		return parseLogfmt(line)
+	default:
+		return parseText(line)
+	}
+}
+
+func parseText(line string) (*Entry, bool) {
+	e := &Entry{Raw: line, Message: line, Time: ParseTime(line)}
+	// 只在行首附近识别级别，避免把消息内容里的 "error" 当作级别
+	head := line
+	if len(head) > 64 {
+		head = head[:64]
+	}
+	if m := textLevelRe.FindString(head); m != "" {
+		e.Level = NormalizeLevel(m)
+	}
+	return e, e.Time > 0 || e.Level != ""
+}
+
+func parseJSON(line string) (*Entry, bool) {
+	trimmed := strings.TrimSpace(line)
+	if !strings.HasPrefix(trimmed, "{") {
+		return &Entry{Raw: line}, false
+	}
+	var obj map[string]interface{}
+	if err := json.Unmarshal([]byte(trimmed), &obj); err != nil {
+		return &Entry{Raw: line}, false
+	}
+	fields := make(map[string]string, len(obj))
+	for k, v := range obj {
+		fields[k] = stringify(v)
+	}
+	return buildEntry(line, fields), true
+}
+
+func parseLogfmt(line string) (*Entry, bool) {
+	fields := splitLogfmt(line)
+	if len(fields) == 0 {
+		return &Entry{Raw: line}, false
+	}
+	return buildEntry(line, fields), true
+}
+
+// buildEntry 从字段中提取时间/级别/消息
+func buildEntry(raw string, fields map[string]string) *Entry {
+	e := &Entry{Raw: raw}
+	if k, v := takeField(fields, timeKeys); k != "" {
+		e.Time = parseTimeValue(v)
+	}
+	if k, v := takeField(fields, levelKeys); k != "" {
+		e.Level = NormalizeLevel(v)
+	}
+	if k, v := takeField(fields, msgKeys); k != "" {
+		e.Message = v
+	}
+	if len(fields) > 0 {
+		e.Fields = fields
+	}
+	return e
+}
+
+// takeField 取出第一个存在的字段 (从 fields 中删除)
+func takeField(fields map[string]string, keys []string) (string, string) {
+	for _, k := range keys {
+		if v, ok := fields[k]; ok {
+			delete(fields, k)
+			return k, v
+		}
+	}
+	return "", ""
+}
+
+func stringify(v interface{}) string {
+	switch x := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return x
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(x)
+	default:
+		// 嵌套对象/数组保留 JSON 形式
+		data, _ := json.Marshal(x)
+		return string(data)
+	}
+}
+
+// parseTimeValue 支持 RFC3339、"2006-01-02 15:04:05" 前缀与 Unix 秒/毫秒/微秒/纳秒
+func parseTimeValue(v string) int64 {
+	if n, err := strconv.ParseFloat(v, 64); err == nil {
+		switch {
+		case n > 1e17:
+			return int64(n / 1e9)
+		case n > 1e14:
+			return int64(n / 1e6)
+		case n > 1e11:
+			return int64(n / 1e3)
+		default:
+			return int64(n)
+		}
+	}
+	if t, err := time.Parse(time.RFC3339Nano, v); err == nil {
+		return t.Unix()
+	}
+	return ParseTime(v)
+}
+
+// splitLogfmt 解析 key=value 序列，值可以用双引号包裹
+// 第一个 token 不是 key=value 时返回 nil (视为非 logfmt 行)
+func splitLogfmt(line string) map[string]string {
+	fields := make(map[string]string)
+	i, n := 0, len(line)
+	for i < n {
+		for i < n && line[i] == ' ' {
+			i++
+		}
+		if i >= n {
+			break
+		}
+		start := i
+		for i < n && line[i] != '=' && line[i] != ' ' {
+			i++
+		}
+		key := line[start:i]
+		if key == "" || i >= n || line[i] != '=' {
+			if len(fields) == 0 {
+				return nil
+			}
+			// 裸单词视为布尔标记
+			if key != "" {
+				fields[key] = "true"
+			}
+			continue
+		}
+		i++ // '='
+		var val string
+		if i < n && line[i] == '"' {
+			j := i + 1
+			var sb strings.Builder
+			for j < n && line[j] != '"' {
+				if line[j] == '\\' && j+1 < n {
+					j++
+				}
+				sb.WriteByte(line[j])
+				j++
+			}
+			val = sb.String()
+			i = j + 1
+		} else {
+			start = i
+			for i < n && line[i] != ' ' {
+				i++
+			}
+			val = line[start:i]
+		}
+		fields[key] = val
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+	return fields
+}
+
+// 级别顺序 (用于最低级别过滤)
+var levelRank = map[string]int{"trace": 0, "debug": 1, "info": 2, "warn": 3, "error": 4, "fatal": 5}
+
+// NormalizeLevel 归一化级别: trace/debug/info/warn/error/fatal，无法识别时原样转小写
+func NormalizeLevel(level string) string {
+	l := strings.ToLower(strings.TrimFunc(level, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) }))
+	switch l {
+	case "warning":
+		return "warn"
+	case "err":
+		return "error"
+	case "critical", "crit", "panic", "dpanic", "emerg", "alert":
+		return "fatal"
+	case "information", "notice":
+		return "info"
+	case "dbg":
+		return "debug"
+	}
+	// 数字级别 (如 bunyan/pino: 10 trace ... 60 fatal)
+	if n, err := strconv.Atoi(l); err == nil && n >= 10 && n <= 60 {
+		return []string{"trace", "debug", "info", "warn", "error", "fatal"}[n/10-1]
+	}
+	return l
+}
+
+// CheckLevel 校验最低级别参数
+func CheckLevel(level string) error {
+	if level == "" {
+		return nil
+	}
+	if _, ok := levelRank[NormalizeLevel(level)]; !ok {
+		return fmt.Errorf("unknown level: %s", level)
+	}
+	return nil
+}
+
+// LevelAtLeast 判断 level 是否不低于 min (未知级别视为不满足)
+func LevelAtLeast(level, min string) bool {
+	r, ok := levelRank[level]
+	return ok && r >= levelRank[NormalizeLevel(min)]
+}
@@ -0,0 +1,60 @@
+package logparse_test
+
+import (
+	"testing"
+
+	"ops-system/pkg/logparse"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	e, ok := logparse.Parse(logparse.FormatJSON, `{"ts":1700000000123,"level":"WARNING","msg":"slow query","request_id":"r-1","cost":1.5,"ctx":{"db":"main"}}`)
+	require.True(t, ok)
+	assert.Equal(t, int64(1700000000), e.Time)
+	assert.Equal(t, "warn", e.Level)
+	assert.Equal(t, "slow query", e.Message)
+	assert.Equal(t, map[string]string{"request_id": "r-1", "cost": "1.5", "ctx": `{"db":"main"}`}, e.Fields)
+
+	e, ok = logparse.Parse(logparse.FormatLogfmt, `time=2023-11-14T22:13:20Z level=error msg="conn reset by peer" user=42 retry`)
+	require.True(t, ok)
+	assert.Equal(t, int64(1700000000), e.Time)
+	assert.Equal(t, "error", e.Level)
+	assert.Equal(t, "conn reset by peer", e.Message)
+	assert.Equal(t, map[string]string{"user": "42", "retry": "true"}, e.Fields)
+
+	e, ok = logparse.Parse(logparse.FormatText, "2024-01-02 15:04:05 [ERROR] boom")
+	require.True(t, ok)
+	assert.Equal(t, "error", e.Level)
+
+	// 续行
+	_, ok = logparse.Parse(logparse.FormatJSON, "\tat com.example.Main(Main.java:10)")
+	assert.False(t, ok)
+	_, ok = logparse.Parse(logparse.FormatLogfmt, "plain text")
+	assert.False(t, ok)
+}
+
+func TestStreamFilter(t *testing.T) {
+	f, err := logparse.NewFilter("warn", "request_id=r-2")
+	require.NoError(t, err)
+	s := logparse.NewStream(logparse.FormatJSON, f)
+
+	var kept []string
+	for _, line := range []string{
+		`  orphan`,
+		`{"level":"info","msg":"a","request_id":"r-2"}`,
+		`{"level":"error","msg":"b","request_id":"r-1"}`,
+		`  stack of b`,
+		`{"level":"error","msg":"c","request_id":"r-2"}`,
+		`  stack of c`,
+	} {
+		if e, keep := s.Feed(line); keep {
+			kept = append(kept, e.Raw)
+		}
+	}
+	assert.Equal(t, []string{`{"level":"error","msg":"c","request_id":"r-2"}`, `  stack of c`}, kept)
+
+	_, err = logparse.NewFilter("loud", "")
+	assert.Error(t, err)
+}
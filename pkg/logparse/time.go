@@ -0,0 +1,24 @@
+package logparse
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// timeRe 行首时间戳，如 "2024-01-02 15:04:05"、"2024/01/02 15:04:05"、"2024-01-02T15:04:05"
+var timeRe = regexp.MustCompile(`^\[?(\d{4})[-/](\d{2})[-/](\d{2})[ T](\d{2}):(\d{2}):(\d{2})`)
+
+// ParseTime 解析行首时间戳 (本地时区)，无法识别时返回 0
+func ParseTime(line string) int64 {
+	m := timeRe.FindStringSubmatch(line)
+	if m == nil {
+		return 0
+	}
+	t, err := time.ParseInLocation("2006-01-02 15:04:05",
+		fmt.Sprintf("%s-%s-%s %s:%s:%s", m[1], m[2], m[3], m[4], m[5], m[6]), time.Local)
+	if err != nil {
+		return 0
+	}
+	return t.Unix()
+}
@@ -0,0 +1,236 @@
+package logrotate
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultMaxSize  = 100 * 1024 * 1024 // 默认单文件 100MB
+	DefaultMaxFiles = 10                // 默认保留 10 个历史文件
+
+	// 历史文件命名: app.log.20240102-150405.000000000(.gz)，字典序即时间序
+	timeLayout = "20060102-150405.000000000"
+)
+
+// Options 轮转策略
+type Options struct {
+	MaxSize  int64 // 单文件大小上限 (字节)，<= 0 使用默认值
+	MaxFiles int   // 保留的历史文件数，<= 0 使用默认值
+	Daily    bool  // 跨天时轮转
+	Compress bool  // 历史文件 gzip 压缩
+}
+
+// Writer 按大小/日期轮转的日志文件
+// 打开时若已有非空文件会先将其轮转为历史文件 (保留上一次运行的输出)
+type Writer struct {
+	path string
+	opts Options
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+	day  string
+
+	wg   sync.WaitGroup // 后台压缩任务
+	bgMu sync.Mutex     // 串行化压缩与清理，避免并发清理时误删
+}
+
+// Open 打开日志文件
+func Open(path string, opts Options) (*Writer, error) {
+	if opts.MaxSize <= 0 {
+		opts.MaxSize = DefaultMaxSize
+	}
+	if opts.MaxFiles <= 0 {
+		opts.MaxFiles = DefaultMaxFiles
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+
+	w := &Writer{path: path, opts: opts}
+	if fi, err := os.Stat(path); err == nil && fi.Size() > 0 {
+		if err := w.archive(fi.ModTime()); err != nil {
+			return nil, err
+		}
+	}
+	if err := w.openNew(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Write 实现 io.Writer
+func (w *Writer) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return 0, os.ErrClosed
+	}
+	if w.size > 0 && (w.size+int64(len(p)) > w.opts.MaxSize || (w.opts.Daily && today() != w.day)) {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// Rotate 立即轮转当前文件
+func (w *Writer) Rotate() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		return os.ErrClosed
+	}
+	if w.size == 0 {
+		return nil
+	}
+	return w.rotate()
+}
+
+// Close 关闭文件并等待后台压缩完成
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	var err error
+	if w.file != nil {
+		err = w.file.Close()
+		w.file = nil
+	}
+	w.mu.Unlock()
+	w.wg.Wait()
+	return err
+}
+
+// Segments 返回 path 对应的历史文件 (从旧到新，不含当前文件)
+func Segments(path string) []string {
+	dir, base := filepath.Split(path)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var segs []string
+	for _, e := range entries {
+		name := e.Name()
+		// 跳过压缩中的临时文件
+		if e.IsDir() || !strings.HasPrefix(name, base+".") || strings.HasSuffix(name, ".tmp") {
+			continue
+		}
+		segs = append(segs, filepath.Join(dir, name))
+	}
+	sort.Strings(segs)
+	return segs
+}
+
+func (w *Writer) rotate() error {
+	if err := w.file.Close(); err != nil {
+		return err
+	}
+	w.file = nil
+	if err := w.archive(time.Now()); err != nil {
+		return err
+	}
+	return w.openNew()
+}
+
+func (w *Writer) openNew() error {
+	f, err := os.OpenFile(w.path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	w.file = f
+	w.size = 0
+	w.day = today()
+	return nil
+}
+
+// archive 将当前文件改名为历史文件，随后异步压缩并清理超出保留数的文件
+func (w *Writer) archive(t time.Time) error {
+	var dst string
+	for {
+		dst = w.path + "." + t.Format(timeLayout)
+		if !exists(dst) && !exists(dst+".gz") {
+			break
+		}
+		t = t.Add(time.Nanosecond)
+	}
+	if err := os.Rename(w.path, dst); err != nil {
+		return err
+	}
+
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		w.bgMu.Lock()
+		defer w.bgMu.Unlock()
+		if w.opts.Compress {
+			compressFile(dst)
+		}
+		w.cleanup()
+	}()
+	return nil
+}
+
+func (w *Writer) cleanup() {
+	segs := Segments(w.path)
+	for i := 0; i < len(segs)-w.opts.MaxFiles; i++ {
+		os.Remove(segs[i])
+	}
+}
+
+// compressFile 压缩为 .gz 并保留原修改时间 (按 mtime 排序的读取方依赖它)
+func compressFile(src string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	fi, err := in.Stat()
+	if err != nil {
+		return err
+	}
+
+	tmp := src + ".gz.tmp"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(out)
+	_, err = io.Copy(gz, in)
+	if cerr := gz.Close(); err == nil {
+		err = cerr
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	dst := src + ".gz"
+	if err := os.Rename(tmp, dst); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	os.Chtimes(dst, fi.ModTime(), fi.ModTime())
+	in.Close()
+	return os.Remove(src)
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func today() string {
+	return time.Now().Format("20060102")
+}
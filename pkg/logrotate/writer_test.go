@@ -0,0 +1,57 @@
+package logrotate_test
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"ops-system/pkg/logrotate"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readAll(t *testing.T, path string) string {
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	var r io.Reader = f
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(f)
+		require.NoError(t, err)
+		r = gz
+	}
+	data, err := io.ReadAll(r)
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestWriterRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	require.NoError(t, os.WriteFile(path, []byte("previous run\n"), 0644))
+
+	// 打开时归档上一次运行的输出
+	w, err := logrotate.Open(path, logrotate.Options{MaxSize: 10, MaxFiles: 2, Compress: true})
+	require.NoError(t, err)
+
+	// 每次写入都会超过 10 字节上限，触发轮转
+	for _, line := range []string{"line-1 ....\n", "line-2 ....\n", "line-3 ....\n"} {
+		_, err := w.Write([]byte(line))
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+
+	assert.Equal(t, "line-3 ....\n", readAll(t, path))
+
+	// 共产生 3 个历史文件，只保留最新的 2 个且均已压缩
+	segs := logrotate.Segments(path)
+	require.Len(t, segs, 2)
+	for _, s := range segs {
+		assert.True(t, strings.HasSuffix(s, ".gz"), s)
+	}
+	assert.Equal(t, "line-1 ....\n", readAll(t, segs[0]))
+	assert.Equal(t, "line-2 ....\n", readAll(t, segs[1]))
+}
@@ -0,0 +1,38 @@
+package nodeauth_test
+
+import (
+	"testing"
+	"time"
+
+	"ops-system/pkg/nodeauth"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignVerify(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	token := nodeauth.Sign("secret", "/api/terminal/ws", now)
+
+	assert.NoError(t, nodeauth.Verify("secret", "/api/terminal/ws", token, now))
+	assert.NoError(t, nodeauth.Verify("secret", "/api/terminal/ws", token, now.Add(time.Minute)))
+
+	// 密钥、路径不符或篡改过期时间均不通过
+	assert.ErrorIs(t, nodeauth.Verify("other", "/api/terminal/ws", token, now), nodeauth.ErrInvalidToken)
+	assert.ErrorIs(t, nodeauth.Verify("secret", "/api/agent/upgrade", token, now), nodeauth.ErrInvalidToken)
+	assert.ErrorIs(t, nodeauth.Verify("secret", "/api/terminal/ws", "9999999999"+token[10:], now), nodeauth.ErrInvalidToken)
+	assert.ErrorIs(t, nodeauth.Verify("secret", "/api/terminal/ws", "", now), nodeauth.ErrInvalidToken)
+
+	// 过期
+	assert.ErrorIs(t, nodeauth.Verify("secret", "/api/terminal/ws", token, now.Add(nodeauth.TTL+time.Second)), nodeauth.ErrExpired)
+	// 过期时间远超有效期 (Master 时钟超前)
+	assert.ErrorIs(t, nodeauth.Verify("secret", "/api/terminal/ws", nodeauth.Sign("secret", "/api/terminal/ws", now.Add(time.Hour)), now), nodeauth.ErrExpired)
+
+	// 签名范围包含请求参数时，参数被替换即失效
+	scope := nodeauth.Scope("/api/agent/upgrade", "v1.2.0", "http://master/download/worker", "abc")
+	token = nodeauth.Sign("secret", scope, now)
+	assert.NoError(t, nodeauth.Verify("secret", scope, token, now))
+	assert.ErrorIs(t, nodeauth.Verify("secret", nodeauth.Scope("/api/agent/upgrade", "v1.2.0", "http://evil/worker", "def"), token, now), nodeauth.ErrInvalidToken)
+
+	// Worker 尚未注册时一律拒绝
+	assert.ErrorIs(t, nodeauth.Verify("", "/api/terminal/ws", nodeauth.Sign("", "/api/terminal/ws", now), now), nodeauth.ErrNoSecret)
+}
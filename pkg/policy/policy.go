@@ -0,0 +1,268 @@
+// Package policy 远程命令执行策略 (Master 下发前检查，Worker 执行前再次检查)
+//
+// 规则写法:
+//   - 以 "re:" 开头为正则表达式，对完整命令及拆分后规范化的每条子命令匹配
+//     (子命令去掉引号、转义、sudo/env/nohup 等前缀和 VAR=value 赋值，程序名去掉路径，如 sudo /bin/rm -rf "/" 规范化为 rm -rf /)
+//   - 否则为命令名，匹配子命令的程序名
+//
+// 判定顺序: 拒绝列表 -> 角色允许列表 (配置了该角色才限制，每条子命令都须命中) -> 审批列表。
+// 子命令按 ; && || | & 换行以及 $( ) ` ( ) 拆分，不解析引号，引号内的分隔符同样会被拆分 (宁可误拒)；
+// 策略是防误操作的护栏而非沙箱，sh -c / eval 等可嵌套执行的程序应通过允许列表限制。
+package policy
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// 判定结果
+const (
+	Allow    = "allow"
+	Deny     = "deny"
+	Approval = "approval" // 需要其他用户审批后执行
+)
+
+// DefaultDeny 内置拒绝规则，Master 与 Worker 始终生效
+var DefaultDeny = []string{
+	`re:^rm\s+(-\S+\s+)*/\*?(\s|$)`,      // 删除根目录
+	`re:^mkfs(\.\S+)?(\s|$)`,             // 格式化文件系统
+	`re:^(dd|shred|wipefs)\s.*/dev/\w+`,  // 覆写块设备
+	`re:>\s*/dev/(sd|hd|vd|xvd|nvme)\w*`, // 重定向到块设备
+	`re::\(\)\s*\{.*:\s*\|\s*:\s*&.*\}`,  // fork 炸弹
+}
+
+// Rules 策略配置 (Master 配置 security.exec_policy)
+type Rules struct {
+	Deny     []string            `mapstructure:"deny" json:"deny"`
+	Approval []string            `mapstructure:"approval" json:"approval"`
+	Allow    map[string][]string `mapstructure:"allow" json:"allow"` // 角色 -> 允许的命令，未配置的角色不限制
+}
+
+// Result 判定结果，Rule 为命中的规则
+type Result struct {
+	Decision string `json:"decision"`
+	Rule     string `json:"rule,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+type rule struct {
+	src  string
+	re   *regexp.Regexp // 为空时按程序名匹配
+	name string
+}
+
+func compile(src string) (rule, error) {
+	if expr, ok := strings.CutPrefix(src, "re:"); ok {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return rule{}, fmt.Errorf("invalid pattern %q: %v", src, err)
+		}
+		return rule{src: src, re: re}, nil
+	}
+	name := strings.TrimSpace(src)
+	if name == "" {
+		return rule{}, fmt.Errorf("empty rule")
+	}
+	return rule{src: src, name: name}, nil
+}
+
+func compileAll(srcs []string) ([]rule, error) {
+	rules := make([]rule, 0, len(srcs))
+	for _, s := range srcs {
+		r, err := compile(s)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+// Engine 编译后的策略 (只读，可并发使用)
+type Engine struct {
+	custom   []string // 配置的拒绝规则 (不含内置规则)
+	deny     []rule
+	approval []rule
+	allow    map[string][]rule
+}
+
+// New 编译策略，内置拒绝规则始终包含在内
+func New(r Rules) (*Engine, error) {
+	e := &Engine{custom: r.Deny, allow: make(map[string][]rule)}
+	var err error
+	if e.deny, err = compileAll(append(append([]string{}, DefaultDeny...), r.Deny...)); err != nil {
+		return nil, err
+	}
+	if e.approval, err = compileAll(r.Approval); err != nil {
+		return nil, err
+	}
+	for role, srcs := range r.Allow {
+		if e.allow[role], err = compileAll(srcs); err != nil {
+			return nil, err
+		}
+	}
+	return e, nil
+}
+
+// CustomDeny 配置的拒绝规则 (同步给 Worker，Worker 自带内置规则)
+func (e *Engine) CustomDeny() []string {
+	return append([]string{}, e.custom...)
+}
+
+// Check 判定 role 角色能否执行 command
+func (e *Engine) Check(command, role string) Result {
+	segs := Segments(command)
+	if r, ok := matchAny(e.deny, command, segs); ok {
+		return Result{Decision: Deny, Rule: r, Reason: "命中拒绝规则 " + r}
+	}
+	if allowed, limited := e.allow[role]; limited {
+		for _, seg := range segs {
+			if _, ok := matchAny(allowed, seg.Line, []Segment{seg}); !ok {
+				return Result{Decision: Deny, Reason: fmt.Sprintf("命令 %s 不在角色 %s 的允许列表中", seg.Program, role)}
+			}
+		}
+	}
+	if r, ok := matchAny(e.approval, command, segs); ok {
+		return Result{Decision: Approval, Rule: r, Reason: "命中审批规则 " + r}
+	}
+	return Result{Decision: Allow}
+}
+
+// matchAny 正则规则匹配完整命令或任一子命令，命令名规则匹配任一子命令的程序名
+func matchAny(rules []rule, command string, segs []Segment) (string, bool) {
+	for _, r := range rules {
+		if r.re != nil && r.re.MatchString(command) {
+			return r.src, true
+		}
+		for _, seg := range segs {
+			if r.re != nil && r.re.MatchString(seg.Line) || r.re == nil && r.name == seg.Program {
+				return r.src, true
+			}
+		}
+	}
+	return "", false
+}
+
+// Segment 拆分出的规范化子命令: Line 为去掉引号、转义与前缀程序后的命令行 (程序名不含路径)，Program 为程序名
+type Segment struct {
+	Line    string
+	Program string
+}
+
+var splitter = strings.NewReplacer(
+	// 重定向中的 & 不是分隔符
+	">&", ">&", "&>", "&>",
+	"$(", "\n", "`", "\n", "(", "\n", ")", "\n",
+	"&&", "\n", "||", "\n", ";", "\n", "|", "\n", "&", "\n",
+)
+
+// 引号与转义不改变 Shell 实际执行的命令 ("/" 即 /，\rm 即 rm)
+var unquoter = strings.NewReplacer(`"`, "", "'", "", `\`, "")
+
+// 执行其后命令的前缀程序 -> 该程序带参数值的选项
+var wrappers = map[string]map[string]bool{
+	"sudo":    {"-u": true, "-g": true, "-C": true, "-D": true, "-h": true, "-p": true, "-r": true, "-t": true, "-U": true, "--user": true, "--group": true},
+	"doas":    {"-u": true, "-C": true},
+	"env":     {"-u": true, "-C": true, "--unset": true, "--chdir": true},
+	"nice":    {"-n": true},
+	"timeout": {"-s": true, "-k": true, "--signal": true, "--kill-after": true},
+	"exec":    {"-a": true},
+	"time":    {"-f": true, "-o": true},
+	"nohup":   nil,
+	"command": nil,
+}
+
+// 最内层的命令替换 $(...) 或 `...`
+var substitution = regexp.MustCompile("\\$\\(([^()]*)\\)|`([^`]*)`")
+
+// Segments 将命令拆分为规范化的子命令 (命令替换中的命令单独成为子命令，原位置以 _ 占位)
+// 此前的子命令 cd 到绝对路径后，后续子命令的相对路径参数按该目录展开 (cd / && rm -rf * 视为 rm -rf /*)
+func Segments(command string) []Segment {
+	lines := []string{}
+	for {
+		m := substitution.FindStringSubmatchIndex(command)
+		if m == nil {
+			break
+		}
+		if m[2] >= 0 {
+			lines = append(lines, command[m[2]:m[3]])
+		} else {
+			lines = append(lines, command[m[4]:m[5]])
+		}
+		command = command[:m[0]] + "_" + command[m[1]:]
+	}
+	lines = append(lines, command)
+
+	var segs []Segment
+	cwd := "" // 此前 cd 到的绝对目录，未知时为空
+	for _, line := range strings.Split(splitter.Replace(strings.Join(lines, "\n")), "\n") {
+		fields := stripWrappers(strings.Fields(unquoter.Replace(line)))
+		if len(fields) == 0 {
+			continue
+		}
+		fields[0] = path.Base(fields[0])
+		if fields[0] == "cd" {
+			cwd = changeDir(cwd, fields[1:])
+		} else {
+			for i := 1; i < len(fields); i++ {
+				fields[i] = resolvePath(cwd, fields[i])
+			}
+		}
+		segs = append(segs, Segment{Line: strings.Join(fields, " "), Program: fields[0]})
+	}
+	return segs
+}
+
+// stripWrappers 去掉 VAR=value 赋值与 sudo/env/timeout 等前缀程序 (及其选项)
+func stripWrappers(fields []string) []string {
+	for len(fields) > 0 {
+		f := fields[0]
+		if valued, ok := wrappers[path.Base(f)]; ok {
+			fields = fields[1:]
+			for len(fields) > 0 && strings.HasPrefix(fields[0], "-") {
+				if valued[fields[0]] && len(fields) > 1 {
+					fields = fields[1:] // 选项的参数值 (如 sudo -u root)
+				}
+				fields = fields[1:]
+			}
+			if path.Base(f) == "timeout" && len(fields) > 0 {
+				fields = fields[1:] // 超时时长
+			}
+			continue
+		}
+		if strings.Contains(f, "=") && !strings.HasPrefix(f, "=") {
+			fields = fields[1:] // VAR=value
+			continue
+		}
+		break
+	}
+	return fields
+}
+
+// changeDir 跟踪 cd 后的目录，无法确定时返回空
+func changeDir(cwd string, args []string) string {
+	if len(args) == 0 {
+		return ""
+	}
+	dir := resolvePath(cwd, args[0])
+	if !path.IsAbs(dir) {
+		return ""
+	}
+	return dir
+}
+
+// resolvePath 清理路径参数，相对路径按 cwd 展开 (选项、变量、赋值与重定向保持不变)
+func resolvePath(cwd, arg string) string {
+	if strings.HasPrefix(arg, "-") || strings.ContainsAny(arg, "$~=<>&") {
+		return arg
+	}
+	if path.IsAbs(arg) {
+		return path.Clean(arg)
+	}
+	if cwd != "" {
+		return path.Join(cwd, arg)
+	}
+	return arg
+}
@@ -0,0 +1,67 @@
+package policy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSegments(t *testing.T) {
+	segs := Segments(`FOO=1 sudo -E /usr/bin/systemctl restart web && echo $(date) 2>&1 | tee -a /tmp/x`)
+	var progs []string
+	for _, s := range segs {
+		progs = append(progs, s.Program)
+	}
+	assert.Equal(t, []string{"date", "systemctl", "echo", "tee"}, progs)
+	assert.Equal(t, "echo _ 2>&1", segs[2].Line)
+	assert.Equal(t, "systemctl restart web", Segments("sudo systemctl  restart   web")[0].Line)
+	assert.Equal(t, "rm -rf /", Segments(`sudo -u root /bin/rm -rf "/"`)[0].Line)
+	assert.Equal(t, "rm -rf /*", Segments("cd / && rm -rf *")[1].Line)
+	assert.Equal(t, "rm -rf /opt/app/logs", Segments("cd /opt; cd app && rm -rf ./logs/")[2].Line)
+	assert.Equal(t, "rm -rf logs", Segments("cd ~ && rm -rf logs")[1].Line) // 目录未知时不展开
+}
+
+func TestCheck(t *testing.T) {
+	e, err := New(Rules{
+		Deny:     []string{"re:^iptables\\s+-F"},
+		Approval: []string{"reboot", "re:^systemctl\\s+(stop|restart)\\s"},
+		Allow:    map[string][]string{"operator": {"ls", "cat", "tail", "systemctl", "reboot"}},
+	})
+	require.NoError(t, err)
+
+	cases := []struct {
+		cmd, role, want string
+	}{
+		{"rm -rf /", "admin", Deny},
+		{"cd /tmp; sudo rm -rf --no-preserve-root /", "admin", Deny},
+		{"rm -rf /tmp/build", "admin", Allow},
+		{"/bin/rm -rf /", "admin", Deny},
+		{"sudo /bin/rm -rf /", "admin", Deny},
+		{`rm -rf "/"`, "admin", Deny},
+		{`\rm -rf '/'*`, "admin", Deny},
+		{"cd / && rm -rf *", "admin", Deny},
+		{"cd /usr/.. ; rm -rf .", "admin", Deny},
+		{"timeout 5 nice -n 10 rm -rf //", "admin", Deny},
+		{"cd /tmp/build && rm -rf *", "admin", Allow},
+		{"/sbin/mkfs.ext4 /dev/sda", "admin", Deny},
+		{"mkfs.ext4 /dev/sdb1", "admin", Deny},
+		{"echo x > /dev/sda", "admin", Deny},
+		{":(){ :|:& };:", "admin", Deny},
+		{"echo $(rm -rf /)", "admin", Deny}, // 命令替换中的命令同样检查
+		{"iptables -F", "admin", Deny},
+		{"ls -l | tail -n 3", "operator", Allow},
+		{"ls; curl http://x", "operator", Deny}, // curl 不在允许列表
+		{"curl http://x", "admin", Allow},       // admin 未配置允许列表
+		{"systemctl restart web", "operator", Approval},
+		{"systemctl status web", "operator", Allow},
+		{"sudo reboot", "admin", Approval},
+		{"ls `reboot`", "operator", Approval},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.want, e.Check(c.cmd, c.role).Decision, c.cmd)
+	}
+
+	_, err = New(Rules{Deny: []string{"re:("}})
+	assert.Error(t, err)
+}
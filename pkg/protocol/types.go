@@ -7,21 +7,40 @@ package protocol
 // NodeInfo 静态信息
 // NodeInfo 节点信息 (持久化存储)
 type NodeInfo struct {
-	IP        string `json:"ip"` // 主键 (物理唯一标识)
-	Port      int    `json:"port"`
-	Hostname  string `json:"hostname"` // 机器原本的主机名
-	Name      string `json:"name"`     // 用户自定义的节点名称 (别名)
-	MacAddr   string `json:"mac_addr"`
-	OS        string `json:"os"`
-	Arch      string `json:"arch"`
-	CPUCores  int    `json:"cpu_cores"`
-	MemTotal  uint64 `json:"mem_total"`
-	DiskTotal uint64 `json:"disk_total"`
+	ID        string   `json:"id"`            // 主键: Worker 首次启动生成并持久化的 UUID (旧数据与规划节点暂以 IP 代替)
+	IP        string   `json:"ip"`            // 当前通信地址 (Master 观察到的地址，随心跳更新)
+	IPs       []string `json:"ips,omitempty"` // Worker 上报的全部网卡地址
+	Port      int      `json:"port"`
+	Hostname  string   `json:"hostname"` // 机器原本的主机名
+	Name      string   `json:"name"`     // 用户自定义的节点名称 (别名)
+	MacAddr   string   `json:"mac_addr"`
+	OS        string   `json:"os"`
+	Arch      string   `json:"arch"`
+	CPUCores  int      `json:"cpu_cores"`
+	MemTotal  uint64   `json:"mem_total"`
+	DiskTotal uint64   `json:"disk_total"`
+
+	// 标签: Worker 配置上报的标签与接口设置的标签合并 (同名键以接口设置为准)
+	Labels map[string]string `json:"labels,omitempty"`
 
 	// 状态字段
-	Status        string `json:"status"`         // "online", "offline", "planned"
+	Status        string `json:"status"`         // "online", "offline", "planned", "maintenance" (已封锁)
 	LastHeartbeat int64  `json:"last_heartbeat"` // 上次心跳时间
 
+	// 维护状态: 封锁后不再接受新部署；排空时记录被停止的实例，解除封锁时恢复
+	Cordoned         bool     `json:"cordoned"`
+	CordonTime       int64    `json:"cordon_time,omitempty"`
+	DrainedInstances []string `json:"drained_instances,omitempty"`
+
+	// Worker 程序版本与自升级状态
+	AgentVersion  string `json:"agent_version,omitempty"`
+	AgentPlatform string `json:"agent_platform,omitempty"`
+	UpgradeTarget string `json:"upgrade_target,omitempty"` // 升级中的目标版本
+	Upgrade       string `json:"upgrade,omitempty"`        // upgrading / failed (超时未以目标版本上线)
+
+	// 是否经反向通道连接 (Worker 主动建立的长连接，Master 访问该节点时走通道)
+	Tunnel bool `json:"tunnel"`
+
 	// 实时监控 (存内存，不存DB，或者存DB为了简单)
 	// 为了统一架构，建议基础信息存DB，高频监控数据存内存(同Instance)
 	// 这里简化处理：UpdateHeartbeat 时顺便更新到 DB，因为节点只有几百个，频率不高
@@ -44,11 +63,53 @@ type NodeStatus struct {
 
 // RegisterRequest 注册/心跳请求
 type RegisterRequest struct {
-	Port   int        `json:"port"` // Worker 监听的端口
+	NodeID string     `json:"node_id"`          // 节点 UUID (旧版 Worker 不上报，按 IP 识别)
+	Secret string     `json:"secret,omitempty"` // Master 签发的节点密钥 (首次注册前为空)
+	Port   int        `json:"port"`             // Worker 监听的端口
 	Info   NodeInfo   `json:"info"`
 	Status NodeStatus `json:"status"`
+
+	Labels map[string]string `json:"labels,omitempty"` // Worker 配置中声明的标签
+
+	Version  string `json:"version,omitempty"`  // Worker 构建版本 (旧版 Worker 不上报)
+	Platform string `json:"platform,omitempty"` // GOOS/GOARCH，如 linux/amd64，用于匹配升级包
+}
+
+// NodeGroup 命名节点分组: 成员为显式指定的节点与匹配选择器的节点之并集
+type NodeGroup struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Selector    string   `json:"selector"` // 标签选择器，为空表示只包含显式节点
+	NodeIDs     []string `json:"node_ids"` // 显式成员
+	Members     []string `json:"members"`  // 当前实际成员的节点 ID (只读)
+	CreateTime  int64    `json:"create_time"`
 }
 
+// NodeTarget 批量操作的目标节点
+// 各条件之间为 AND 关系：显式节点、分组成员、标签选择器依次收窄范围，至少指定一项
+type NodeTarget struct {
+	NodeIDs  []string `json:"node_ids,omitempty"`
+	NodeIPs  []string `json:"node_ips,omitempty"`
+	Group    string   `json:"group,omitempty"`
+	Selector string   `json:"selector,omitempty"`
+}
+
+// Empty 是否未指定任何条件
+func (t NodeTarget) Empty() bool {
+	return len(t.NodeIDs) == 0 && len(t.NodeIPs) == 0 && t.Group == "" && t.Selector == ""
+}
+
+// HeartbeatResp 心跳响应
+type HeartbeatResp struct {
+	Secret string `json:"secret,omitempty"` // 首次注册时签发的节点密钥，Worker 须持久化并在之后的心跳中携带
+}
+
+// Worker 回调 Master (状态、任务、日志上报等) 时携带节点 ID 与密钥的请求头
+const (
+	NodeIDHeader     = "X-Node-Id"
+	NodeSecretHeader = "X-Node-Secret"
+)
+
 // ==========================================
 // 2. 指令与控制 (Command)
 // ==========================================
@@ -56,6 +117,85 @@ type RegisterRequest struct {
 // CommandRequest 执行 CMD 指令
 type CommandRequest struct {
 	Command string `json:"command"`
+	Timeout int    `json:"timeout,omitempty"`  // 执行超时 (秒)，超时后终止命令；0 表示不限制
+	WorkDir string `json:"work_dir,omitempty"` // 工作目录，为空时使用 Worker 当前目录
+}
+
+// BatchExecRequest 批量命令执行 (按节点列表/分组/标签选择器选择节点)
+type BatchExecRequest struct {
+	Command     string `json:"command"`
+	Concurrency int    `json:"concurrency"` // 同时执行的节点数 (默认 10)
+	Timeout     int    `json:"timeout"`     // 单个节点的超时 (秒，默认 60)
+	WorkDir     string `json:"work_dir"`
+	NodeTarget
+}
+
+// ExecEvent 流式命令执行的事件 (Worker -> Master 为 NDJSON，Master -> 前端为 WebSocket 消息)
+type ExecEvent struct {
+	Type     string `json:"type"` // output: 输出片段; exit: 单个节点结束; done: 全部结束
+	NodeID   string `json:"node_id,omitempty"`
+	NodeIP   string `json:"node_ip,omitempty"`
+	Stream   string `json:"stream,omitempty"` // stdout / stderr
+	Data     string `json:"data,omitempty"`
+	ExitCode *int   `json:"exit_code,omitempty"`
+	Status   string `json:"status,omitempty"` // exit 事件: 子任务状态
+	Error    string `json:"error,omitempty"`
+}
+
+// ExecApproval 命中审批规则的命令，须由申请人以外的用户审批后才下发
+type ExecApproval struct {
+	ID         string `json:"id"`
+	Kind       string `json:"kind"` // cmd: /api/ctrl/cmd; batch_exec: /api/exec/batch
+	Command    string `json:"command"`
+	Target     string `json:"target"`
+	Rule       string `json:"rule"`   // 命中的审批规则
+	Status     string `json:"status"` // pending / approved / rejected / expired
+	Requester  string `json:"requester"`
+	Approver   string `json:"approver,omitempty"`
+	Comment    string `json:"comment,omitempty"`
+	Result     string `json:"result,omitempty"` // 审批通过后的下发结果 (任务 ID 或错误)
+	CreateTime int64  `json:"create_time"`
+	DecideTime int64  `json:"decide_time,omitempty"`
+	Payload    string `json:"-"` // 原始请求 (JSON)，审批通过后按其下发
+}
+
+// ExecApprovalQueryResp 审批单分页
+type ExecApprovalQueryResp struct {
+	Total int64           `json:"total"`
+	List  []*ExecApproval `json:"list"`
+}
+
+// ExecPolicyResp Worker 同步的执行策略 (Worker 无法识别用户，只执行拒绝规则)
+type ExecPolicyResp struct {
+	Deny []string `json:"deny"`
+}
+
+// TerminalMessage Web 终端的控制消息 (WebSocket 文本帧)，终端输出为二进制帧
+type TerminalMessage struct {
+	Type string `json:"type"` // input / resize: 前端 -> Worker; exit: Worker -> 前端 (Data 为结束原因)
+	Data string `json:"data,omitempty"`
+	Cols uint16 `json:"cols,omitempty"`
+	Rows uint16 `json:"rows,omitempty"`
+}
+
+// TerminalSession Web 终端会话 (录像为 asciicast v2 格式)
+type TerminalSession struct {
+	ID         string `json:"id"`
+	Operator   string `json:"operator"`
+	ClientIP   string `json:"client_ip"`
+	NodeID     string `json:"node_id"`
+	NodeIP     string `json:"node_ip"`
+	InstanceID string `json:"instance_id,omitempty"`
+	StartTime  int64  `json:"start_time"`
+	EndTime    int64  `json:"end_time,omitempty"` // 0 表示进行中
+	Size       int64  `json:"size"`               // 录像字节数
+	Reason     string `json:"reason,omitempty"`   // 结束原因
+}
+
+// TerminalQueryResp 终端会话分页
+type TerminalQueryResp struct {
+	Total int64              `json:"total"`
+	List  []*TerminalSession `json:"list"`
 }
 
 // ==========================================
@@ -77,6 +217,13 @@ type ServiceManifest struct {
 	// Value: 日志绝对路径 或 相对工作目录的路径 (如 "/var/log/nginx/access.log", "logs/gc.log")
 	LogPaths map[string]string `json:"log_paths"`
 
+	// 日志格式: Key 为日志显示名称 (含 "Console Log")，Value 为 text/json/logfmt
+	// 配置后日志流与检索可以按级别、字段过滤
+	LogFormats map[string]string `json:"log_formats,omitempty"`
+
+	// 控制台日志 (app.log) 轮转策略，不配置时使用默认值
+	LogRotate *LogRotateConfig `json:"log_rotate,omitempty"`
+
 	// --- 新增：纳管专用字段 ---
 	IsExternal      bool   `json:"is_external"`       // 是否为纳管服务
 	ExternalWorkDir string `json:"external_work_dir"` // 外部服务的真实工作目录
@@ -88,6 +235,14 @@ type ServiceManifest struct {
 	OS          string `json:"os"`          // 适用系统
 }
 
+// LogRotateConfig 控制台日志轮转策略
+type LogRotateConfig struct {
+	MaxSizeMB  int  `json:"max_size_mb"` // 单个文件上限 (MB，默认 100)
+	MaxFiles   int  `json:"max_files"`   // 保留的历史文件数 (默认 10)
+	Daily      bool `json:"daily"`       // 跨天轮转
+	NoCompress bool `json:"no_compress"` // 历史文件不压缩 (默认 gzip)
+}
+
 // PackageInfo 用于前端展示的服务包列表信息
 type PackageInfo struct {
 	Name       string   `json:"name"`
@@ -111,7 +266,8 @@ type SystemInfo struct {
 type InstanceInfo struct {
 	ID             string `json:"id"`
 	SystemID       string `json:"system_id"`
-	NodeIP         string `json:"node_ip"`
+	NodeID         string `json:"node_id"`
+	NodeIP         string `json:"node_ip"` // 所在节点的当前地址 (节点地址变化时同步更新)
 	ServiceName    string `json:"service_name"`
 	ServiceVersion string `json:"service_version"`
 
@@ -125,6 +281,7 @@ type InstanceInfo struct {
 	MemUsage uint64  `json:"mem_usage"`
 	IoRead   uint64  `json:"io_read"`
 	IoWrite  uint64  `json:"io_write"`
+	ProcessMetrics
 }
 
 // InstanceStatusReport Worker 上报的状态 (增加监控字段)
@@ -134,22 +291,118 @@ type InstanceStatusReport struct {
 	PID        int    `json:"pid"`
 	Uptime     int64  `json:"uptime"`
 
-	// 新增监控数据
+	// 新增监控数据 (CPU/内存/IO 为整个进程树的汇总值)
 	CpuUsage float64 `json:"cpu_usage"`
 	MemUsage uint64  `json:"mem_usage"`
 	IoRead   uint64  `json:"io_read"`
 	IoWrite  uint64  `json:"io_write"`
+	ProcessMetrics
+}
+
+// ProcessMetrics 进程树扩展指标 (主进程 + 所有子进程)
+type ProcessMetrics struct {
+	ProcessCount   int          `json:"process_count"`    // 进程树中的进程数 (含主进程)
+	Threads        int          `json:"threads"`          // 线程总数
+	FDs            int          `json:"fds"`              // 已打开的文件描述符总数
+	FDLimit        uint64       `json:"fd_limit"`         // 主进程 RLIMIT_NOFILE 软限制 (0 表示未知)
+	CtxSwitchVol   uint64       `json:"ctx_switch_vol"`   // 自愿上下文切换次数 (累计)
+	CtxSwitchInvol uint64       `json:"ctx_switch_invol"` // 非自愿上下文切换次数 (累计)
+	ListenPorts    []ListenPort `json:"listen_ports"`     // 正在监听的端口
+	Restarts       int          `json:"restarts"`         // 实例被自动重启的次数 (人工启动与重新部署后清零)
+}
+
+// ListenPort 进程监听的端口
+type ListenPort struct {
+	Proto string `json:"proto"` // "tcp", "udp"
+	IP    string `json:"ip"`
+	Port  uint32 `json:"port"`
+	PID   int32  `json:"pid"`
 }
 
 // SystemModule 系统服务定义 (规划阶段)
 // 表示：某个系统 "包含" 某个服务包的特定版本
 type SystemModule struct {
-	ID             string `json:"id"`
-	SystemID       string `json:"system_id"`
-	ModuleName     string `json:"module_name"`
-	PackageName    string `json:"package_name"`
-	PackageVersion string `json:"package_version"`
-	Description    string `json:"description"`
+	ID             string          `json:"id"`
+	SystemID       string          `json:"system_id"`
+	ModuleName     string          `json:"module_name"`
+	PackageName    string          `json:"package_name"`
+	PackageVersion string          `json:"package_version"`
+	Description    string          `json:"description"`
+	ConfigBindings []ConfigBinding `json:"config_bindings"` // 配置绑定 (部署与发布配置时下发到实例目录)
+
+	// 副本与调度: Replicas > 0 时由调度器按 Placement 选择节点创建实例
+	Replicas  int             `json:"replicas"`
+	Placement PlacementPolicy `json:"placement"`
+}
+
+// PlacementPolicy 模块的调度约束
+type PlacementPolicy struct {
+	Selector     string   `json:"selector,omitempty"`      // 节点标签选择器
+	SpreadBy     string   `json:"spread_by,omitempty"`     // 按该标签的取值均匀打散 (如 rack、zone)
+	AntiAffinity []string `json:"anti_affinity,omitempty"` // 不与同系统内这些模块 (模块名) 的实例同节点
+	MinFreeMem   uint64   `json:"min_free_mem,omitempty"`  // 节点最小空闲内存 (MB)，取自心跳
+	MinFreeCPU   float64  `json:"min_free_cpu,omitempty"`  // 节点最小空闲 CPU (核)，取自心跳
+}
+
+// PlacementPlan 调度计划 (dry-run 时只返回计划，不创建实例)
+type PlacementPlan struct {
+	ModuleID    string          `json:"module_id"`
+	SystemID    string          `json:"system_id"`
+	ServiceName string          `json:"service_name"`
+	Version     string          `json:"version"`
+	Replicas    int             `json:"replicas"`           // 期望副本数
+	Current     int             `json:"current"`            // 现有副本数
+	Nodes       []PlacementNode `json:"nodes"`              // 新副本将落在的节点
+	Unplaced    int             `json:"unplaced"`           // 没有合适节点的副本数
+	Rejected    []PlacementNode `json:"rejected,omitempty"` // 被排除的节点及原因
+}
+
+// PlacementNode 调度计划中的节点
+type PlacementNode struct {
+	NodeID string `json:"node_id"`
+	NodeIP string `json:"node_ip"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// 配置变更后的实例重载策略
+const (
+	ReloadNone    = "none"    // 仅写文件
+	ReloadSignal  = "signal"  // 向进程发送信号 (仅 Unix)
+	ReloadRestart = "restart" // 重启实例
+)
+
+// ConfigBinding 配置绑定：配置中心的配置 -> 实例目录下的文件
+// 指定 DataID 时 Path 为文件路径；DataID 为空时绑定整个 Group，Path 为目录，每个配置按 Data ID 落为一个文件
+// 配置内容中的 ${ops.instance_id} ${ops.node_ip} ${ops.system_id} ${ops.service_name} ${ops.service_version} 会在下发前替换
+type ConfigBinding struct {
+	Namespace string `json:"namespace,omitempty"` // 命名空间 (tenant)，默认 public
+	Group     string `json:"group,omitempty"`     // 默认 DEFAULT_GROUP
+	DataID    string `json:"data_id,omitempty"`
+	Path      string `json:"path"`             // 相对实例目录 (纳管服务为其工作目录)
+	Reload    string `json:"reload,omitempty"` // none / signal / restart，默认 none
+	Signal    string `json:"signal,omitempty"` // reload=signal 时发送的信号，默认 HUP
+}
+
+// ConfigFile 下发到实例的一个配置文件
+type ConfigFile struct {
+	Path    string `json:"path"`
+	Content string `json:"content"`
+	Reload  string `json:"reload,omitempty"`
+	Signal  string `json:"signal,omitempty"`
+	Source  string `json:"source,omitempty"` // 来源 group/dataId，用于日志
+}
+
+// InstanceConfigRequest 下发配置文件 (Master -> Worker)
+type InstanceConfigRequest struct {
+	InstanceID string       `json:"instance_id"`
+	Files      []ConfigFile `json:"files"`
+}
+
+// InstanceConfigResp 配置下发结果 (Worker -> Master)
+type InstanceConfigResp struct {
+	Changed  []string `json:"changed"`         // 内容有变化并已写入的文件
+	Reloaded string   `json:"reloaded"`        // 实际执行的重载动作: none / signal / restart
+	Error    string   `json:"error,omitempty"` // 部分文件写入失败或重载失败时的错误 (Changed 为已写入的文件)
 }
 
 // SystemView 聚合视图 (用于前端展示)
@@ -169,6 +422,11 @@ type DeployRequest struct {
 	Entrypoint  string            `json:"entrypoint"`
 	Args        []string          `json:"args"`
 	Env         map[string]string `json:"env"`
+	ConfigFiles []ConfigFile      `json:"config_files,omitempty"` // 解压后写入的配置文件
+
+	// 所属任务 (Worker 据此上报部署进度，旧版 Master 不携带)
+	TaskID     string `json:"task_id,omitempty"`
+	TaskItemID int64  `json:"task_item_id,omitempty"`
 }
 
 // InstanceActionRequest 实例控制请求 (Master -> Worker)
@@ -233,31 +491,312 @@ type BackupFile struct {
 type LogFilesResp struct {
 	InstanceID string   `json:"instance_id"`
 	Files      []string `json:"files"` // e.g. ["Console Log", "Access Log"]
+
+	// 各日志的文件分段 (从旧到新，最后一个为当前文件)，供分页/下载时通过 file 参数指定
+	Segments map[string][]LogSegment `json:"segments,omitempty"`
+}
+
+// LogSegment 日志文件分段 (当前文件或轮转出的历史文件)
+type LogSegment struct {
+	File    string `json:"file"` // 文件名
+	Size    int64  `json:"size"`
+	ModTime int64  `json:"mod_time"`
+}
+
+// LogPage 按字节偏移向前分页读取的一页日志
+type LogPage struct {
+	File    string   `json:"file"`
+	Size    int64    `json:"size"`     // 文件当前大小
+	Start   int64    `json:"start"`    // 本页第一行的偏移量 (加载上一页时作为 before)
+	End     int64    `json:"end"`      // 本页结束偏移量 (不含)
+	HasMore bool     `json:"has_more"` // 前面是否还有内容
+	Lines   []string `json:"lines"`
+}
+
+// LogSearchReq 日志检索请求
+// Master 收到的请求按实例所在节点拆分后转发给各 Worker
+type LogSearchReq struct {
+	SystemID    string   `json:"system_id"`    // 仅 Master 使用：按系统圈定实例
+	ServiceName string   `json:"service_name"` // 仅 Master 使用：按服务圈定实例
+	InstanceIDs []string `json:"instance_ids"`
+	LogKey      string   `json:"log_key"` // 为空表示控制台日志
+	Query       string   `json:"query"`   // 正则表达式
+	IgnoreCase  bool     `json:"ignore_case"`
+	StartTime   int64    `json:"start_time"` // Unix 秒，0 表示不限制
+	EndTime     int64    `json:"end_time"`
+	MaxResults  int      `json:"max_results"`
+	Source      string   `json:"source"` // 仅 Master 使用："" 实时检索 Worker 磁盘，"central" 检索 Master 集中存储
+
+	// 结构化过滤 (按 service.json 中 log_formats 配置的格式解析)
+	MinLevel string `json:"min_level"` // 最低级别: debug/info/warn/error/fatal
+	Filter   string `json:"filter"`    // 字段选择器，如 "request_id=abc,level in (error,fatal)"
+}
+
+// LogSearchHit 一条命中的日志行
+type LogSearchHit struct {
+	InstanceID  string `json:"instance_id"`
+	ServiceName string `json:"service_name"`
+	NodeIP      string `json:"node_ip"`
+	File        string `json:"file"` // 所在文件名 (含轮转文件)
+	LineNo      int    `json:"line_no"`
+	Time        int64  `json:"time"` // 从行首解析出的时间，无法解析时为 0
+	Line        string `json:"line"`
+
+	Level  string            `json:"level,omitempty"`  // 解析出的级别 (结构化日志或文本中的级别关键字)
+	Fields map[string]string `json:"fields,omitempty"` // 结构化日志的字段
+}
+
+// LogSearchResp 日志检索结果
+type LogSearchResp struct {
+	Hits      []LogSearchHit `json:"hits"`
+	Truncated bool           `json:"truncated"` // 结果数达到上限被截断
+	Errors    []string       `json:"errors"`    // 部分节点/实例失败的原因
+}
+
+// LogShipReq Worker 推送到 Master 的一批日志
+// Seq 为本批数据在该日志流中的起始字节序号 (跨文件轮转累计)，Master 据此去重
+type LogShipReq struct {
+	NodeIP     string `json:"node_ip"`
+	InstanceID string `json:"instance_id"`
+	LogKey     string `json:"log_key"`
+	Seq        int64  `json:"seq"`
+	Data       []byte `json:"data"` // 若干完整行，以 \n 结尾 (原始字节，JSON 中为 base64，非 UTF-8 日志的序号两端一致)
+}
+
+// LogShipResp Master 已持久化的序号 (即下一批应当开始的位置)
+type LogShipResp struct {
+	Acked int64 `json:"acked"`
 }
 
 // AlertRule 告警规则配置
 type AlertRule struct {
 	ID         int64   `json:"id"`
 	Name       string  `json:"name"`        // 规则名称
-	TargetType string  `json:"target_type"` // "node", "instance"
-	Metric     string  `json:"metric"`      // "cpu", "mem", "status"(offline/stopped)
-	Condition  string  `json:"condition"`   // ">", "<", "="
+	TargetType string  `json:"target_type"` // "node", "instance", "log"
+	Metric     string  `json:"metric"`      // "cpu", "mem", "status"(offline/stopped)，Expr 为空时使用
+	Condition  string  `json:"condition"`   // ">", "<", "=", ">=", "<="
 	Threshold  float64 `json:"threshold"`   // 阈值
 	Duration   int     `json:"duration"`    // 持续时间(秒)，防抖动
 	Enabled    bool    `json:"enabled"`
+	ChannelIDs []int64 `json:"channel_ids"` // 通知渠道 (触发/恢复时推送)
+
+	RepeatInterval int    `json:"repeat_interval"` // 持续告警时重复通知的间隔(秒)，0 表示不重复；已确认的事件不再重复通知
+	Severity       string `json:"severity"`        // "info", "warning", "critical"
+
+	// 表达式规则 (优先于 Metric/Condition/Threshold)，例如 "avg_over_time(instance_cpu_usage[5m]) > 80"
+	Expr string `json:"expr"`
+	// 告警消息模板 (text/template)，可用字段: .Rule .Severity .Target .TargetID .Value .Expr .Labels
+	MessageTemplate string `json:"message_template"`
+
+	// 日志规则 (TargetType = "log")：Window 秒内 Pattern 匹配次数 >= Threshold 时触发
+	LogKey  string `json:"log_key"` // 日志名称 (同 GetLogPath)，为空表示控制台日志
+	Pattern string `json:"pattern"` // 正则表达式
+	Window  int    `json:"window"`  // 统计窗口(秒)
+
+	// 作用范围 (为空表示不限制，多个条件之间为 AND 关系)
+	// 对节点类规则，SystemID/ServiceName 表示"部署了该系统/服务的节点"
+	SystemID      string   `json:"system_id"`
+	ServiceName   string   `json:"service_name"`
+	NodeIPs       []string `json:"node_ips"`
+	NodeGroup     string   `json:"node_group"`     // 节点分组 (实例类规则按所在节点判断)
+	LabelSelector string   `json:"label_selector"` // 例如 "os=linux,env=prod"，节点可用内置标签与自定义标签
 }
 
+// 告警级别
+const (
+	SeverityInfo     = "info"
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+)
+
 // AlertEvent 告警历史/活跃事件
 type AlertEvent struct {
-	ID         int64   `json:"id"`
-	RuleID     int64   `json:"rule_id"`
-	RuleName   string  `json:"rule_name"`
-	TargetType string  `json:"target_type"`
-	TargetID   string  `json:"target_id"`   // NodeIP 或 InstanceID
-	TargetName string  `json:"target_name"` //用于展示
-	MetricVal  float64 `json:"metric_val"`  // 触发时的值
-	Message    string  `json:"message"`
-	Status     string  `json:"status"` // "firing", "resolved"
-	StartTime  int64   `json:"start_time"`
-	EndTime    int64   `json:"end_time"` // resolved 时更新
+	ID         int64    `json:"id"`
+	RuleID     int64    `json:"rule_id"`
+	RuleName   string   `json:"rule_name"`
+	TargetType string   `json:"target_type"`
+	TargetID   string   `json:"target_id"`   // NodeIP 或 InstanceID
+	TargetName string   `json:"target_name"` //用于展示
+	MetricVal  float64  `json:"metric_val"`  // 触发时的值
+	Message    string   `json:"message"`
+	Status     string   `json:"status"` // "firing", "resolved"
+	StartTime  int64    `json:"start_time"`
+	EndTime    int64    `json:"end_time"` // resolved 时更新
+	Severity   string   `json:"severity"` // 继承自规则
+	Samples    []string `json:"samples"`  // 日志规则触发时的样例行
+
+	Silenced   bool   `json:"silenced"`    // 触发时命中静默/维护窗口 (仅展示，不发通知)
+	Acked      bool   `json:"acked"`       // 是否已确认
+	AckBy      string `json:"ack_by"`      // 确认人
+	AckComment string `json:"ack_comment"` // 确认备注
+	AckTime    int64  `json:"ack_time"`
+}
+
+// LogWatchRule 下发给 Worker 的日志匹配任务 (规则 × 实例)
+type LogWatchRule struct {
+	RuleID     int64  `json:"rule_id"`
+	InstanceID string `json:"instance_id"`
+	LogKey     string `json:"log_key"`
+	Pattern    string `json:"pattern"`
+	Window     int    `json:"window"`
+}
+
+// LogMatchReport Worker 上报的日志匹配统计
+type LogMatchReport struct {
+	RuleID     int64    `json:"rule_id"`
+	InstanceID string   `json:"instance_id"`
+	Count      int      `json:"count"`   // 窗口内的匹配次数
+	Samples    []string `json:"samples"` // 最近的若干匹配行
+}
+
+// LogMatchReportRequest Worker 周期性上报的全部匹配统计
+type LogMatchReportRequest struct {
+	IP    string           `json:"ip"`
+	Items []LogMatchReport `json:"items"`
+}
+
+// AlertSilence 一次性静默 (在 [StartTime, EndTime] 内匹配的告警不发送通知)
+// 匹配条件为空表示不限制，多个条件之间为 AND 关系
+type AlertSilence struct {
+	ID         int64  `json:"id"`
+	RuleID     int64  `json:"rule_id"`   // 0 表示任意规则
+	TargetID   string `json:"target_id"` // NodeIP 或 InstanceID
+	SystemID   string `json:"system_id"` // 仅对实例类告警生效
+	StartTime  int64  `json:"start_time"`
+	EndTime    int64  `json:"end_time"`
+	Comment    string `json:"comment"`
+	Creator    string `json:"creator"`
+	CreateTime int64  `json:"create_time"`
+}
+
+// MaintenanceWindow 周期性维护窗口 (如每周日 02:00-04:00)
+type MaintenanceWindow struct {
+	ID        int64  `json:"id"`
+	Name      string `json:"name"`
+	RuleID    int64  `json:"rule_id"`
+	TargetID  string `json:"target_id"`
+	SystemID  string `json:"system_id"`
+	Weekdays  []int  `json:"weekdays"`   // 0=周日 ... 6=周六，为空表示每天
+	StartTime string `json:"start_time"` // "HH:MM" (Master 本地时间)
+	EndTime   string `json:"end_time"`   // "HH:MM"，小于 StartTime 表示跨越零点
+	Enabled   bool   `json:"enabled"`
+	Comment   string `json:"comment"`
+}
+
+// NotifyChannel 告警通知渠道
+type NotifyChannel struct {
+	ID         int64               `json:"id"`
+	Name       string              `json:"name"`
+	Type       string              `json:"type"` // "webhook", "email", "dingtalk", "wecom", "feishu", "slack"
+	Config     NotifyChannelConfig `json:"config"`
+	Enabled    bool                `json:"enabled"`
+	CreateTime int64               `json:"create_time"`
+}
+
+// NotifyChannelConfig 渠道配置 (不同类型使用其中不同的字段)
+type NotifyChannelConfig struct {
+	// Webhook / 机器人
+	URL          string            `json:"url,omitempty"`
+	Method       string            `json:"method,omitempty"`        // 仅 webhook，默认 POST
+	Headers      map[string]string `json:"headers,omitempty"`       // 仅 webhook
+	BodyTemplate string            `json:"body_template,omitempty"` // 仅 webhook，text/template 模板
+	Secret       string            `json:"secret,omitempty"`        // 钉钉/飞书加签密钥
+
+	// SMTP 邮件
+	SMTPHost string   `json:"smtp_host,omitempty"`
+	SMTPPort int      `json:"smtp_port,omitempty"`
+	Username string   `json:"username,omitempty"`
+	Password string   `json:"password,omitempty"`
+	From     string   `json:"from,omitempty"`
+	To       []string `json:"to,omitempty"`
+}
+
+// NotifyLog 通知投递记录
+type NotifyLog struct {
+	ID          int64  `json:"id"`
+	ChannelID   int64  `json:"channel_id"`
+	ChannelName string `json:"channel_name"`
+	EventID     int64  `json:"event_id"`
+	Kind        string `json:"kind"`   // "firing", "resolved", "test"
+	Status      string `json:"status"` // "success", "fail"
+	Attempts    int    `json:"attempts"`
+	Error       string `json:"error"`
+	CreateTime  int64  `json:"create_time"`
+}
+
+// AgentBinary Master 保存的 Worker 程序 (按版本与平台区分)
+type AgentBinary struct {
+	Version    string `json:"version"`
+	Platform   string `json:"platform"` // GOOS/GOARCH
+	SHA256     string `json:"sha256"`
+	Size       int64  `json:"size"`
+	UploadTime int64  `json:"upload_time"`
+}
+
+// AgentUpgradeRequest Master -> Worker 自升级指令
+type AgentUpgradeRequest struct {
+	Version         string `json:"version"`
+	DownloadURL     string `json:"download_url"`
+	SHA256          string `json:"sha256"`
+	RollbackTimeout int    `json:"rollback_timeout"` // 新程序在该时长 (秒) 内未成功心跳则回滚
+}
+
+// 任务状态
+const (
+	TaskQueued    = "queued"
+	TaskRunning   = "running"
+	TaskSucceeded = "succeeded"
+	TaskFailed    = "failed"
+	TaskTimeout   = "timeout"
+)
+
+// Task Master -> Worker 操作的异步任务 (部署、启停、批量操作、命令执行)
+type Task struct {
+	ID         string     `json:"id"`
+	Type       string     `json:"type"`   // deploy / start / stop / destroy / system_start / system_stop / exec_cmd
+	Target     string     `json:"target"` // 操作对象描述 (服务名、系统 ID、节点条件)
+	Operator   string     `json:"operator"`
+	Status     string     `json:"status"` // queued / running / succeeded / failed / timeout
+	Error      string     `json:"error,omitempty"`
+	Total      int        `json:"total"`  // 子任务数
+	Done       int        `json:"done"`   // 已结束的子任务数
+	Failed     int        `json:"failed"` // 失败或超时的子任务数
+	CreateTime int64      `json:"create_time"`
+	StartTime  int64      `json:"start_time,omitempty"`
+	EndTime    int64      `json:"end_time,omitempty"`
+	Deadline   int64      `json:"deadline"` // 超过该时间仍未结束的子任务标记为 timeout
+	Items      []TaskItem `json:"items,omitempty"`
+}
+
+// TaskItem 任务在单个节点/实例上的子任务
+type TaskItem struct {
+	ID         int64  `json:"id"`
+	TaskID     string `json:"task_id"`
+	NodeID     string `json:"node_id,omitempty"`
+	NodeIP     string `json:"node_ip,omitempty"`
+	InstanceID string `json:"instance_id,omitempty"`
+	Status     string `json:"status"`
+	Progress   int    `json:"progress"`            // 0-100
+	Message    string `json:"message,omitempty"`   // 当前阶段 (如 downloading / extracting)
+	Output     string `json:"output,omitempty"`    // 命令输出
+	ExitCode   *int   `json:"exit_code,omitempty"` // 命令退出码 (命令未执行完成时为空)
+	Error      string `json:"error,omitempty"`
+	StartTime  int64  `json:"start_time,omitempty"`
+	EndTime    int64  `json:"end_time,omitempty"`
+}
+
+// TaskQueryResp 任务分页查询响应
+type TaskQueryResp struct {
+	Total int64   `json:"total"`
+	List  []*Task `json:"list"`
+}
+
+// TaskReport Worker -> Master 子任务进度上报
+type TaskReport struct {
+	TaskID   string `json:"task_id"`
+	ItemID   int64  `json:"item_id"`
+	Status   string `json:"status"` // running / succeeded / failed
+	Progress int    `json:"progress"`
+	Message  string `json:"message,omitempty"`
+	Error    string `json:"error,omitempty"`
 }
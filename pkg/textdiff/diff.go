@@ -0,0 +1,195 @@
+package textdiff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// maxCells LCS 表的最大规模，超过时整体视为替换 (避免超大文件占用过多内存)
+const maxCells = 4 << 20
+
+// Op 行级差异操作
+type Op byte
+
+const (
+	Equal  Op = ' '
+	Delete Op = '-'
+	Insert Op = '+'
+)
+
+// Line 差异结果中的一行
+type Line struct {
+	Op   Op
+	Text string
+}
+
+// Lines 计算 a -> b 的逐行差异 (基于最长公共子序列)
+func Lines(a, b string) []Line {
+	x, y := splitLines(a), splitLines(b)
+
+	// 去掉公共前后缀，缩小 LCS 规模
+	pre := 0
+	for pre < len(x) && pre < len(y) && x[pre] == y[pre] {
+		pre++
+	}
+	suf := 0
+	for suf < len(x)-pre && suf < len(y)-pre && x[len(x)-1-suf] == y[len(y)-1-suf] {
+		suf++
+	}
+
+	var res []Line
+	for _, l := range x[:pre] {
+		res = append(res, Line{Equal, l})
+	}
+	res = append(res, lcsDiff(x[pre:len(x)-suf], y[pre:len(y)-suf])...)
+	for _, l := range x[len(x)-suf:] {
+		res = append(res, Line{Equal, l})
+	}
+	return res
+}
+
+func lcsDiff(x, y []string) []Line {
+	n, m := len(x), len(y)
+	var res []Line
+	if n*m > maxCells {
+		for _, l := range x {
+			res = append(res, Line{Delete, l})
+		}
+		for _, l := range y {
+			res = append(res, Line{Insert, l})
+		}
+		return res
+	}
+
+	// dp[i][j] = x[i:] 与 y[j:] 的 LCS 长度
+	dp := make([][]int, n+1)
+	for i := range dp {
+		dp[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				dp[i][j] = dp[i+1][j+1] + 1
+			} else if dp[i+1][j] >= dp[i][j+1] {
+				dp[i][j] = dp[i+1][j]
+			} else {
+				dp[i][j] = dp[i][j+1]
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < n && j < m {
+		switch {
+		case x[i] == y[j]:
+			res = append(res, Line{Equal, x[i]})
+			i++
+			j++
+		case dp[i+1][j] >= dp[i][j+1]:
+			res = append(res, Line{Delete, x[i]})
+			i++
+		default:
+			res = append(res, Line{Insert, y[j]})
+			j++
+		}
+	}
+	for ; i < n; i++ {
+		res = append(res, Line{Delete, x[i]})
+	}
+	for ; j < m; j++ {
+		res = append(res, Line{Insert, y[j]})
+	}
+	return res
+}
+
+// Unified 生成 unified diff 文本 (context 为上下文行数)，内容相同时返回空字符串
+func Unified(fromName, toName, a, b string, context int) string {
+	lines := Lines(a, b)
+
+	changed := false
+	for _, l := range lines {
+		if l.Op != Equal {
+			changed = true
+			break
+		}
+	}
+	if !changed {
+		return ""
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", fromName, toName)
+
+	// 按变更位置切分 hunk：相邻变更之间的相同行不超过 2*context 时合并
+	for start := 0; start < len(lines); {
+		// 找到下一处变更
+		first := start
+		for first < len(lines) && lines[first].Op == Equal {
+			first++
+		}
+		if first == len(lines) {
+			break
+		}
+		lo := max(first-context, start)
+		hi := first
+		for hi < len(lines) {
+			if lines[hi].Op != Equal {
+				hi++
+				continue
+			}
+			run := hi
+			for run < len(lines) && lines[run].Op == Equal {
+				run++
+			}
+			if run == len(lines) || run-hi > 2*context {
+				hi = min(hi+context, len(lines))
+				break
+			}
+			hi = run
+		}
+		writeHunk(&sb, lines, lo, hi)
+		start = hi
+	}
+	return sb.String()
+}
+
+func writeHunk(sb *strings.Builder, lines []Line, lo, hi int) {
+	// 计算 hunk 在新旧文本中的起始行号与行数
+	aStart, bStart := 1, 1
+	for _, l := range lines[:lo] {
+		if l.Op != Insert {
+			aStart++
+		}
+		if l.Op != Delete {
+			bStart++
+		}
+	}
+	aLen, bLen := 0, 0
+	for _, l := range lines[lo:hi] {
+		if l.Op != Insert {
+			aLen++
+		}
+		if l.Op != Delete {
+			bLen++
+		}
+	}
+	if aLen == 0 {
+		aStart--
+	}
+	if bLen == 0 {
+		bStart--
+	}
+	fmt.Fprintf(sb, "@@ -%d,%d +%d,%d @@\n", aStart, aLen, bStart, bLen)
+	for _, l := range lines[lo:hi] {
+		sb.WriteByte(byte(l.Op))
+		sb.WriteString(l.Text)
+		sb.WriteByte('\n')
+	}
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
@@ -0,0 +1,36 @@
+package textdiff_test
+
+import (
+	"testing"
+
+	"ops-system/pkg/textdiff"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnified(t *testing.T) {
+	a := "server:\n  port: 8080\n  host: 0.0.0.0\nlog:\n  level: info\n"
+	b := "server:\n  port: 9090\n  host: 0.0.0.0\nlog:\n  level: info\n  file: app.log\n"
+
+	want := "--- v1\n+++ v2\n" +
+		"@@ -1,5 +1,6 @@\n" +
+		" server:\n" +
+		"-  port: 8080\n" +
+		"+  port: 9090\n" +
+		"   host: 0.0.0.0\n" +
+		" log:\n" +
+		"   level: info\n" +
+		"+  file: app.log\n"
+	assert.Equal(t, want, textdiff.Unified("v1", "v2", a, b, 3))
+
+	// 相隔较远的变更拆成两个 hunk
+	a = "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n"
+	b = "one\n2\n3\n4\n5\n6\n7\n8\n9\nten\n"
+	want = "--- a\n+++ b\n" +
+		"@@ -1,2 +1,2 @@\n-1\n+one\n 2\n" +
+		"@@ -9,2 +9,2 @@\n 9\n-10\n+ten\n"
+	assert.Equal(t, want, textdiff.Unified("a", "b", a, b, 1))
+
+	assert.Equal(t, "", textdiff.Unified("a", "b", a, a, 3))
+	assert.Equal(t, "--- a\n+++ b\n@@ -0,0 +1,1 @@\n+x\n", textdiff.Unified("a", "b", "", "x", 3))
+}
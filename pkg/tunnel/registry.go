@@ -0,0 +1,88 @@
+package tunnel
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+)
+
+// HostSuffix 通道地址的主机名后缀: <节点ID>.tunnel
+const HostSuffix = ".tunnel"
+
+// DialFunc 与 http.Transport.DialContext 签名一致
+type DialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// Host 节点的通道主机名
+func Host(nodeID string) string {
+	return nodeID + HostSuffix
+}
+
+// Registry Master 侧的节点会话表
+type Registry struct {
+	mu       sync.RWMutex
+	sessions map[string]*Session
+}
+
+func NewRegistry() *Registry {
+	return &Registry{sessions: make(map[string]*Session)}
+}
+
+// Register 登记节点会话，同一节点的旧会话会被关闭
+func (r *Registry) Register(nodeID string, s *Session) {
+	r.mu.Lock()
+	old := r.sessions[nodeID]
+	r.sessions[nodeID] = s
+	r.mu.Unlock()
+	if old != nil && old != s {
+		old.Close()
+	}
+}
+
+// Unregister 移除节点会话 (仅当仍是同一会话时，避免误删重连后的新会话)
+func (r *Registry) Unregister(nodeID string, s *Session) {
+	r.mu.Lock()
+	if r.sessions[nodeID] == s {
+		delete(r.sessions, nodeID)
+	}
+	r.mu.Unlock()
+}
+
+// Connected 节点当前是否通过通道连接
+func (r *Registry) Connected(nodeID string) bool {
+	return r.get(nodeID) != nil
+}
+
+func (r *Registry) get(nodeID string) *Session {
+	r.mu.RLock()
+	s := r.sessions[nodeID]
+	r.mu.RUnlock()
+	if s == nil || s.isClosed() {
+		return nil
+	}
+	return s
+}
+
+// Open 在节点会话上打开一个流
+func (r *Registry) Open(nodeID string) (net.Conn, error) {
+	s := r.get(nodeID)
+	if s == nil {
+		return nil, fmt.Errorf("node %s is not connected via tunnel", nodeID)
+	}
+	return s.Open()
+}
+
+// DialContext 包装拨号函数: 目标主机为 <节点ID>.tunnel 时经通道连接，其余交给 fallback
+func (r *Registry) DialContext(fallback DialFunc) DialFunc {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			host = addr
+		}
+		if nodeID, ok := strings.CutSuffix(host, HostSuffix); ok {
+			return r.Open(nodeID)
+		}
+		return fallback(ctx, network, addr)
+	}
+}
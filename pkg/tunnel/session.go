@@ -0,0 +1,280 @@
+// Package tunnel 在一条 WebSocket 连接上复用多个双向字节流
+//
+// Worker 位于 NAT / 防火墙之后时，由 Worker 主动连接 Master 建立会话，
+// 双方都可以在会话上打开流 (Open) 或接受对端打开的流 (Accept)。
+// 流实现 net.Conn，会话实现 net.Listener，因此现有的 HTTP / WebSocket 通信可以原样运行在通道之上。
+package tunnel
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// 帧格式 (一个 WebSocket 二进制消息): [类型 1B][流 ID 4B][负载]
+const (
+	frameOpen   byte = 1 // 打开流
+	frameData   byte = 2 // 数据
+	frameWindow byte = 3 // 窗口更新，负载为 4 字节的增量
+	frameClose  byte = 4 // 对端关闭
+	frameReset  byte = 5 // 拒绝或中止 (如 Accept 队列已满)
+
+	headerSize    = 5
+	maxFrameData  = 32 * 1024  // 单帧最大数据量
+	initialWindow = 256 * 1024 // 每个流的接收窗口
+	acceptBacklog = 64
+
+	pingInterval = 20 * time.Second
+	pongWait     = 60 * time.Second
+	writeTimeout = 10 * time.Second
+)
+
+var (
+	ErrSessionClosed = errors.New("tunnel: session closed")
+	ErrStreamReset   = errors.New("tunnel: stream reset by peer")
+)
+
+// Session 一条通道连接上的多路复用会话
+type Session struct {
+	conn   *websocket.Conn
+	client bool
+
+	writeMu sync.Mutex
+
+	mu      sync.Mutex
+	streams map[uint32]*Stream
+	nextID  uint32
+
+	acceptCh  chan *Stream
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// Client 以发起方身份创建会话 (Worker 侧，流 ID 为奇数)
+func Client(conn *websocket.Conn) *Session {
+	return newSession(conn, true)
+}
+
+// Server 以接受方身份创建会话 (Master 侧，流 ID 为偶数)
+func Server(conn *websocket.Conn) *Session {
+	return newSession(conn, false)
+}
+
+func newSession(conn *websocket.Conn, client bool) *Session {
+	s := &Session{
+		conn:     conn,
+		client:   client,
+		streams:  make(map[uint32]*Stream),
+		acceptCh: make(chan *Stream, acceptBacklog),
+		done:     make(chan struct{}),
+	}
+	if client {
+		s.nextID = 1
+	} else {
+		s.nextID = 2
+	}
+
+	conn.SetReadDeadline(time.Now().Add(pongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+	go s.recvLoop()
+	go s.keepalive()
+	return s
+}
+
+// Open 打开一个新的流
+func (s *Session) Open() (net.Conn, error) {
+	s.mu.Lock()
+	if s.isClosed() {
+		s.mu.Unlock()
+		return nil, ErrSessionClosed
+	}
+	id := s.nextID
+	s.nextID += 2
+	st := newStream(s, id)
+	s.streams[id] = st
+	s.mu.Unlock()
+
+	if err := s.writeFrame(frameOpen, id, nil); err != nil {
+		s.removeStream(id)
+		return nil, err
+	}
+	return st, nil
+}
+
+// Accept 等待对端打开的流 (实现 net.Listener)
+func (s *Session) Accept() (net.Conn, error) {
+	select {
+	case st := <-s.acceptCh:
+		return st, nil
+	case <-s.done:
+		return nil, ErrSessionClosed
+	}
+}
+
+// Addr 本端地址 (实现 net.Listener)
+func (s *Session) Addr() net.Addr {
+	return s.conn.LocalAddr()
+}
+
+// RemoteAddr 对端地址
+func (s *Session) RemoteAddr() net.Addr {
+	return s.conn.RemoteAddr()
+}
+
+// Done 会话关闭时关闭的通道
+func (s *Session) Done() <-chan struct{} {
+	return s.done
+}
+
+// Close 关闭会话及其上的所有流
+func (s *Session) Close() error {
+	s.shutdown()
+	return nil
+}
+
+func (s *Session) isClosed() bool {
+	select {
+	case <-s.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *Session) shutdown() {
+	s.closeOnce.Do(func() {
+		s.mu.Lock()
+		streams := s.streams
+		s.streams = make(map[uint32]*Stream)
+		close(s.done)
+		s.mu.Unlock()
+
+		for _, st := range streams {
+			st.abort(ErrSessionClosed)
+		}
+		s.writeMu.Lock()
+		s.conn.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+		s.writeMu.Unlock()
+		s.conn.Close()
+	})
+}
+
+func (s *Session) writeFrame(typ byte, id uint32, payload []byte) error {
+	buf := make([]byte, headerSize+len(payload))
+	buf[0] = typ
+	binary.BigEndian.PutUint32(buf[1:], id)
+	copy(buf[headerSize:], payload)
+
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	if s.isClosed() {
+		return ErrSessionClosed
+	}
+	s.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	if err := s.conn.WriteMessage(websocket.BinaryMessage, buf); err != nil {
+		go s.shutdown()
+		return err
+	}
+	return nil
+}
+
+func (s *Session) keepalive() {
+	ticker := time.NewTicker(pingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.writeMu.Lock()
+			err := s.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeTimeout))
+			s.writeMu.Unlock()
+			if err != nil {
+				s.shutdown()
+				return
+			}
+		case <-s.done:
+			return
+		}
+	}
+}
+
+func (s *Session) recvLoop() {
+	defer s.shutdown()
+	for {
+		mt, data, err := s.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		s.conn.SetReadDeadline(time.Now().Add(pongWait))
+		if mt != websocket.BinaryMessage || len(data) < headerSize {
+			continue
+		}
+		typ, id, payload := data[0], binary.BigEndian.Uint32(data[1:headerSize]), data[headerSize:]
+
+		switch typ {
+		case frameOpen:
+			s.handleOpen(id)
+		case frameData:
+			if st := s.getStream(id); st != nil && !st.push(payload) {
+				// 对端未遵守窗口限制
+				s.removeStream(id)
+				st.abort(ErrStreamReset)
+				s.writeFrame(frameReset, id, nil)
+			}
+		case frameWindow:
+			if st := s.getStream(id); st != nil && len(payload) == 4 {
+				st.addCredit(int(binary.BigEndian.Uint32(payload)))
+			}
+		case frameClose:
+			if st := s.getStream(id); st != nil {
+				st.remoteClose()
+			}
+		case frameReset:
+			if st := s.getStream(id); st != nil {
+				s.removeStream(id)
+				st.abort(ErrStreamReset)
+			}
+		}
+	}
+}
+
+func (s *Session) handleOpen(id uint32) {
+	// 对端只能使用自己一侧的 ID (客户端奇数，服务端偶数)
+	if (id%2 == 1) == s.client {
+		s.writeFrame(frameReset, id, nil)
+		return
+	}
+	s.mu.Lock()
+	if _, exists := s.streams[id]; exists || s.isClosed() {
+		s.mu.Unlock()
+		return
+	}
+	st := newStream(s, id)
+	s.streams[id] = st
+	s.mu.Unlock()
+
+	select {
+	case s.acceptCh <- st:
+	default:
+		s.removeStream(id)
+		s.writeFrame(frameReset, id, nil)
+	}
+}
+
+func (s *Session) getStream(id uint32) *Stream {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.streams[id]
+}
+
+func (s *Session) removeStream(id uint32) {
+	s.mu.Lock()
+	delete(s.streams, id)
+	s.mu.Unlock()
+}
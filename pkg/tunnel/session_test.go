@@ -0,0 +1,100 @@
+package tunnel_test
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"ops-system/pkg/tunnel"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+// pair 建立一对会话: server 为 Master 侧，client 为 Worker 侧
+func pair(t *testing.T) (server, client *tunnel.Session) {
+	ch := make(chan *tunnel.Session, 1)
+	upgrader := websocket.Upgrader{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		ch <- tunnel.Server(conn)
+	}))
+	t.Cleanup(ts.Close)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client = tunnel.Client(conn)
+	server = <-ch
+	t.Cleanup(func() { client.Close(); server.Close() })
+	return server, client
+}
+
+func TestTunnelHTTP(t *testing.T) {
+	server, client := pair(t)
+
+	// Worker 在会话上提供 HTTP 服务，Master 通过 <节点ID>.tunnel 访问
+	big := bytes.Repeat([]byte("0123456789abcdef"), 64*1024) // 1MB，超过接收窗口
+	mux := http.NewServeMux()
+	mux.HandleFunc("/echo", func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(w, r.Body)
+	})
+	go http.Serve(client, mux)
+
+	reg := tunnel.NewRegistry()
+	reg.Register("node-1", server)
+	assert.True(t, reg.Connected("node-1"))
+	assert.False(t, reg.Connected("node-2"))
+
+	hc := &http.Client{Timeout: 10 * time.Second, Transport: &http.Transport{DialContext: reg.DialContext(nil)}}
+	for i := 0; i < 3; i++ {
+		resp, err := hc.Post("http://"+tunnel.Host("node-1")+"/echo", "application/octet-stream", bytes.NewReader(big))
+		if !assert.NoError(t, err) {
+			return
+		}
+		got, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		assert.NoError(t, err)
+		assert.True(t, bytes.Equal(big, got), "echo body mismatch")
+	}
+
+	_, err := hc.Get("http://" + tunnel.Host("node-2") + "/echo")
+	assert.Error(t, err)
+
+	// 会话断开后自动视为未连接，重连的新会话不会被旧会话的注销覆盖
+	server.Close()
+	<-client.Done()
+	assert.False(t, reg.Connected("node-1"))
+	server2, _ := pair(t)
+	reg.Register("node-1", server2)
+	reg.Unregister("node-1", server)
+	assert.True(t, reg.Connected("node-1"))
+}
+
+func TestStreamDeadline(t *testing.T) {
+	server, client := pair(t)
+
+	go func() {
+		if c, err := client.Accept(); err == nil {
+			defer c.Close()
+			io.Copy(io.Discard, c)
+		}
+	}()
+	c, err := server.Open()
+	assert.NoError(t, err)
+	defer c.Close()
+
+	// 对端不回写，读取应按截止时间返回
+	c.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+	_, err = c.Read(make([]byte, 1))
+	assert.ErrorIs(t, err, os.ErrDeadlineExceeded)
+}
@@ -0,0 +1,236 @@
+package tunnel
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// Stream 会话上的一个双向字节流 (实现 net.Conn)
+// 流量控制: 发送方最多发送对端窗口允许的数据，接收方读走一半窗口后归还额度
+type Stream struct {
+	id   uint32
+	sess *Session
+
+	mu            sync.Mutex
+	readBuf       bytes.Buffer
+	consumed      int // 已读走但尚未归还的额度
+	sendWindow    int
+	remoteClosed  bool
+	localClosed   bool
+	err           error // 流被中止的原因
+	readDeadline  time.Time
+	writeDeadline time.Time
+
+	readNotify  chan struct{}
+	writeNotify chan struct{}
+}
+
+func newStream(s *Session, id uint32) *Stream {
+	return &Stream{
+		id:          id,
+		sess:        s,
+		sendWindow:  initialWindow,
+		readNotify:  make(chan struct{}, 1),
+		writeNotify: make(chan struct{}, 1),
+	}
+}
+
+func notify(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
+
+// wait 等待通知或截止时间
+func (st *Stream) wait(ch chan struct{}, deadline time.Time) error {
+	var timeout <-chan time.Time
+	if !deadline.IsZero() {
+		d := time.Until(deadline)
+		if d <= 0 {
+			return os.ErrDeadlineExceeded
+		}
+		t := time.NewTimer(d)
+		defer t.Stop()
+		timeout = t.C
+	}
+	select {
+	case <-ch:
+	case <-timeout:
+		return os.ErrDeadlineExceeded
+	case <-st.sess.done:
+	}
+	return nil
+}
+
+func (st *Stream) Read(p []byte) (int, error) {
+	for {
+		st.mu.Lock()
+		if st.readBuf.Len() > 0 {
+			n, _ := st.readBuf.Read(p)
+			st.consumed += n
+			var credit int
+			if st.consumed >= initialWindow/2 && !st.remoteClosed {
+				credit, st.consumed = st.consumed, 0
+			}
+			st.mu.Unlock()
+			if credit > 0 {
+				var buf [4]byte
+				binary.BigEndian.PutUint32(buf[:], uint32(credit))
+				st.sess.writeFrame(frameWindow, st.id, buf[:])
+			}
+			return n, nil
+		}
+		switch {
+		case st.localClosed:
+			st.mu.Unlock()
+			return 0, net.ErrClosed
+		case st.err != nil:
+			err := st.err
+			st.mu.Unlock()
+			return 0, err
+		case st.remoteClosed:
+			st.mu.Unlock()
+			return 0, io.EOF
+		}
+		deadline := st.readDeadline
+		st.mu.Unlock()
+
+		if err := st.wait(st.readNotify, deadline); err != nil {
+			return 0, err
+		}
+	}
+}
+
+func (st *Stream) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		st.mu.Lock()
+		switch {
+		case st.localClosed:
+			st.mu.Unlock()
+			return written, net.ErrClosed
+		case st.err != nil:
+			err := st.err
+			st.mu.Unlock()
+			return written, err
+		case st.remoteClosed:
+			st.mu.Unlock()
+			return written, io.ErrClosedPipe
+		}
+		if st.sendWindow == 0 {
+			deadline := st.writeDeadline
+			st.mu.Unlock()
+			if err := st.wait(st.writeNotify, deadline); err != nil {
+				return written, err
+			}
+			continue
+		}
+		n := len(p)
+		if n > st.sendWindow {
+			n = st.sendWindow
+		}
+		if n > maxFrameData {
+			n = maxFrameData
+		}
+		st.sendWindow -= n
+		st.mu.Unlock()
+
+		if err := st.sess.writeFrame(frameData, st.id, p[:n]); err != nil {
+			return written, err
+		}
+		written += n
+		p = p[n:]
+	}
+	return written, nil
+}
+
+// Close 关闭流并通知对端 (未读完的数据被丢弃)
+func (st *Stream) Close() error {
+	st.mu.Lock()
+	if st.localClosed {
+		st.mu.Unlock()
+		return nil
+	}
+	st.localClosed = true
+	aborted := st.err != nil
+	st.mu.Unlock()
+
+	notify(st.readNotify)
+	notify(st.writeNotify)
+	st.sess.removeStream(st.id)
+	if !aborted {
+		st.sess.writeFrame(frameClose, st.id, nil)
+	}
+	return nil
+}
+
+// push 收到数据，超出窗口时返回 false
+func (st *Stream) push(data []byte) bool {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.localClosed {
+		return true
+	}
+	if st.readBuf.Len()+len(data) > initialWindow {
+		return false
+	}
+	st.readBuf.Write(data)
+	notify(st.readNotify)
+	return true
+}
+
+func (st *Stream) addCredit(n int) {
+	st.mu.Lock()
+	st.sendWindow += n
+	st.mu.Unlock()
+	notify(st.writeNotify)
+}
+
+func (st *Stream) remoteClose() {
+	st.mu.Lock()
+	st.remoteClosed = true
+	st.mu.Unlock()
+	notify(st.readNotify)
+	notify(st.writeNotify)
+}
+
+func (st *Stream) abort(err error) {
+	st.mu.Lock()
+	if st.err == nil {
+		st.err = err
+	}
+	st.mu.Unlock()
+	notify(st.readNotify)
+	notify(st.writeNotify)
+}
+
+func (st *Stream) LocalAddr() net.Addr  { return st.sess.conn.LocalAddr() }
+func (st *Stream) RemoteAddr() net.Addr { return st.sess.conn.RemoteAddr() }
+
+func (st *Stream) SetDeadline(t time.Time) error {
+	st.SetReadDeadline(t)
+	return st.SetWriteDeadline(t)
+}
+
+// SetReadDeadline 修改截止时间会唤醒阻塞的 Read (http.Server 依赖此行为中断后台读取)
+func (st *Stream) SetReadDeadline(t time.Time) error {
+	st.mu.Lock()
+	st.readDeadline = t
+	st.mu.Unlock()
+	notify(st.readNotify)
+	return nil
+}
+
+func (st *Stream) SetWriteDeadline(t time.Time) error {
+	st.mu.Lock()
+	st.writeDeadline = t
+	st.mu.Unlock()
+	notify(st.writeNotify)
+	return nil
+}
@@ -2,6 +2,7 @@ package utils
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net"
@@ -13,27 +14,41 @@ func InitHTTPClient(timeout time.Duration) {
 	GlobalClient.Timeout = timeout
 }
 
+// Transport 全局共享连接池
+// Master 会在其拨号函数外包装反向通道 (<节点ID>.tunnel)，因此访问 Worker 的 Client 都应复用它
+var Transport = &http.Transport{
+	MaxIdleConns:        100,              // 总空闲连接数
+	MaxIdleConnsPerHost: 20,               // 对每个 Host (Worker IP) 保持的空闲连接数
+	IdleConnTimeout:     90 * time.Second, // 空闲连接保持时间
+	DisableKeepAlives:   false,            // 开启 Keep-Alive
+
+	// 拨号优化
+	DialContext: (&net.Dialer{
+		Timeout:   5 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}).DialContext,
+}
+
 // GlobalClient 全局单例 HTTP Client，配置长连接池
 var GlobalClient = &http.Client{
-	Timeout: 10 * time.Second, // 设置一个合理的超时
-	Transport: &http.Transport{
-		MaxIdleConns:        100,              // 总空闲连接数
-		MaxIdleConnsPerHost: 20,               // 对每个 Host (Worker IP) 保持的空闲连接数
-		IdleConnTimeout:     90 * time.Second, // 空闲连接保持时间
-		DisableKeepAlives:   false,            // 开启 Keep-Alive
+	Timeout:   10 * time.Second, // 设置一个合理的超时
+	Transport: Transport,
+}
 
-		// 拨号优化
-		DialContext: (&net.Dialer{
-			Timeout:   5 * time.Second,
-			KeepAlive: 30 * time.Second,
-		}).DialContext,
-	},
+// NewClient 复用 GlobalClient 的传输层 (连接池及 Worker 附加的节点凭据)、单独设置超时的 Client (0 表示不超时)
+func NewClient(timeout time.Duration) *http.Client {
+	return &http.Client{Timeout: timeout, Transport: GlobalClient.Transport}
 }
 
 // PostJSON 发送 JSON 请求并自动处理连接复用
 // 如果状态码不是 200，会返回错误
 func PostJSON(url string, data []byte) error {
-	resp, err := GlobalClient.Post(url, "application/json", bytes.NewBuffer(data))
+	return PostJSONHeader(url, data, nil)
+}
+
+// PostJSONHeader 同 PostJSON，附加请求头 (如 Master 签发的节点令牌)
+func PostJSONHeader(url string, data []byte, header http.Header) error {
+	resp, err := postJSON(url, data, header)
 	if err != nil {
 		return err
 	}
@@ -49,3 +64,51 @@ func PostJSON(url string, data []byte) error {
 
 	return nil
 }
+
+// GetJSON 发送 GET 请求并将响应体解析到 out
+// 如果状态码不是 200，会返回错误
+func GetJSON(url string, out interface{}) error {
+	resp, err := GlobalClient.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("http status %d: %s", resp.StatusCode, string(body))
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// PostJSONResult 发送 POST 请求并将响应体解析到 out
+func PostJSONResult(url string, data []byte, out interface{}) error {
+	return PostJSONResultHeader(url, data, nil, out)
+}
+
+// PostJSONResultHeader 同 PostJSONResult，附加请求头
+func PostJSONResultHeader(url string, data []byte, header http.Header, out interface{}) error {
+	resp, err := postJSON(url, data, header)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("http status %d: %s", resp.StatusCode, string(body))
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func postJSON(url string, data []byte, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return GlobalClient.Do(req)
+}